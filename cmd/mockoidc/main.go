@@ -0,0 +1,55 @@
+// Package main mock OpenID Connect issuer for local development
+//
+// every authorization is approved as the user set by $MOCK_OIDC_SUBJECT,
+// $MOCK_OIDC_EMAIL and $MOCK_OIDC_USERNAME, run the server with
+// $OIDC_ISSUER=http://localhost:$MOCK_OIDC_PORT
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/trevatk/go-pkg/logging"
+
+	"github.com/trevatk/go-chat/internal/oidc/oidctest"
+)
+
+func main() {
+
+	l := logging.NewLoggerFromEnv()
+
+	p := os.Getenv("MOCK_OIDC_PORT")
+	if p == "" {
+		p = "9000"
+	}
+
+	iss, e := oidctest.NewIssuer()
+	if e != nil {
+		l.Fatalf("unable to create mock issuer %v", e)
+	}
+
+	u := oidctest.User{
+		Subject:           os.Getenv("MOCK_OIDC_SUBJECT"),
+		Email:             os.Getenv("MOCK_OIDC_EMAIL"),
+		EmailVerified:     true,
+		PreferredUsername: os.Getenv("MOCK_OIDC_USERNAME"),
+	}
+
+	if u.Subject != "" {
+		iss.SetUser(u)
+	}
+
+	s := &http.Server{
+		Addr:              ":" + p,
+		Handler:           iss,
+		ReadHeaderTimeout: time.Second * 15,
+	}
+
+	l.Infof("start mock oidc issuer http://localhost:%s", p)
+
+	if e := s.ListenAndServe(); e != nil && !errors.Is(e, http.ErrServerClosed) {
+		l.Fatalf("failed to start mock oidc issuer %v", e)
+	}
+}
@@ -9,20 +9,27 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/trevatk/go-pkg/db"
 	"github.com/trevatk/go-pkg/logging"
 
 	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-chat/internal/mail"
+	"github.com/trevatk/go-chat/internal/metrics"
+	"github.com/trevatk/go-chat/internal/oidc"
 	"github.com/trevatk/go-chat/internal/port"
 	"github.com/trevatk/go-chat/internal/port/middleware"
-	pb "github.com/trevatk/go-chat/proto/messenger/v1"
+	"github.com/trevatk/go-chat/internal/tracing"
 )
 
 func main() {
@@ -30,12 +37,20 @@ func main() {
 	fx.New(
 		fx.Provide(provideLogger),
 		fx.Provide(db.NewSQLite),
+		fx.Provide(mail.NewSender),
+		fx.Provide(oidc.NewClientFromEnv),
 		fx.Provide(domain.NewBundle),
+		fx.Provide(middleware.NewPrivateKey),
+		fx.Provide(middleware.NewKeyManager),
 		fx.Provide(port.NewHTTPServer),
 		fx.Provide(middleware.NewAuthenticator),
+		fx.Provide(tracing.NewProvider),
+		fx.Provide(middleware.NewTracing),
 		fx.Provide(fx.Annotate(port.NewRouter, fx.As(new(http.Handler)))),
 		fx.Provide(port.NewGrpcServer),
+		fx.Provide(port.NewGrpcHealth),
 		fx.Invoke(registerHooks),
+		fx.StopTimeout(shutdownTimeout),
 		fx.WithLogger(func(log *zap.Logger) fxevent.Logger {
 			return &fxevent.ZapLogger{Logger: log}
 		}),
@@ -50,7 +65,124 @@ func provideLogger() (*zap.Logger, context.Context) {
 	return l.Desugar(), ctx
 }
 
-func registerHooks(lc fx.Lifecycle, log *zap.Logger, handler http.Handler, gSrv *port.GrpcServer, sqlite *sql.DB) error {
+const (
+	// shutdownTimeout deadline for draining http requests and gRPC streams
+	shutdownTimeout = time.Second * 30
+	// defaultShutdownDrainDelay time readiness fails before listeners close when unset
+	defaultShutdownDrainDelay = time.Second * 5
+
+	// defaultGrpcMaxMessageSize maximum grpc message size in bytes when unset
+	defaultGrpcMaxMessageSize = 4 << 20
+	// defaultGrpcMaxConnectionAge maximum grpc connection age when unset
+	defaultGrpcMaxConnectionAge = time.Minute * 30
+)
+
+// grpcServerOptions keepalive, message size and connection age server options
+//
+// $GRPC_MAX_MESSAGE_SIZE and $GRPC_MAX_CONNECTION_AGE override the defaults,
+// connections older than the max age are closed after a grace period
+// so long lived streams reconnect and rebalance across instances
+func grpcServerOptions() ([]grpc.ServerOption, error) {
+
+	ms := defaultGrpcMaxMessageSize
+	if v := os.Getenv("GRPC_MAX_MESSAGE_SIZE"); v != "" {
+
+		i, e := strconv.Atoi(v)
+		if e != nil || i < 1 {
+			return nil, fmt.Errorf("invalid $GRPC_MAX_MESSAGE_SIZE %s", v)
+		}
+
+		ms = i
+	}
+
+	ma := defaultGrpcMaxConnectionAge
+	if v := os.Getenv("GRPC_MAX_CONNECTION_AGE"); v != "" {
+
+		d, e := time.ParseDuration(v)
+		if e != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid $GRPC_MAX_CONNECTION_AGE %s", v)
+		}
+
+		ma = d
+	}
+
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(ms),
+		grpc.MaxSendMsgSize(ms),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     time.Minute * 5,
+			MaxConnectionAge:      ma,
+			MaxConnectionAgeGrace: time.Second * 30,
+			Time:                  time.Minute,
+			Timeout:               time.Second * 20,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Second * 30,
+			PermitWithoutStream: true,
+		}),
+	}, nil
+}
+
+// shutdownDrainDelay time between failing readiness checks and closing the listeners
+//
+// $SHUTDOWN_DRAIN_DELAY overrides the default and should exceed the load balancer
+// readiness check interval, at most half the shutdown timeout so requests still drain
+func shutdownDrainDelay() (time.Duration, error) {
+
+	v := os.Getenv("SHUTDOWN_DRAIN_DELAY")
+	if v == "" {
+		return defaultShutdownDrainDelay, nil
+	}
+
+	d, e := time.ParseDuration(v)
+	if e != nil || d < 0 || d > shutdownTimeout/2 {
+		return 0, fmt.Errorf("invalid $SHUTDOWN_DRAIN_DELAY %s", v)
+	}
+
+	return d, nil
+}
+
+// defaultUnverifiedRestrictions actions rejected for unverified users when unset
+const defaultUnverifiedRestrictions = "create_conversation"
+
+// unverifiedRestrictions actions rejected for users who have not verified their email
+//
+// $UNVERIFIED_RESTRICTIONS is a comma separated list of actions or none,
+// accepted actions are create_conversation, send_message, add_contact and create_invite
+func unverifiedRestrictions() ([]domain.Action, error) {
+
+	v := os.Getenv("UNVERIFIED_RESTRICTIONS")
+	if v == "" {
+		v = defaultUnverifiedRestrictions
+	} else if v == "none" {
+		return nil, nil
+	}
+
+	al := make([]domain.Action, 0, len(domain.Actions))
+
+	for _, s := range strings.Split(v, ",") {
+
+		a := domain.Action(strings.TrimSpace(s))
+
+		ok := false
+		for _, da := range domain.Actions {
+			if a == da {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("invalid $UNVERIFIED_RESTRICTIONS action %s", a)
+		}
+
+		al = append(al, a)
+	}
+
+	return al, nil
+}
+
+func registerHooks(appCtx context.Context, lc fx.Lifecycle, log *zap.Logger, handler http.Handler, hSrv *port.HTTPServer, bundle *domain.Bundle, gSrv *port.GrpcServer, gHealth *port.GrpcHealth, auth *middleware.Authenticator, keys *middleware.KeyManager, tr *middleware.Tracing, tp *tracing.Provider, sqlite *sql.DB) error {
 
 	l := log.Sugar()
 
@@ -67,26 +199,117 @@ func registerHooks(lc fx.Lifecycle, log *zap.Logger, handler http.Handler, gSrv
 		IdleTimeout:  time.Second * 15,
 	}
 
+	// metrics are only served when $METRICS_PORT is set, on a listener kept off the public api
+	var s3 *http.Server
+	if p3 := os.Getenv("METRICS_PORT"); p3 != "" {
+		s3 = &http.Server{
+			Addr:         ":" + p3,
+			Handler:      port.NewMetricsRouter(),
+			ReadTimeout:  time.Second * 15,
+			WriteTimeout: time.Second * 15,
+			IdleTimeout:  time.Second * 15,
+		}
+	}
+
 	p2 := os.Getenv("GRPC_SERVER_PORT")
 	if p2 == "" {
 		return errors.New("$GRPC_SERVER_PORT is unset")
 	}
 
-	s2 := grpc.NewServer()
-	pb.RegisterMessengerServiceServer(s2, gSrv)
+	auth.AllowUnauthenticated(port.PublicGrpcMethods...)
+	auth.CheckRevocations(bundle.RevocationList)
+	auth.AcceptAPIKeys(bundle.APIKeyService)
+	auth.AllowReadScope(port.ReadGrpcMethods...)
+	auth.RequireSessionFor(port.SessionGrpcMethods...)
+	auth.AuthorizeWith(bundle.AccessControl)
+	auth.RequirePermissions(port.AdminGrpcPermissions)
+
+	ur, e := unverifiedRestrictions()
+	if e != nil {
+		return e
+	}
+
+	dd, e := shutdownDrainDelay()
+	if e != nil {
+		return e
+	}
+
+	bundle.VerificationService.Restrict(ur...)
+	bundle.IdentityService.AllowProvisioning(os.Getenv("OIDC_AUTO_PROVISION") == "true")
+
+	opts, e := grpcServerOptions()
+	if e != nil {
+		return e
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(tr.UnaryInterceptor, middleware.MetricsUnaryInterceptor, auth.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(tr.StreamInterceptor, middleware.MetricsStreamInterceptor, auth.StreamInterceptor),
+	)
+
+	e = metrics.RegisterDBStats(sqlite)
+	if e != nil {
+		return fmt.Errorf("unable to register database metrics %v", e)
+	}
+
+	e = metrics.RegisterSubscribers(bundle.MessengerService.SubscriberCounts)
+	if e != nil {
+		return fmt.Errorf("unable to register broker metrics %v", e)
+	}
+
+	s2 := grpc.NewServer(opts...)
+	gSrv.Register(s2)
+
+	if os.Getenv("GRPC_REFLECTION") == "true" {
+		auth.AllowUnauthenticated(port.ReflectionGrpcMethods...)
+		reflection.Register(s2)
+	}
+
+	gHealth.Register(s2)
 
 	lc.Append(
 		fx.Hook{
 			OnStart: func(ctx context.Context) error {
 
+				// revoked tokens must be rejected from the first request, pruning outlives the start hook context
+				e := bundle.RevocationList.Start(appCtx)
+				if e != nil {
+					return fmt.Errorf("unable to load revocation list %v", e)
+				}
+
+				// bootstrap the first administrator, the user must be registered before
+				// the variable is set so nobody can claim the username in the meantime
+				if un := os.Getenv("ADMIN_USERNAME"); un != "" {
+
+					e = bundle.AdminService.Promote(ctx, un)
+					if e == domain.ErrAdminExists {
+						l.Infof("administrator exists, admin user %s not promoted", un)
+					} else if e == domain.ErrResourceNotFound {
+						return fmt.Errorf("admin user %s not found", un)
+					} else if e != nil {
+						return fmt.Errorf("unable to promote admin user %v", e)
+					}
+				}
+
 				l.Infof("start http server http://localhost:%s", p1)
 
 				go func() {
-					if e := s1.ListenAndServe(); e != nil {
+					if e := s1.ListenAndServe(); e != nil && !errors.Is(e, http.ErrServerClosed) {
 						l.Fatalf("failed to start http server %v", e)
 					}
 				}()
 
+				if s3 != nil {
+
+					l.Infof("start metrics server http://localhost%s/metrics", s3.Addr)
+
+					go func() {
+						if e := s3.ListenAndServe(); e != nil && !errors.Is(e, http.ErrServerClosed) {
+							l.Fatalf("failed to start metrics server %v", e)
+						}
+					}()
+				}
+
 				li, e := net.Listen("tcp", ":"+p2)
 				if e != nil {
 					return fmt.Errorf("unable to create network listener %v", e)
@@ -94,6 +317,10 @@ func registerHooks(lc fx.Lifecycle, log *zap.Logger, handler http.Handler, gSrv
 
 				l.Infof("start gRPC server localhost:%s", p2)
 
+				// checks and key rotation outlive the start hook context
+				gHealth.Start(appCtx)
+				keys.Start(appCtx)
+
 				go func() {
 					if e := s2.Serve(li); e != nil {
 						l.Fatalf("failed to start gRPC server %v", e)
@@ -104,27 +331,68 @@ func registerHooks(lc fx.Lifecycle, log *zap.Logger, handler http.Handler, gSrv
 			},
 			OnStop: func(ctx context.Context) error {
 
-				var e error
+				// fail readiness and health checks so load balancers stop routing new requests
+				hSrv.Drain()
+				gHealth.Shutdown()
 
-				log.Info("close database connection")
+				// keep serving until load balancers observe the failing checks
+				l.Infof("wait %s for load balancers to stop routing", dd)
 
-				e = sqlite.Close()
-				if e != nil {
-					l.Errorf("failed to close database connection %v", e)
+				select {
+				case <-time.After(dd):
+				case <-ctx.Done():
 				}
 
+				log.Info("shutdown gRPC server")
+
+				// stop accepting gRPC connections and wait for in flight calls
+				stopped := make(chan struct{})
+				go func() {
+					s2.GracefulStop()
+					close(stopped)
+				}()
+
+				// end long lived streams with a going away status so clients reconnect elsewhere
+				log.Info("close streaming subscriptions")
+				bundle.MessengerService.Shutdown()
+
 				log.Info("shutdown http server")
 
-				e = s1.Close()
-				if e != nil && !errors.Is(e, http.ErrServerClosed) {
-					l.Errorf("failed to shutdown http server %v", e)
+				// stop accepting http connections and wait for in flight requests until the deadline
+				e := s1.Shutdown(ctx)
+				if e != nil {
+					l.Errorf("failed to gracefully shutdown http server %v", e)
+					_ = s1.Close()
 				}
 
-				log.Info("shutdown gRPC server")
-				s2.GracefulStop()
+				select {
+				case <-stopped:
+				case <-ctx.Done():
+					l.Errorf("gRPC server did not drain before deadline %v", ctx.Err())
+					s2.Stop()
+					<-stopped
+				}
+
+				// scraped until the api servers stopped so draining is observable
+				if s3 != nil {
+					_ = s3.Close()
+				}
+
+				bundle.RevocationList.Shutdown()
+				keys.Shutdown()
+
+				// closed last so drained requests never hit a closed database
+				log.Info("close database connection")
+
+				e = sqlite.Close()
+				if e != nil {
+					return fmt.Errorf("failed to close database connection %v", e)
+				}
+
+				// flush spans recorded while draining
+				log.Info("shutdown tracer provider")
 
-				// redudant logging
-				return e
+				return tp.Shutdown(ctx)
 			},
 		},
 	)
@@ -16,6 +16,9 @@ job "chat" {
 
             port "grpc" {
             }
+
+            port "metrics" {
+            }
         }
 
         service {
@@ -34,10 +37,29 @@ job "chat" {
                 name = "alive"
                 type = "http"
                 port = "http"
-                path = "/health"
+                path = "/livez"
                 interval = "1m"
                 timeout = "10s"
             }
+
+            check {
+                name = "ready"
+                type = "http"
+                port = "http"
+                path = "/readyz"
+                interval = "10s"
+                timeout = "5s"
+            }
+        }
+
+        # scraped by prometheus through consul, not routed by traefik
+        service {
+            name = "chat-metrics"
+            tags = [
+                "metrics"
+            ]
+            port = "metrics"
+            provider = "consul"
         }
 
         volume "db" {
@@ -70,9 +92,12 @@ job "chat" {
 
             driver = "docker"
 
+            # drain delay plus the graceful shutdown deadline
+            kill_timeout = "45s"
+
             config {
                 image = "structx/go-chat:v0.1.0"
-                ports = ["http", "grpc"]
+                ports = ["http", "grpc", "metrics"]
             }
 
             volume_mount {
@@ -89,11 +114,16 @@ job "chat" {
             env {
                 HTTP_SERVER_PORT = "${NOMAD_PORT_http}"
 		        GRPC_SERVER_PORT = "${NOMAD_PORT_grpc}"
-                SQLITE_DSN = "/app/sqlite/chat.db"
+                METRICS_PORT = "${NOMAD_PORT_metrics}"
+                SQLITE_DSN = "/app/sqlite/chat.db?_pragma=busy_timeout(5000)&_txlock=immediate"
                 SQLITE_MIGRATIONS_DIR = "/app/migrations"
                 LOG_LEVEL = "development"
                 ALLOWED_ORIGINS = "http://localhost:3000"
                 JWT_PRIVATE_KEY = "/app/certs/keyPair.pem"
+                TRUSTED_PROXIES = "127.0.0.1"
+                SHUTDOWN_DRAIN_DELAY = "12s"
+                GRPC_REFLECTION = "true"
+                OTEL_TRACES_EXPORTER = "stdout"
             }
 
             resources {
@@ -16,6 +16,9 @@ job "chat" {
 
             port "grpc" {
             }
+
+            port "metrics" {
+            }
         }
 
         service {
@@ -39,10 +42,19 @@ job "chat" {
                 name = "alive"
                 type = "http"
                 port = "http"
-                path = "/health"
+                path = "/livez"
                 interval = "1m"
                 timeout = "10s"
             }
+
+            check {
+                name = "ready"
+                type = "http"
+                port = "http"
+                path = "/readyz"
+                interval = "10s"
+                timeout = "5s"
+            }
         }
 
         service {
@@ -61,6 +73,24 @@ job "chat" {
             connect {
                 sidecar_service {}
             }
+
+            check {
+                name = "grpc-health"
+                type = "grpc"
+                port = "grpc"
+                interval = "1m"
+                timeout = "10s"
+            }
+        }
+
+        # scraped by prometheus through consul, not routed by traefik
+        service {
+            name = "chat-metrics"
+            tags = [
+                "metrics"
+            ]
+            port = "metrics"
+            provider = "consul"
         }
 
         volume "db" {
@@ -93,9 +123,12 @@ job "chat" {
 
             driver = "docker"
 
+            # drain delay plus the graceful shutdown deadline
+            kill_timeout = "45s"
+
             config {
                 image = "structx/go-chat:v0.1.0"
-                ports = ["http", "grpc"]
+                ports = ["http", "grpc", "metrics"]
             }
 
             volume_mount {
@@ -111,11 +144,14 @@ job "chat" {
             env {
                 HTTP_SERVER_PORT = "${NOMAD_PORT_http}"
 		        GRPC_SERVER_PORT = "${NOMAD_PORT_grpc}"
-                SQLITE_DSN = "/app/sqlite/chat.db"
+                METRICS_PORT = "${NOMAD_PORT_metrics}"
+                SQLITE_DSN = "/app/sqlite/chat.db?_pragma=busy_timeout(5000)&_txlock=immediate"
                 SQLITE_MIGRATIONS_DIR = "/app/migrations"
                 LOG_LEVEL = "production"
                 ALLOWED_ORIGINS = "https://messenger.structx.io"
                 JWT_PRIVATE_KEY = "/app/certs/keyPair.pem"
+                TRUSTED_PROXIES = "127.0.0.1"
+                SHUTDOWN_DRAIN_DELAY = "12s"
             }
 
             resources {
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Role global role of a user
+type Role string
+
+const (
+	// RoleAdmin manage every user, their roles and sessions
+	RoleAdmin Role = "admin"
+	// RoleModerator list users and end their sessions
+	RoleModerator Role = "moderator"
+	// RoleUser no administrative permissions
+	RoleUser Role = "user"
+)
+
+// Roles every role ordered from least to most privileged
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// Permission administrative operation granted to roles
+type Permission string
+
+const (
+	// PermissionListUsers list every user
+	PermissionListUsers Permission = "list_users"
+	// PermissionManageSessions list and end sessions of other users
+	PermissionManageSessions Permission = "manage_sessions"
+	// PermissionDisableUsers disable and enable accounts
+	PermissionDisableUsers Permission = "disable_users"
+	// PermissionManageRoles change the role of other users
+	PermissionManageRoles Permission = "manage_roles"
+)
+
+// rolePermissions permissions granted to each role
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermissionListUsers, PermissionManageSessions},
+	RoleAdmin:     {PermissionListUsers, PermissionManageSessions, PermissionDisableUsers, PermissionManageRoles},
+}
+
+// Grants report whether role was granted permission
+func (r Role) Grants(permission Permission) bool {
+
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rank position of role in Roles, unknown roles rank lowest
+func (r Role) rank() int {
+
+	for i, rr := range Roles {
+		if rr == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// validRole report whether role is one of Roles
+func validRole(role Role) bool {
+	return role.rank() >= 0
+}
+
+// AccessControl role based authorization consulted before administrative operations
+type AccessControl struct {
+	db *sql.DB
+}
+
+func newAccessControl(db *sql.DB) *AccessControl {
+	return &AccessControl{db: db}
+}
+
+// Authorize verify the role of user grants permission
+//
+// unknown and disabled users are never authorized
+func (ac *AccessControl) Authorize(ctx context.Context, user uuid.UUID, permission Permission) error {
+
+	co, e := ac.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	su, e := newQueries(co).ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrForbidden
+		}
+
+		return fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if su.DisabledAt.Valid || !Role(su.Role).Grants(permission) {
+		return ErrForbidden
+	}
+
+	return nil
+}
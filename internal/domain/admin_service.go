@@ -0,0 +1,343 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+const (
+	// DefaultUserLimit page size of user listings when unset
+	DefaultUserLimit = 50
+	// MaxUserLimit largest page size of user listings
+	MaxUserLimit = 200
+)
+
+// UserQuery application layer user listing query model
+type UserQuery struct {
+	// Role only list users with role when set
+	Role Role
+	// Cursor opaque next cursor from previous page, empty for first page
+	Cursor string
+	Limit  int64
+}
+
+// UserPage application layer user listing page model
+type UserPage struct {
+	Users []*User
+	// NextCursor empty when there are no more pages
+	NextCursor string
+}
+
+// AdminService user administration service
+//
+// callers are authorized with AccessControl first, the service only
+// prevents acting on yourself or on users with the same or a higher role
+type AdminService struct {
+	db       *sql.DB
+	sessions *SessionService
+}
+
+func newAdminService(db *sql.DB, sessions *SessionService) *AdminService {
+	return &AdminService{db: db, sessions: sessions}
+}
+
+// ListUsers retrieve page of users ordered by username
+func (as *AdminService) ListUsers(ctx context.Context, query *UserQuery) (*UserPage, error) {
+
+	if query.Role != "" && !validRole(query.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	var after string
+	if query.Cursor != "" {
+
+		b, e := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if e != nil || len(b) == 0 {
+			return nil, ErrInvalidCursor
+		}
+
+		after = string(b)
+	}
+
+	l := query.Limit
+	if l < 1 {
+		l = DefaultUserLimit
+	} else if l > MaxUserLimit {
+		l = MaxUserLimit
+	}
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	// read one additional row to determine if another page exists
+	var sul []*repository.User
+	if query.Role == "" {
+		sul, e = q.ListUsers(ctx, &repository.ListUsersParams{Usernm: after, Limit: l + 1})
+	} else {
+		sul, e = q.ListUsersByRole(ctx, &repository.ListUsersByRoleParams{Role: string(query.Role), Usernm: after, Limit: l + 1})
+	}
+	if e != nil {
+		return nil, fmt.Errorf("error executing list users query %v", e)
+	}
+
+	p := &UserPage{}
+
+	if int64(len(sul)) > l {
+		sul = sul[:l]
+		p.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(sul[l-1].Usernm))
+	}
+
+	p.Users = make([]*User, 0, len(sul))
+	for _, su := range sul {
+		p.Users = append(p.Users, transformSQLUser(su))
+	}
+
+	return p, nil
+}
+
+// SetRole replace global role of user on behalf of actor
+//
+// actors can not change their own role and bots always have the user role
+func (as *AdminService) SetRole(ctx context.Context, actor, user uuid.UUID, role Role) (*User, error) {
+
+	if !validRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	su, e := readAdminTarget(ctx, q, actor, user)
+	if e != nil {
+		return nil, e
+	}
+
+	if UserType(su.UserType) == UserTypeBot && role != RoleUser {
+		return nil, ErrForbidden
+	}
+
+	su, e = q.UpdateUserRole(ctx, &repository.UpdateUserRoleParams{
+		Role: string(role),
+		Uuid: su.Uuid,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing update user role query %v", e)
+	}
+
+	return transformSQLUser(su), nil
+}
+
+// Disable disable user on behalf of actor and end every session of the user
+//
+// disabled users can not sign in and their api keys and the keys of their bots are rejected
+func (as *AdminService) Disable(ctx context.Context, actor, user uuid.UUID) (*User, error) {
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	su, e := readAdminTarget(ctx, q, actor, user)
+	if e != nil {
+		return nil, e
+	}
+
+	su, e = q.DisableUser(ctx, su.Uuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing disable user query %v", e)
+	}
+
+	commit, e := as.sessions.revokeAllSessions(ctx, q, su.Uuid, "")
+	if e != nil {
+		return nil, e
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	commit()
+
+	return transformSQLUser(su), nil
+}
+
+// Enable enable disabled user on behalf of actor
+func (as *AdminService) Enable(ctx context.Context, actor, user uuid.UUID) (*User, error) {
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	su, e := readAdminTarget(ctx, q, actor, user)
+	if e != nil {
+		return nil, e
+	}
+
+	su, e = q.EnableUser(ctx, su.Uuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing enable user query %v", e)
+	}
+
+	return transformSQLUser(su), nil
+}
+
+// ListSessions retrieve active sessions of user on behalf of actor
+func (as *AdminService) ListSessions(ctx context.Context, actor, user uuid.UUID) ([]*Session, error) {
+
+	e := as.checkTarget(ctx, actor, user)
+	if e != nil {
+		return nil, e
+	}
+
+	return as.sessions.List(ctx, user)
+}
+
+// RevokeSession end session of user on behalf of actor
+func (as *AdminService) RevokeSession(ctx context.Context, actor, user, session uuid.UUID) error {
+
+	e := as.checkTarget(ctx, actor, user)
+	if e != nil {
+		return e
+	}
+
+	return as.sessions.Revoke(ctx, user, session)
+}
+
+// Logout end every session of user on behalf of actor
+func (as *AdminService) Logout(ctx context.Context, actor, user uuid.UUID) error {
+
+	e := as.checkTarget(ctx, actor, user)
+	if e != nil {
+		return e
+	}
+
+	return as.sessions.LogoutAll(ctx, user)
+}
+
+// Promote grant the admin role to a human user by username
+//
+// only bootstraps the first administrator, returns ErrAdminExists once any user
+// is an admin and ErrResourceNotFound when no human user has the username
+func (as *AdminService) Promote(ctx context.Context, username string) error {
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	c, e := q.CountAdmins(ctx)
+	if e != nil {
+		return fmt.Errorf("error executing count admins query %v", e)
+	}
+
+	if c > 0 {
+		return ErrAdminExists
+	}
+
+	r, e := q.PromoteUserByUsername(ctx, username)
+	if e != nil {
+		return fmt.Errorf("error executing promote user by username query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrResourceNotFound
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return nil
+}
+
+// checkTarget verify actor may act on user
+func (as *AdminService) checkTarget(ctx context.Context, actor, user uuid.UUID) error {
+
+	co, e := as.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	_, e = readAdminTarget(ctx, newQueries(co), actor, user)
+
+	return e
+}
+
+// readAdminTarget read user acted on by actor
+//
+// actors may only act on users with a lower role so administrators
+// and moderators can not demote, disable or log out each other
+func readAdminTarget(ctx context.Context, q *repository.Queries, actor, user uuid.UUID) (*repository.User, error) {
+
+	if actor == user {
+		return nil, ErrForbidden
+	}
+
+	sa, e := q.ReadUser(ctx, actor.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrForbidden
+		}
+
+		return nil, fmt.Errorf("error executing read user query %v", e)
+	}
+
+	su, e := q.ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if Role(su.Role).rank() >= Role(sa.Role).rank() {
+		return nil, ErrForbidden
+	}
+
+	return su, nil
+}
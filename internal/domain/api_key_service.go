@@ -0,0 +1,387 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+const (
+	// APIKeyPrefix prefix of every api key, distinguishes keys from access tokens
+	APIKeyPrefix = "gck_"
+
+	// apiKeyDisplayLength characters of a key kept to identify it in listings
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	// maxAPIKeyNameLength longest api key name
+	maxAPIKeyNameLength = 64
+	// maxAPIKeysPerUser unrevoked api keys a user or bot may hold
+	maxAPIKeysPerUser = 25
+	// apiKeyTouchInterval minimum time between last used updates of a key
+	apiKeyTouchInterval = time.Minute
+)
+
+// Scope permission granted to an api key
+type Scope string
+
+const (
+	// ScopeRead read resources
+	ScopeRead Scope = "read"
+	// ScopeWrite create, update and delete resources
+	ScopeWrite Scope = "write"
+)
+
+// Scopes every scope which may be granted
+var Scopes = []Scope{ScopeRead, ScopeWrite}
+
+// NewAPIKey application layer new api key model
+//
+// a zero expiry never expires
+type NewAPIKey struct {
+	Name      string
+	Scopes    []Scope
+	ExpiresAt time.Time
+}
+
+// APIKey application layer api key model
+//
+// key is only populated when the api key is created,
+// zero expiry and last used times are unset
+type APIKey struct {
+	UID        uuid.UUID
+	User       uuid.UUID
+	Name       string
+	Prefix     string
+	Key        string
+	Scopes     []Scope
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope report whether the key was granted scope
+func (k *APIKey) HasScope(scope Scope) bool {
+
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// APIKeyService personal and bot api key service
+//
+// users manage their own keys and the keys of bots they own
+type APIKeyService struct {
+	db *sql.DB
+}
+
+func newAPIKeyService(db *sql.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// Create issue new api key for owner on behalf of actor
+//
+// owner is the actor or a bot owned by the actor, the key is only returned now
+func (aks *APIKeyService) Create(ctx context.Context, actor, owner uuid.UUID, newKey *NewAPIKey) (*APIKey, error) {
+
+	sc, e := validateAPIKey(newKey)
+	if e != nil {
+		return nil, e
+	}
+
+	k, e := generateToken()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate api key %v", e)
+	}
+
+	k = APIKeyPrefix + k
+
+	co, e := aks.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	e = authorizeKeyOwner(ctx, q, actor, owner)
+	if e != nil {
+		return nil, e
+	}
+
+	n, e := q.CountUserAPIKeys(ctx, owner.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing count user api keys query %v", e)
+	}
+
+	if n >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	var exp sql.NullTime
+	if !newKey.ExpiresAt.IsZero() {
+		exp = sql.NullTime{Time: newKey.ExpiresAt.UTC(), Valid: true}
+	}
+
+	sk, e := q.InsertAPIKey(ctx, &repository.InsertAPIKeyParams{
+		Uuid:      uuid.NewString(),
+		UserUuid:  owner.String(),
+		Name:      newKey.Name,
+		Prefix:    k[:apiKeyDisplayLength],
+		KeyHash:   hashToken(k),
+		Scopes:    sc,
+		ExpiresAt: exp,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert api key query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	ak := transformSQLAPIKey(sk)
+	ak.Key = k
+
+	return ak, nil
+}
+
+// List retrieve unrevoked api keys of owner on behalf of actor
+func (aks *APIKeyService) List(ctx context.Context, actor, owner uuid.UUID) ([]*APIKey, error) {
+
+	co, e := aks.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = authorizeKeyOwner(ctx, q, actor, owner)
+	if e != nil {
+		return nil, e
+	}
+
+	sl, e := q.ListUserAPIKeys(ctx, owner.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing list user api keys query %v", e)
+	}
+
+	kl := make([]*APIKey, 0, len(sl))
+	for _, sk := range sl {
+		kl = append(kl, transformSQLAPIKey(sk))
+	}
+
+	return kl, nil
+}
+
+// Revoke revoke api key of owner on behalf of actor
+func (aks *APIKeyService) Revoke(ctx context.Context, actor, owner, key uuid.UUID) error {
+
+	co, e := aks.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = authorizeKeyOwner(ctx, q, actor, owner)
+	if e != nil {
+		return e
+	}
+
+	r, e := q.RevokeAPIKey(ctx, &repository.RevokeAPIKeyParams{
+		Uuid:     key.String(),
+		UserUuid: owner.String(),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing revoke api key query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// Verify resolve api key to its user and scopes
+//
+// unknown, revoked and expired keys and keys of disabled users are rejected,
+// use is recorded at most once a minute
+func (aks *APIKeyService) Verify(ctx context.Context, key string) (*APIKey, error) {
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	co, e := aks.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	sk, e := q.ReadAPIKeyByHash(ctx, hashToken(key))
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+
+		return nil, fmt.Errorf("error executing read api key by hash query %v", e)
+	}
+
+	now := time.Now().UTC()
+
+	if sk.RevokedAt.Valid || (sk.ExpiresAt.Valid && now.After(sk.ExpiresAt.Time)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	e = checkKeyUserEnabled(ctx, q, sk.UserUuid)
+	if e != nil {
+		return nil, e
+	}
+
+	if !sk.LastUsedAt.Valid || now.Sub(sk.LastUsedAt.Time) >= apiKeyTouchInterval {
+
+		e = q.TouchAPIKey(ctx, &repository.TouchAPIKeyParams{
+			LastUsedAt: sql.NullTime{Time: now, Valid: true},
+			Uuid:       sk.Uuid,
+		})
+		if e != nil {
+			return nil, fmt.Errorf("error executing touch api key query %v", e)
+		}
+
+		sk.LastUsedAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	return transformSQLAPIKey(sk), nil
+}
+
+// authorizeKeyOwner verify actor may manage the api keys of owner
+func authorizeKeyOwner(ctx context.Context, q *repository.Queries, actor, owner uuid.UUID) error {
+
+	if actor == owner {
+		return nil
+	}
+
+	su, e := q.ReadUser(ctx, owner.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrResourceNotFound
+		}
+
+		return fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if UserType(su.UserType) != UserTypeBot || su.OwnerUuid.String != actor.String() {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// checkKeyUserEnabled reject keys of disabled users and of bots owned by disabled users
+func checkKeyUserEnabled(ctx context.Context, q *repository.Queries, user string) error {
+
+	su, e := q.ReadUser(ctx, user)
+	if e != nil {
+		return fmt.Errorf("error executing read user query %v", e)
+	} else if su.DisabledAt.Valid {
+		return ErrInvalidAPIKey
+	}
+
+	if su.OwnerUuid.Valid {
+		return checkKeyUserEnabled(ctx, q, su.OwnerUuid.String)
+	}
+
+	return nil
+}
+
+// validateAPIKey check name, scopes and expiry of a new key and return its scopes column
+func validateAPIKey(newKey *NewAPIKey) (string, error) {
+
+	if len(newKey.Name) < 1 || len(newKey.Name) > maxAPIKeyNameLength {
+		return "", ErrInvalidAPIKeyRequest
+	} else if len(newKey.Scopes) < 1 {
+		return "", ErrInvalidAPIKeyRequest
+	} else if !newKey.ExpiresAt.IsZero() && !newKey.ExpiresAt.After(time.Now()) {
+		return "", ErrInvalidAPIKeyRequest
+	}
+
+	granted := make(map[Scope]bool, len(newKey.Scopes))
+
+	for _, ns := range newKey.Scopes {
+
+		ok := false
+		for _, s := range Scopes {
+			if ns == s {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return "", ErrInvalidAPIKeyRequest
+		}
+
+		granted[ns] = true
+	}
+
+	// stored in declaration order without duplicates
+	sl := make([]string, 0, len(granted))
+	for _, s := range Scopes {
+		if granted[s] {
+			sl = append(sl, string(s))
+		}
+	}
+
+	return strings.Join(sl, ","), nil
+}
+
+func transformSQLAPIKey(key *repository.ApiKey) *APIKey {
+
+	ak := &APIKey{
+		UID:       uuid.MustParse(key.Uuid),
+		User:      uuid.MustParse(key.UserUuid),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		CreatedAt: key.CreatedAt,
+	}
+
+	for _, s := range strings.Split(key.Scopes, ",") {
+		if s != "" {
+			ak.Scopes = append(ak.Scopes, Scope(s))
+		}
+	}
+
+	if key.ExpiresAt.Valid {
+		ak.ExpiresAt = key.ExpiresAt.Time
+	}
+
+	if key.LastUsedAt.Valid {
+		ak.LastUsedAt = key.LastUsedAt.Time
+	}
+
+	return ak
+}
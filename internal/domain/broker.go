@@ -0,0 +1,355 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionBuffer envelopes buffered per subscriber before it is considered too slow
+const subscriptionBuffer = 64
+
+// EventType conversation event type
+type EventType int
+
+const (
+	// EventTypingStarted member started typing
+	EventTypingStarted EventType = iota
+	// EventTypingStopped member stopped typing
+	EventTypingStopped
+	// EventAcknowledged member read conversation up to envelope
+	EventAcknowledged
+)
+
+// Event application layer conversation event model
+type Event struct {
+	Type             EventType
+	ConversationUUID uuid.UUID
+	User             uuid.UUID
+	// EnvelopeUID acknowledged envelope, only set for acknowledged events
+	EnvelopeUID uuid.UUID
+	CreatedAt   time.Time
+}
+
+// Subscription live envelope and event feed
+//
+// conversation subscriptions receive envelopes for a single conversation,
+// user subscriptions receive envelopes and events for every conversation
+// the user is a member of at the time of publishing, envelopes of muted
+// conversations are not delivered to user subscriptions
+//
+// the channels are closed when the subscription is closed, the subscriber
+// falls too far behind and is dropped or the broker is shut down
+type Subscription struct {
+	// Conversation nil for user subscriptions
+	Conversation uuid.UUID
+	User         uuid.UUID
+
+	ch     chan *Envelope
+	events chan *Event
+	broker *Broker
+	once   sync.Once
+	err    error
+}
+
+// Envelopes receive published envelopes
+func (s *Subscription) Envelopes() <-chan *Envelope {
+	return s.ch
+}
+
+// Events receive published events, only user subscriptions receive events
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Close unsubscribe from broker
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s, nil)
+}
+
+// Err reason the broker closed the subscription channels
+//
+// ErrSubscriberTooSlow or ErrShuttingDown, nil when closed by the subscriber,
+// only valid after the channels are closed
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// closeChannels record reason and close subscription channels once
+func (s *Subscription) closeChannels(reason error) {
+	s.once.Do(func() {
+		s.err = reason
+		close(s.ch)
+		if s.events != nil {
+			close(s.events)
+		}
+	})
+}
+
+// Broker in memory conversation envelope fan-out
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	users       map[uuid.UUID]map[*Subscription]struct{}
+	closed      bool
+
+	// orderMu guards ordering, the publish order locks of conversations with a send in flight
+	orderMu  sync.Mutex
+	ordering map[uuid.UUID]*conversationLock
+}
+
+// conversationLock publish order lock of a conversation
+type conversationLock struct {
+	mu sync.Mutex
+	// refs senders holding or waiting for the lock, removed from the broker at zero
+	refs int
+}
+
+func newBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[uuid.UUID]map[*Subscription]struct{}),
+		users:       make(map[uuid.UUID]map[*Subscription]struct{}),
+		ordering:    make(map[uuid.UUID]*conversationLock),
+	}
+}
+
+func (b *Broker) subscribe(conversationUUID, user uuid.UUID) *Subscription {
+
+	s := &Subscription{
+		Conversation: conversationUUID,
+		User:         user,
+		ch:           make(chan *Envelope, subscriptionBuffer),
+		broker:       b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		s.closeChannels(ErrShuttingDown)
+		return s
+	}
+
+	addSubscription(b.subscribers, conversationUUID, s)
+
+	return s
+}
+
+func (b *Broker) subscribeUser(user uuid.UUID) *Subscription {
+
+	s := &Subscription{
+		User:   user,
+		ch:     make(chan *Envelope, subscriptionBuffer),
+		events: make(chan *Event, subscriptionBuffer),
+		broker: b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		s.closeChannels(ErrShuttingDown)
+		return s
+	}
+
+	addSubscription(b.users, user, s)
+
+	return s
+}
+
+func (b *Broker) unsubscribe(s *Subscription, reason error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.Conversation == uuid.Nil {
+		removeSubscription(b.users, s.User, s)
+	} else {
+		removeSubscription(b.subscribers, s.Conversation, s)
+	}
+
+	s.closeChannels(reason)
+}
+
+// shutdown close every subscription with ErrShuttingDown
+// and close new subscriptions immediately
+func (b *Broker) shutdown() {
+
+	b.mu.Lock()
+
+	b.closed = true
+
+	ss := make([]*Subscription, 0)
+	for _, m := range []map[uuid.UUID]map[*Subscription]struct{}{b.subscribers, b.users} {
+		for _, subs := range m {
+			for s := range subs {
+				ss = append(ss, s)
+			}
+		}
+	}
+
+	b.mu.Unlock()
+
+	for _, s := range ss {
+		b.unsubscribe(s, ErrShuttingDown)
+	}
+}
+
+// publish deliver envelope to all conversation subscribers
+// and user subscribers of the notified members
+//
+// publishing never blocks, subscribers with a full buffer are dropped
+// so the client can reconnect instead of stalling every sender.
+// callers hold the conversation lock so envelopes are published in sequence order
+func (b *Broker) publish(envelope *Envelope, members []uuid.UUID) {
+
+	b.mu.RLock()
+
+	slow := make([]*Subscription, 0)
+
+	for s := range b.subscribers[envelope.ConversationUUID] {
+		select {
+		case s.ch <- envelope:
+		default:
+			slow = append(slow, s)
+		}
+	}
+
+	for _, m := range members {
+		for s := range b.users[m] {
+			select {
+			case s.ch <- envelope:
+			default:
+				slow = append(slow, s)
+			}
+		}
+	}
+
+	b.mu.RUnlock()
+
+	for _, s := range slow {
+		b.unsubscribe(s, ErrSubscriberTooSlow)
+	}
+}
+
+// publishEvent deliver event to user subscribers of recipients
+func (b *Broker) publishEvent(event *Event, recipients []uuid.UUID) {
+
+	b.mu.RLock()
+
+	slow := make([]*Subscription, 0)
+
+	for _, r := range recipients {
+		for s := range b.users[r] {
+			select {
+			case s.events <- event:
+			default:
+				slow = append(slow, s)
+			}
+		}
+	}
+
+	b.mu.RUnlock()
+
+	for _, s := range slow {
+		b.unsubscribe(s, ErrSubscriberTooSlow)
+	}
+}
+
+// counts number of conversation and user subscriptions
+func (b *Broker) counts() (int, int) {
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	c, u := 0, 0
+
+	for _, ss := range b.subscribers {
+		c += len(ss)
+	}
+
+	for _, ss := range b.users {
+		u += len(ss)
+	}
+
+	return c, u
+}
+
+// ping verify the subscriber maps can be read before ctx is done
+//
+// a read lock is taken like publishing does so steady publish traffic
+// does not fail the check, only a held write lock does
+func (b *Broker) ping(ctx context.Context) error {
+
+	ok := make(chan struct{})
+
+	go func() {
+		_, _ = b.counts()
+		close(ok)
+	}()
+
+	select {
+	case <-ok:
+		return nil
+	case <-ctx.Done():
+		return ErrBrokerUnavailable
+	}
+}
+
+// lockConversation hold the publish order lock of conversation until the returned func is called
+//
+// senders hold it from commit until publish, sequence numbers are assigned under the
+// database write lock so the sender of the next number commits after it is taken.
+// ordering only holds within one instance, the broker is not shared between instances
+func (b *Broker) lockConversation(conversationUUID uuid.UUID) func() {
+
+	b.orderMu.Lock()
+
+	cl, ok := b.ordering[conversationUUID]
+	if !ok {
+		cl = &conversationLock{}
+		b.ordering[conversationUUID] = cl
+	}
+
+	cl.refs++
+
+	b.orderMu.Unlock()
+
+	cl.mu.Lock()
+
+	return func() {
+
+		cl.mu.Unlock()
+
+		b.orderMu.Lock()
+		defer b.orderMu.Unlock()
+
+		cl.refs--
+		if cl.refs == 0 {
+			delete(b.ordering, conversationUUID)
+		}
+	}
+}
+
+func addSubscription(m map[uuid.UUID]map[*Subscription]struct{}, key uuid.UUID, s *Subscription) {
+
+	if _, ok := m[key]; !ok {
+		m[key] = make(map[*Subscription]struct{})
+	}
+
+	m[key][s] = struct{}{}
+}
+
+func removeSubscription(m map[uuid.UUID]map[*Subscription]struct{}, key uuid.UUID, s *Subscription) {
+
+	if ss, ok := m[key]; ok {
+
+		delete(ss, s)
+
+		if len(ss) == 0 {
+			delete(m, key)
+		}
+	}
+}
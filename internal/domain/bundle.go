@@ -1,20 +1,55 @@
 // Package domain application layer
 package domain
 
-import "database/sql"
+import (
+	"database/sql"
+
+	"github.com/trevatk/go-chat/internal/mail"
+	"github.com/trevatk/go-chat/internal/oidc"
+)
 
 // Bundle domain service bundle
 type Bundle struct {
-	UserService      *UserService
-	MessengerService *MessengerService
-	ContactService   *ContactService
+	UserService         *UserService
+	SessionService      *SessionService
+	PasswordService     *PasswordService
+	VerificationService *VerificationService
+	TwoFactorService    *TwoFactorService
+	IdentityService     *IdentityService
+	APIKeyService       *APIKeyService
+	AccessControl       *AccessControl
+	AdminService        *AdminService
+	MessengerService    *MessengerService
+	ContactService      *ContactService
+	InviteService       *InviteService
+	HealthService       *HealthService
+	RevocationList      *RevocationList
 }
 
 // NewBundle create new service bundle
-func NewBundle(db *sql.DB) *Bundle {
+//
+// idp is nil when oidc login is disabled
+func NewBundle(db *sql.DB, mailer mail.Sender, idp *oidc.Client) *Bundle {
+
+	b := newBroker()
+	rl := newRevocationList(db)
+	ss := newSessionService(db, rl)
+	vs := newVerificationService(db, mailer)
+
 	return &Bundle{
-		UserService:      newUserService(db),
-		MessengerService: newMessengerService(db),
-		ContactService:   newContactService(db),
+		UserService:         newUserService(db, vs),
+		SessionService:      ss,
+		PasswordService:     newPasswordService(db, ss, mailer),
+		VerificationService: vs,
+		TwoFactorService:    newTwoFactorService(db),
+		IdentityService:     newIdentityService(db, idp),
+		APIKeyService:       newAPIKeyService(db),
+		AccessControl:       newAccessControl(db),
+		AdminService:        newAdminService(db, ss),
+		MessengerService:    newMessengerService(db, b, vs),
+		ContactService:      newContactService(db, vs),
+		InviteService:       newInviteService(db, vs),
+		HealthService:       newHealthService(db, b),
+		RevocationList:      rl,
 	}
 }
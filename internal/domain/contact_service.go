@@ -28,16 +28,22 @@ type Contact struct {
 
 // ContactService contact management service
 type ContactService struct {
-	db *sql.DB
+	db           *sql.DB
+	verification *VerificationService
 }
 
-func newContactService(db *sql.DB) *ContactService {
-	return &ContactService{db: db}
+func newContactService(db *sql.DB, verification *VerificationService) *ContactService {
+	return &ContactService{db: db, verification: verification}
 }
 
 // Create add contact record to database
 func (cs *ContactService) Create(ctx context.Context, newContact *NewContact) (*Contact, error) {
 
+	e := cs.verification.Require(ctx, newContact.Owner, ActionAddContact)
+	if e != nil {
+		return nil, e
+	}
+
 	uid := uuid.New()
 
 	co, e := cs.db.Conn(ctx)
@@ -46,7 +52,7 @@ func (cs *ContactService) Create(ctx context.Context, newContact *NewContact) (*
 	}
 	defer func() { _ = co.Close() }()
 
-	c, e := repository.New(co).InsertContact(ctx, &repository.InsertContactParams{
+	c, e := newQueries(co).InsertContact(ctx, &repository.InsertContactParams{
 		Uuid:          uid.String(),
 		OriginUuid:    newContact.Owner.String(),
 		RecipientUuid: newContact.Recipient.String(),
@@ -67,7 +73,7 @@ func (cs *ContactService) Read(ctx context.Context, uid uuid.UUID) (*Contact, er
 	}
 	defer func() { _ = co.Close() }()
 
-	sc, e := repository.New(co).ReadContact(ctx, uid.String())
+	sc, e := newQueries(co).ReadContact(ctx, uid.String())
 	if e != nil {
 
 		if errors.Is(e, sql.ErrNoRows) {
@@ -89,7 +95,7 @@ func (cs *ContactService) List(ctx context.Context, uuid uuid.UUID) ([]*Contact,
 	}
 	defer func() { _ = co.Close() }()
 
-	scl, e := repository.New(co).ReadAllContacts(ctx, uuid.String())
+	scl, e := newQueries(co).ReadAllContacts(ctx, uuid.String())
 	if e != nil {
 
 		if errors.Is(e, sql.ErrNoRows) {
@@ -117,7 +123,7 @@ func (cs *ContactService) Search(ctx context.Context, search string) ([]*Contact
 	}
 	defer func() { _ = co.Close() }()
 
-	scs, e := repository.New(co).SearchContacts(ctx, &repository.SearchContactsParams{
+	scs, e := newQueries(co).SearchContacts(ctx, &repository.SearchContactsParams{
 		Usernm: "%" + strings.ToUpper(search) + "%",
 		Email:  "%" + strings.ToUpper(search) + "%",
 	})
@@ -152,7 +158,7 @@ func (cs *ContactService) Delete(ctx context.Context, uuid uuid.UUID) error {
 	}
 	defer func() { _ = co.Close() }()
 
-	r, e := repository.New(co).DeleteContact(ctx, uuid.String())
+	r, e := newQueries(co).DeleteContact(ctx, uuid.String())
 	if e != nil {
 		return fmt.Errorf("unable to delete contact %v", e)
 	}
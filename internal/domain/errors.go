@@ -11,4 +11,68 @@ var (
 	ErrEmptyResult = errors.New("empty result set")
 	// ErrMinRecipients less than two recipients provided for a new conversation
 	ErrMinRecipients = errors.New("invalid number recipients")
+	// ErrForbidden user lacks permission for requested resource
+	ErrForbidden = errors.New("insufficient permissions for resource")
+	// ErrInviteInvalid invite token is unknown, revoked, expired or exhausted
+	ErrInviteInvalid = errors.New("invite is no longer valid")
+	// ErrInvalidCursor pagination cursor could not be decoded
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	// ErrInvalidClientMessageID client message id exceeds the maximum length
+	ErrInvalidClientMessageID = errors.New("invalid client message id")
+	// ErrBrokerUnavailable broker did not respond before the deadline
+	ErrBrokerUnavailable = errors.New("broker unavailable")
+	// ErrSubscriberTooSlow subscriber fell too far behind and was dropped by the broker
+	ErrSubscriberTooSlow = errors.New("subscriber fell behind")
+	// ErrShuttingDown broker closed subscriptions because the server is shutting down
+	ErrShuttingDown = errors.New("server is shutting down")
+	// ErrInvalidRefreshToken refresh token is unknown or its session expired or was revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused refresh token was already exchanged, its session has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrInvalidCredentials provided password does not match the stored password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidPassword new password is too short or too long
+	ErrInvalidPassword = errors.New("password must be between 8 and 72 characters")
+	// ErrInvalidResetToken password reset token is unknown, used or expired
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+	// ErrEmailNotVerified action is restricted to users with a verified email
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrEmailAlreadyVerified verification was requested for a verified email
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+	// ErrInvalidVerificationToken email verification token is unknown, used, expired or for a previous email
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+	// ErrTooManyRequests request was throttled, retry later
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrTwoFactorEnabled enrollment was requested while two factor login is enabled
+	ErrTwoFactorEnabled = errors.New("two factor authentication is already enabled")
+	// ErrTwoFactorNotEnabled user has not enrolled or confirmed two factor login
+	ErrTwoFactorNotEnabled = errors.New("two factor authentication is not enabled")
+	// ErrInvalidTwoFactorCode totp or recovery code is wrong or was already used
+	ErrInvalidTwoFactorCode = errors.New("invalid two factor code")
+	// ErrLoginLocked account or client ip is locked after too many failed logins
+	ErrLoginLocked = errors.New("too many failed login attempts, try again later")
+	// ErrOIDCDisabled no identity provider is configured
+	ErrOIDCDisabled = errors.New("oidc login is not configured")
+	// ErrInvalidOIDCState oidc login state is unknown, used or expired
+	ErrInvalidOIDCState = errors.New("invalid oidc login state")
+	// ErrIdentityRejected identity provider denied the login or returned an invalid ID token
+	ErrIdentityRejected = errors.New("identity provider login failed")
+	// ErrIdentityNotLinked external identity has no user and may not be provisioned
+	ErrIdentityNotLinked = errors.New("external identity is not linked to a user")
+	// ErrIdentityLinked external identity is already linked to another user
+	ErrIdentityLinked = errors.New("external identity is linked to another user")
+	// ErrInvalidLoginChallenge login challenge is unknown, answered, expired or had too many attempts
+	ErrInvalidLoginChallenge = errors.New("invalid login challenge")
+	// ErrInvalidAPIKey api key is unknown, revoked or expired
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrInvalidAPIKeyRequest api key name, scopes or expiry are invalid
+	ErrInvalidAPIKeyRequest = errors.New("api key requires a name of at most 64 characters, read or write scopes and a future expiry")
+	// ErrTooManyAPIKeys user holds the maximum number of unrevoked api keys
+	ErrTooManyAPIKeys = errors.New("too many api keys")
+	// ErrAccountDisabled user was disabled by an administrator
+	ErrAccountDisabled = errors.New("account is disabled")
+	// ErrInvalidRole role is not admin, moderator or user
+	ErrInvalidRole = errors.New("role must be admin, moderator or user")
+	// ErrAdminExists an administrator already exists so no user is bootstrapped
+	ErrAdminExists = errors.New("an administrator already exists")
 )
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion latest migration version the binary was built against
+//
+// must be bumped with every new migration
+const SchemaVersion = 18
+
+// HealthService dependency health checks
+type HealthService struct {
+	db     *sql.DB
+	broker *Broker
+}
+
+func newHealthService(db *sql.DB, broker *Broker) *HealthService {
+	return &HealthService{db: db, broker: broker}
+}
+
+// PingDatabase verify the database connection is available
+func (hs *HealthService) PingDatabase(ctx context.Context) error {
+
+	e := hs.db.PingContext(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to ping database %v", e)
+	}
+
+	return nil
+}
+
+// PingBroker verify the broker is responsive
+func (hs *HealthService) PingBroker(ctx context.Context) error {
+	return hs.broker.ping(ctx)
+}
+
+// CheckSchemaVersion verify the applied migration version matches SchemaVersion
+//
+// fails when migrations are missing, ahead or left dirty
+func (hs *HealthService) CheckSchemaVersion(ctx context.Context) error {
+
+	var (
+		v     int64
+		dirty bool
+	)
+
+	// schema_migrations is maintained by the migrate task and not part of the sqlc schema
+	e := hs.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&v, &dirty)
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return errors.New("no migrations applied")
+		}
+
+		return fmt.Errorf("error executing read schema version query %v", e)
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", v)
+	} else if v != SchemaVersion {
+		return fmt.Errorf("schema version %d does not match expected version %d", v, SchemaVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,355 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	codes "modernc.org/sqlite/lib"
+
+	"github.com/trevatk/go-chat/internal/oidc"
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// OIDCLoginTTL time a user has to complete a login at the identity provider
+	OIDCLoginTTL = time.Minute * 10
+
+	// maxUsernameLength longest username derived from identity claims
+	maxUsernameLength = 32
+	// provisionAttempts usernames tried before provisioning fails
+	provisionAttempts = 5
+)
+
+// IdentityLogin outcome of an identity provider callback
+type IdentityLogin struct {
+	User uuid.UUID
+	// Linked identity was linked to an authenticated user, no session should be created
+	Linked bool
+	// Provisioned user was created for the identity
+	Provisioned bool
+}
+
+// IdentityService OpenID Connect login and identity linking service
+//
+// external identities sign in as the user they are linked to,
+// unknown identities are rejected unless provisioning is allowed
+type IdentityService struct {
+	db  *sql.DB
+	idp *oidc.Client
+
+	mu        sync.RWMutex
+	provision bool
+}
+
+func newIdentityService(db *sql.DB, idp *oidc.Client) *IdentityService {
+	return &IdentityService{db: db, idp: idp}
+}
+
+// AllowProvisioning create users for unknown identities with a verified email
+//
+// identities whose email belongs to an existing user are never linked automatically,
+// the user must link them while signed in
+func (is *IdentityService) AllowProvisioning(allow bool) {
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	is.provision = allow
+}
+
+// Login start login at the identity provider and return the authorization url
+func (is *IdentityService) Login(ctx context.Context) (string, error) {
+	return is.begin(ctx, sql.NullString{})
+}
+
+// Link start linking an identity to user and return the authorization url
+func (is *IdentityService) Link(ctx context.Context, user uuid.UUID) (string, error) {
+	return is.begin(ctx, sql.NullString{String: user.String(), Valid: true})
+}
+
+// begin persist state, nonce and PKCE verifier of a new authorization request
+func (is *IdentityService) begin(ctx context.Context, user sql.NullString) (string, error) {
+
+	if is.idp == nil {
+		return "", ErrOIDCDisabled
+	}
+
+	st, e := generateToken()
+	if e != nil {
+		return "", fmt.Errorf("unable to generate oidc state %v", e)
+	}
+
+	n, e := generateToken()
+	if e != nil {
+		return "", fmt.Errorf("unable to generate oidc nonce %v", e)
+	}
+
+	v, e := generateToken()
+	if e != nil {
+		return "", fmt.Errorf("unable to generate pkce verifier %v", e)
+	}
+
+	u, e := is.idp.AuthCodeURL(ctx, st, n, oidc.Challenge(v))
+	if e != nil {
+		return "", fmt.Errorf("unable to create authorization url %v", e)
+	}
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return "", fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	e = newQueries(co).InsertOIDCLogin(ctx, &repository.InsertOIDCLoginParams{
+		StateHash:    hashToken(st),
+		Nonce:        n,
+		CodeVerifier: v,
+		UserUuid:     user,
+		ExpiresAt:    time.Now().UTC().Add(OIDCLoginTTL),
+	})
+	if e != nil {
+		return "", fmt.Errorf("error executing insert oidc login query %v", e)
+	}
+
+	return u, nil
+}
+
+// Callback redeem authorization code of state and resolve the user of the identity
+//
+// the state is used up before the code is redeemed so a callback cannot be replayed
+func (is *IdentityService) Callback(ctx context.Context, state, code string) (*IdentityLogin, error) {
+
+	if is.idp == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	ol, e := is.useLogin(ctx, state)
+	if e != nil {
+		return nil, e
+	}
+
+	cl, e := is.idp.Exchange(ctx, code, ol.CodeVerifier, ol.Nonce)
+	if e != nil {
+		logging.FromContext(ctx).Warnf("identity provider login failed %v", e)
+		return nil, ErrIdentityRejected
+	}
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	id, e := q.ReadUserIdentity(ctx, &repository.ReadUserIdentityParams{
+		Issuer:  is.idp.Issuer(),
+		Subject: cl.Subject,
+	})
+	if e != nil && !errors.Is(e, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error executing read user identity query %v", e)
+	}
+
+	found := e == nil
+
+	var il *IdentityLogin
+
+	switch {
+	case ol.UserUuid.Valid && found:
+
+		if id.UserUuid != ol.UserUuid.String {
+			return nil, ErrIdentityLinked
+		}
+
+		return &IdentityLogin{User: uuid.MustParse(id.UserUuid), Linked: true}, nil
+
+	case ol.UserUuid.Valid:
+
+		e = is.insertIdentity(ctx, q, ol.UserUuid.String, cl)
+		if e != nil {
+			return nil, e
+		}
+
+		il = &IdentityLogin{User: uuid.MustParse(ol.UserUuid.String), Linked: true}
+
+	case found:
+		return &IdentityLogin{User: uuid.MustParse(id.UserUuid)}, nil
+
+	default:
+
+		uid, e := is.provisionUser(ctx, q, cl)
+		if e != nil {
+			return nil, e
+		}
+
+		e = is.insertIdentity(ctx, q, uid, cl)
+		if e != nil {
+			return nil, e
+		}
+
+		il = &IdentityLogin{User: uuid.MustParse(uid), Provisioned: true}
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return il, nil
+}
+
+// useLogin mark pending login of state used and return it
+func (is *IdentityService) useLogin(ctx context.Context, state string) (*repository.OidcLogin, error) {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	sh := hashToken(state)
+
+	r, e := q.UseOIDCLogin(ctx, sh)
+	if e != nil {
+		return nil, fmt.Errorf("error executing use oidc login query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	ol, e := q.ReadOIDCLogin(ctx, sh)
+	if e != nil {
+		return nil, fmt.Errorf("error executing read oidc login query %v", e)
+	}
+
+	if time.Now().After(ol.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	return ol, nil
+}
+
+func (is *IdentityService) insertIdentity(ctx context.Context, q *repository.Queries, user string, cl *oidc.Claims) error {
+
+	e := q.InsertUserIdentity(ctx, &repository.InsertUserIdentityParams{
+		Uuid:     uuid.NewString(),
+		UserUuid: user,
+		Issuer:   is.idp.Issuer(),
+		Subject:  cl.Subject,
+		Email:    cl.Email,
+	})
+	if e != nil {
+		return fmt.Errorf("error executing insert user identity query %v", e)
+	}
+
+	return nil
+}
+
+// provisionUser create verified user without a password for identity claims
+//
+// the username is derived from the claims and suffixed when taken
+func (is *IdentityService) provisionUser(ctx context.Context, q *repository.Queries, cl *oidc.Claims) (string, error) {
+
+	is.mu.RLock()
+	p := is.provision
+	is.mu.RUnlock()
+
+	if !p || cl.Email == "" || !cl.EmailVerified {
+		return "", ErrIdentityNotLinked
+	}
+
+	_, e := q.ReadUserByEmail(ctx, cl.Email)
+	if e == nil {
+		return "", ErrIdentityNotLinked
+	} else if !errors.Is(e, sql.ErrNoRows) {
+		return "", fmt.Errorf("error executing read user by email query %v", e)
+	}
+
+	base := identityUsername(cl)
+	un := base
+
+	for i := 0; i < provisionAttempts; i++ {
+
+		su, e := q.InsertUser(ctx, &repository.InsertUserParams{
+			Uuid:   uuid.NewString(),
+			Usernm: un,
+			Email:  cl.Email,
+			// no password until one is set with a password reset
+			Pssword: "",
+		})
+		if e != nil {
+
+			if se, ok := e.(*sqlite.Error); ok && se.Code() == codes.SQLITE_CONSTRAINT_UNIQUE {
+
+				b := make([]byte, 2)
+				if _, e := rand.Read(b); e != nil {
+					return "", fmt.Errorf("unable to generate username suffix %v", e)
+				}
+
+				un = base + "-" + hex.EncodeToString(b)
+				continue
+			}
+
+			return "", fmt.Errorf("error executing insert user query %v", e)
+		}
+
+		// the provider verified the email
+		_, e = q.VerifyUserEmail(ctx, &repository.VerifyUserEmailParams{
+			Uuid:  su.Uuid,
+			Email: su.Email,
+		})
+		if e != nil {
+			return "", fmt.Errorf("error executing verify user email query %v", e)
+		}
+
+		return su.Uuid, nil
+	}
+
+	return "", ErrUniqueExists
+}
+
+// identityUsername username from the preferred username or email of claims
+func identityUsername(cl *oidc.Claims) string {
+
+	s := cl.PreferredUsername
+	if s == "" {
+		s, _, _ = strings.Cut(cl.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+
+		if b.Len() >= maxUsernameLength {
+			break
+		}
+	}
+
+	if b.Len() < 1 {
+		return "user"
+	}
+
+	return b.String()
+}
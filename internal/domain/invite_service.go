@@ -0,0 +1,455 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+// NewInvite application layer new invite model
+type NewInvite struct {
+	ConversationUUID uuid.UUID
+	Creator          uuid.UUID
+	// MaxUses zero value allows unlimited uses
+	MaxUses int64
+	// ExpiresIn zero value never expires
+	ExpiresIn time.Duration
+}
+
+// Invite application layer invite model
+//
+// token is only populated when the invite is created
+// only the hashed token is persisted in the database
+type Invite struct {
+	UID              uuid.UUID
+	ConversationUUID uuid.UUID
+	Creator          uuid.UUID
+	Token            string
+	MaxUses          int64
+	Uses             int64
+	ExpiresAt        time.Time
+	RevokedAt        time.Time
+	CreatedAt        time.Time
+}
+
+// InvitePreview application layer invite preview model
+type InvitePreview struct {
+	ConversationUUID uuid.UUID
+	Members          int64
+	ExpiresAt        time.Time
+	CreatedAt        time.Time
+}
+
+// InviteRedemption application layer invite usage audit model
+type InviteRedemption struct {
+	UID       uuid.UUID
+	InviteUID uuid.UUID
+	User      uuid.UUID
+	CreatedAt time.Time
+}
+
+// InviteService conversation invite management service
+type InviteService struct {
+	db           *sql.DB
+	verification *VerificationService
+}
+
+func newInviteService(db *sql.DB, verification *VerificationService) *InviteService {
+	return &InviteService{db: db, verification: verification}
+}
+
+// Create add new invite for conversation
+//
+// only conversation admins are permitted to create invites
+func (is *InviteService) Create(ctx context.Context, newInvite *NewInvite) (*Invite, error) {
+
+	e := is.verification.Require(ctx, newInvite.Creator, ActionCreateInvite)
+	if e != nil {
+		return nil, e
+	}
+
+	uid := uuid.New()
+
+	t, e := generateToken()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate invite token %v", e)
+	}
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = requireConversationAdmin(ctx, q, newInvite.ConversationUUID, newInvite.Creator)
+	if e != nil {
+		return nil, e
+	}
+
+	mu := sql.NullInt64{}
+	if newInvite.MaxUses > 0 {
+		mu = sql.NullInt64{Int64: newInvite.MaxUses, Valid: true}
+	}
+
+	ea := sql.NullTime{}
+	if newInvite.ExpiresIn > 0 {
+		ea = sql.NullTime{Time: time.Now().UTC().Add(newInvite.ExpiresIn), Valid: true}
+	}
+
+	si, e := q.InsertInvite(ctx, &repository.InsertInviteParams{
+		Uuid:             uid.String(),
+		ConversationUuid: newInvite.ConversationUUID.String(),
+		CreatorUuid:      newInvite.Creator.String(),
+		TokenHash:        hashToken(t),
+		MaxUses:          mu,
+		ExpiresAt:        ea,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert invite query %v", e)
+	}
+
+	i := transformSQLInvite(si)
+	i.Token = t
+
+	return i, nil
+}
+
+// List retrieve all invites for conversation
+//
+// only conversation admins are permitted to list invites
+func (is *InviteService) List(ctx context.Context, conversationUUID, requester uuid.UUID) ([]*Invite, error) {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = requireConversationAdmin(ctx, q, conversationUUID, requester)
+	if e != nil {
+		return nil, e
+	}
+
+	sil, e := q.ReadAllInvites(ctx, conversationUUID.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all invites query %v", e)
+	}
+
+	il := make([]*Invite, 0, len(sil))
+
+	for _, si := range sil {
+		il = append(il, transformSQLInvite(si))
+	}
+
+	return il, nil
+}
+
+// Revoke revoke invite so it can no longer be redeemed
+//
+// only conversation admins are permitted to revoke invites
+func (is *InviteService) Revoke(ctx context.Context, conversationUUID, inviteUUID, requester uuid.UUID) error {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = requireConversationAdmin(ctx, q, conversationUUID, requester)
+	if e != nil {
+		return e
+	}
+
+	r, e := q.RevokeInvite(ctx, &repository.RevokeInviteParams{
+		Uuid:             inviteUUID.String(),
+		ConversationUuid: conversationUUID.String(),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing revoke invite query %v", e)
+	}
+
+	af, e := r.RowsAffected()
+	if e != nil {
+		return fmt.Errorf("failed to get rows affected %v", e)
+	}
+
+	if af < 1 {
+		return ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// ListRedemptions retrieve usage audit records for invite
+//
+// only conversation admins are permitted to audit invites of their conversation
+func (is *InviteService) ListRedemptions(ctx context.Context, conversationUUID, inviteUUID, requester uuid.UUID) ([]*InviteRedemption, error) {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = requireConversationAdmin(ctx, q, conversationUUID, requester)
+	if e != nil {
+		return nil, e
+	}
+
+	// invites of other conversations are reported as missing
+	_, e = q.ReadConversationInvite(ctx, &repository.ReadConversationInviteParams{
+		Uuid:             inviteUUID.String(),
+		ConversationUuid: conversationUUID.String(),
+	})
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error executing read conversation invite query %v", e)
+	}
+
+	srl, e := q.ReadAllInviteRedemptions(ctx, &repository.ReadAllInviteRedemptionsParams{
+		Uuid:             inviteUUID.String(),
+		ConversationUuid: conversationUUID.String(),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all invite redemptions query %v", e)
+	}
+
+	rl := make([]*InviteRedemption, 0, len(srl))
+
+	for _, sr := range srl {
+		rl = append(rl, &InviteRedemption{
+			UID:       uuid.MustParse(sr.Uuid),
+			InviteUID: uuid.MustParse(sr.InviteUuid),
+			User:      uuid.MustParse(sr.UserUuid),
+			CreatedAt: sr.CreatedAt,
+		})
+	}
+
+	return rl, nil
+}
+
+// Preview retrieve conversation summary by invite token
+func (is *InviteService) Preview(ctx context.Context, token string) (*InvitePreview, error) {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	si, e := readRedeemableInvite(ctx, q, token)
+	if e != nil {
+		return nil, e
+	}
+
+	sc, e := q.ReadConversation(ctx, si.ConversationUuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing read conversation query %v", e)
+	}
+
+	n, e := q.CountConversationMembers(ctx, si.ConversationUuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing count conversation members query %v", e)
+	}
+
+	i := transformSQLInvite(si)
+
+	return &InvitePreview{
+		ConversationUUID: i.ConversationUUID,
+		Members:          n,
+		ExpiresAt:        i.ExpiresAt,
+		CreatedAt:        sc.CreatedAt,
+	}, nil
+}
+
+// Redeem add user as conversation member using invite token
+//
+// redeeming an invite for a conversation the user
+// is already a member of does not consume a use
+func (is *InviteService) Redeem(ctx context.Context, token string, user uuid.UUID) (*Conversation, error) {
+
+	co, e := is.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	si, e := readInvite(ctx, q, token)
+	if e != nil {
+		return nil, e
+	}
+
+	sc, e := q.ReadConversation(ctx, si.ConversationUuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing read conversation query %v", e)
+	}
+
+	_, e = q.ReadConversationMember(ctx, &repository.ReadConversationMemberParams{
+		ConversationUuid: si.ConversationUuid,
+		UserUuid:         user.String(),
+	})
+	if e == nil {
+		return transformSQLConversation(sc), nil
+	} else if !errors.Is(e, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error executing read conversation member query %v", e)
+	}
+
+	e = validateInvite(si)
+	if e != nil {
+		return nil, e
+	}
+
+	r, e := q.IncrementInviteUses(ctx, si.Uuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing increment invite uses query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		// invite was revoked or exhausted by a concurrent redemption
+		return nil, ErrInviteInvalid
+	}
+
+	_, e = q.InsertMMConversationUser(ctx, &repository.InsertMMConversationUserParams{
+		Uuid:             uuid.New().String(),
+		ConversationUuid: si.ConversationUuid,
+		UserUuid:         user.String(),
+		UserRole:         MemberRoleMember,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("unable to add user to conversation %v", e)
+	}
+
+	_, e = q.InsertInviteRedemption(ctx, &repository.InsertInviteRedemptionParams{
+		Uuid:       uuid.New().String(),
+		InviteUuid: si.Uuid,
+		UserUuid:   user.String(),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("unable to audit invite redemption %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return transformSQLConversation(sc), nil
+}
+
+// readRedeemableInvite lookup invite by token and verify
+// it has not been revoked, expired or exhausted
+func readRedeemableInvite(ctx context.Context, q *repository.Queries, token string) (*repository.ConversationInvite, error) {
+
+	si, e := readInvite(ctx, q, token)
+	if e != nil {
+		return nil, e
+	}
+
+	e = validateInvite(si)
+	if e != nil {
+		return nil, e
+	}
+
+	return si, nil
+}
+
+func readInvite(ctx context.Context, q *repository.Queries, token string) (*repository.ConversationInvite, error) {
+
+	si, e := q.ReadInviteByTokenHash(ctx, hashToken(token))
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrInviteInvalid
+		}
+
+		return nil, fmt.Errorf("error executing read invite query %v", e)
+	}
+
+	return si, nil
+}
+
+func validateInvite(invite *repository.ConversationInvite) error {
+
+	if invite.RevokedAt.Valid {
+		return ErrInviteInvalid
+	} else if invite.ExpiresAt.Valid && time.Now().After(invite.ExpiresAt.Time) {
+		return ErrInviteInvalid
+	} else if invite.MaxUses.Valid && invite.Uses >= invite.MaxUses.Int64 {
+		return ErrInviteInvalid
+	}
+
+	return nil
+}
+
+func generateToken() (string, error) {
+
+	b := make([]byte, 32)
+
+	_, e := rand.Read(b)
+	if e != nil {
+		return "", e
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func transformSQLInvite(invite *repository.ConversationInvite) *Invite {
+
+	i := &Invite{
+		UID:              uuid.MustParse(invite.Uuid),
+		ConversationUUID: uuid.MustParse(invite.ConversationUuid),
+		Creator:          uuid.MustParse(invite.CreatorUuid),
+		Uses:             invite.Uses,
+		CreatedAt:        invite.CreatedAt,
+	}
+
+	if invite.MaxUses.Valid {
+		i.MaxUses = invite.MaxUses.Int64
+	}
+
+	if invite.ExpiresAt.Valid {
+		i.ExpiresAt = invite.ExpiresAt.Time
+	}
+
+	if invite.RevokedAt.Valid {
+		i.RevokedAt = invite.RevokedAt.Time
+	}
+
+	return i
+}
@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// accountLockoutThreshold failed logins of an account before it is locked
+	accountLockoutThreshold = 5
+	// ipLockoutThreshold failed logins from a client ip before it is locked
+	ipLockoutThreshold = 20
+	// lockoutBase lockout after reaching a threshold, doubled by every further failure
+	lockoutBase = time.Minute
+	// lockoutMax longest lockout
+	lockoutMax = time.Hour
+	// failureWindow failures are forgotten after no failed login for this long
+	failureWindow = time.Hour * 24
+
+	// auditLoginLocked account or client ip was locked after too many failed logins
+	auditLoginLocked = "login_locked"
+)
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// compareDummyPassword spend the time of a password comparison
+//
+// used for unknown users so response times do not reveal which accounts exist
+func compareDummyPassword(password string) {
+
+	dummyOnce.Do(func() {
+
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+
+		h, e := hashPassword(string(b))
+		if e == nil {
+			dummyHash = []byte(h)
+		}
+	})
+
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// loginThrottle failed login tracking of an account or client ip
+type loginThrottle struct {
+	key       string
+	threshold int64
+	// user set when the key belongs to a known account
+	user sql.NullString
+	// subject username or ip recorded on lockout
+	subject string
+}
+
+// loginThrottles throttles checked for a login attempt
+//
+// known accounts are tracked by uuid so username and email share their failures,
+// unknown usernames are tracked the same way to avoid revealing which accounts exist
+func loginThrottles(username, user, ip string) []*loginThrottle {
+
+	a := &loginThrottle{
+		key:       accountThrottleKey(strings.ToLower(username)),
+		threshold: accountLockoutThreshold,
+		subject:   username,
+	}
+
+	if user != "" {
+		a.key = accountThrottleKey(user)
+		a.user = sql.NullString{String: user, Valid: true}
+	}
+
+	tl := []*loginThrottle{a}
+
+	if ip != "" {
+		tl = append(tl, &loginThrottle{
+			key:       "ip:" + ip,
+			threshold: ipLockoutThreshold,
+			subject:   ip,
+		})
+	}
+
+	return tl
+}
+
+// accountThrottleKey throttle key of a user uuid or unknown username
+func accountThrottleKey(account string) string {
+	return "account:" + account
+}
+
+// lockedOut any throttle is locked at now
+func lockedOut(ctx context.Context, q *repository.Queries, throttles []*loginThrottle, now time.Time) (bool, error) {
+
+	for _, t := range throttles {
+
+		lt, e := q.ReadLoginThrottle(ctx, t.key)
+		if e != nil {
+
+			if errors.Is(e, sql.ErrNoRows) {
+				continue
+			}
+
+			return false, fmt.Errorf("error executing read login throttle query %v", e)
+		}
+
+		if lt.LockedUntil.Valid && now.Before(lt.LockedUntil.Time) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// recordLoginFailure count failed login against throttles, locking those reaching their threshold
+//
+// a lockout is audited when it starts
+func recordLoginFailure(ctx context.Context, q *repository.Queries, throttles []*loginThrottle, ip string, now time.Time) error {
+
+	for _, t := range throttles {
+
+		var f int64
+
+		lt, e := q.ReadLoginThrottle(ctx, t.key)
+		if e == nil && now.Sub(lt.UpdatedAt) < failureWindow {
+			f = lt.Failures
+		} else if e != nil && !errors.Is(e, sql.ErrNoRows) {
+			return fmt.Errorf("error executing read login throttle query %v", e)
+		}
+
+		f++
+
+		var lu sql.NullTime
+
+		if f >= t.threshold {
+			lu = sql.NullTime{Time: now.Add(lockoutDuration(f - t.threshold)), Valid: true}
+		}
+
+		e = q.UpsertLoginThrottle(ctx, &repository.UpsertLoginThrottleParams{
+			ThrottleKey: t.key,
+			Failures:    f,
+			LockedUntil: lu,
+			UpdatedAt:   now,
+		})
+		if e != nil {
+			return fmt.Errorf("error executing upsert login throttle query %v", e)
+		}
+
+		if !lu.Valid {
+			continue
+		}
+
+		e = q.InsertAuditEvent(ctx, &repository.InsertAuditEventParams{
+			Uuid:      uuid.NewString(),
+			Event:     auditLoginLocked,
+			UserUuid:  t.user,
+			Subject:   t.subject,
+			IpAddress: ip,
+		})
+		if e != nil {
+			return fmt.Errorf("error executing insert audit event query %v", e)
+		}
+
+		logging.FromContext(ctx).Warnf("%s locked until %s after %d failed logins", t.key, lu.Time.Format(time.RFC3339), f)
+	}
+
+	return nil
+}
+
+// lockoutDuration lockout after n failures beyond the threshold
+func lockoutDuration(n int64) time.Duration {
+
+	d := lockoutBase
+	for i := int64(0); i < n && d < lockoutMax; i++ {
+		d *= 2
+	}
+
+	if d > lockoutMax {
+		return lockoutMax
+	}
+
+	return d
+}
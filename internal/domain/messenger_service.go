@@ -3,23 +3,109 @@ package domain
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/trevatk/go-chat/internal/metrics"
 	"github.com/trevatk/go-chat/internal/repository"
 )
 
+const (
+	// MemberRoleAdmin conversation member permitted to manage the conversation
+	MemberRoleAdmin = "admin"
+	// MemberRoleMember default conversation member
+	MemberRoleMember = "member"
+
+	// DefaultConversationLimit conversations per page when no limit is requested
+	DefaultConversationLimit = 20
+	// MaxConversationLimit maximum conversations per page
+	MaxConversationLimit = 100
+
+	// DefaultMessageLimit messages per page when no limit is requested
+	DefaultMessageLimit = 50
+	// MaxMessageLimit maximum messages per page
+	MaxMessageLimit = 200
+	// MaxClientMessageIDLength maximum length of client supplied message ids
+	MaxClientMessageIDLength = 128
+
+	// activityKeyLayout sortable timestamp prefix of conversation activity keys
+	activityKeyLayout = "2006-01-02T15:04:05.000000"
+	// maxActivityKey sorts after every activity key and starts the first page
+	maxActivityKey = "9999"
+)
+
 // NewConversation application layer new conversation model
 type NewConversation struct {
+	// Creator is assigned the admin role and added to the
+	// conversation if not already included in recipients
+	Creator    uuid.UUID
 	Recipients []uuid.UUID
 }
 
+// ConversationFilter archive state filter when listing conversations
+type ConversationFilter int
+
+const (
+	// ConversationFilterUnarchived only list conversations that are not archived
+	ConversationFilterUnarchived ConversationFilter = iota
+	// ConversationFilterArchived only list archived conversations
+	ConversationFilterArchived
+	// ConversationFilterAll list conversations regardless of archive state
+	ConversationFilterAll
+)
+
 // Conversation application layer conversation model
+//
+// preference and unread fields are scoped to the member the conversation was listed for
 type Conversation struct {
-	UID       uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	UID         uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	MutedUntil  time.Time
+	Archived    bool
+	PinnedAt    time.Time
+	LastMessage *Envelope
+	UnreadCount int64
+	MemberCount int64
+	MemberNames []string
+}
+
+// ConversationQuery application layer conversation inbox query model
+type ConversationQuery struct {
+	User   uuid.UUID
+	Filter ConversationFilter
+	// Cursor opaque next cursor from previous page, empty for first page
+	Cursor string
+	Limit  int64
+}
+
+// ConversationPage application layer conversation inbox page model
+//
+// pinned conversations are only included in the first page
+type ConversationPage struct {
+	Conversations []*Conversation
+	// NextCursor empty when there are no more pages
+	NextCursor string
+}
+
+// ConversationPreferences application layer per member conversation preferences
+type ConversationPreferences struct {
+	ConversationUUID uuid.UUID
+	User             uuid.UUID
+	// MutedUntil zero value is not muted
+	MutedUntil time.Time
+	Archived   bool
+	Pinned     bool
+	PinnedAt   time.Time
+}
+
+// Muted conversation notifications are muted at time t
+func (cp *ConversationPreferences) Muted(t time.Time) bool {
+	return !cp.MutedUntil.IsZero() && t.Before(cp.MutedUntil)
 }
 
 // NewEnvelope application layer new envelope model
@@ -27,6 +113,9 @@ type NewEnvelope struct {
 	Sender           uuid.UUID
 	Message          string
 	ConversationUUID uuid.UUID
+	// ClientMessageID optional key unique per sender and conversation,
+	// retrying a send with the same key returns the original envelope
+	ClientMessageID string
 }
 
 // Envelope application layer envelope model
@@ -35,21 +124,48 @@ type Envelope struct {
 	Sender           uuid.UUID
 	Message          string
 	ConversationUUID uuid.UUID
-	CreatedAt        time.Time
+	// Seq strictly increasing per conversation without gaps,
+	// the first message in a conversation is 1
+	Seq             int64
+	ClientMessageID string
+	CreatedAt       time.Time
+	// Duplicate envelope was created by an earlier send with the same
+	// client message id, only set on envelopes returned by CreateMessage
+	Duplicate bool
+}
+
+// MessageQuery application layer conversation message query model
+type MessageQuery struct {
+	ConversationUUID uuid.UUID
+	Requester        uuid.UUID
+	// AfterSeq only list messages with a greater sequence number, zero for first page
+	AfterSeq int64
+	Limit    int64
 }
 
 // MessengerService messenger management service
 type MessengerService struct {
-	db *sql.DB
+	db           *sql.DB
+	broker       *Broker
+	verification *VerificationService
 }
 
-func newMessengerService(db *sql.DB) *MessengerService {
-	return &MessengerService{db: db}
+func newMessengerService(db *sql.DB, broker *Broker, verification *VerificationService) *MessengerService {
+	return &MessengerService{db: db, broker: broker, verification: verification}
 }
 
 // CreateConversation add new conversation to database
 func (ms *MessengerService) CreateConversation(ctx context.Context, newConversation *NewConversation) (*Conversation, error) {
 
+	if len(newConversation.Recipients) < 2 {
+		return nil, ErrMinRecipients
+	}
+
+	e := ms.verification.Require(ctx, newConversation.Creator, ActionCreateConversation)
+	if e != nil {
+		return nil, e
+	}
+
 	uid := uuid.New()
 
 	co, e := ms.db.Conn(ctx)
@@ -64,25 +180,35 @@ func (ms *MessengerService) CreateConversation(ctx context.Context, newConversat
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	if len(newConversation.Recipients) < 2 {
-		return nil, ErrMinRecipients
-	}
-
-	q := repository.New(co).WithTx(tx)
+	q := newQueries(tx)
 
-	c, e := q.InsertConversation(ctx, uid.String())
+	c, e := q.InsertConversation(ctx, &repository.InsertConversationParams{
+		Uuid:        uid.String(),
+		ActivityKey: newActivityKey(uid),
+	})
 	if e != nil {
 		return nil, fmt.Errorf("unable to add conversation to database %v", e)
 	}
 
-	for _, r := range newConversation.Recipients {
+	rs := newConversation.Recipients
+	if newConversation.Creator != uuid.Nil && !containsUUID(rs, newConversation.Creator) {
+		rs = append([]uuid.UUID{newConversation.Creator}, rs...)
+	}
+
+	for _, r := range rs {
 
 		cuID := uuid.New()
 
+		ro := MemberRoleMember
+		if r == newConversation.Creator {
+			ro = MemberRoleAdmin
+		}
+
 		r, e := q.InsertMMConversationUser(ctx, &repository.InsertMMConversationUserParams{
 			Uuid:             cuID.String(),
 			ConversationUuid: uid.String(),
 			UserUuid:         r.String(),
+			UserRole:         ro,
 		})
 		if e != nil {
 			return nil, fmt.Errorf("unable to add users to conversation %v", e)
@@ -101,8 +227,28 @@ func (ms *MessengerService) CreateConversation(ctx context.Context, newConversat
 	return transformSQLConversation(c), nil
 }
 
-// ListConversations retrieve all conversations by recipient uuid
-func (ms *MessengerService) ListConversations(ctx context.Context, uid uuid.UUID) ([]*Conversation, error) {
+// ListConversations retrieve page of conversations by recipient uuid
+//
+// pinned conversations are returned first followed by most recent activity
+func (ms *MessengerService) ListConversations(ctx context.Context, query *ConversationQuery) (*ConversationPage, error) {
+
+	ak := maxActivityKey
+	if query.Cursor != "" {
+
+		b, e := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if e != nil || len(b) == 0 {
+			return nil, ErrInvalidCursor
+		}
+
+		ak = string(b)
+	}
+
+	l := query.Limit
+	if l < 1 {
+		l = DefaultConversationLimit
+	} else if l > MaxConversationLimit {
+		l = MaxConversationLimit
+	}
 
 	co, e := ms.db.Conn(ctx)
 	if e != nil {
@@ -110,30 +256,184 @@ func (ms *MessengerService) ListConversations(ctx context.Context, uid uuid.UUID
 	}
 	defer func() { _ = co.Close() }()
 
-	scl, e := repository.New(co).ReadAllConversations(ctx, uid.String())
+	q := newQueries(co)
+
+	aMin := query.Filter == ConversationFilterArchived
+	aMax := query.Filter != ConversationFilterUnarchived
+
+	cl := make([]*Conversation, 0, l)
+
+	if query.Cursor == "" {
+
+		spl, e := q.ReadPinnedInbox(ctx, &repository.ReadPinnedInboxParams{
+			UserUuid:   query.User.String(),
+			Archived:   aMin,
+			Archived_2: aMax,
+		})
+		if e != nil {
+			return nil, fmt.Errorf("error executing read pinned inbox query %v", e)
+		}
+
+		for _, sp := range spl {
+			r := repository.ReadInboxRow(*sp)
+			cl = append(cl, transformReadInboxRow(&r))
+		}
+	}
+
+	// read one additional row to determine if another page exists
+	scl, e := q.ReadInbox(ctx, &repository.ReadInboxParams{
+		UserUuid:    query.User.String(),
+		Archived:    aMin,
+		Archived_2:  aMax,
+		ActivityKey: ak,
+		Limit:       l + 1,
+	})
 	if e != nil {
+		return nil, fmt.Errorf("error excuting read inbox query %v", e)
+	}
+
+	p := &ConversationPage{}
 
-		return nil, fmt.Errorf("error excuting read all conversations query %v", e)
+	if int64(len(scl)) > l {
+		scl = scl[:l]
+		p.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(scl[l-1].ActivityKey))
 	}
 
-	fmt.Println(scl)
+	for _, sc := range scl {
+		cl = append(cl, transformReadInboxRow(sc))
+	}
+
+	p.Conversations = cl
+
+	return p, nil
+}
 
-	if len(scl) == 0 {
-		return []*Conversation{}, ErrEmptyResult
+// MarkRead mark all current conversation messages as read for member
+//
+// the user must be a conversation member
+func (ms *MessengerService) MarkRead(ctx context.Context, conversationUUID, user uuid.UUID) error {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
 	}
+	defer func() { _ = co.Close() }()
 
-	cl := make([]*Conversation, 0, len(scl))
+	r, e := newQueries(co).UpdateLastRead(ctx, &repository.UpdateLastReadParams{
+		LastReadAt:       sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ConversationUuid: conversationUUID.String(),
+		UserUuid:         user.String(),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing update last read query %v", e)
+	}
 
-	for _, sc := range scl {
-		cl = append(cl, transformReadAllConversationsRow(sc))
+	af, e := r.RowsAffected()
+	if e != nil {
+		return fmt.Errorf("failed to get rows affected %v", e)
 	}
 
-	return cl, nil
+	// no membership row for user
+	if af < 1 {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// UpdatePreferences update per member conversation preferences
+//
+// the user must be a conversation member
+// pinning an already pinned conversation keeps its original position
+func (ms *MessengerService) UpdatePreferences(ctx context.Context, preferences *ConversationPreferences) (*ConversationPreferences, error) {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	m, e := requireConversationMember(ctx, q, preferences.ConversationUUID, preferences.User)
+	if e != nil {
+		return nil, e
+	}
+
+	mu := sql.NullTime{}
+	if !preferences.MutedUntil.IsZero() {
+		mu = sql.NullTime{Time: preferences.MutedUntil.UTC(), Valid: true}
+	}
+
+	pa := sql.NullTime{}
+	if preferences.Pinned {
+		pa = m.PinnedAt
+		if !pa.Valid {
+			pa = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+	}
+
+	sm, e := q.UpdateConversationPreferences(ctx, &repository.UpdateConversationPreferencesParams{
+		MutedUntil:       mu,
+		Archived:         preferences.Archived,
+		PinnedAt:         pa,
+		ConversationUuid: preferences.ConversationUUID.String(),
+		UserUuid:         preferences.User.String(),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing update conversation preferences query %v", e)
+	}
+
+	return transformSQLConversationMember(sm), nil
+}
+
+// readNotificationRecipients retrieve conversation members to notify of a new message
+//
+// the sender and members who have the conversation muted at time t are excluded
+func readNotificationRecipients(ctx context.Context, q *repository.Queries, conversationUUID, sender uuid.UUID, t time.Time) ([]uuid.UUID, error) {
+
+	sml, e := q.ReadAllConversationMembers(ctx, conversationUUID.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all conversation members query %v", e)
+	}
+
+	rl := make([]uuid.UUID, 0, len(sml))
+
+	for _, sm := range sml {
+
+		m := transformSQLConversationMember(sm)
+
+		if m.User == sender || m.Muted(t) {
+			continue
+		}
+
+		rl = append(rl, m.User)
+	}
+
+	return rl, nil
 }
 
 // CreateMessage add new envelope into database as message model
+//
+// the sender must be a conversation member, the conversation last activity
+// is updated in the same transaction and the envelope is published to subscribers,
+// members who muted the conversation only receive it through history.
+// subscribers of one instance receive envelopes in sequence order, clients
+// connected to several instances order envelopes by sequence number
+//
+// when the client message id was already used by the sender in the conversation
+// the original envelope is returned and nothing is inserted or published
 func (ms *MessengerService) CreateMessage(ctx context.Context, newEnvelope *NewEnvelope) (*Envelope, error) {
 
+	if len(newEnvelope.ClientMessageID) > MaxClientMessageIDLength {
+		return nil, ErrInvalidClientMessageID
+	}
+
+	e := ms.verification.Require(ctx, newEnvelope.Sender, ActionSendMessage)
+	if e != nil {
+		return nil, e
+	}
+
 	uid := uuid.New()
 
 	co, e := ms.db.Conn(ctx)
@@ -142,21 +442,117 @@ func (ms *MessengerService) CreateMessage(ctx context.Context, newEnvelope *NewE
 	}
 	defer func() { _ = co.Close() }()
 
-	m, e := repository.New(co).InsertMessage(ctx, &repository.InsertMessageParams{
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	_, e = requireConversationMember(ctx, q, newEnvelope.ConversationUUID, newEnvelope.Sender)
+	if e != nil {
+		return nil, e
+	}
+
+	// the increment and insert share the write transaction so a rolled
+	// back send never leaves a gap in the conversation sequence
+	seq, e := q.IncrementConversationSeq(ctx, newEnvelope.ConversationUUID.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error executing increment conversation seq query %v", e)
+	}
+
+	cmID := sql.NullString{}
+	if newEnvelope.ClientMessageID != "" {
+
+		cmID = sql.NullString{String: newEnvelope.ClientMessageID, Valid: true}
+
+		// checked after the increment so the write lock is already held
+		// and a concurrent retry cannot insert between the read and insert,
+		// returning rolls back the reserved sequence number
+		sm, e := q.ReadMessageByClientID(ctx, &repository.ReadMessageByClientIDParams{
+			ConversationUuid: newEnvelope.ConversationUUID.String(),
+			Sender:           newEnvelope.Sender.String(),
+			ClientMessageID:  cmID,
+		})
+		if e == nil {
+			ev := transformSQLMessage(sm)
+			ev.Duplicate = true
+			return ev, nil
+		} else if !errors.Is(e, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error executing read message by client id query %v", e)
+		}
+	}
+
+	m, e := q.InsertMessage(ctx, &repository.InsertMessageParams{
 		Uuid:             uid.String(),
 		ConversationUuid: newEnvelope.ConversationUUID.String(),
 		Sender:           newEnvelope.Sender.String(),
 		Body:             newEnvelope.Message,
+		CreatedAt:        time.Now().UTC(),
+		Seq:              seq,
+		ClientMessageID:  cmID,
 	})
 	if e != nil {
 		return nil, fmt.Errorf("error executing insert message query %v", e)
 	}
 
-	return transformSQLMessage(m), nil
+	r, e := q.UpdateConversationActivity(ctx, &repository.UpdateConversationActivityParams{
+		LastMessageUuid: sql.NullString{String: m.Uuid, Valid: true},
+		ActivityKey:     newActivityKey(newEnvelope.ConversationUUID),
+		Uuid:            newEnvelope.ConversationUUID.String(),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing update conversation activity query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrResourceNotFound
+	}
+
+	rl, e := readNotificationRecipients(ctx, q, newEnvelope.ConversationUUID, newEnvelope.Sender, m.CreatedAt)
+	if e != nil {
+		return nil, e
+	}
+
+	// held until published so a later sequence number is never delivered first
+	unlock := ms.broker.lockConversation(newEnvelope.ConversationUUID)
+	defer unlock()
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	metrics.MessagesSent.Inc()
+
+	// the sender is always delivered their own envelope so their other streams stay in sync
+	ev := transformSQLMessage(m)
+	ms.broker.publish(ev, append(rl, newEnvelope.Sender))
+
+	return ev, nil
 }
 
-// ListMessages retrive all messages by conversation uuid
-func (ms *MessengerService) ListMessages(ctx context.Context, conversationUUID uuid.UUID) ([]*Envelope, error) {
+// ListMessages retrieve page of conversation messages in sequence order
+//
+// the requester must be a conversation member
+func (ms *MessengerService) ListMessages(ctx context.Context, query *MessageQuery) ([]*Envelope, error) {
+
+	if query.AfterSeq < 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	l := query.Limit
+	if l < 1 {
+		l = DefaultMessageLimit
+	} else if l > MaxMessageLimit {
+		l = MaxMessageLimit
+	}
 
 	co, e := ms.db.Conn(ctx)
 	if e != nil {
@@ -164,9 +560,20 @@ func (ms *MessengerService) ListMessages(ctx context.Context, conversationUUID u
 	}
 	defer func() { _ = co.Close() }()
 
-	sml, e := repository.New(co).ReadAllMessages(ctx, conversationUUID.String())
+	q := newQueries(co)
+
+	_, e = requireConversationMember(ctx, q, query.ConversationUUID, query.Requester)
 	if e != nil {
-		return nil, fmt.Errorf("error executing read all messages %v", e)
+		return nil, e
+	}
+
+	sml, e := q.ReadMessagesAfterSeq(ctx, &repository.ReadMessagesAfterSeqParams{
+		ConversationUuid: query.ConversationUUID.String(),
+		Seq:              query.AfterSeq,
+		Limit:            l,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing read messages after seq query %v", e)
 	}
 
 	el := make([]*Envelope, 0, len(sml))
@@ -178,6 +585,258 @@ func (ms *MessengerService) ListMessages(ctx context.Context, conversationUUID u
 	return el, nil
 }
 
+// MessageSeq retrieve sequence number of envelope
+//
+// the requester must be a conversation member, an envelope outside the
+// conversation returns ErrInvalidCursor
+func (ms *MessengerService) MessageSeq(ctx context.Context, conversationUUID, requester, envelopeUID uuid.UUID) (int64, error) {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return 0, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	_, e = requireConversationMember(ctx, q, conversationUUID, requester)
+	if e != nil {
+		return 0, e
+	}
+
+	sm, e := q.ReadMessage(ctx, &repository.ReadMessageParams{
+		Uuid:             envelopeUID.String(),
+		ConversationUuid: conversationUUID.String(),
+	})
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return 0, ErrInvalidCursor
+		}
+
+		return 0, fmt.Errorf("error executing read message query %v", e)
+	}
+
+	return sm.Seq, nil
+}
+
+// Subscribe receive new envelopes published to conversation
+//
+// the user must be a conversation member, callers must close the subscription
+func (ms *MessengerService) Subscribe(ctx context.Context, conversationUUID, user uuid.UUID) (*Subscription, error) {
+
+	e := ms.RequireMember(ctx, conversationUUID, user)
+	if e != nil {
+		return nil, e
+	}
+
+	return ms.broker.subscribe(conversationUUID, user), nil
+}
+
+// Shutdown close every subscription so streaming clients are notified
+// the server is going away, new subscriptions are closed immediately
+func (ms *MessengerService) Shutdown() {
+	ms.broker.shutdown()
+}
+
+// SubscriberCounts number of active conversation and user subscriptions
+func (ms *MessengerService) SubscriberCounts() (int, int) {
+	return ms.broker.counts()
+}
+
+// SubscribeUser receive new envelopes and events for every conversation the user is a member of
+//
+// callers must close the subscription
+func (ms *MessengerService) SubscribeUser(user uuid.UUID) *Subscription {
+	return ms.broker.subscribeUser(user)
+}
+
+// SendTyping notify conversation members the user started or stopped typing
+//
+// the user must be a conversation member
+func (ms *MessengerService) SendTyping(ctx context.Context, conversationUUID, user uuid.UUID, typing bool) error {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	_, e = requireConversationMember(ctx, q, conversationUUID, user)
+	if e != nil {
+		return e
+	}
+
+	ml, e := readMemberUUIDs(ctx, q, conversationUUID)
+	if e != nil {
+		return e
+	}
+
+	t := EventTypingStopped
+	if typing {
+		t = EventTypingStarted
+	}
+
+	ms.broker.publishEvent(&Event{
+		Type:             t,
+		ConversationUUID: conversationUUID,
+		User:             user,
+		CreatedAt:        time.Now().UTC(),
+	}, excludeUUID(ml, user))
+
+	return nil
+}
+
+// Acknowledge mark conversation read up to envelope and notify conversation members
+//
+// the user must be a conversation member, the read position only moves forward
+// so acknowledging an older envelope leaves later messages unread,
+// an envelope outside the conversation returns ErrInvalidCursor
+func (ms *MessengerService) Acknowledge(ctx context.Context, conversationUUID, user, envelopeUID uuid.UUID) error {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	_, e = requireConversationMember(ctx, q, conversationUUID, user)
+	if e != nil {
+		return e
+	}
+
+	_, e = q.ReadMessage(ctx, &repository.ReadMessageParams{
+		Uuid:             envelopeUID.String(),
+		ConversationUuid: conversationUUID.String(),
+	})
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrInvalidCursor
+		}
+
+		return fmt.Errorf("error executing read message query %v", e)
+	}
+
+	_, e = q.AdvanceLastRead(ctx, &repository.AdvanceLastReadParams{
+		LastReadAt:       sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		Uuid:             envelopeUID.String(),
+		ConversationUuid: conversationUUID.String(),
+		UserUuid:         user.String(),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing advance last read query %v", e)
+	}
+
+	ml, e := readMemberUUIDs(ctx, q, conversationUUID)
+	if e != nil {
+		return e
+	}
+
+	ms.broker.publishEvent(&Event{
+		Type:             EventAcknowledged,
+		ConversationUUID: conversationUUID,
+		User:             user,
+		EnvelopeUID:      envelopeUID,
+		CreatedAt:        time.Now().UTC(),
+	}, excludeUUID(ml, user))
+
+	return nil
+}
+
+// RequireMember verify user is a conversation member
+//
+// non members receive ErrForbidden regardless of whether the conversation exists
+func (ms *MessengerService) RequireMember(ctx context.Context, conversationUUID, user uuid.UUID) error {
+
+	co, e := ms.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	_, e = requireConversationMember(ctx, newQueries(co), conversationUUID, user)
+
+	return e
+}
+
+// requireConversationMember verify user is a member of conversation
+func requireConversationMember(ctx context.Context, q *repository.Queries, conversationUUID, user uuid.UUID) (*repository.MmConversationsUser, error) {
+
+	m, e := q.ReadConversationMember(ctx, &repository.ReadConversationMemberParams{
+		ConversationUuid: conversationUUID.String(),
+		UserUuid:         user.String(),
+	})
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrForbidden
+		}
+
+		return nil, fmt.Errorf("error executing read conversation member query %v", e)
+	}
+
+	return m, nil
+}
+
+// readMemberUUIDs retrieve user uuids of all conversation members
+func readMemberUUIDs(ctx context.Context, q *repository.Queries, conversationUUID uuid.UUID) ([]uuid.UUID, error) {
+
+	sml, e := q.ReadAllConversationMembers(ctx, conversationUUID.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all conversation members query %v", e)
+	}
+
+	ml := make([]uuid.UUID, 0, len(sml))
+
+	for _, sm := range sml {
+		ml = append(ml, uuid.MustParse(sm.UserUuid))
+	}
+
+	return ml, nil
+}
+
+// requireConversationAdmin verify user is an admin member of conversation
+func requireConversationAdmin(ctx context.Context, q *repository.Queries, conversationUUID, user uuid.UUID) error {
+
+	m, e := requireConversationMember(ctx, q, conversationUUID, user)
+	if e != nil {
+		return e
+	}
+
+	if m.UserRole != MemberRoleAdmin {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func excludeUUID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+
+	l := make([]uuid.UUID, 0, len(ids))
+
+	for _, i := range ids {
+		if i != id {
+			l = append(l, i)
+		}
+	}
+
+	return l
+}
+
 func transformSQLConversation(conv *repository.Conversation) *Conversation {
 
 	u := time.Time{}
@@ -193,19 +852,78 @@ func transformSQLConversation(conv *repository.Conversation) *Conversation {
 	}
 }
 
-func transformReadAllConversationsRow(row *repository.ReadAllConversationsRow) *Conversation {
+func transformReadInboxRow(row *repository.ReadInboxRow) *Conversation {
 
 	cID := uuid.MustParse(row.Uuid)
 
-	u := time.Time{}
+	c := &Conversation{
+		UID:         cID,
+		CreatedAt:   row.CreatedAt,
+		Archived:    row.Archived,
+		UnreadCount: row.UnreadCount,
+		MemberCount: row.MemberCount,
+		MemberNames: splitMemberNames(row.MemberNames),
+	}
+
 	if row.UpdatedAt.Valid {
-		u = row.UpdatedAt.Time
+		c.UpdatedAt = row.UpdatedAt.Time
 	}
 
-	return &Conversation{
-		UID:       cID,
-		UpdatedAt: u,
+	if row.MutedUntil.Valid {
+		c.MutedUntil = row.MutedUntil.Time
+	}
+
+	if row.PinnedAt.Valid {
+		c.PinnedAt = row.PinnedAt.Time
+	}
+
+	// updated at is maintained in the same transaction as the latest message
+	if row.LastMessageUuid != "" {
+		c.LastMessage = &Envelope{
+			UID:              uuid.MustParse(row.LastMessageUuid),
+			Sender:           uuid.MustParse(row.LastMessageSender),
+			Message:          row.LastMessageBody,
+			ConversationUUID: cID,
+			CreatedAt:        c.UpdatedAt,
+		}
+	}
+
+	return c
+}
+
+// splitMemberNames member usernames of comma separated list, an empty list has no members
+func splitMemberNames(names string) []string {
+
+	if names == "" {
+		return []string{}
 	}
+
+	return strings.Split(names, ",")
+}
+
+// newActivityKey generate sortable unique key marking latest conversation activity
+func newActivityKey(conversationUUID uuid.UUID) string {
+	return time.Now().UTC().Format(activityKeyLayout) + conversationUUID.String()
+}
+
+func transformSQLConversationMember(member *repository.MmConversationsUser) *ConversationPreferences {
+
+	cp := &ConversationPreferences{
+		ConversationUUID: uuid.MustParse(member.ConversationUuid),
+		User:             uuid.MustParse(member.UserUuid),
+		Archived:         member.Archived,
+		Pinned:           member.PinnedAt.Valid,
+	}
+
+	if member.MutedUntil.Valid {
+		cp.MutedUntil = member.MutedUntil.Time
+	}
+
+	if member.PinnedAt.Valid {
+		cp.PinnedAt = member.PinnedAt.Time
+	}
+
+	return cp
 }
 
 func transformSQLMessage(message *repository.Message) *Envelope {
@@ -214,6 +932,8 @@ func transformSQLMessage(message *repository.Message) *Envelope {
 		Sender:           uuid.MustParse(message.Sender),
 		Message:          message.Body,
 		ConversationUUID: uuid.MustParse(message.ConversationUuid),
+		Seq:              message.Seq,
+		ClientMessageID:  message.ClientMessageID.String,
 		CreatedAt:        message.CreatedAt,
 	}
 }
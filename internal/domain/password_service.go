@@ -0,0 +1,359 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/trevatk/go-chat/internal/mail"
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// PasswordResetTTL lifetime of an emailed password reset token
+	PasswordResetTTL = time.Hour
+
+	// minPasswordLength shortest password accepted when changing or resetting
+	minPasswordLength = 8
+	// maxPasswordLength bcrypt ignores anything past 72 bytes
+	maxPasswordLength = 72
+
+	// passwordResetMaxPerHour password reset requests an hour for an email
+	passwordResetMaxPerHour = 3
+	// passwordResetIPMaxPerHour password reset requests an hour from a client ip
+	passwordResetIPMaxPerHour = 20
+	// passwordResetSendTimeout longest time spent delivering a password reset email
+	passwordResetSendTimeout = time.Minute
+)
+
+// PasswordService password change and reset service
+type PasswordService struct {
+	db       *sql.DB
+	sessions *SessionService
+	mailer   mail.Sender
+}
+
+func newPasswordService(db *sql.DB, sessions *SessionService, mailer mail.Sender) *PasswordService {
+	return &PasswordService{db: db, sessions: sessions, mailer: mailer}
+}
+
+// Change replace password of user after verifying the current password
+//
+// every other session of the user is ended, the session
+// the change was requested from stays signed in
+func (ps *PasswordService) Change(ctx context.Context, user, session uuid.UUID, current, password string) error {
+
+	e := validatePassword(password)
+	if e != nil {
+		return e
+	}
+
+	h, e := hashPassword(password)
+	if e != nil {
+		return e
+	}
+
+	co, e := ps.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	su, e := q.ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrResourceNotFound
+		}
+
+		return fmt.Errorf("error executing read user query %v", e)
+	}
+
+	e = bcrypt.CompareHashAndPassword([]byte(su.Pssword), []byte(current))
+	if e != nil {
+		return ErrInvalidCredentials
+	}
+
+	commit, e := ps.setPassword(ctx, q, su.Uuid, h, session.String())
+	if e != nil {
+		return e
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	commit()
+
+	return nil
+}
+
+// RequestReset email a single use password reset token to the owner of email
+//
+// unknown emails are not reported so the endpoint cannot be used to discover accounts,
+// the email is sent in the background so known and unknown emails get the same response.
+// requests are limited per email and client ip whether or not the email belongs to a user
+func (ps *PasswordService) RequestReset(ctx context.Context, email, ip string) error {
+
+	co, e := ps.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	e = throttlePasswordReset(ctx, q, passwordResetThrottles(email, ip), time.Now().UTC())
+	if e != nil {
+		return e
+	}
+
+	su, e := q.ReadUserByEmail(ctx, email)
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil
+		}
+
+		return fmt.Errorf("error executing read user by email query %v", e)
+	}
+
+	// bots have no password
+	if UserType(su.UserType) == UserTypeBot {
+		return nil
+	}
+
+	t, e := generateToken()
+	if e != nil {
+		return fmt.Errorf("unable to generate password reset token %v", e)
+	}
+
+	e = q.InsertPasswordReset(ctx, &repository.InsertPasswordResetParams{
+		TokenHash: hashToken(t),
+		UserUuid:  su.Uuid,
+		ExpiresAt: time.Now().UTC().Add(PasswordResetTTL),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing insert password reset query %v", e)
+	}
+
+	go ps.sendReset(logging.WithLogger(context.Background(), logging.FromContext(ctx)), &mail.Message{
+		To:      su.Email,
+		Subject: "Reset your go-chat password",
+		Body: fmt.Sprintf(
+			"Hi %s,\r\n\r\nuse the token below to reset your password, it expires in %s.\r\n\r\n%s\r\n\r\n"+
+				"If you did not request a password reset you can ignore this email.\r\n",
+			su.Usernm, PasswordResetTTL, t,
+		),
+	})
+
+	return nil
+}
+
+// sendReset deliver password reset email outside of the request
+//
+// failures are only logged, the user can request another email
+func (ps *PasswordService) sendReset(ctx context.Context, m *mail.Message) {
+
+	ctx, cancel := context.WithTimeout(ctx, passwordResetSendTimeout)
+	defer cancel()
+
+	e := ps.mailer.Send(ctx, m)
+	if e != nil {
+		logging.FromContext(ctx).Errorf("failed to send password reset email %v", e)
+	}
+}
+
+// passwordResetThrottle password reset requests allowed an hour for key
+type passwordResetThrottle struct {
+	key   string
+	limit int64
+}
+
+// passwordResetThrottles throttles checked for a password reset request
+//
+// the requested email is tracked rather than the user so unknown emails are throttled the same way
+func passwordResetThrottles(email, ip string) []*passwordResetThrottle {
+
+	tl := []*passwordResetThrottle{{
+		key:   "email:" + strings.ToLower(email),
+		limit: passwordResetMaxPerHour,
+	}}
+
+	if ip != "" {
+		tl = append(tl, &passwordResetThrottle{
+			key:   "ip:" + ip,
+			limit: passwordResetIPMaxPerHour,
+		})
+	}
+
+	return tl
+}
+
+// throttlePasswordReset record password reset request against throttles
+//
+// ErrTooManyRequests is returned without recording when any throttle reached its limit within the hour
+func throttlePasswordReset(ctx context.Context, q *repository.Queries, throttles []*passwordResetThrottle, now time.Time) error {
+
+	e := q.DeletePasswordResetRequestsBefore(ctx, now.Add(-time.Hour))
+	if e != nil {
+		return fmt.Errorf("error executing delete password reset requests query %v", e)
+	}
+
+	for _, t := range throttles {
+
+		sl, e := q.ReadRecentPasswordResetRequests(ctx, &repository.ReadRecentPasswordResetRequestsParams{
+			ThrottleKey: t.key,
+			Limit:       t.limit,
+		})
+		if e != nil {
+			return fmt.Errorf("error executing read recent password reset requests query %v", e)
+		}
+
+		if int64(len(sl)) >= t.limit && now.Sub(sl[len(sl)-1]) < time.Hour {
+			return ErrTooManyRequests
+		}
+	}
+
+	for _, t := range throttles {
+
+		e = q.InsertPasswordResetRequest(ctx, t.key)
+		if e != nil {
+			return fmt.Errorf("error executing insert password reset request query %v", e)
+		}
+	}
+
+	return nil
+}
+
+// Reset replace password of user with a password reset token
+//
+// the token and any other outstanding tokens of the user are used up
+// and every session of the user is ended
+func (ps *PasswordService) Reset(ctx context.Context, token, password string) error {
+
+	e := validatePassword(password)
+	if e != nil {
+		return e
+	}
+
+	h, e := hashPassword(password)
+	if e != nil {
+		return e
+	}
+
+	co, e := ps.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	th := hashToken(token)
+
+	r, e := q.UsePasswordReset(ctx, th)
+	if e != nil {
+		return fmt.Errorf("error executing use password reset query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrInvalidResetToken
+	}
+
+	pr, e := q.ReadPasswordReset(ctx, th)
+	if e != nil {
+		return fmt.Errorf("error executing read password reset query %v", e)
+	}
+
+	if time.Now().After(pr.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	commit, e := ps.setPassword(ctx, q, pr.UserUuid, h, "")
+	if e != nil {
+		return e
+	}
+
+	e = q.UseAllUserPasswordResets(ctx, pr.UserUuid)
+	if e != nil {
+		return fmt.Errorf("error executing use all user password resets query %v", e)
+	}
+
+	// proving control of the email ends an account lockout
+	e = q.DeleteLoginThrottle(ctx, accountThrottleKey(pr.UserUuid))
+	if e != nil {
+		return fmt.Errorf("error executing delete login throttle query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	commit()
+
+	return nil
+}
+
+// setPassword persist hashed password and end sessions of user except keep
+//
+// the returned func adds revoked sessions to memory once the transaction is committed
+func (ps *PasswordService) setPassword(ctx context.Context, q *repository.Queries, user, hashed, keep string) (func(), error) {
+
+	r, e := q.UpdateUserPassword(ctx, &repository.UpdateUserPasswordParams{
+		Pssword: hashed,
+		Uuid:    user,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing update user password query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrResourceNotFound
+	}
+
+	return ps.sessions.revokeAllSessions(ctx, q, user, keep)
+}
+
+// validatePassword password length is within bcrypt limits
+func validatePassword(password string) error {
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+
+	p, e := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost+bcrypt.MinCost)
+	if e != nil {
+		return "", fmt.Errorf("failed to generated hashed password %v", e)
+	}
+
+	return string(p), nil
+}
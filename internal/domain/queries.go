@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-chat/internal/tracing"
+)
+
+// newQueries repository queries on a connection or transaction
+//
+// used in place of repository.New and Queries.WithTx so every query records a span
+func newQueries(db repository.DBTX) *repository.Queries {
+	return repository.New(tracing.WrapDB(db))
+}
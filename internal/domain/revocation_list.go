@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// AccessTokenTTL lifetime of access tokens, clients use a refresh token for a new one
+	AccessTokenTTL = time.Minute * 15
+
+	// revocationPruneInterval time between removing expired revocation list entries
+	revocationPruneInterval = time.Minute * 5
+
+	revocationKeyToken   = "token:"
+	revocationKeySession = "session:"
+)
+
+// RevocationList access tokens and sessions revoked before their tokens expire
+//
+// entries are persisted so revocations survive restarts and kept in memory
+// so every authenticated request is checked without a query
+type RevocationList struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	entries map[string]time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newRevocationList(db *sql.DB) *RevocationList {
+	return &RevocationList{
+		db:      db,
+		entries: make(map[string]time.Time),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start load persisted entries and prune expired entries on an interval until shutdown
+func (rl *RevocationList) Start(ctx context.Context) error {
+
+	sl, e := newQueries(rl.db).ReadAllRevocations(ctx, time.Now().Unix())
+	if e != nil {
+		return fmt.Errorf("error executing read all revocations query %v", e)
+	}
+
+	rl.mu.Lock()
+	for _, s := range sl {
+		rl.entries[s.RevocationKey] = time.Unix(s.ExpiresAt, 0)
+	}
+	rl.mu.Unlock()
+
+	go func() {
+
+		t := time.NewTicker(revocationPruneInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-rl.stop:
+				return
+			case <-t.C:
+				if e := rl.Prune(ctx); e != nil {
+					logging.FromContext(ctx).Errorf("failed to prune revocation list %v", e)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stop pruning expired entries
+func (rl *RevocationList) Shutdown() {
+	rl.once.Do(func() { close(rl.stop) })
+}
+
+// IsRevoked report whether the access token or its session has been revoked
+func (rl *RevocationList) IsRevoked(tokenID, sessionID string) bool {
+
+	now := time.Now()
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	for _, k := range []string{revocationKeyToken + tokenID, revocationKeySession + sessionID} {
+		if exp, ok := rl.entries[k]; ok && now.Before(exp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prune remove entries once every access token they cover has expired
+func (rl *RevocationList) Prune(ctx context.Context) error {
+
+	now := time.Now()
+
+	_, e := newQueries(rl.db).DeleteExpiredRevocations(ctx, now.Unix())
+	if e != nil {
+		return fmt.Errorf("error executing delete expired revocations query %v", e)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for k, exp := range rl.entries {
+		if !now.Before(exp) {
+			delete(rl.entries, k)
+		}
+	}
+
+	return nil
+}
+
+// revokeSessions persist revocation of every access token issued within sessions
+//
+// entries are only added to memory by commit once the transaction is committed
+func (rl *RevocationList) revokeSessions(ctx context.Context, q *repository.Queries, sessionIDs ...string) (func(), error) {
+
+	keys := make([]string, 0, len(sessionIDs))
+	for _, sid := range sessionIDs {
+		keys = append(keys, revocationKeySession+sid)
+	}
+
+	return rl.revoke(ctx, q, keys...)
+}
+
+// revokeToken persist revocation of a single access token
+func (rl *RevocationList) revokeToken(ctx context.Context, q *repository.Queries, tokenID string) (func(), error) {
+	return rl.revoke(ctx, q, revocationKeyToken+tokenID)
+}
+
+func (rl *RevocationList) revoke(ctx context.Context, q *repository.Queries, keys ...string) (func(), error) {
+
+	// tokens issued up until now expire within the access token lifetime
+	exp := time.Now().Add(AccessTokenTTL).Truncate(time.Second).Add(time.Second)
+
+	for _, k := range keys {
+
+		e := q.InsertRevocation(ctx, &repository.InsertRevocationParams{
+			RevocationKey: k,
+			ExpiresAt:     exp.Unix(),
+		})
+		if e != nil {
+			return nil, fmt.Errorf("error executing insert revocation query %v", e)
+		}
+	}
+
+	return func() {
+
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+
+		for _, k := range keys {
+			rl.entries[k] = exp
+		}
+	}, nil
+}
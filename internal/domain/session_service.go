@@ -0,0 +1,392 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+const (
+	// SessionTTL lifetime of a session, refreshing does not extend it
+	SessionTTL = time.Hour * 24 * 30
+
+	// maxUserAgentLength user agents are truncated to the column size
+	maxUserAgentLength = 256
+)
+
+// NewSession application layer new session model
+type NewSession struct {
+	User      uuid.UUID
+	UserAgent string
+	IPAddress string
+}
+
+// Session application layer session model
+//
+// refresh token is only populated when the session is created or refreshed
+// only the hashed token is persisted in the database
+type Session struct {
+	UID          uuid.UUID
+	User         uuid.UUID
+	UserAgent    string
+	IPAddress    string
+	RefreshToken string
+	ExpiresAt    time.Time
+	LastUsedAt   time.Time
+	CreatedAt    time.Time
+}
+
+// SessionService login session and refresh token management service
+type SessionService struct {
+	db      *sql.DB
+	revoked *RevocationList
+}
+
+func newSessionService(db *sql.DB, revoked *RevocationList) *SessionService {
+	return &SessionService{db: db, revoked: revoked}
+}
+
+// Create start new session for an authenticated user and issue its first refresh token
+func (ss *SessionService) Create(ctx context.Context, newSession *NewSession) (*Session, error) {
+
+	uid := uuid.New()
+
+	t, e := generateToken()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate refresh token %v", e)
+	}
+
+	co, e := ss.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	// every sign in path ends here, disabled users may never start a session
+	su, e := q.ReadUser(ctx, newSession.User.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing read user query %v", e)
+	} else if su.DisabledAt.Valid {
+		return nil, ErrAccountDisabled
+	}
+
+	ua := newSession.UserAgent
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+
+	s, e := q.InsertSession(ctx, &repository.InsertSessionParams{
+		Uuid:      uid.String(),
+		UserUuid:  newSession.User.String(),
+		UserAgent: ua,
+		IpAddress: newSession.IPAddress,
+		ExpiresAt: time.Now().UTC().Add(SessionTTL),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert session query %v", e)
+	}
+
+	e = q.InsertRefreshToken(ctx, &repository.InsertRefreshTokenParams{
+		TokenHash:   hashToken(t),
+		SessionUuid: s.Uuid,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert refresh token query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	session := transformSQLSession(s)
+	session.RefreshToken = t
+
+	return session, nil
+}
+
+// Refresh exchange a refresh token for a new one within the same session
+//
+// every refresh token can only be used once, presenting a used token
+// means it was stolen or replayed so the whole session is revoked
+func (ss *SessionService) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+
+	t, e := generateToken()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate refresh token %v", e)
+	}
+
+	co, e := ss.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	h := hashToken(refreshToken)
+
+	// consume the token first so concurrent refreshes serialize on the write lock
+	r, e := q.UseRefreshToken(ctx, h)
+	if e != nil {
+		return nil, fmt.Errorf("error executing use refresh token query %v", e)
+	}
+
+	st, e := q.ReadRefreshToken(ctx, h)
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		return nil, fmt.Errorf("error executing read refresh token query %v", e)
+	}
+
+	s, e := q.ReadSession(ctx, st.SessionUuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing read session query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+
+		commit, e := ss.revokeSession(ctx, q, s.UserUuid, s.Uuid)
+		if e != nil && e != ErrResourceNotFound {
+			return nil, e
+		}
+
+		e = tx.Commit()
+		if e != nil {
+			return nil, fmt.Errorf("failed to commit transaction %v", e)
+		}
+
+		if commit != nil {
+			commit()
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	if s.RevokedAt.Valid || time.Now().After(s.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	e = q.InsertRefreshToken(ctx, &repository.InsertRefreshTokenParams{
+		TokenHash:   hashToken(t),
+		SessionUuid: s.Uuid,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert refresh token query %v", e)
+	}
+
+	e = q.TouchSession(ctx, s.Uuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing touch session query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	session := transformSQLSession(s)
+	session.RefreshToken = t
+	session.LastUsedAt = time.Now().UTC()
+
+	return session, nil
+}
+
+// List retrieve active sessions of user
+func (ss *SessionService) List(ctx context.Context, user uuid.UUID) ([]*Session, error) {
+
+	co, e := ss.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	ssl, e := newQueries(co).ReadAllUserSessions(ctx, user.String())
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all user sessions query %v", e)
+	}
+
+	now := time.Now()
+	sl := make([]*Session, 0, len(ssl))
+
+	for _, s := range ssl {
+
+		if now.After(s.ExpiresAt) {
+			continue
+		}
+
+		sl = append(sl, transformSQLSession(s))
+	}
+
+	return sl, nil
+}
+
+// Revoke end session of user
+//
+// its refresh tokens can no longer be used and its access tokens are rejected
+func (ss *SessionService) Revoke(ctx context.Context, user, session uuid.UUID) error {
+	return ss.Logout(ctx, user, session, "")
+}
+
+// Logout end session of user and revoke the access token used to request it
+func (ss *SessionService) Logout(ctx context.Context, user, session uuid.UUID, tokenID string) error {
+
+	co, e := ss.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	commits := make([]func(), 0, 2)
+
+	c, e := ss.revokeSession(ctx, q, user.String(), session.String())
+	if e != nil {
+		return e
+	}
+	commits = append(commits, c)
+
+	if tokenID != "" {
+
+		c, e = ss.revoked.revokeToken(ctx, q, tokenID)
+		if e != nil {
+			return e
+		}
+		commits = append(commits, c)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	for _, c := range commits {
+		c()
+	}
+
+	return nil
+}
+
+// LogoutAll end every session of user and reject all of their access tokens
+func (ss *SessionService) LogoutAll(ctx context.Context, user uuid.UUID) error {
+
+	co, e := ss.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	commit, e := ss.revokeAllSessions(ctx, newQueries(tx), user.String(), "")
+	if e != nil {
+		return e
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	commit()
+
+	return nil
+}
+
+// revokeAllSessions mark every session of user revoked except keep and add them to the revocation list
+//
+// the returned func adds the entries to memory once the transaction is committed
+func (ss *SessionService) revokeAllSessions(ctx context.Context, q *repository.Queries, user, keep string) (func(), error) {
+
+	ssl, e := q.ReadAllUserSessions(ctx, user)
+	if e != nil {
+		return nil, fmt.Errorf("error executing read all user sessions query %v", e)
+	}
+
+	// a session created after the read fails the transaction instead of escaping revocation
+	if keep == "" {
+		e = q.RevokeAllUserSessions(ctx, user)
+	} else {
+		e = q.RevokeOtherUserSessions(ctx, &repository.RevokeOtherUserSessionsParams{
+			UserUuid: user,
+			Uuid:     keep,
+		})
+	}
+	if e != nil {
+		return nil, fmt.Errorf("error executing revoke user sessions query %v", e)
+	}
+
+	sids := make([]string, 0, len(ssl))
+	for _, s := range ssl {
+		if s.Uuid != keep {
+			sids = append(sids, s.Uuid)
+		}
+	}
+
+	return ss.revoked.revokeSessions(ctx, q, sids...)
+}
+
+// revokeSession mark session revoked and add it to the revocation list
+//
+// the returned func adds the entry to memory once the transaction is committed
+func (ss *SessionService) revokeSession(ctx context.Context, q *repository.Queries, user, session string) (func(), error) {
+
+	r, e := q.RevokeSession(ctx, &repository.RevokeSessionParams{
+		Uuid:     session,
+		UserUuid: user,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing revoke session query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrResourceNotFound
+	}
+
+	return ss.revoked.revokeSessions(ctx, q, session)
+}
+
+func transformSQLSession(session *repository.UserSession) *Session {
+	return &Session{
+		UID:        uuid.MustParse(session.Uuid),
+		User:       uuid.MustParse(session.UserUuid),
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IpAddress,
+		ExpiresAt:  session.ExpiresAt,
+		LastUsedAt: session.LastUsedAt,
+		CreatedAt:  session.CreatedAt,
+	}
+}
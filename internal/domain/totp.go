@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// totpIssuer issuer shown by authenticator apps
+	totpIssuer = "go-chat"
+	// totpPeriod seconds each code is valid for
+	totpPeriod = 30
+	// totpDigits length of a code
+	totpDigits = 6
+	// totpSkew steps before and after the current step accepted for clock drift
+	totpSkew = 1
+	// totpSecretSize secret length in bytes, the size of a SHA1 HMAC key recommended by RFC 4226
+	totpSecretSize = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret random base32 encoded totp secret
+func generateTOTPSecret() (string, error) {
+
+	b := make([]byte, totpSecretSize)
+
+	_, e := rand.Read(b)
+	if e != nil {
+		return "", e
+	}
+
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpURI otpauth key uri scanned by authenticator apps
+func totpURI(account, secret string) string {
+
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", totpIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+
+	return u.String()
+}
+
+// totpMatch time step of the code within the skew window of now
+//
+// RFC 6238 with HMAC-SHA1, ok is false if no step in the window matches
+func totpMatch(secret, code string, now time.Time) (int64, bool) {
+
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	k, e := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if e != nil {
+		return 0, false
+	}
+
+	cur := now.Unix() / totpPeriod
+
+	for s := cur - totpSkew; s <= cur+totpSkew; s++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(k, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// totpCode RFC 4226 HOTP value of key at counter step
+func totpCode(key []byte, step int64) string {
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	m := hmac.New(sha1.New, key)
+	m.Write(msg[:])
+	h := m.Sum(nil)
+
+	// dynamic truncation
+	o := h[len(h)-1] & 0x0f
+	v := binary.BigEndian.Uint32(h[o:o+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, v%1000000)
+}
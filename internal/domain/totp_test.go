@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/db"
+)
+
+// rfc6238Secret base32 of the RFC 6238 SHA1 test key "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type TOTPSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (s *TOTPSuite) SetupTest() {
+
+	_ = os.Setenv("SQLITE_MIGRATIONS_DIR", "../../migrations")
+
+	var e error
+	s.db, e = sql.Open("sqlite", ":memory:")
+	s.Require().NoError(e)
+
+	// every connection of an in memory database is a separate database
+	s.db.SetMaxOpenConns(1)
+
+	s.Require().NoError(db.MigrateSQLite(s.db))
+}
+
+func (s *TOTPSuite) TearDownTest() {
+	_ = s.db.Close()
+}
+
+func (s *TOTPSuite) TestRFC6238Vectors() {
+
+	a := assert.New(s.T())
+
+	k, e := totpEncoding.DecodeString(rfc6238Secret)
+	a.NoError(e)
+
+	// RFC 6238 appendix B SHA1 values truncated to six digits
+	cases := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+		{unix: 20000000000, expected: "353130"},
+	}
+
+	for _, c := range cases {
+
+		a.Equal(c.expected, totpCode(k, c.unix/totpPeriod))
+
+		step, ok := totpMatch(rfc6238Secret, c.expected, time.Unix(c.unix, 0))
+		a.True(ok)
+		a.Equal(c.unix/totpPeriod, step)
+	}
+}
+
+func (s *TOTPSuite) TestSkew() {
+
+	a := assert.New(s.T())
+
+	now := time.Unix(1111111109, 0)
+	cur := now.Unix() / totpPeriod
+
+	// codes of the previous and next step are accepted for clock drift
+	step, ok := totpMatch(rfc6238Secret, "081804", now.Add(time.Second*totpPeriod))
+	a.True(ok)
+	a.Equal(cur, step)
+
+	step, ok = totpMatch(rfc6238Secret, "081804", now.Add(-time.Second*totpPeriod))
+	a.True(ok)
+	a.Equal(cur, step)
+
+	// two steps away is rejected
+	_, ok = totpMatch(rfc6238Secret, "081804", now.Add(time.Second*totpPeriod*2))
+	a.False(ok)
+
+	_, ok = totpMatch(rfc6238Secret, "081804", now.Add(-time.Second*totpPeriod*2))
+	a.False(ok)
+
+	// wrong length, wrong code and lowercase secrets
+	_, ok = totpMatch(rfc6238Secret, "07081804", now)
+	a.False(ok)
+
+	_, ok = totpMatch(rfc6238Secret, "081805", now)
+	a.False(ok)
+
+	_, ok = totpMatch("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "081804", now)
+	a.True(ok)
+}
+
+func (s *TOTPSuite) TestReplay() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+	q := newQueries(s.db)
+
+	uid := uuid.NewString()
+
+	_, e := q.InsertUser(ctx, &repository.InsertUserParams{
+		Uuid:   uid,
+		Usernm: "totp",
+		Email:  "totp@example.com",
+	})
+	a.NoError(e)
+
+	_, e = s.db.Exec(
+		"INSERT INTO user_totp (user_uuid, secret, confirmed_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
+		uid, rfc6238Secret,
+	)
+	a.NoError(e)
+
+	k, e := totpEncoding.DecodeString(rfc6238Secret)
+	a.NoError(e)
+
+	tfs := &TwoFactorService{}
+	cur := time.Now().Unix() / totpPeriod
+
+	a.NoError(tfs.verifyCode(ctx, q, uid, totpCode(k, cur)))
+
+	// a step is accepted once and earlier steps are rejected after it
+	a.Equal(ErrInvalidTwoFactorCode, tfs.verifyCode(ctx, q, uid, totpCode(k, cur)))
+	a.Equal(ErrInvalidTwoFactorCode, tfs.verifyCode(ctx, q, uid, totpCode(k, cur-1)))
+
+	a.NoError(tfs.verifyCode(ctx, q, uid, totpCode(k, cur+1)))
+}
+
+func TestTOTPSuite(t *testing.T) {
+	suite.Run(t, new(TOTPSuite))
+}
@@ -0,0 +1,396 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+const (
+	// LoginChallengeTTL time a user has to answer a login challenge with a code
+	LoginChallengeTTL = time.Minute * 5
+
+	// maxChallengeAttempts codes accepted per login challenge before the password must be entered again
+	maxChallengeAttempts = 5
+	// recoveryCodeCount recovery codes issued when two factor login is enabled
+	recoveryCodeCount = 10
+)
+
+// TwoFactorEnrollment totp secret to add to an authenticator app
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// LoginChallenge answered with a totp or recovery code to complete a login
+type LoginChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// TwoFactorService totp two factor login service
+type TwoFactorService struct {
+	db *sql.DB
+}
+
+func newTwoFactorService(db *sql.DB) *TwoFactorService {
+	return &TwoFactorService{db: db}
+}
+
+// Enroll generate new totp secret for user
+//
+// two factor login is enabled once a code from the secret is confirmed,
+// enrolling again before then replaces the secret
+func (tfs *TwoFactorService) Enroll(ctx context.Context, user uuid.UUID) (*TwoFactorEnrollment, error) {
+
+	s, e := generateTOTPSecret()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate totp secret %v", e)
+	}
+
+	co, e := tfs.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	su, e := q.ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error executing read user query %v", e)
+	}
+
+	r, e := q.UpsertUserTOTP(ctx, &repository.UpsertUserTOTPParams{
+		UserUuid: su.Uuid,
+		Secret:   s,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing upsert user totp query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &TwoFactorEnrollment{
+		Secret: s,
+		URI:    totpURI(su.Usernm, s),
+	}, nil
+}
+
+// Confirm enable two factor login with a code from the enrolled secret
+//
+// the returned recovery codes are only available now, only their hashes are persisted
+func (tfs *TwoFactorService) Confirm(ctx context.Context, user uuid.UUID, code string) ([]string, error) {
+
+	co, e := tfs.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	t, e := q.ReadUserTOTP(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrTwoFactorNotEnabled
+		}
+
+		return nil, fmt.Errorf("error executing read user totp query %v", e)
+	}
+
+	if t.ConfirmedAt.Valid {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	step, ok := totpMatch(t.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	r, e := q.ConfirmUserTOTP(ctx, &repository.ConfirmUserTOTPParams{
+		LastStep: step,
+		UserUuid: t.UserUuid,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing confirm user totp query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	e = q.DeleteUserRecoveryCodes(ctx, t.UserUuid)
+	if e != nil {
+		return nil, fmt.Errorf("error executing delete user recovery codes query %v", e)
+	}
+
+	cl := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+
+		c, e := generateRecoveryCode()
+		if e != nil {
+			return nil, fmt.Errorf("unable to generate recovery code %v", e)
+		}
+
+		e = q.InsertRecoveryCode(ctx, &repository.InsertRecoveryCodeParams{
+			CodeHash: hashToken(normalizeRecoveryCode(c)),
+			UserUuid: t.UserUuid,
+		})
+		if e != nil {
+			return nil, fmt.Errorf("error executing insert recovery code query %v", e)
+		}
+
+		cl = append(cl, c)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return cl, nil
+}
+
+// Disable turn off two factor login with a totp or recovery code
+func (tfs *TwoFactorService) Disable(ctx context.Context, user uuid.UUID, code string) error {
+
+	co, e := tfs.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	e = tfs.verifyCode(ctx, q, user.String(), code)
+	if e != nil {
+		return e
+	}
+
+	e = q.DeleteUserTOTP(ctx, user.String())
+	if e != nil {
+		return fmt.Errorf("error executing delete user totp query %v", e)
+	}
+
+	e = q.DeleteUserRecoveryCodes(ctx, user.String())
+	if e != nil {
+		return fmt.Errorf("error executing delete user recovery codes query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return nil
+}
+
+// Challenge issue login challenge for user after their password was verified
+//
+// nil is returned when two factor login is not enabled and a session may be created
+func (tfs *TwoFactorService) Challenge(ctx context.Context, user uuid.UUID) (*LoginChallenge, error) {
+
+	co, e := tfs.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	t, e := q.ReadUserTOTP(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error executing read user totp query %v", e)
+	}
+
+	if !t.ConfirmedAt.Valid {
+		return nil, nil
+	}
+
+	tk, e := generateToken()
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate login challenge token %v", e)
+	}
+
+	exp := time.Now().UTC().Add(LoginChallengeTTL)
+
+	e = q.InsertLoginChallenge(ctx, &repository.InsertLoginChallengeParams{
+		TokenHash: hashToken(tk),
+		UserUuid:  t.UserUuid,
+		ExpiresAt: exp,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("error executing insert login challenge query %v", e)
+	}
+
+	return &LoginChallenge{Token: tk, ExpiresAt: exp}, nil
+}
+
+// Answer complete login challenge with a totp or recovery code and return its user
+//
+// every attempt is counted, the challenge is used up after too many wrong codes
+func (tfs *TwoFactorService) Answer(ctx context.Context, challenge, code string) (uuid.UUID, error) {
+
+	co, e := tfs.db.Conn(ctx)
+	if e != nil {
+		return uuid.Nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return uuid.Nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	h := hashToken(challenge)
+
+	r, e := q.AttemptLoginChallenge(ctx, h)
+	if e != nil {
+		return uuid.Nil, fmt.Errorf("error executing attempt login challenge query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return uuid.Nil, ErrInvalidLoginChallenge
+	}
+
+	lc, e := q.ReadLoginChallenge(ctx, h)
+	if e != nil {
+		return uuid.Nil, fmt.Errorf("error executing read login challenge query %v", e)
+	}
+
+	if time.Now().After(lc.ExpiresAt) || lc.Attempts > maxChallengeAttempts {
+		return uuid.Nil, ErrInvalidLoginChallenge
+	}
+
+	ce := tfs.verifyCode(ctx, q, lc.UserUuid, code)
+	if ce == nil {
+
+		e = q.UseLoginChallenge(ctx, h)
+		if e != nil {
+			return uuid.Nil, fmt.Errorf("error executing use login challenge query %v", e)
+		}
+	} else if ce != ErrInvalidTwoFactorCode {
+		return uuid.Nil, ce
+	}
+
+	// wrong codes are committed so the attempt is counted
+	e = tx.Commit()
+	if e != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	if ce != nil {
+		return uuid.Nil, ce
+	}
+
+	return uuid.MustParse(lc.UserUuid), nil
+}
+
+// verifyCode accept an unused totp code of a confirmed secret or an unused recovery code
+func (tfs *TwoFactorService) verifyCode(ctx context.Context, q *repository.Queries, user, code string) error {
+
+	t, e := q.ReadUserTOTP(ctx, user)
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnabled
+		}
+
+		return fmt.Errorf("error executing read user totp query %v", e)
+	}
+
+	if !t.ConfirmedAt.Valid {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := totpMatch(t.Secret, code, time.Now()); ok {
+
+		r, e := q.UseTOTPStep(ctx, &repository.UseTOTPStepParams{
+			LastStep:   step,
+			UserUuid:   user,
+			LastStep_2: step,
+		})
+		if e != nil {
+			return fmt.Errorf("error executing use totp step query %v", e)
+		}
+
+		// code was already used
+		if af, e := r.RowsAffected(); e != nil || af < 1 {
+			return ErrInvalidTwoFactorCode
+		}
+
+		return nil
+	}
+
+	r, e := q.UseRecoveryCode(ctx, &repository.UseRecoveryCodeParams{
+		CodeHash: hashToken(normalizeRecoveryCode(code)),
+		UserUuid: user,
+	})
+	if e != nil {
+		return fmt.Errorf("error executing use recovery code query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// generateRecoveryCode random recovery code formatted as two groups of five characters
+func generateRecoveryCode() (string, error) {
+
+	b := make([]byte, 7)
+
+	_, e := rand.Read(b)
+	if e != nil {
+		return "", e
+	}
+
+	c := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+
+	return c[:5] + "-" + c[5:], nil
+}
+
+// normalizeRecoveryCode recovery codes are accepted regardless of case and separators
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -3,12 +3,15 @@ package domain
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/trevatk/go-chat/internal/metrics"
 	"github.com/trevatk/go-chat/internal/repository"
+	"github.com/trevatk/go-pkg/logging"
 	"golang.org/x/crypto/bcrypt"
 	"modernc.org/sqlite"
 	codes "modernc.org/sqlite/lib"
@@ -23,14 +26,35 @@ type NewUser struct {
 
 // User application layer user model
 type User struct {
-	UID       uuid.UUID
-	Username  string
-	Email     string
-	Password  string
+	UID      uuid.UUID
+	Username string
+	Email    string
+	Password string
+	// EmailVerified set once the emailed verification token is confirmed
+	EmailVerified bool
+	Type          UserType
+	// Owner user managing a bot, nil for human users
+	Owner uuid.UUID
+	Role  Role
+	// Disabled set while an administrator has disabled the account
+	Disabled  bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// UserType kind of account
+type UserType string
+
+const (
+	// UserTypeHuman user signing in with a password or identity provider
+	UserTypeHuman UserType = "human"
+	// UserTypeBot service account owned by a human user, authenticated with api keys only
+	UserTypeBot UserType = "bot"
+)
+
+// botEmailDomain reserved domain of bot email addresses, no mail is delivered to bots
+const botEmailDomain = "bots.go-chat.invalid"
+
 // UserDetails application layer user details model
 type UserDetails struct {
 	UUID     uuid.UUID
@@ -46,11 +70,12 @@ type UpdateUser struct {
 
 // UserService user management service
 type UserService struct {
-	db *sql.DB
+	db           *sql.DB
+	verification *VerificationService
 }
 
-func newUserService(db *sql.DB) *UserService {
-	return &UserService{db: db}
+func newUserService(db *sql.DB, verification *VerificationService) *UserService {
+	return &UserService{db: db, verification: verification}
 }
 
 // Create insert new user into database
@@ -68,19 +93,19 @@ func (us *UserService) Create(ctx context.Context, newUser *NewUser) (*User, err
 	}
 	defer func() { _ = co.Close() }()
 
-	p, e := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcrypt.DefaultCost+bcrypt.MinCost)
+	p, e := hashPassword(newUser.Password)
 	if e != nil {
-		return nil, fmt.Errorf("failed to generated hashed password %v", e)
+		return nil, e
 	}
 
 	to, ca := context.WithTimeout(ctx, time.Second*3)
 	defer ca()
 
-	su, e := repository.New(co).InsertUser(to, &repository.InsertUserParams{
+	su, e := newQueries(co).InsertUser(to, &repository.InsertUserParams{
 		Uuid:    uid.String(),
 		Usernm:  newUser.Username,
 		Email:   newUser.Email,
-		Pssword: string(p),
+		Pssword: p,
 	})
 	if e != nil {
 
@@ -107,11 +132,16 @@ func (us *UserService) Create(ctx context.Context, newUser *NewUser) (*User, err
 		return nil, fmt.Errorf("error excuting insert user query %v", e)
 	}
 
+	us.sendVerification(ctx, co, su)
+
 	return transformSQLUser(su), nil
 }
 
 // Login authenticate user
-func (us *UserService) Login(ctx context.Context, username, password string) (string, error) {
+//
+// failed logins are counted per account and client ip, a locked account or ip
+// is rejected without comparing the password until its lockout ends
+func (us *UserService) Login(ctx context.Context, username, password, ip string) (string, error) {
 
 	co, e := us.db.Conn(ctx)
 	if e != nil {
@@ -127,19 +157,135 @@ func (us *UserService) Login(ctx context.Context, username, password string) (st
 	to, ca := context.WithTimeout(ctx, time.Second*3)
 	defer ca()
 
-	r, e := repository.New(co).ReadUserLoginDetails(to, p)
+	q := newQueries(co)
+
+	r, e := q.ReadUserLoginDetails(to, p)
+	if e != nil && !errors.Is(e, sql.ErrNoRows) {
+		return "", fmt.Errorf("error executing read user login details query %v", e)
+	}
+
+	tl := loginThrottles(username, r.Uuid, ip)
+	now := time.Now().UTC()
+
+	lo, e := lockedOut(ctx, q, tl, now)
 	if e != nil {
-		return "", fmt.Errorf("read user login details ")
+		return "", e
+	} else if lo {
+		return "", ErrLoginLocked
+	}
+
+	s := time.Now()
+	if r.Uuid == "" || r.Pssword == "" {
+		compareDummyPassword(password)
+		e = bcrypt.ErrMismatchedHashAndPassword
+	} else {
+		e = bcrypt.CompareHashAndPassword([]byte(r.Pssword), []byte(password))
 	}
+	metrics.LoginBcryptDuration.Observe(time.Since(s).Seconds())
 
-	e = bcrypt.CompareHashAndPassword([]byte(r.Pssword), []byte(password))
 	if e != nil {
-		return "", fmt.Errorf("failed to compare passwords %v", e)
+
+		tx, e := co.BeginTx(ctx, nil)
+		if e != nil {
+			return "", fmt.Errorf("unable to begin transaction %v", e)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		e = recordLoginFailure(ctx, newQueries(tx), tl, ip, now)
+		if e != nil {
+			return "", e
+		}
+
+		e = tx.Commit()
+		if e != nil {
+			return "", fmt.Errorf("failed to commit transaction %v", e)
+		}
+
+		return "", ErrInvalidCredentials
+	}
+
+	// failures from the client ip are kept, a valid login must not reset them
+	e = q.DeleteLoginThrottle(ctx, tl[0].key)
+	if e != nil {
+		return "", fmt.Errorf("error executing delete login throttle query %v", e)
+	}
+
+	if r.DisabledAt.Valid {
+		return "", ErrAccountDisabled
 	}
 
 	return r.Uuid, nil
 }
 
+// CreateBot insert new bot owned by user
+//
+// only human users may own bots, bots authenticate with api keys created by their owner
+func (us *UserService) CreateBot(ctx context.Context, owner uuid.UUID, username string) (*User, error) {
+
+	co, e := us.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	ou, e := q.ReadUser(ctx, owner.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if UserType(ou.UserType) != UserTypeHuman {
+		return nil, ErrForbidden
+	}
+
+	uid := uuid.NewString()
+
+	su, e := q.InsertBotUser(ctx, &repository.InsertBotUserParams{
+		Uuid:      uid,
+		Usernm:    username,
+		Email:     uid + "@" + botEmailDomain,
+		OwnerUuid: sql.NullString{String: ou.Uuid, Valid: true},
+	})
+	if e != nil {
+
+		if se, ok := e.(*sqlite.Error); ok && se.Code() == codes.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, ErrUniqueExists
+		}
+
+		return nil, fmt.Errorf("error executing insert bot user query %v", e)
+	}
+
+	return transformSQLUser(su), nil
+}
+
+// ListBots retrieve bots owned by user
+func (us *UserService) ListBots(ctx context.Context, owner uuid.UUID) ([]*User, error) {
+
+	co, e := us.db.Conn(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	sl, e := newQueries(co).ListOwnedBots(ctx, sql.NullString{String: owner.String(), Valid: true})
+	if e != nil {
+		return nil, fmt.Errorf("error executing list owned bots query %v", e)
+	}
+
+	ul := make([]*User, 0, len(sl))
+	for _, su := range sl {
+		ul = append(ul, transformSQLUser(su))
+	}
+
+	return ul, nil
+}
+
 // Read retrieve user from database by uuid
 //
 // this method attempts to read by uuid
@@ -153,7 +299,7 @@ func (us *UserService) Read(ctx context.Context, uuid uuid.UUID) (*User, error)
 	}
 	defer func() { _ = co.Close() }()
 
-	su, e := repository.New(co).ReadUser(ctx, uuid.String())
+	su, e := newQueries(co).ReadUser(ctx, uuid.String())
 	if e != nil {
 
 		if e == sql.ErrNoRows {
@@ -179,7 +325,25 @@ func (us *UserService) Update(ctx context.Context, updateUser *UpdateUser) (*Use
 	}
 	defer func() { _ = co.Close() }()
 
-	su, e := repository.New(co).UpdateUser(ctx, &repository.UpdateUserParams{
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	prev, e := q.ReadUser(ctx, updateUser.UID.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return nil, ErrResourceNotFound
+		}
+
+		return nil, fmt.Errorf("error excuting read user query %v", e)
+	}
+
+	su, e := q.UpdateUser(ctx, &repository.UpdateUserParams{
 		Uuid:   updateUser.UID.String(),
 		Usernm: updateUser.Username,
 		Email:  updateUser.Email,
@@ -201,9 +365,41 @@ func (us *UserService) Update(ctx context.Context, updateUser *UpdateUser) (*Use
 		return nil, fmt.Errorf("error excuting update user query %v", e)
 	}
 
+	// a new email must be verified again
+	changed := su.Email != prev.Email
+	if changed {
+
+		e = q.ResetEmailVerification(ctx, su.Uuid)
+		if e != nil {
+			return nil, fmt.Errorf("error executing reset email verification query %v", e)
+		}
+
+		su.EmailVerifiedAt = sql.NullTime{}
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return nil, fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	if changed {
+		us.sendVerification(ctx, co, su)
+	}
+
 	return transformSQLUser(su), nil
 }
 
+// sendVerification email verification token to a new or changed email
+//
+// failures are only logged, the user can request another email
+func (us *UserService) sendVerification(ctx context.Context, co *sql.Conn, user *repository.User) {
+
+	e := us.verification.send(ctx, newQueries(co), user)
+	if e != nil {
+		logging.FromContext(ctx).Errorf("failed to send verification email %v", e)
+	}
+}
+
 // Search query users by email or username
 //
 // this method is attempting to return one/many records
@@ -216,7 +412,7 @@ func (us *UserService) Search(ctx context.Context, search string) ([]*UserDetail
 	}
 	defer func() { _ = co.Close() }()
 
-	sud, e := repository.New(co).SearchUserDetails(ctx, &repository.SearchUserDetailsParams{
+	sud, e := newQueries(co).SearchUserDetails(ctx, &repository.SearchUserDetailsParams{
 		Usernm: "%" + strings.ToUpper(search) + "%",
 		Email:  "%" + strings.ToUpper(search) + "%",
 	})
@@ -253,11 +449,21 @@ func transformSQLUser(user *repository.User) *User {
 
 	uid := uuid.MustParse(user.Uuid)
 
+	o := uuid.Nil
+	if user.OwnerUuid.Valid {
+		o = uuid.MustParse(user.OwnerUuid.String)
+	}
+
 	return &User{
-		UID:       uid,
-		Username:  user.Usernm,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: u,
+		UID:           uid,
+		Username:      user.Usernm,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Type:          UserType(user.UserType),
+		Owner:         o,
+		Role:          Role(user.Role),
+		Disabled:      user.DisabledAt.Valid,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     u,
 	}
 }
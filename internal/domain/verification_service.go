@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/mail"
+	"github.com/trevatk/go-chat/internal/repository"
+)
+
+const (
+	// VerificationTTL lifetime of an emailed verification token
+	VerificationTTL = time.Hour * 24
+
+	// verificationResendInterval minimum time between verification emails to a user
+	verificationResendInterval = time.Minute
+	// verificationMaxPerHour verification emails sent to a user within an hour
+	verificationMaxPerHour = 5
+)
+
+// Action operation which may be restricted to users with a verified email
+type Action string
+
+const (
+	// ActionCreateConversation create a new conversation
+	ActionCreateConversation Action = "create_conversation"
+	// ActionSendMessage send a message to a conversation
+	ActionSendMessage Action = "send_message"
+	// ActionAddContact add a user to contacts
+	ActionAddContact Action = "add_contact"
+	// ActionCreateInvite create a conversation invite link
+	ActionCreateInvite Action = "create_invite"
+)
+
+// Actions every action which may be restricted
+var Actions = []Action{
+	ActionCreateConversation,
+	ActionSendMessage,
+	ActionAddContact,
+	ActionCreateInvite,
+}
+
+// VerificationService email verification service
+//
+// new accounts are unverified until the emailed token is confirmed,
+// restricted actions are rejected for unverified users
+type VerificationService struct {
+	db     *sql.DB
+	mailer mail.Sender
+
+	mu         sync.RWMutex
+	restricted map[Action]struct{}
+}
+
+func newVerificationService(db *sql.DB, mailer mail.Sender) *VerificationService {
+	return &VerificationService{
+		db:         db,
+		mailer:     mailer,
+		restricted: make(map[Action]struct{}),
+	}
+}
+
+// Restrict reject actions for users who have not verified their email
+//
+// replaces any previously restricted actions, no actions are restricted by default
+func (vs *VerificationService) Restrict(actions ...Action) {
+
+	r := make(map[Action]struct{}, len(actions))
+	for _, a := range actions {
+		r[a] = struct{}{}
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.restricted = r
+}
+
+// Require verify user may perform action
+//
+// the user is only read when the action is restricted
+func (vs *VerificationService) Require(ctx context.Context, user uuid.UUID, action Action) error {
+
+	vs.mu.RLock()
+	_, ok := vs.restricted[action]
+	vs.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	co, e := vs.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	su, e := newQueries(co).ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrResourceNotFound
+		}
+
+		return fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if !su.EmailVerifiedAt.Valid {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// Resend email a new verification token to user
+//
+// users are limited to one email a minute and five an hour
+func (vs *VerificationService) Resend(ctx context.Context, user uuid.UUID) error {
+
+	co, e := vs.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	q := newQueries(co)
+
+	su, e := q.ReadUser(ctx, user.String())
+	if e != nil {
+
+		if errors.Is(e, sql.ErrNoRows) {
+			return ErrResourceNotFound
+		}
+
+		return fmt.Errorf("error executing read user query %v", e)
+	}
+
+	if su.EmailVerifiedAt.Valid {
+		return ErrEmailAlreadyVerified
+	}
+
+	sl, e := q.ReadRecentEmailVerifications(ctx, &repository.ReadRecentEmailVerificationsParams{
+		UserUuid: su.Uuid,
+		Limit:    verificationMaxPerHour,
+	})
+	if e != nil {
+		return fmt.Errorf("error executing read recent email verifications query %v", e)
+	}
+
+	now := time.Now()
+
+	if len(sl) > 0 && now.Sub(sl[0]) < verificationResendInterval {
+		return ErrTooManyRequests
+	} else if len(sl) >= verificationMaxPerHour && now.Sub(sl[len(sl)-1]) < time.Hour {
+		return ErrTooManyRequests
+	}
+
+	return vs.send(ctx, q, su)
+}
+
+// Confirm mark email verified with an emailed verification token
+//
+// the token and any other outstanding tokens of the user are used up
+func (vs *VerificationService) Confirm(ctx context.Context, token string) error {
+
+	co, e := vs.db.Conn(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to get database connection from pool %v", e)
+	}
+	defer func() { _ = co.Close() }()
+
+	tx, e := co.BeginTx(ctx, nil)
+	if e != nil {
+		return fmt.Errorf("unable to begin transaction %v", e)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := newQueries(tx)
+
+	th := hashToken(token)
+
+	r, e := q.UseEmailVerification(ctx, th)
+	if e != nil {
+		return fmt.Errorf("error executing use email verification query %v", e)
+	}
+
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrInvalidVerificationToken
+	}
+
+	ev, e := q.ReadEmailVerification(ctx, th)
+	if e != nil {
+		return fmt.Errorf("error executing read email verification query %v", e)
+	}
+
+	if time.Now().After(ev.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	r, e = q.VerifyUserEmail(ctx, &repository.VerifyUserEmailParams{
+		Uuid:  ev.UserUuid,
+		Email: ev.Email,
+	})
+	if e != nil {
+		return fmt.Errorf("error executing verify user email query %v", e)
+	}
+
+	// email was changed after the token was sent
+	if af, e := r.RowsAffected(); e != nil || af < 1 {
+		return ErrInvalidVerificationToken
+	}
+
+	e = q.UseAllUserEmailVerifications(ctx, ev.UserUuid)
+	if e != nil {
+		return fmt.Errorf("error executing use all user email verifications query %v", e)
+	}
+
+	e = tx.Commit()
+	if e != nil {
+		return fmt.Errorf("failed to commit transaction %v", e)
+	}
+
+	return nil
+}
+
+// send persist a new verification token for the current email of user and email it
+func (vs *VerificationService) send(ctx context.Context, q *repository.Queries, user *repository.User) error {
+
+	t, e := generateToken()
+	if e != nil {
+		return fmt.Errorf("unable to generate verification token %v", e)
+	}
+
+	e = q.InsertEmailVerification(ctx, &repository.InsertEmailVerificationParams{
+		TokenHash: hashToken(t),
+		UserUuid:  user.Uuid,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(VerificationTTL),
+	})
+	if e != nil {
+		return fmt.Errorf("error executing insert email verification query %v", e)
+	}
+
+	e = vs.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Verify your go-chat email",
+		Body: fmt.Sprintf(
+			"Hi %s,\r\n\r\nuse the token below to verify your email, it expires in %s.\r\n\r\n%s\r\n",
+			user.Usernm, VerificationTTL, t,
+		),
+	})
+	if e != nil {
+		return fmt.Errorf("unable to send verification email %v", e)
+	}
+
+	return nil
+}
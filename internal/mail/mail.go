@@ -0,0 +1,87 @@
+// Package mail outgoing email senders
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	senderLog  = "log"
+	senderFile = "file"
+	senderSMTP = "smtp"
+
+	// defaultFrom from address when $MAIL_FROM is unset
+	defaultFrom = "no-reply@go-chat.local"
+)
+
+// Message plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender deliver email messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender create new sender from environment
+//
+// $MAIL_SENDER selects the sender
+//   - log  write messages to the request logger, the default for development
+//   - file write each message to a file in $MAIL_DIR
+//   - smtp deliver through $SMTP_ADDR authenticated by $SMTP_USERNAME and $SMTP_PASSWORD
+//
+// $MAIL_FROM sets the from address
+func NewSender() (Sender, error) {
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+
+	s := os.Getenv("MAIL_SENDER")
+	switch s {
+	case "", senderLog:
+		return NewLogSender(), nil
+
+	case senderFile:
+
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			return nil, errors.New("$MAIL_DIR is unset")
+		}
+
+		return NewFileSender(dir, from)
+
+	case senderSMTP:
+
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, errors.New("$SMTP_ADDR is unset")
+		}
+
+		return NewSMTPSender(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	}
+
+	return nil, fmt.Errorf("invalid $MAIL_SENDER %s", s)
+}
+
+// validate reject messages which would inject additional headers
+func validate(msg *Message) error {
+
+	if msg.To == "" {
+		return errors.New("no recipient provided")
+	}
+
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errors.New("invalid message header")
+	}
+
+	return nil
+}
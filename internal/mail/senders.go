@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// LogSender write messages to the request logger instead of delivering them
+type LogSender struct{}
+
+// NewLogSender create new log sender
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send log message
+func (ls *LogSender) Send(ctx context.Context, msg *Message) error {
+
+	e := validate(msg)
+	if e != nil {
+		return e
+	}
+
+	logging.FromContext(ctx).Infof("mail to %s subject %q\n%s", msg.To, msg.Subject, msg.Body)
+
+	return nil
+}
+
+// FileSender write each message to its own .eml file in a directory
+type FileSender struct {
+	dir  string
+	from string
+}
+
+// NewFileSender create new file sender writing to dir, creating it if missing
+func NewFileSender(dir, from string) (*FileSender, error) {
+
+	e := os.MkdirAll(dir, 0o700)
+	if e != nil {
+		return nil, fmt.Errorf("failed to create mail directory %v", e)
+	}
+
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+// Send write message to file
+func (fs *FileSender) Send(_ context.Context, msg *Message) error {
+
+	e := validate(msg)
+	if e != nil {
+		return e
+	}
+
+	// timestamp prefix keeps messages in delivery order
+	fn := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), uuid.NewString())
+
+	e = os.WriteFile(filepath.Join(fs.dir, fn), format(fs.from, msg), 0o600)
+	if e != nil {
+		return fmt.Errorf("failed to write mail file %v", e)
+	}
+
+	return nil
+}
+
+// SMTPSender deliver messages through an smtp relay
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender create new smtp sender
+//
+// plain auth is used when username is set, it requires TLS unless the relay is on localhost
+func NewSMTPSender(addr, username, password, from string) *SMTPSender {
+
+	s := &SMTPSender{addr: addr, from: from}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+// Send deliver message
+func (ss *SMTPSender) Send(_ context.Context, msg *Message) error {
+
+	e := validate(msg)
+	if e != nil {
+		return e
+	}
+
+	e = smtp.SendMail(ss.addr, ss.auth, ss.from, []string{msg.To}, format(ss.from, msg))
+	if e != nil {
+		return fmt.Errorf("failed to send mail %v", e)
+	}
+
+	return nil
+}
+
+// format encode message with headers as RFC 5322 text
+func format(from string, msg *Message) []byte {
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return b.Bytes()
+}
@@ -0,0 +1,106 @@
+// Package metrics prometheus collectors
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "chat"
+
+var (
+	// HTTPRequests http requests served by chi route pattern
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served by route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPRequestDuration http request latency by chi route pattern
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// GrpcRequests gRPC calls handled by full method name
+	GrpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "gRPC calls handled by method and status code.",
+	}, []string{"method", "code"})
+
+	// GrpcRequestDuration gRPC call latency by full method name
+	GrpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC call latency by method, streams are observed when they end.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// GrpcActiveStreams open gRPC streams by full method name
+	GrpcActiveStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "active_streams",
+		Help:      "Open gRPC streams by method.",
+	}, []string{"method"})
+
+	// MessagesSent messages persisted, duplicate sends are not counted
+	MessagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "messenger",
+		Name:      "messages_sent_total",
+		Help:      "Messages persisted.",
+	})
+
+	// LoginBcryptDuration bcrypt password comparison latency during login
+	LoginBcryptDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "user",
+		Name:      "login_bcrypt_duration_seconds",
+		Help:      "Bcrypt password comparison latency during login.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 10),
+	})
+)
+
+// RegisterDBStats expose connection pool stats of db
+func RegisterDBStats(db *sql.DB) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, "sqlite"))
+}
+
+// RegisterSubscribers expose broker subscriber counts
+//
+// subscribers returns the number of conversation and user subscriptions
+func RegisterSubscribers(subscribers func() (int, int)) error {
+
+	for _, sub := range []struct {
+		kind  string
+		count func() float64
+	}{
+		{kind: "conversation", count: func() float64 { c, _ := subscribers(); return float64(c) }},
+		{kind: "user", count: func() float64 { _, u := subscribers(); return float64(u) }},
+	} {
+
+		e := prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "broker",
+			Name:        "subscribers",
+			Help:        "Active broker subscriptions by kind.",
+			ConstLabels: prometheus.Labels{"kind": sub.kind},
+		}, sub.count))
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
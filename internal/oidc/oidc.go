@@ -0,0 +1,276 @@
+// Package oidc OpenID Connect relying party using the authorization code flow with PKCE
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultScopes requested scopes when $OIDC_SCOPES is unset
+	defaultScopes = "openid email profile"
+
+	// requestTimeout deadline of discovery, key and token requests to the provider
+	requestTimeout = time.Second * 10
+	// maxResponseSize largest provider response read
+	maxResponseSize = 1 << 20
+)
+
+// Config relying party registration with a provider
+type Config struct {
+	// Issuer provider issuer url, discovery is read from its well known configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL callback receiving the authorization code
+	RedirectURL string
+	Scopes      []string
+}
+
+// Claims verified identity of an ID token
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// Client relying party of a single provider
+//
+// discovery and signing keys are fetched on first use and cached
+type Client struct {
+	cfg *Config
+	hc  *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+// discovery subset of the provider configuration
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewClient create new relying party client
+func NewClient(cfg *Config) *Client {
+	return &Client{
+		cfg: cfg,
+		hc:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// NewClientFromEnv create new client from environment
+//
+// nil is returned when $OIDC_ISSUER is unset and OIDC login is disabled,
+// otherwise $OIDC_CLIENT_ID and $OIDC_REDIRECT_URL are required,
+// $OIDC_CLIENT_SECRET is omitted for public clients and
+// $OIDC_SCOPES is a space separated list defaulting to openid email profile
+func NewClientFromEnv() (*Client, error) {
+
+	iss := os.Getenv("OIDC_ISSUER")
+	if iss == "" {
+		return nil, nil
+	}
+
+	id := os.Getenv("OIDC_CLIENT_ID")
+	if id == "" {
+		return nil, errors.New("$OIDC_CLIENT_ID is unset")
+	}
+
+	ru := os.Getenv("OIDC_REDIRECT_URL")
+	if ru == "" {
+		return nil, errors.New("$OIDC_REDIRECT_URL is unset")
+	}
+
+	sc := os.Getenv("OIDC_SCOPES")
+	if sc == "" {
+		sc = defaultScopes
+	}
+
+	return NewClient(&Config{
+		Issuer:       iss,
+		ClientID:     id,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  ru,
+		Scopes:       strings.Fields(sc),
+	}), nil
+}
+
+// Issuer configured provider issuer
+func (c *Client) Issuer() string {
+	return c.cfg.Issuer
+}
+
+// AuthCodeURL provider authorization url the user agent is redirected to
+//
+// state and nonce are returned unchanged in the callback and ID token,
+// challenge is the S256 code challenge of the verifier passed to Exchange
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+
+	d, e := c.provider(ctx)
+	if e != nil {
+		return "", e
+	}
+
+	u, e := url.Parse(d.AuthorizationEndpoint)
+	if e != nil {
+		return "", fmt.Errorf("invalid authorization endpoint %v", e)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.cfg.ClientID)
+	q.Set("redirect_uri", c.cfg.RedirectURL)
+	q.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// tokenResponse token endpoint response
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeem authorization code and verify the returned ID token
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+
+	d, e := c.provider(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	f := url.Values{}
+	f.Set("grant_type", "authorization_code")
+	f.Set("code", code)
+	f.Set("redirect_uri", c.cfg.RedirectURL)
+	f.Set("client_id", c.cfg.ClientID)
+	f.Set("code_verifier", verifier)
+
+	rq, e := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(f.Encode()))
+	if e != nil {
+		return nil, fmt.Errorf("unable to create token request %v", e)
+	}
+
+	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rq.Header.Set("Accept", "application/json")
+
+	if c.cfg.ClientSecret != "" {
+		rq.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	tr := &tokenResponse{}
+
+	sc, e := c.do(rq, tr)
+	if e != nil {
+		return nil, fmt.Errorf("token request failed %v", e)
+	}
+
+	if sc != http.StatusOK {
+
+		if tr.Error != "" {
+			return nil, fmt.Errorf("token request rejected %s %s", tr.Error, tr.ErrorDescription)
+		}
+
+		return nil, fmt.Errorf("token request returned status %d", sc)
+	}
+
+	if tr.IDToken == "" {
+		return nil, errors.New("token response has no id token")
+	}
+
+	return c.verify(ctx, d, tr.IDToken, nonce)
+}
+
+// Challenge S256 code challenge of a PKCE code verifier
+func Challenge(verifier string) string {
+
+	h := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// provider discovery of the configured issuer
+func (c *Client) provider(ctx context.Context) (*discovery, error) {
+
+	c.mu.Lock()
+	d := c.discovery
+	c.mu.Unlock()
+
+	if d != nil {
+		return d, nil
+	}
+
+	u := strings.TrimSuffix(c.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	rq, e := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to create discovery request %v", e)
+	}
+
+	d = &discovery{}
+
+	sc, e := c.do(rq, d)
+	if e != nil {
+		return nil, fmt.Errorf("discovery request failed %v", e)
+	} else if sc != http.StatusOK {
+		return nil, fmt.Errorf("discovery request returned status %d", sc)
+	}
+
+	if d.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %s does not match %s", d.Issuer, c.cfg.Issuer)
+	} else if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery is missing endpoints")
+	}
+
+	c.mu.Lock()
+	c.discovery = d
+	c.mu.Unlock()
+
+	return d, nil
+}
+
+// do send request and decode the JSON response body into v
+func (c *Client) do(rq *http.Request, v interface{}) (int, error) {
+
+	rsp, e := c.hc.Do(rq)
+	if e != nil {
+		return 0, e
+	}
+	defer func() { _ = rsp.Body.Close() }()
+
+	bb, e := io.ReadAll(io.LimitReader(rsp.Body, maxResponseSize))
+	if e != nil {
+		return 0, fmt.Errorf("unable to read response %v", e)
+	}
+
+	// error responses without a JSON body only report their status
+	if e := json.Unmarshal(bb, v); e != nil && rsp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("unable to decode response %v", e)
+	}
+
+	return rsp.StatusCode, nil
+}
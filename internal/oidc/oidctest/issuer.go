@@ -0,0 +1,265 @@
+// Package oidctest mock OpenID Connect issuer for tests and local development
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/trevatk/go-chat/internal/oidc"
+)
+
+const (
+	// keyID kid of the issuer signing key
+	keyID = "oidctest"
+	// codeTTL lifetime of an authorization code
+	codeTTL = time.Minute
+	// tokenTTL lifetime of an ID token
+	tokenTTL = time.Minute * 5
+)
+
+// User identity asserted for every authorization
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// grant issued authorization code
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	expiresAt   time.Time
+}
+
+// Issuer mock provider approving every authorization request as the current user
+//
+// the issuer url is derived from the request host so the issuer may be served
+// by httptest or any listener, authorization codes are single use and
+// require a matching S256 code verifier
+type Issuer struct {
+	key *rsa.PrivateKey
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	user   User
+	grants map[string]*grant
+}
+
+// NewIssuer create new mock issuer with a random signing key
+func NewIssuer() (*Issuer, error) {
+
+	k, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		return nil, e
+	}
+
+	i := &Issuer{
+		key:    k,
+		mux:    http.NewServeMux(),
+		grants: make(map[string]*grant),
+		user: User{
+			Subject:           "oidctest-user",
+			Email:             "oidctest@example.com",
+			EmailVerified:     true,
+			PreferredUsername: "oidctest",
+		},
+	}
+
+	i.mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	i.mux.HandleFunc("/authorize", i.authorize)
+	i.mux.HandleFunc("/token", i.token)
+	i.mux.HandleFunc("/jwks", i.jwks)
+
+	return i, nil
+}
+
+// SetUser identity asserted for following authorizations
+func (i *Issuer) SetUser(u User) {
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.user = u
+}
+
+// ServeHTTP serve discovery, authorization, token and key set endpoints
+func (i *Issuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mux.ServeHTTP(w, r)
+}
+
+func issuerURL(r *http.Request) string {
+	return "http://" + r.Host
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+
+	iss := issuerURL(r)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                iss,
+		"authorization_endpoint":                iss + "/authorize",
+		"token_endpoint":                        iss + "/token",
+		"jwks_uri":                              iss + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize redirect back to the client with a code, the user is never prompted
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	ru, e := url.Parse(q.Get("redirect_uri"))
+	if e != nil || ru.Scheme == "" || q.Get("client_id") == "" {
+		http.Error(w, "invalid client or redirect uri", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		redirectError(w, r, ru, q.Get("state"), "invalid_request")
+		return
+	}
+
+	c := randomString()
+
+	i.mu.Lock()
+	i.grants[c] = &grant{
+		user:        i.user,
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		expiresAt:   time.Now().Add(codeTTL),
+	}
+	i.mu.Unlock()
+
+	rq := ru.Query()
+	rq.Set("code", c)
+	rq.Set("state", q.Get("state"))
+	ru.RawQuery = rq.Encode()
+
+	http.Redirect(w, r, ru.String(), http.StatusFound)
+}
+
+// token redeem authorization code for a signed ID token
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	id := r.PostForm.Get("client_id")
+	if u, _, ok := r.BasicAuth(); ok {
+		id, _ = url.QueryUnescape(u)
+	}
+
+	i.mu.Lock()
+	g, ok := i.grants[r.PostForm.Get("code")]
+	delete(i.grants, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	if !ok || time.Now().After(g.expiresAt) || g.clientID != id || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	ch := oidc.Challenge(r.PostForm.Get("code_verifier"))
+	if subtle.ConstantTimeCompare([]byte(ch), []byte(g.challenge)) != 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code verifier does not match"})
+		return
+	}
+
+	now := time.Now()
+
+	tk := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                issuerURL(r),
+		"sub":                g.user.Subject,
+		"aud":                g.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(tokenTTL).Unix(),
+		"nonce":              g.nonce,
+		"email":              g.user.Email,
+		"email_verified":     g.user.EmailVerified,
+		"preferred_username": g.user.PreferredUsername,
+	})
+	tk.Header["kid"] = keyID
+
+	s, e := tk.SignedString(i.key)
+	if e != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTTL.Seconds()),
+		"id_token":     s,
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, _ *http.Request) {
+
+	pk := &i.key.PublicKey
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(pk.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pk.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, ru *url.URL, state, code string) {
+
+	q := ru.Query()
+	q.Set("error", code)
+	q.Set("state", state)
+	ru.RawQuery = q.Encode()
+
+	http.Redirect(w, r, ru.String(), http.StatusFound)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
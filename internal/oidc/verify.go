@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// keyRefreshInterval minimum time between key set fetches for unknown key ids
+	keyRefreshInterval = time.Minute
+	// clockSkew leeway of ID token time claims
+	clockSkew = time.Minute
+)
+
+// signingMethods accepted ID token algorithms
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// jwk public RSA or EC JSON web key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// idTokenClaims registered and profile claims of an ID token
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// verify check ID token signature, issuer, audience, expiry and nonce
+func (c *Client) verify(ctx context.Context, d *discovery, token, nonce string) (*Claims, error) {
+
+	cl := &idTokenClaims{}
+
+	_, e := jwt.ParseWithClaims(token, cl, func(t *jwt.Token) (interface{}, error) {
+
+		kid, _ := t.Header["kid"].(string)
+
+		return c.key(ctx, d, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithLeeway(clockSkew),
+	)
+	if e != nil {
+		return nil, fmt.Errorf("invalid id token %v", e)
+	}
+
+	if cl.ExpiresAt == nil {
+		return nil, errors.New("id token has no expiry")
+	} else if cl.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	} else if cl.Nonce != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	return &Claims{
+		Subject:           cl.Subject,
+		Email:             cl.Email,
+		EmailVerified:     cl.EmailVerified,
+		PreferredUsername: cl.PreferredUsername,
+		Name:              cl.Name,
+	}, nil
+}
+
+// key public key of kid, the key set is fetched again for unknown ids after the refresh interval
+func (c *Client) key(ctx context.Context, d *discovery, kid string) (interface{}, error) {
+
+	c.mu.Lock()
+	k, ok := c.keys[kid]
+	stale := time.Since(c.keysAt) > keyRefreshInterval
+	c.mu.Unlock()
+
+	if ok {
+		return k, nil
+	} else if !stale {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	rq, e := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if e != nil {
+		return nil, fmt.Errorf("unable to create key set request %v", e)
+	}
+
+	ks := &struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+
+	sc, e := c.do(rq, ks)
+	if e != nil {
+		return nil, fmt.Errorf("key set request failed %v", e)
+	} else if sc != http.StatusOK {
+		return nil, fmt.Errorf("key set request returned status %d", sc)
+	}
+
+	km := make(map[string]interface{}, len(ks.Keys))
+	for _, j := range ks.Keys {
+
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+
+		pk, e := j.publicKey()
+		if e != nil {
+			continue
+		}
+
+		km[j.Kid] = pk
+	}
+
+	c.mu.Lock()
+	c.keys = km
+	c.keysAt = time.Now()
+	c.mu.Unlock()
+
+	k, ok = km[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return k, nil
+}
+
+// publicKey decode RSA or EC public key
+func (j *jwk) publicKey() (interface{}, error) {
+
+	switch j.Kty {
+	case "RSA":
+
+		n, e := decodeInt(j.N)
+		if e != nil {
+			return nil, e
+		}
+
+		x, e := decodeInt(j.E)
+		if e != nil {
+			return nil, e
+		}
+
+		return &rsa.PublicKey{N: n, E: int(x.Int64())}, nil
+
+	case "EC":
+
+		var cv elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			cv = elliptic.P256()
+		case "P-384":
+			cv = elliptic.P384()
+		case "P-521":
+			cv = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", j.Crv)
+		}
+
+		x, e := decodeInt(j.X)
+		if e != nil {
+			return nil, e
+		}
+
+		y, e := decodeInt(j.Y)
+		if e != nil {
+			return nil, e
+		}
+
+		if !cv.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: cv, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", j.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+
+	bb, e := base64.RawURLEncoding.DecodeString(s)
+	if e != nil || len(bb) < 1 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(bb), nil
+}
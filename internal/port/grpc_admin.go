@@ -0,0 +1,189 @@
+package port
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	userpb "github.com/trevatk/go-chat/proto/user/v1"
+)
+
+// AdminGrpcPermissions permission required by full gRPC method names of administrative methods
+var AdminGrpcPermissions = map[string]domain.Permission{
+	"/user.UserService/ListUsers":         domain.PermissionListUsers,
+	"/user.UserService/SetUserRole":       domain.PermissionManageRoles,
+	"/user.UserService/DisableUser":       domain.PermissionDisableUsers,
+	"/user.UserService/EnableUser":        domain.PermissionDisableUsers,
+	"/user.UserService/ListUserSessions":  domain.PermissionManageSessions,
+	"/user.UserService/RevokeUserSession": domain.PermissionManageSessions,
+	"/user.UserService/LogoutUser":        domain.PermissionManageSessions,
+}
+
+// ListUsers retrieve page of users ordered by username
+func (g *GrpcServer) ListUsers(ctx context.Context, in *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+
+	p, e := g.bundle.AdminService.ListUsers(ctx, &domain.UserQuery{
+		Role:   domain.Role(in.Role),
+		Cursor: in.Cursor,
+		Limit:  in.Limit,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list users")
+	}
+
+	rsp := &userpb.ListUsersResponse{
+		Users:      make([]*userpb.User, 0, len(p.Users)),
+		NextCursor: p.NextCursor,
+	}
+
+	for _, u := range p.Users {
+		rsp.Users = append(rsp.Users, transformUser(u))
+	}
+
+	return rsp, nil
+}
+
+// SetUserRole replace global role of user
+func (g *GrpcServer) SetUserRole(ctx context.Context, in *userpb.SetUserRoleRequest) (*userpb.User, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.AdminService.SetRole(ctx, actor, uid, domain.Role(in.Role))
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to set user role")
+	}
+
+	return transformUser(u), nil
+}
+
+// DisableUser disable user and end every session of the user
+func (g *GrpcServer) DisableUser(ctx context.Context, in *userpb.DisableUserRequest) (*userpb.User, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.AdminService.Disable(ctx, actor, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to disable user")
+	}
+
+	return transformUser(u), nil
+}
+
+// EnableUser enable disabled user
+func (g *GrpcServer) EnableUser(ctx context.Context, in *userpb.EnableUserRequest) (*userpb.User, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.AdminService.Enable(ctx, actor, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to enable user")
+	}
+
+	return transformUser(u), nil
+}
+
+// ListUserSessions retrieve active sessions of user
+func (g *GrpcServer) ListUserSessions(ctx context.Context, in *userpb.ListUserSessionsRequest) (*userpb.ListSessionsResponse, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	sl, e := g.bundle.AdminService.ListSessions(ctx, actor, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list user sessions")
+	}
+
+	rsp := &userpb.ListSessionsResponse{Sessions: make([]*userpb.Session, 0, len(sl))}
+
+	for _, ss := range sl {
+		rsp.Sessions = append(rsp.Sessions, &userpb.Session{
+			Uid:        ss.UID.String(),
+			UserAgent:  ss.UserAgent,
+			IpAddress:  ss.IPAddress,
+			ExpiresAt:  timestamppb.New(ss.ExpiresAt),
+			LastUsedAt: timestamppb.New(ss.LastUsedAt),
+			CreatedAt:  timestamppb.New(ss.CreatedAt),
+		})
+	}
+
+	return rsp, nil
+}
+
+// RevokeUserSession end session of user
+func (g *GrpcServer) RevokeUserSession(ctx context.Context, in *userpb.RevokeUserSessionRequest) (*userpb.RevokeSessionResponse, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	sid, e := parseUUID("session", in.SessionId)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.AdminService.RevokeSession(ctx, actor, uid, sid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to revoke user session")
+	}
+
+	return &userpb.RevokeSessionResponse{}, nil
+}
+
+// LogoutUser end every session of user
+func (g *GrpcServer) LogoutUser(ctx context.Context, in *userpb.LogoutUserRequest) (*userpb.LogoutUserResponse, error) {
+
+	actor, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.AdminService.Logout(ctx, actor, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to logout user")
+	}
+
+	return &userpb.LogoutUserResponse{}, nil
+}
@@ -0,0 +1,176 @@
+package port
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	userpb "github.com/trevatk/go-chat/proto/user/v1"
+)
+
+// CreateAPIKey issue api key for the authenticated user or one of their bots
+func (g *GrpcServer) CreateAPIKey(ctx context.Context, in *userpb.CreateAPIKeyRequest) (*userpb.APIKey, error) {
+
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no name parameter provided")
+	} else if len(in.Scopes) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "no scopes parameter provided")
+	}
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	owner, e := grpcKeyOwner(uid, in.BotId)
+	if e != nil {
+		return nil, e
+	}
+
+	nk := &domain.NewAPIKey{Name: in.Name}
+
+	for _, s := range in.Scopes {
+		nk.Scopes = append(nk.Scopes, domain.Scope(s))
+	}
+
+	if in.ExpiresAt != nil {
+		nk.ExpiresAt = in.ExpiresAt.AsTime()
+	}
+
+	k, e := g.bundle.APIKeyService.Create(ctx, uid, owner, nk)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create api key")
+	}
+
+	return transformAPIKey(k), nil
+}
+
+// ListAPIKeys retrieve unrevoked api keys of the authenticated user or one of their bots
+func (g *GrpcServer) ListAPIKeys(ctx context.Context, in *userpb.ListAPIKeysRequest) (*userpb.ListAPIKeysResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	owner, e := grpcKeyOwner(uid, in.BotId)
+	if e != nil {
+		return nil, e
+	}
+
+	kl, e := g.bundle.APIKeyService.List(ctx, uid, owner)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list api keys")
+	}
+
+	rsp := &userpb.ListAPIKeysResponse{ApiKeys: make([]*userpb.APIKey, 0, len(kl))}
+
+	for _, k := range kl {
+		rsp.ApiKeys = append(rsp.ApiKeys, transformAPIKey(k))
+	}
+
+	return rsp, nil
+}
+
+// RevokeAPIKey revoke api key of the authenticated user or one of their bots
+func (g *GrpcServer) RevokeAPIKey(ctx context.Context, in *userpb.RevokeAPIKeyRequest) (*userpb.RevokeAPIKeyResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	kid, e := parseUUID("api key", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	owner, e := grpcKeyOwner(uid, in.BotId)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.APIKeyService.Revoke(ctx, uid, owner, kid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to revoke api key")
+	}
+
+	return &userpb.RevokeAPIKeyResponse{}, nil
+}
+
+// CreateBot create bot owned by the authenticated user
+func (g *GrpcServer) CreateBot(ctx context.Context, in *userpb.CreateBotRequest) (*userpb.User, error) {
+
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "no username parameter provided")
+	}
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.UserService.CreateBot(ctx, uid, in.Username)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create bot")
+	}
+
+	return transformUser(u), nil
+}
+
+// ListBots retrieve bots owned by the authenticated user
+func (g *GrpcServer) ListBots(ctx context.Context, _ *userpb.ListBotsRequest) (*userpb.ListBotsResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	ul, e := g.bundle.UserService.ListBots(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list bots")
+	}
+
+	rsp := &userpb.ListBotsResponse{Bots: make([]*userpb.User, 0, len(ul))}
+
+	for _, u := range ul {
+		rsp.Bots = append(rsp.Bots, transformUser(u))
+	}
+
+	return rsp, nil
+}
+
+// grpcKeyOwner user whose api keys are managed, the bot when set or the authenticated user
+func grpcKeyOwner(uid uuid.UUID, botID string) (uuid.UUID, error) {
+
+	if botID == "" {
+		return uid, nil
+	}
+
+	return parseUUID("bot", botID)
+}
+
+func transformAPIKey(k *domain.APIKey) *userpb.APIKey {
+
+	kp := &userpb.APIKey{
+		Uid:        k.UID.String(),
+		User:       k.User.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Key:        k.Key,
+		Scopes:     make([]string, 0, len(k.Scopes)),
+		ExpiresAt:  timestampOrNil(k.ExpiresAt),
+		LastUsedAt: timestampOrNil(k.LastUsedAt),
+		CreatedAt:  timestamppb.New(k.CreatedAt),
+	}
+
+	for _, s := range k.Scopes {
+		kp.Scopes = append(kp.Scopes, string(s))
+	}
+
+	return kp
+}
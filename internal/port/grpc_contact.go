@@ -0,0 +1,117 @@
+package port
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	contactpb "github.com/trevatk/go-chat/proto/contact/v1"
+)
+
+// AddContact add recipient to authenticated user contacts
+func (g *GrpcServer) AddContact(ctx context.Context, in *contactpb.AddContactRequest) (*contactpb.Contact, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	rID, e := parseUUID("recipient", in.Recipient)
+	if e != nil {
+		return nil, e
+	}
+
+	c, e := g.bundle.ContactService.Create(ctx, &domain.NewContact{
+		Owner:     uid,
+		Recipient: rID,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to add new contact")
+	}
+
+	return transformContact(c), nil
+}
+
+// SearchContacts search contacts by recipient username
+func (g *GrpcServer) SearchContacts(ctx context.Context, in *contactpb.SearchContactsRequest) (*contactpb.ListContactsResponse, error) {
+
+	if in.Search == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid search string parameter")
+	}
+
+	cs, e := g.bundle.ContactService.Search(ctx, in.Search)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to search contacts")
+	}
+
+	return transformContacts(cs), nil
+}
+
+// ReadContact retrieve contact by uid
+func (g *GrpcServer) ReadContact(ctx context.Context, in *contactpb.ReadContactRequest) (*contactpb.Contact, error) {
+
+	cID, e := parseUUID("contact", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	c, e := g.bundle.ContactService.Read(ctx, cID)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to read contact")
+	}
+
+	return transformContact(c), nil
+}
+
+// ListContacts retrieve authenticated user contacts
+func (g *GrpcServer) ListContacts(ctx context.Context, _ *contactpb.ListContactsRequest) (*contactpb.ListContactsResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cs, e := g.bundle.ContactService.List(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to list contacts")
+	}
+
+	return transformContacts(cs), nil
+}
+
+// DeleteContact remove contact by uid
+func (g *GrpcServer) DeleteContact(ctx context.Context, in *contactpb.DeleteContactRequest) (*contactpb.DeleteContactResponse, error) {
+
+	cID, e := parseUUID("contact", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.ContactService.Delete(ctx, cID)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to delete contact")
+	}
+
+	return &contactpb.DeleteContactResponse{}, nil
+}
+
+func transformContact(contact *domain.Contact) *contactpb.Contact {
+	return &contactpb.Contact{
+		Uid:       contact.UID.String(),
+		Owner:     contact.Owner.String(),
+		Recipient: contact.Recipient.String(),
+	}
+}
+
+func transformContacts(contacts []*domain.Contact) *contactpb.ListContactsResponse {
+
+	cs := make([]*contactpb.Contact, 0, len(contacts))
+
+	for _, c := range contacts {
+		cs = append(cs, transformContact(c))
+	}
+
+	return &contactpb.ListContactsResponse{Contacts: cs}
+}
@@ -0,0 +1,430 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	conversationpb "github.com/trevatk/go-chat/proto/conversation/v1"
+)
+
+// CreateConversation create new conversation with the authenticated user as admin
+func (g *GrpcServer) CreateConversation(ctx context.Context, in *conversationpb.CreateConversationRequest) (*conversationpb.Conversation, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	if len(in.Recipients) < 2 {
+		return nil, status.Error(codes.InvalidArgument, "invalid number of recipients for conversation")
+	}
+
+	rIDs := make([]uuid.UUID, 0, len(in.Recipients))
+
+	for _, r := range in.Recipients {
+
+		rID, e := parseUUID("recipient", r)
+		if e != nil {
+			return nil, e
+		}
+
+		rIDs = append(rIDs, rID)
+	}
+
+	c, e := g.bundle.MessengerService.CreateConversation(ctx, &domain.NewConversation{
+		Creator:    uid,
+		Recipients: rIDs,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create new conversation")
+	}
+
+	return transformConversation(c), nil
+}
+
+// ListConversations retrieve authenticated user conversation inbox
+func (g *GrpcServer) ListConversations(ctx context.Context, in *conversationpb.ListConversationsRequest) (*conversationpb.ListConversationsResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit parameter")
+	}
+
+	f := domain.ConversationFilterUnarchived
+
+	switch in.Filter {
+	case conversationpb.CONVERSATION_FILTER_UNARCHIVED:
+	case conversationpb.CONVERSATION_FILTER_ARCHIVED:
+		f = domain.ConversationFilterArchived
+	case conversationpb.CONVERSATION_FILTER_ALL:
+		f = domain.ConversationFilterAll
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid filter parameter")
+	}
+
+	p, e := g.bundle.MessengerService.ListConversations(ctx, &domain.ConversationQuery{
+		User:   uid,
+		Filter: f,
+		Cursor: in.Cursor,
+		Limit:  in.Limit,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to list conversations")
+	}
+
+	cs := make([]*conversationpb.Conversation, 0, len(p.Conversations))
+
+	for _, c := range p.Conversations {
+		cs = append(cs, transformConversation(c))
+	}
+
+	return &conversationpb.ListConversationsResponse{
+		Conversations: cs,
+		NextCursor:    p.NextCursor,
+	}, nil
+}
+
+// UpdatePreferences update authenticated user conversation preferences
+func (g *GrpcServer) UpdatePreferences(ctx context.Context, in *conversationpb.UpdatePreferencesRequest) (*conversationpb.Preferences, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.Preferences == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing preferences parameters")
+	}
+
+	cp := &domain.ConversationPreferences{
+		ConversationUUID: cID,
+		User:             uid,
+		Archived:         in.Preferences.Archived,
+		Pinned:           in.Preferences.Pinned,
+	}
+
+	if in.Preferences.MutedUntil != nil {
+		cp.MutedUntil = in.Preferences.MutedUntil.AsTime()
+	}
+
+	cp, e = g.bundle.MessengerService.UpdatePreferences(ctx, cp)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to update conversation preferences")
+	}
+
+	return &conversationpb.Preferences{
+		MutedUntil: timestampOrNil(cp.MutedUntil),
+		Archived:   cp.Archived,
+		Pinned:     cp.Pinned,
+	}, nil
+}
+
+// MarkRead mark conversation messages read for authenticated user
+func (g *GrpcServer) MarkRead(ctx context.Context, in *conversationpb.MarkReadRequest) (*conversationpb.MarkReadResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.MessengerService.MarkRead(ctx, cID, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to mark conversation read")
+	}
+
+	return &conversationpb.MarkReadResponse{}, nil
+}
+
+// SendMessage send message to conversation as the authenticated user
+func (g *GrpcServer) SendMessage(ctx context.Context, in *conversationpb.SendMessageRequest) (*conversationpb.Message, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "no message parameter provided")
+	}
+
+	ev, e := g.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           uid,
+		ConversationUUID: cID,
+		Message:          in.Message,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to send message")
+	}
+
+	return transformMessage(ev), nil
+}
+
+// ListMessages retrieve page of conversation messages in seq order
+func (g *GrpcServer) ListMessages(ctx context.Context, in *conversationpb.ListMessagesRequest) (*conversationpb.ListMessagesResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.AfterSeq < 0 || in.Limit < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "after_seq and limit must not be negative")
+	}
+
+	evs, e := g.bundle.MessengerService.ListMessages(ctx, &domain.MessageQuery{
+		ConversationUUID: cID,
+		Requester:        uid,
+		AfterSeq:         in.AfterSeq,
+		Limit:            in.Limit,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list messages")
+	}
+
+	ms := make([]*conversationpb.Message, 0, len(evs))
+
+	for _, ev := range evs {
+		ms = append(ms, transformMessage(ev))
+	}
+
+	return &conversationpb.ListMessagesResponse{Messages: ms}, nil
+}
+
+// CreateInvite create conversation invite
+func (g *GrpcServer) CreateInvite(ctx context.Context, in *conversationpb.CreateInviteRequest) (*conversationpb.Invite, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.MaxUses < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid max uses parameter")
+	} else if in.ExpiresIn < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid expires in parameter")
+	}
+
+	i, e := g.bundle.InviteService.Create(ctx, &domain.NewInvite{
+		ConversationUUID: cID,
+		Creator:          uid,
+		MaxUses:          in.MaxUses,
+		ExpiresIn:        time.Duration(in.ExpiresIn) * time.Second,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create invite")
+	}
+
+	return transformInvite(i), nil
+}
+
+// ListInvites retrieve conversation invites
+func (g *GrpcServer) ListInvites(ctx context.Context, in *conversationpb.ListInvitesRequest) (*conversationpb.ListInvitesResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	il, e := g.bundle.InviteService.List(ctx, cID, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list invites")
+	}
+
+	is := make([]*conversationpb.Invite, 0, len(il))
+
+	for _, i := range il {
+		is = append(is, transformInvite(i))
+	}
+
+	return &conversationpb.ListInvitesResponse{Invites: is}, nil
+}
+
+// RevokeInvite revoke conversation invite
+func (g *GrpcServer) RevokeInvite(ctx context.Context, in *conversationpb.RevokeInviteRequest) (*conversationpb.RevokeInviteResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	iID, e := parseUUID("invite", in.Invite)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.InviteService.Revoke(ctx, cID, iID, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to revoke invite")
+	}
+
+	return &conversationpb.RevokeInviteResponse{}, nil
+}
+
+// ListInviteRedemptions retrieve invite usage audit records
+func (g *GrpcServer) ListInviteRedemptions(ctx context.Context, in *conversationpb.ListInviteRedemptionsRequest) (*conversationpb.ListInviteRedemptionsResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cID, e := parseUUID("conversation", in.Conversation)
+	if e != nil {
+		return nil, e
+	}
+
+	iID, e := parseUUID("invite", in.Invite)
+	if e != nil {
+		return nil, e
+	}
+
+	rl, e := g.bundle.InviteService.ListRedemptions(ctx, cID, iID, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list invite redemptions")
+	}
+
+	rs := make([]*conversationpb.InviteRedemption, 0, len(rl))
+
+	for _, r := range rl {
+		rs = append(rs, &conversationpb.InviteRedemption{
+			Uid:       r.UID.String(),
+			Invite:    r.InviteUID.String(),
+			User:      r.User.String(),
+			CreatedAt: timestamppb.New(r.CreatedAt),
+		})
+	}
+
+	return &conversationpb.ListInviteRedemptionsResponse{Redemptions: rs}, nil
+}
+
+// PreviewInvite retrieve conversation summary by invite token
+func (g *GrpcServer) PreviewInvite(ctx context.Context, in *conversationpb.PreviewInviteRequest) (*conversationpb.InvitePreview, error) {
+
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "no invite token provided")
+	}
+
+	p, e := g.bundle.InviteService.Preview(ctx, in.Token)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to preview invite")
+	}
+
+	return &conversationpb.InvitePreview{
+		Conversation: p.ConversationUUID.String(),
+		MemberCount:  p.Members,
+		ExpiresAt:    timestampOrNil(p.ExpiresAt),
+		CreatedAt:    timestamppb.New(p.CreatedAt),
+	}, nil
+}
+
+// RedeemInvite join conversation as the authenticated user using invite token
+func (g *GrpcServer) RedeemInvite(ctx context.Context, in *conversationpb.RedeemInviteRequest) (*conversationpb.Conversation, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "no invite token provided")
+	}
+
+	c, e := g.bundle.InviteService.Redeem(ctx, in.Token, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to redeem invite")
+	}
+
+	return transformConversation(c), nil
+}
+
+func transformConversation(conversation *domain.Conversation) *conversationpb.Conversation {
+
+	c := &conversationpb.Conversation{
+		Uid:         conversation.UID.String(),
+		CreatedAt:   timestamppb.New(conversation.CreatedAt),
+		UpdatedAt:   timestamppb.New(conversation.UpdatedAt),
+		MutedUntil:  timestampOrNil(conversation.MutedUntil),
+		Archived:    conversation.Archived,
+		Pinned:      !conversation.PinnedAt.IsZero(),
+		UnreadCount: conversation.UnreadCount,
+		MemberCount: conversation.MemberCount,
+		MemberNames: conversation.MemberNames,
+	}
+
+	if conversation.LastMessage != nil {
+		c.LastMessage = transformMessage(conversation.LastMessage)
+	}
+
+	return c
+}
+
+func transformMessage(envelope *domain.Envelope) *conversationpb.Message {
+	return &conversationpb.Message{
+		Uid:             envelope.UID.String(),
+		Conversation:    envelope.ConversationUUID.String(),
+		Sender:          envelope.Sender.String(),
+		Message:         envelope.Message,
+		CreatedAt:       timestamppb.New(envelope.CreatedAt),
+		Seq:             envelope.Seq,
+		ClientMessageId: envelope.ClientMessageID,
+	}
+}
+
+func transformInvite(invite *domain.Invite) *conversationpb.Invite {
+	return &conversationpb.Invite{
+		Uid:          invite.UID.String(),
+		Conversation: invite.ConversationUUID.String(),
+		Creator:      invite.Creator.String(),
+		Token:        invite.Token,
+		MaxUses:      invite.MaxUses,
+		Uses:         invite.Uses,
+		ExpiresAt:    timestampOrNil(invite.ExpiresAt),
+		RevokedAt:    timestampOrNil(invite.RevokedAt),
+		CreatedAt:    timestamppb.New(invite.CreatedAt),
+	}
+}
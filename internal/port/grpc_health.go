@@ -0,0 +1,112 @@
+package port
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// healthCheckInterval time between database and broker health checks
+	healthCheckInterval = time.Second * 10
+	// healthCheckTimeout maximum duration of a single health check
+	healthCheckTimeout = time.Second * 3
+)
+
+// GrpcHealth grpc.health.v1 service reporting database and broker health
+//
+// every registered service and the overall server status
+// are serving while the database and broker pings succeed
+type GrpcHealth struct {
+	bundle   *domain.Bundle
+	srv      *health.Server
+	services []string
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewGrpcHealth create new grpc health service
+func NewGrpcHealth(bundle *domain.Bundle) *GrpcHealth {
+	return &GrpcHealth{
+		bundle: bundle,
+		srv:    health.NewServer(),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Register register health service on grpc server
+//
+// must be called after every other service is registered
+// so each service reports its own status
+func (gh *GrpcHealth) Register(s *grpc.Server) {
+
+	gh.services = []string{""}
+	for name := range s.GetServiceInfo() {
+		gh.services = append(gh.services, name)
+	}
+
+	healthpb.RegisterHealthServer(s, gh.srv)
+}
+
+// Start run initial health check and keep checking on an interval until shutdown
+func (gh *GrpcHealth) Start(ctx context.Context) {
+
+	gh.Check(ctx)
+
+	go func() {
+
+		t := time.NewTicker(healthCheckInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-gh.stop:
+				return
+			case <-t.C:
+				gh.Check(ctx)
+			}
+		}
+	}()
+}
+
+// Check ping database and broker and update serving status of every service
+func (gh *GrpcHealth) Check(ctx context.Context) {
+
+	cctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	st := healthpb.HealthCheckResponse_SERVING
+
+	for _, ping := range []func(context.Context) error{
+		gh.bundle.HealthService.PingDatabase,
+		gh.bundle.HealthService.PingBroker,
+	} {
+
+		e := ping(cctx)
+		if e != nil {
+			logging.FromContext(ctx).Errorf("health check failed %v", e)
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+			break
+		}
+	}
+
+	for _, s := range gh.services {
+		gh.srv.SetServingStatus(s, st)
+	}
+}
+
+// Shutdown stop health checks and report every service as not serving
+func (gh *GrpcHealth) Shutdown() {
+	gh.once.Do(func() {
+		close(gh.stop)
+		gh.srv.Shutdown()
+	})
+}
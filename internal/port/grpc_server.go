@@ -1,36 +1,126 @@
 package port
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"time"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/google/uuid"
 	"github.com/trevatk/go-chat/internal/domain"
+	mw "github.com/trevatk/go-chat/internal/port/middleware"
+	contactpb "github.com/trevatk/go-chat/proto/contact/v1"
+	conversationpb "github.com/trevatk/go-chat/proto/conversation/v1"
 	pb "github.com/trevatk/go-chat/proto/messenger/v1"
+	userpb "github.com/trevatk/go-chat/proto/user/v1"
 	"github.com/trevatk/go-pkg/logging"
 )
 
+// PublicGrpcMethods full gRPC method names served without a token
+var PublicGrpcMethods = []string{
+	"/user.UserService/CreateUser",
+	"/user.UserService/LoginUser",
+	"/user.UserService/RefreshToken",
+	"/user.UserService/AnswerLoginChallenge",
+	"/user.UserService/RequestPasswordReset",
+	"/user.UserService/ResetPassword",
+	"/user.UserService/VerifyEmail",
+	"/conversation.ConversationService/PreviewInvite",
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+}
+
+// ReadGrpcMethods full gRPC method names api keys call with the read scope
+//
+// every other authenticated method requires the write scope
+var ReadGrpcMethods = []string{
+	"/user.UserService/ReadUser",
+	"/user.UserService/SearchUsers",
+	"/contact.ContactService/SearchContacts",
+	"/contact.ContactService/ReadContact",
+	"/contact.ContactService/ListContacts",
+	"/conversation.ConversationService/ListConversations",
+	"/conversation.ConversationService/ListMessages",
+	"/conversation.ConversationService/ListInvites",
+	"/conversation.ConversationService/ListInviteRedemptions",
+	"/messenger.MessengerService/StreamEnvelopes",
+}
+
+// SessionGrpcMethods full gRPC method names api keys may not call
+//
+// managing the account, its sessions, api keys and other users requires an access token
+var SessionGrpcMethods = []string{
+	"/user.UserService/UpdateUser",
+	"/user.UserService/ListSessions",
+	"/user.UserService/RevokeSession",
+	"/user.UserService/Logout",
+	"/user.UserService/LogoutAll",
+	"/user.UserService/ChangePassword",
+	"/user.UserService/EnrollTwoFactor",
+	"/user.UserService/ConfirmTwoFactor",
+	"/user.UserService/DisableTwoFactor",
+	"/user.UserService/CreateAPIKey",
+	"/user.UserService/ListAPIKeys",
+	"/user.UserService/RevokeAPIKey",
+	"/user.UserService/CreateBot",
+	"/user.UserService/ListBots",
+	"/user.UserService/ListUsers",
+	"/user.UserService/SetUserRole",
+	"/user.UserService/DisableUser",
+	"/user.UserService/EnableUser",
+	"/user.UserService/ListUserSessions",
+	"/user.UserService/RevokeUserSession",
+	"/user.UserService/LogoutUser",
+}
+
+// ReflectionGrpcMethods full gRPC method names of the server reflection service
+var ReflectionGrpcMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
 // GrpcServer protobuf server implementation
 type GrpcServer struct {
-	bundle         *domain.Bundle
-	conversationCh map[string][]chan *pb.Envelope
+	bundle *domain.Bundle
+	keys   *mw.KeyManager
 	pb.UnimplementedMessengerServiceServer
+	userpb.UnimplementedUserServiceServer
+	contactpb.UnimplementedContactServiceServer
+	conversationpb.UnimplementedConversationServiceServer
 }
 
 // interface verification
-var _ pb.MessengerServiceServer = (*GrpcServer)(nil)
+var (
+	_ pb.MessengerServiceServer                = (*GrpcServer)(nil)
+	_ userpb.UserServiceServer                 = (*GrpcServer)(nil)
+	_ contactpb.ContactServiceServer           = (*GrpcServer)(nil)
+	_ conversationpb.ConversationServiceServer = (*GrpcServer)(nil)
+)
 
 // NewGrpcServer create new grpc server implementation
-func NewGrpcServer(bundle *domain.Bundle) *GrpcServer {
-	return &GrpcServer{
-		bundle:         bundle,
-		conversationCh: make(map[string][]chan *pb.Envelope)}
+//
+// requests are authenticated by the middleware interceptors
+func NewGrpcServer(bundle *domain.Bundle, keys *mw.KeyManager) *GrpcServer {
+	return &GrpcServer{bundle: bundle, keys: keys}
+}
+
+// Register register every protobuf service on grpc server
+func (g *GrpcServer) Register(s *grpc.Server) {
+	pb.RegisterMessengerServiceServer(s, g)
+	userpb.RegisterUserServiceServer(s, g)
+	contactpb.RegisterContactServiceServer(s, g)
+	conversationpb.RegisterConversationServiceServer(s, g)
 }
 
-// SendEnvelope persist new envelope and pass to conversation uuid channel
+// SendEnvelope persist new envelope sent by the token user
+//
+// the persisted envelope is delivered to conversation subscribers by the messenger service
 func (g *GrpcServer) SendEnvelope(stream pb.MessengerService_SendEnvelopeServer) error {
 
 	ctx := stream.Context()
@@ -46,25 +136,29 @@ func (g *GrpcServer) SendEnvelope(stream pb.MessengerService_SendEnvelopeServer)
 		return status.Errorf(codes.DataLoss, "unable to receive envelope")
 	}
 
-	ne, e := transformNewEnvelope(gne)
+	uid, e := authenticatedUser(ctx)
 	if e != nil {
-		return status.Errorf(codes.InvalidArgument, e.Error())
+		return e
 	}
 
-	ev, e := g.bundle.MessengerService.CreateMessage(ctx, ne)
+	ne, e := transformNewEnvelope(gne)
 	if e != nil {
-		logging.FromContext(ctx).Errorf("unable to send message %v", e)
-		return status.Errorf(codes.Internal, "failed to persist new envelope")
+		return status.Error(codes.InvalidArgument, e.Error())
+	}
+
+	// reject attempts to send on behalf of another user
+	if gne.Sender != "" && gne.Sender != uid.String() {
+		return status.Errorf(codes.PermissionDenied, "sender does not match authenticated user")
 	}
 
-	pbEv := transformEnvelope(ev)
+	ne.Sender = uid
 
-	s := g.conversationCh[ev.ConversationUUID.String()]
-	for _, ch := range s {
-		ch <- pbEv
+	ev, e := g.bundle.MessengerService.CreateMessage(ctx, ne)
+	if e != nil {
+		return statusFromError(ctx, e, "failed to persist new envelope")
 	}
 
-	e = stream.SendAndClose(pbEv)
+	e = stream.SendAndClose(transformEnvelope(ev))
 	if e != nil {
 		logging.FromContext(ctx).Errorf("unable to send envelope %v", e)
 		return status.Errorf(codes.Internal, "failed to send envelope")
@@ -73,22 +167,111 @@ func (g *GrpcServer) SendEnvelope(stream pb.MessengerService_SendEnvelopeServer)
 	return nil
 }
 
+// replayBatch envelopes read per replay query
+const replayBatch = 100
+
 // StreamEnvelopes stream new envelopes to client
+//
+// only conversation members are permitted to subscribe
+// when since or since_seq is provided envelopes persisted after it are replayed
+// first, the live subscription is opened before replaying so no envelope is missed
+// and envelopes already replayed are not delivered again
 func (g *GrpcServer) StreamEnvelopes(in *pb.Conversation, stream pb.MessengerService_StreamEnvelopesServer) error {
 
 	ctx := stream.Context()
 
-	ch := make(chan *pb.Envelope)
-	g.conversationCh[in.Conversation] = append(g.conversationCh[in.Conversation], ch)
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return e
+	}
+
+	cID, e := uuid.Parse(in.Conversation)
+	if e != nil {
+		return status.Errorf(codes.InvalidArgument, "unable to parse conversation uuid %v", e)
+	}
+
+	sub, e := g.bundle.MessengerService.Subscribe(ctx, cID, uid)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			return status.Errorf(codes.PermissionDenied, "not a conversation member")
+		}
+
+		logging.FromContext(ctx).Errorf("unable to subscribe to conversation %v", e)
+		return status.Errorf(codes.Internal, "failed to subscribe to conversation")
+	}
+	defer sub.Close()
+
+	// flush headers so clients know the subscription is live
+	e = stream.SendHeader(metadata.MD{})
+	if e != nil {
+		logging.FromContext(ctx).Errorf("failed to send stream header %v", e)
+		return status.Errorf(codes.Internal, "failed to stream envelopes")
+	}
+
+	// envelopes up to seq were replayed and are skipped if also published live
+	seq := in.SinceSeq
+
+	if seq == 0 && in.Since != "" {
+
+		since, e := parseUUID("since", in.Since)
+		if e != nil {
+			return e
+		}
+
+		seq, e = g.bundle.MessengerService.MessageSeq(ctx, cID, uid, since)
+		if e != nil {
+			return statusFromError(ctx, e, "failed to replay envelopes")
+		}
+	}
+
+	if seq > 0 {
+
+		for {
+
+			evs, e := g.bundle.MessengerService.ListMessages(ctx, &domain.MessageQuery{
+				ConversationUUID: cID,
+				Requester:        uid,
+				AfterSeq:         seq,
+				Limit:            replayBatch,
+			})
+			if e != nil {
+				return statusFromError(ctx, e, "failed to replay envelopes")
+			}
+
+			for _, ev := range evs {
+
+				e := stream.Send(transformEnvelope(ev))
+				if e != nil {
+					logging.FromContext(ctx).Errorf("failed to stream envelope %v", e)
+					return status.Errorf(codes.Internal, "failed to stream envelopes")
+				}
+
+				seq = ev.Seq
+			}
+
+			if len(evs) < replayBatch {
+				break
+			}
+		}
+	}
 
 	for {
 
 		select {
 		case <-ctx.Done():
 			return nil
-		case ev := <-ch:
+		case ev, ok := <-sub.Envelopes():
 
-			e := stream.Send(ev)
+			if !ok {
+				return subscriptionClosedStatus(sub)
+			}
+
+			if ev.Seq <= seq {
+				continue
+			}
+
+			e := stream.Send(transformEnvelope(ev))
 			if e != nil {
 				logging.FromContext(ctx).Errorf("failed to stream envelope %v", e)
 				return status.Errorf(codes.Internal, "failed to stream envelopes")
@@ -98,30 +281,311 @@ func (g *GrpcServer) StreamEnvelopes(in *pb.Conversation, stream pb.MessengerSer
 	}
 }
 
-func transformNewEnvelope(newEnvelope *pb.NewEnvelope) (*domain.NewEnvelope, error) {
+// Chat bidirectional stream of messages, typing signals and acks
+//
+// every request is answered with a response carrying its correlation id,
+// envelopes and events for all user conversations are delivered on the same stream
+// envelopes sent on this stream are only delivered once as the correlated response
+func (g *GrpcServer) Chat(stream pb.MessengerService_ChatServer) error {
+
+	ctx := stream.Context()
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return e
+	}
 
-	sID, e := uuid.Parse(newEnvelope.Sender)
+	sub := g.bundle.MessengerService.SubscribeUser(uid)
+	defer sub.Close()
+
+	// flush headers so clients know the subscription is live
+	e = stream.SendHeader(metadata.MD{})
 	if e != nil {
-		return nil, fmt.Errorf("unable to parse sender uuid %v", e)
+		logging.FromContext(ctx).Errorf("failed to send stream header %v", e)
+		return status.Errorf(codes.Internal, "failed to open chat")
 	}
 
+	rqs := make(chan *pb.ChatRequest)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+
+			in, e := stream.Recv()
+			if e != nil {
+				errs <- e
+				return
+			}
+
+			select {
+			case rqs <- in:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// envelopes created on this stream and already answered
+	sent := make(map[uuid.UUID]struct{})
+
+	for {
+
+		var rsp *pb.ChatResponse
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-errs:
+
+			if e == io.EOF {
+				return nil
+			}
+
+			return e
+		case in := <-rqs:
+
+			// requests are handled on this goroutine so the answered envelope
+			// is recorded before its broker delivery can be read
+			var ev *domain.Envelope
+			rsp, ev = g.handleChatRequest(ctx, uid, in)
+
+			// duplicate sends are not published again
+			if ev != nil && !ev.Duplicate {
+				sent[ev.UID] = struct{}{}
+			}
+
+		case ev, ok := <-sub.Envelopes():
+
+			if !ok {
+				return subscriptionClosedStatus(sub)
+			}
+
+			if _, ok := sent[ev.UID]; ok {
+				delete(sent, ev.UID)
+				continue
+			}
+
+			rsp = &pb.ChatResponse{Payload: &pb.ChatResponse_Envelope{Envelope: transformEnvelope(ev)}}
+
+		case evt, ok := <-sub.Events():
+
+			if !ok {
+				return subscriptionClosedStatus(sub)
+			}
+
+			rsp = &pb.ChatResponse{Payload: &pb.ChatResponse_Event{Event: transformEvent(evt)}}
+		}
+
+		e := stream.Send(rsp)
+		if e != nil {
+			logging.FromContext(ctx).Errorf("failed to send chat response %v", e)
+			return status.Errorf(codes.Internal, "failed to send chat response")
+		}
+	}
+}
+
+// handleChatRequest process chat request and build correlated response
+//
+// failures are reported on the response and do not end the stream,
+// the created envelope is returned for message requests
+func (g *GrpcServer) handleChatRequest(ctx context.Context, uid uuid.UUID, in *pb.ChatRequest) (*pb.ChatResponse, *domain.Envelope) {
+
+	rsp := &pb.ChatResponse{CorrelationId: in.CorrelationId}
+
+	var (
+		e  error
+		ev *domain.Envelope
+	)
+
+	switch p := in.Payload.(type) {
+	case *pb.ChatRequest_Message:
+
+		var cID uuid.UUID
+		cID, e = parseUUID("conversation", p.Message.Conversation)
+		if e != nil {
+			break
+		}
+
+		ev, e = g.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+			Sender:           uid,
+			ConversationUUID: cID,
+			Message:          p.Message.Message,
+			ClientMessageID:  p.Message.ClientMessageId,
+		})
+		if e != nil {
+			e = statusFromError(ctx, e, "failed to persist new envelope")
+			break
+		}
+
+		rsp.Payload = &pb.ChatResponse_Envelope{Envelope: transformEnvelope(ev)}
+
+	case *pb.ChatRequest_Typing:
+
+		var cID uuid.UUID
+		cID, e = parseUUID("conversation", p.Typing.Conversation)
+		if e != nil {
+			break
+		}
+
+		e = g.bundle.MessengerService.SendTyping(ctx, cID, uid, p.Typing.Active)
+		if e != nil {
+			e = statusFromError(ctx, e, "failed to send typing signal")
+			break
+		}
+
+		rsp.Payload = &pb.ChatResponse_Accepted{Accepted: &pb.Accepted{}}
+
+	case *pb.ChatRequest_Ack:
+
+		var cID, eID uuid.UUID
+		cID, e = parseUUID("conversation", p.Ack.Conversation)
+		if e != nil {
+			break
+		}
+
+		eID, e = parseUUID("envelope", p.Ack.Envelope)
+		if e != nil {
+			break
+		}
+
+		e = g.bundle.MessengerService.Acknowledge(ctx, cID, uid, eID)
+		if e != nil {
+			e = statusFromError(ctx, e, "failed to acknowledge envelope")
+			break
+		}
+
+		rsp.Payload = &pb.ChatResponse_Accepted{Accepted: &pb.Accepted{}}
+
+	default:
+		e = status.Error(codes.InvalidArgument, "missing chat request payload")
+	}
+
+	if e != nil {
+		st := status.Convert(e)
+		rsp.Payload = &pb.ChatResponse_Error{Error: &pb.ChatError{
+			Code:    int32(st.Code()),
+			Message: st.Message(),
+		}}
+	}
+
+	return rsp, ev
+}
+
+func transformNewEnvelope(newEnvelope *pb.NewEnvelope) (*domain.NewEnvelope, error) {
+
 	cID, e := uuid.Parse(newEnvelope.Conversation)
 	if e != nil {
 		return nil, fmt.Errorf("unable to parse conversation uuid %v", e)
 	}
 
 	return &domain.NewEnvelope{
-		Sender:           sID,
 		ConversationUUID: cID,
 		Message:          newEnvelope.Message,
+		ClientMessageID:  newEnvelope.ClientMessageId,
 	}, nil
 }
 
 func transformEnvelope(envelope *domain.Envelope) *pb.Envelope {
 	return &pb.Envelope{
-		Uid:     envelope.UID.String(),
-		Sender:  envelope.Sender.String(),
-		Message: envelope.Message,
-		Status:  pb.SEND_ENVELOPE_STATUS_DELIVERED,
+		Uid:             envelope.UID.String(),
+		Sender:          envelope.Sender.String(),
+		Message:         envelope.Message,
+		Status:          pb.SEND_ENVELOPE_STATUS_DELIVERED,
+		Conversation:    envelope.ConversationUUID.String(),
+		Seq:             envelope.Seq,
+		ClientMessageId: envelope.ClientMessageID,
+	}
+}
+
+func transformEvent(event *domain.Event) *pb.Event {
+
+	evt := &pb.Event{
+		Conversation: event.ConversationUUID.String(),
+		User:         event.User.String(),
 	}
+
+	switch event.Type {
+	case domain.EventTypingStarted:
+		evt.Type = pb.EVENT_TYPE_TYPING_STARTED
+	case domain.EventTypingStopped:
+		evt.Type = pb.EVENT_TYPE_TYPING_STOPPED
+	case domain.EventAcknowledged:
+		evt.Type = pb.EVENT_TYPE_ACKNOWLEDGED
+		evt.Envelope = event.EnvelopeUID.String()
+	}
+
+	return evt
+}
+
+// statusFromError map domain errors to grpc status errors
+//
+// unexpected errors are logged and reported as internal with msg
+func statusFromError(ctx context.Context, e error, msg string) error {
+
+	switch e {
+	case domain.ErrResourceNotFound:
+		return status.Error(codes.NotFound, e.Error())
+	case domain.ErrUniqueExists:
+		return status.Error(codes.AlreadyExists, e.Error())
+	case domain.ErrForbidden, domain.ErrInvalidCredentials, domain.ErrEmailNotVerified, domain.ErrAccountDisabled:
+		return status.Error(codes.PermissionDenied, e.Error())
+	case domain.ErrInviteInvalid, domain.ErrEmailAlreadyVerified, domain.ErrTwoFactorEnabled, domain.ErrTwoFactorNotEnabled:
+		return status.Error(codes.FailedPrecondition, e.Error())
+	case domain.ErrInvalidCursor, domain.ErrMinRecipients, domain.ErrInvalidClientMessageID,
+		domain.ErrInvalidPassword, domain.ErrInvalidResetToken, domain.ErrInvalidVerificationToken, domain.ErrInvalidTwoFactorCode,
+		domain.ErrInvalidAPIKeyRequest, domain.ErrInvalidRole:
+		return status.Error(codes.InvalidArgument, e.Error())
+	case domain.ErrInvalidRefreshToken, domain.ErrRefreshTokenReused, domain.ErrInvalidLoginChallenge, domain.ErrInvalidAPIKey:
+		return status.Error(codes.Unauthenticated, e.Error())
+	case domain.ErrTooManyRequests, domain.ErrTooManyAPIKeys:
+		return status.Error(codes.ResourceExhausted, e.Error())
+	}
+
+	logging.FromContext(ctx).Errorf("%s %v", msg, e)
+	return status.Error(codes.Internal, msg)
+}
+
+// subscriptionClosedStatus status ending a stream whose subscription was closed by the broker
+//
+// unavailable tells clients the server is going away and to reconnect elsewhere,
+// resource exhausted tells clients they fell behind and should resume from their last seq
+func subscriptionClosedStatus(sub *domain.Subscription) error {
+
+	if sub.Err() == domain.ErrShuttingDown {
+		return status.Error(codes.Unavailable, "server shutting down, reconnect")
+	}
+
+	return status.Error(codes.ResourceExhausted, "stream fell behind, reconnect")
+}
+
+// authenticatedUser parse user id set by the auth interceptors
+func authenticatedUser(ctx context.Context) (uuid.UUID, error) {
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "invalid token claims")
+	}
+
+	return uid, nil
+}
+
+// parseUUID parse uuid request field
+func parseUUID(field, value string) (uuid.UUID, error) {
+
+	uid, e := uuid.Parse(value)
+	if e != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "unable to parse %s uuid %v", field, e)
+	}
+
+	return uid, nil
+}
+
+// timestampOrNil zero time is transformed to nil timestamp
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
 }
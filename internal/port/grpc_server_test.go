@@ -0,0 +1,1630 @@
+package port_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-chat/internal/port"
+	"github.com/trevatk/go-chat/internal/port/middleware"
+	contactpb "github.com/trevatk/go-chat/proto/contact/v1"
+	conversationpb "github.com/trevatk/go-chat/proto/conversation/v1"
+	pb "github.com/trevatk/go-chat/proto/messenger/v1"
+	userpb "github.com/trevatk/go-chat/proto/user/v1"
+	"github.com/trevatk/go-pkg/db"
+)
+
+type GrpcServerSuite struct {
+	suite.Suite
+	bundle *domain.Bundle
+	keys   *middleware.KeyManager
+	mail   *mailbox
+	db     *sql.DB
+	srv    *grpc.Server
+	health *port.GrpcHealth
+	conn   *grpc.ClientConn
+	client pb.MessengerServiceClient
+
+	users         userpb.UserServiceClient
+	contacts      contactpb.ContactServiceClient
+	conversations conversationpb.ConversationServiceClient
+}
+
+func (s *GrpcServerSuite) SetupTest() {
+
+	a := assert.New(s.T())
+
+	_ = os.Mkdir("testfiles", os.ModePerm)
+
+	_ = os.Mkdir("testfiles/certs", os.ModePerm)
+
+	_ = os.Mkdir("testfiles/db", os.ModePerm)
+
+	f, e := os.Create("testfiles/db/chat.db")
+	a.NoError(e)
+	_ = f.Close()
+
+	pk, e := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	a.NoError(e)
+
+	kb, e := x509.MarshalECPrivateKey(pk)
+	a.NoError(e)
+
+	bb := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kb})
+
+	e = os.WriteFile("testfiles/certs/privateKey.der", bb, 0600)
+	a.NoError(e)
+
+	sdb, e := db.NewSQLite()
+	a.NoError(e)
+
+	e = db.MigrateSQLite(sdb)
+	a.NoError(e)
+
+	s.db = sdb
+	s.mail = &mailbox{}
+	s.bundle = domain.NewBundle(sdb, s.mail, nil)
+
+	k, e := middleware.NewPrivateKey()
+	a.NoError(e)
+
+	s.keys, e = middleware.NewKeyManager(k)
+	a.NoError(e)
+
+	auth := middleware.NewAuthenticator(s.keys)
+	auth.AllowUnauthenticated(port.PublicGrpcMethods...)
+	auth.CheckRevocations(s.bundle.RevocationList)
+	auth.AcceptAPIKeys(s.bundle.APIKeyService)
+	auth.AllowReadScope(port.ReadGrpcMethods...)
+	auth.RequireSessionFor(port.SessionGrpcMethods...)
+	auth.AuthorizeWith(s.bundle.AccessControl)
+	auth.RequirePermissions(port.AdminGrpcPermissions)
+	a.NoError(s.bundle.RevocationList.Start(context.TODO()))
+
+	tr := middleware.NewTracing(zap.NewNop())
+
+	li := bufconn.Listen(1024 * 1024)
+
+	s.srv = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(tr.UnaryInterceptor, middleware.MetricsUnaryInterceptor, auth.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(tr.StreamInterceptor, middleware.MetricsStreamInterceptor, auth.StreamInterceptor),
+	)
+	port.NewGrpcServer(s.bundle, s.keys).Register(s.srv)
+
+	s.health = port.NewGrpcHealth(s.bundle)
+	s.health.Register(s.srv)
+
+	go func() { _ = s.srv.Serve(li) }()
+
+	s.conn, e = grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return li.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	a.NoError(e)
+
+	s.client = pb.NewMessengerServiceClient(s.conn)
+	s.users = userpb.NewUserServiceClient(s.conn)
+	s.contacts = contactpb.NewContactServiceClient(s.conn)
+	s.conversations = conversationpb.NewConversationServiceClient(s.conn)
+}
+
+func (s *GrpcServerSuite) TearDownTest() {
+	_ = s.conn.Close()
+	s.health.Shutdown()
+	s.srv.Stop()
+	s.bundle.RevocationList.Shutdown()
+}
+
+func (s *GrpcServerSuite) TestHealth() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+	hc := healthpb.NewHealthClient(s.conn)
+
+	s.health.Check(ctx)
+
+	// health checks do not require a token
+
+	for _, svc := range []string{"", "messenger.MessengerService", "user.UserService"} {
+		rsp, e := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: svc})
+		if a.NoError(e) {
+			a.Equal(healthpb.HealthCheckResponse_SERVING, rsp.Status)
+		}
+	}
+
+	// database unavailable
+	a.NoError(s.db.Close())
+	s.health.Check(ctx)
+
+	rsp, e := hc.Check(ctx, &healthpb.HealthCheckRequest{})
+	if a.NoError(e) {
+		a.Equal(healthpb.HealthCheckResponse_NOT_SERVING, rsp.Status)
+	}
+}
+
+func (s *GrpcServerSuite) TestSendEnvelope() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+	oID := s.createUser("jim.doe", "jim.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+
+	cases := []struct {
+		expected codes.Code
+		token    string
+		payload  *pb.NewEnvelope
+	}{
+		{
+			// missing token
+			expected: codes.Unauthenticated,
+			payload: &pb.NewEnvelope{
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+		{
+			// token signed by another key
+			expected: codes.Unauthenticated,
+			token:    s.foreignToken(sID),
+			payload: &pb.NewEnvelope{
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+		{
+			// member spoofing another member as sender
+			expected: codes.PermissionDenied,
+			token:    s.token(sID),
+			payload: &pb.NewEnvelope{
+				Sender:       rID.String(),
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+		{
+			// non member spoofing a member as sender
+			expected: codes.PermissionDenied,
+			token:    s.token(oID),
+			payload: &pb.NewEnvelope{
+				Sender:       sID.String(),
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+		{
+			// non member
+			expected: codes.PermissionDenied,
+			token:    s.token(oID),
+			payload: &pb.NewEnvelope{
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+		{
+			// invalid conversation
+			expected: codes.InvalidArgument,
+			token:    s.token(sID),
+			payload: &pb.NewEnvelope{
+				Conversation: "conversation",
+				Message:      "hello",
+			},
+		},
+		{
+			// success
+			expected: codes.OK,
+			token:    s.token(sID),
+			payload: &pb.NewEnvelope{
+				Conversation: c.String(),
+				Message:      "hello",
+			},
+		},
+	}
+
+	for _, cs := range cases {
+
+		st, e := s.client.SendEnvelope(withToken(context.TODO(), cs.token))
+		a.NoError(e)
+
+		// send returns io.EOF when the stream is rejected before receiving
+		_ = st.Send(cs.payload)
+
+		ev, e := st.CloseAndRecv()
+		a.Equal(cs.expected, status.Code(e))
+
+		if cs.expected == codes.OK && a.NotNil(ev) {
+			a.Equal(sID.String(), ev.Sender)
+		}
+	}
+
+	evs, e := s.bundle.MessengerService.ListMessages(context.TODO(), &domain.MessageQuery{
+		ConversationUUID: c,
+		Requester:        sID,
+	})
+	a.NoError(e)
+
+	if a.Len(evs, 1) {
+		a.Equal(sID, evs[0].Sender)
+	}
+}
+
+func (s *GrpcServerSuite) TestStreamEnvelopes() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+	oID := s.createUser("jim.doe", "jim.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cases := []struct {
+		expected codes.Code
+		token    string
+		payload  *pb.Conversation
+	}{
+		{
+			// missing token
+			expected: codes.Unauthenticated,
+			payload:  &pb.Conversation{Conversation: c.String()},
+		},
+		{
+			// non member
+			expected: codes.PermissionDenied,
+			token:    s.token(oID),
+			payload:  &pb.Conversation{Conversation: c.String()},
+		},
+		{
+			// unknown conversation
+			expected: codes.PermissionDenied,
+			token:    s.token(sID),
+			payload:  &pb.Conversation{Conversation: uuid.NewString()},
+		},
+	}
+
+	for _, cs := range cases {
+
+		st, e := s.client.StreamEnvelopes(withToken(ctx, cs.token), cs.payload)
+		a.NoError(e)
+
+		_, e = st.Recv()
+		a.Equal(cs.expected, status.Code(e))
+	}
+
+	st, e := s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{Conversation: c.String()})
+	a.NoError(e)
+
+	// wait for subscription
+	_, e = st.Header()
+	a.NoError(e)
+
+	_, e = s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           sID,
+		ConversationUUID: c,
+		Message:          "hello",
+	})
+	a.NoError(e)
+
+	ev, e := st.Recv()
+	if a.NoError(e) {
+		a.Equal(sID.String(), ev.Sender)
+		a.Equal("hello", ev.Message)
+	}
+}
+
+func (s *GrpcServerSuite) TestStreamEnvelopesReplay() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// messages sent within the same second
+	evs := make([]*domain.Envelope, 0, 5)
+	for _, body := range []string{"one", "two", "three", "four", "five"} {
+		ev, e := s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+			Sender:           sID,
+			ConversationUUID: c,
+			Message:          body,
+		})
+		a.NoError(e)
+		evs = append(evs, ev)
+	}
+
+	st, e := s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{
+		Conversation: c.String(),
+		Since:        uuid.NewString(),
+	})
+	a.NoError(e)
+
+	_, e = st.Recv()
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	st, e = s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{
+		Conversation: c.String(),
+		Since:        evs[1].UID.String(),
+	})
+	a.NoError(e)
+
+	for _, ev := range evs[2:] {
+		rev, e := st.Recv()
+		if a.NoError(e) {
+			a.Equal(ev.UID.String(), rev.Uid)
+		}
+	}
+
+	ev, e := s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           sID,
+		ConversationUUID: c,
+		Message:          "six",
+	})
+	a.NoError(e)
+
+	// live delivery continues after replay without duplicates
+	rev, e := st.Recv()
+	if a.NoError(e) {
+		a.Equal(ev.UID.String(), rev.Uid)
+	}
+
+	// resume from seq
+	st, e = s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{
+		Conversation: c.String(),
+		SinceSeq:     evs[3].Seq,
+	})
+	a.NoError(e)
+
+	for _, uid := range []string{evs[4].UID.String(), ev.UID.String()} {
+		rev, e := st.Recv()
+		if a.NoError(e) {
+			a.Equal(uid, rev.Uid)
+		}
+	}
+}
+
+func (s *GrpcServerSuite) TestMessageSequence() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+	oID := s.createUser("jim.doe", "jim.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+	o := s.createConversation(sID, oID)
+
+	ctx := context.TODO()
+
+	// sequences are assigned per conversation
+	for i := 1; i <= 5; i++ {
+		for _, cID := range []uuid.UUID{c, o} {
+			ev, e := s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+				Sender:           sID,
+				ConversationUUID: cID,
+				Message:          "hello",
+			})
+			if a.NoError(e) {
+				a.Equal(int64(i), ev.Seq)
+			}
+		}
+	}
+
+	gctx := withToken(ctx, s.token(rID))
+
+	_, e := s.conversations.ListMessages(gctx, &conversationpb.ListMessagesRequest{Conversation: c.String(), AfterSeq: -1})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	seqs := make([]int64, 0, 5)
+
+	var after int64
+	for {
+
+		ml, e := s.conversations.ListMessages(gctx, &conversationpb.ListMessagesRequest{
+			Conversation: c.String(),
+			AfterSeq:     after,
+			Limit:        2,
+		})
+		if !a.NoError(e) || len(ml.Messages) == 0 {
+			break
+		}
+
+		for _, m := range ml.Messages {
+			seqs = append(seqs, m.Seq)
+		}
+
+		after = ml.Messages[len(ml.Messages)-1].Seq
+	}
+
+	a.Equal([]int64{1, 2, 3, 4, 5}, seqs)
+
+	// concurrent sends are published in sequence order
+	sub, e := s.bundle.MessengerService.Subscribe(ctx, c, rID)
+	if !a.NoError(e) {
+		return
+	}
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, e := s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+				Sender:           sID,
+				ConversationUUID: c,
+				Message:          "hello",
+			})
+			a.NoError(e)
+		}()
+	}
+
+	wg.Wait()
+
+	for i := int64(6); i <= 15; i++ {
+		select {
+		case ev := <-sub.Envelopes():
+			a.Equal(i, ev.Seq)
+		case <-time.After(time.Second):
+			a.Fail("envelope not published")
+			return
+		}
+	}
+}
+
+func (s *GrpcServerSuite) TestIdempotentSend() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	send := func(token, key string) (*pb.Envelope, error) {
+
+		st, e := s.client.SendEnvelope(withToken(ctx, token))
+		a.NoError(e)
+
+		_ = st.Send(&pb.NewEnvelope{Conversation: c.String(), Message: "hello", ClientMessageId: key})
+
+		return st.CloseAndRecv()
+	}
+
+	ev, e := send(s.token(sID), "k1")
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal("k1", ev.ClientMessageId)
+	a.Equal(int64(1), ev.Seq)
+
+	// retry returns original envelope
+	rev, e := send(s.token(sID), "k1")
+	if a.NoError(e) {
+		a.Equal(ev.Uid, rev.Uid)
+		a.Equal(ev.Seq, rev.Seq)
+	}
+
+	// keys are scoped to the sender
+	oev, e := send(s.token(rID), "k1")
+	if a.NoError(e) {
+		a.NotEqual(ev.Uid, oev.Uid)
+		a.Equal(int64(2), oev.Seq)
+	}
+
+	_, e = send(s.token(sID), strings.Repeat("k", domain.MaxClientMessageIDLength+1))
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	cs, e := s.client.Chat(withToken(ctx, s.token(sID)))
+	a.NoError(e)
+
+	_, e = cs.Header()
+	a.NoError(e)
+
+	for _, key := range []string{"k1", "k2"} {
+		e = cs.Send(&pb.ChatRequest{
+			CorrelationId: key,
+			Payload: &pb.ChatRequest_Message{Message: &pb.ChatMessage{
+				Conversation:    c.String(),
+				Message:         "hello",
+				ClientMessageId: key,
+			}},
+		})
+		a.NoError(e)
+	}
+
+	// duplicate is answered but not published again
+	rsp, e := cs.Recv()
+	if a.NoError(e) {
+		a.Equal("k1", rsp.CorrelationId)
+		a.Equal(ev.Uid, rsp.GetEnvelope().GetUid())
+	}
+
+	rsp, e = cs.Recv()
+	if a.NoError(e) {
+		a.Equal("k2", rsp.CorrelationId)
+		a.Equal(int64(3), rsp.GetEnvelope().GetSeq())
+	}
+
+	evs, e := s.bundle.MessengerService.ListMessages(ctx, &domain.MessageQuery{
+		ConversationUUID: c,
+		Requester:        sID,
+	})
+	a.NoError(e)
+	a.Len(evs, 3)
+}
+
+func (s *GrpcServerSuite) TestShutdown() {
+
+	a := assert.New(s.T())
+
+	sID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+
+	c := s.createConversation(sID, rID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	st, e := s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{Conversation: c.String()})
+	a.NoError(e)
+
+	cs, e := s.client.Chat(withToken(ctx, s.token(sID)))
+	a.NoError(e)
+
+	// wait for subscriptions
+	_, e = st.Header()
+	a.NoError(e)
+	_, e = cs.Header()
+	a.NoError(e)
+
+	s.bundle.MessengerService.Shutdown()
+
+	// open streams are told the server is going away
+	_, e = st.Recv()
+	a.Equal(codes.Unavailable, status.Code(e))
+
+	_, e = cs.Recv()
+	a.Equal(codes.Unavailable, status.Code(e))
+
+	// streams opened during shutdown end immediately
+	st, e = s.client.StreamEnvelopes(withToken(ctx, s.token(rID)), &pb.Conversation{Conversation: c.String()})
+	a.NoError(e)
+
+	_, e = st.Recv()
+	a.Equal(codes.Unavailable, status.Code(e))
+}
+
+func (s *GrpcServerSuite) TestChat() {
+
+	a := assert.New(s.T())
+
+	aID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	bID := s.createUser("john.doe", "john.doe@mailbox.com")
+	cID := s.createUser("jim.doe", "jim.doe@mailbox.com")
+	dID := s.createUser("jill.doe", "jill.doe@mailbox.com")
+
+	c := s.createConversation(aID, bID)
+	o := s.createConversation(cID, dID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	_, e := s.client.Chat(ctx)
+	a.NoError(e)
+
+	as, e := s.client.Chat(withToken(ctx, s.token(aID)))
+	a.NoError(e)
+
+	bs, e := s.client.Chat(withToken(ctx, s.token(bID)))
+	a.NoError(e)
+
+	// wait for subscriptions
+	_, e = as.Header()
+	a.NoError(e)
+	_, e = bs.Header()
+	a.NoError(e)
+
+	e = as.Send(&pb.ChatRequest{
+		CorrelationId: "a1",
+		Payload:       &pb.ChatRequest_Message{Message: &pb.ChatMessage{Conversation: c.String(), Message: "hello"}},
+	})
+	a.NoError(e)
+
+	rsp, e := as.Recv()
+	if !a.NoError(e) || !a.NotNil(rsp.GetEnvelope()) {
+		return
+	}
+	a.Equal("a1", rsp.CorrelationId)
+	a.Equal(aID.String(), rsp.GetEnvelope().Sender)
+
+	ev := rsp.GetEnvelope()
+
+	// recipient receives envelope without correlation id
+	rsp, e = bs.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEnvelope()) {
+		a.Empty(rsp.CorrelationId)
+		a.Equal(ev.Uid, rsp.GetEnvelope().Uid)
+		a.Equal(c.String(), rsp.GetEnvelope().Conversation)
+	}
+
+	e = as.Send(&pb.ChatRequest{
+		CorrelationId: "a2",
+		Payload:       &pb.ChatRequest_Typing{Typing: &pb.Typing{Conversation: c.String(), Active: true}},
+	})
+	a.NoError(e)
+
+	// sent envelope is not delivered twice to the sending stream
+	rsp, e = as.Recv()
+	if a.NoError(e) {
+		a.Equal("a2", rsp.CorrelationId)
+		a.NotNil(rsp.GetAccepted())
+	}
+
+	rsp, e = bs.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEvent()) {
+		a.Equal(pb.EVENT_TYPE_TYPING_STARTED, rsp.GetEvent().Type)
+		a.Equal(aID.String(), rsp.GetEvent().User)
+	}
+
+	e = bs.Send(&pb.ChatRequest{
+		CorrelationId: "b1",
+		Payload:       &pb.ChatRequest_Ack{Ack: &pb.Ack{Conversation: c.String(), Envelope: ev.Uid}},
+	})
+	a.NoError(e)
+
+	rsp, e = bs.Recv()
+	if a.NoError(e) {
+		a.Equal("b1", rsp.CorrelationId)
+		a.NotNil(rsp.GetAccepted())
+	}
+
+	rsp, e = as.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEvent()) {
+		a.Equal(pb.EVENT_TYPE_ACKNOWLEDGED, rsp.GetEvent().Type)
+		a.Equal(ev.Uid, rsp.GetEvent().Envelope)
+	}
+
+	// failures are reported without closing the stream
+	cases := []struct {
+		expected codes.Code
+		payload  *pb.ChatRequest
+	}{
+		{
+			// non member
+			expected: codes.PermissionDenied,
+			payload: &pb.ChatRequest{
+				CorrelationId: "b2",
+				Payload:       &pb.ChatRequest_Message{Message: &pb.ChatMessage{Conversation: o.String(), Message: "hello"}},
+			},
+		},
+		{
+			// invalid conversation
+			expected: codes.InvalidArgument,
+			payload: &pb.ChatRequest{
+				CorrelationId: "b3",
+				Payload:       &pb.ChatRequest_Typing{Typing: &pb.Typing{Conversation: "conversation"}},
+			},
+		},
+		{
+			// missing payload
+			expected: codes.InvalidArgument,
+			payload:  &pb.ChatRequest{CorrelationId: "b4"},
+		},
+	}
+
+	for _, cs := range cases {
+
+		a.NoError(bs.Send(cs.payload))
+
+		rsp, e = bs.Recv()
+		if a.NoError(e) && a.NotNil(rsp.GetError()) {
+			a.Equal(cs.payload.CorrelationId, rsp.CorrelationId)
+			a.Equal(int32(cs.expected), rsp.GetError().Code)
+		}
+	}
+
+	// conversations joined after the stream opened are delivered
+	n := s.createConversation(cID, bID)
+
+	_, e = s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           cID,
+		ConversationUUID: n,
+		Message:          "welcome",
+	})
+	a.NoError(e)
+
+	rsp, e = bs.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEnvelope()) {
+		a.Equal(n.String(), rsp.GetEnvelope().Conversation)
+	}
+
+	// muted conversations are not delivered to the member stream but remain in history
+	_, e = s.bundle.MessengerService.UpdatePreferences(ctx, &domain.ConversationPreferences{
+		ConversationUUID: c,
+		User:             bID,
+		MutedUntil:       time.Now().Add(time.Hour),
+	})
+	a.NoError(e)
+
+	muted, e := s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           aID,
+		ConversationUUID: c,
+		Message:          "quiet",
+	})
+	a.NoError(e)
+
+	_, e = s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           cID,
+		ConversationUUID: n,
+		Message:          "loud",
+	})
+	a.NoError(e)
+
+	rsp, e = bs.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEnvelope()) {
+		a.Equal(n.String(), rsp.GetEnvelope().Conversation)
+		a.Equal("loud", rsp.GetEnvelope().Message)
+	}
+
+	// the sender still receives their own envelope
+	rsp, e = as.Recv()
+	if a.NoError(e) && a.NotNil(rsp.GetEnvelope()) {
+		a.Equal(muted.UID.String(), rsp.GetEnvelope().Uid)
+	}
+
+	el, e := s.bundle.MessengerService.ListMessages(ctx, &domain.MessageQuery{ConversationUUID: c, Requester: bID})
+	if a.NoError(e) && a.NotEmpty(el) {
+		a.Equal(muted.UID, el[len(el)-1].UID)
+	}
+
+	a.NoError(as.CloseSend())
+	_, e = as.Recv()
+	a.Equal(io.EOF, e)
+}
+
+func (s *GrpcServerSuite) TestUserService() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	// public methods do not require a token
+	u, e := s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "jane.doe",
+		Email:    "jane.doe@mailbox.com",
+		Password: "test123",
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	_, e = s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "jane.doe",
+		Email:    "jane.doe@mailbox.com",
+		Password: "test123",
+	})
+	a.Equal(codes.AlreadyExists, status.Code(e))
+
+	lr, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "jane.doe", Password: "test123"})
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal(u.Uid, lr.UserId)
+
+	_, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "jane.doe", Password: "test1234"})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "john.smith", Password: "test1234"})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	// locked accounts reject the correct password
+	_, e = s.db.Exec(
+		"UPDATE login_throttles SET locked_until = ? WHERE throttle_key = ?",
+		time.Now().UTC().Add(time.Minute), "account:"+u.Uid,
+	)
+	a.NoError(e)
+
+	_, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "jane.doe", Password: "test123"})
+	a.Equal(codes.ResourceExhausted, status.Code(e))
+
+	// other methods require a token
+	_, e = s.users.ReadUser(ctx, &userpb.ReadUserRequest{Uid: u.Uid})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	actx := withToken(ctx, lr.AccessToken)
+
+	ru, e := s.users.ReadUser(actx, &userpb.ReadUserRequest{Uid: u.Uid})
+	if a.NoError(e) {
+		a.Equal("jane.doe", ru.Username)
+		a.Equal("jane.doe@mailbox.com", ru.Email)
+	}
+
+	_, e = s.users.ReadUser(actx, &userpb.ReadUserRequest{Uid: uuid.NewString()})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	uu, e := s.users.UpdateUser(actx, &userpb.UpdateUserRequest{Uid: u.Uid, Username: "jane.smith", Email: "jane.doe@mailbox.com"})
+	if a.NoError(e) {
+		a.Equal("jane.smith", uu.Username)
+	}
+
+	sr, e := s.users.SearchUsers(actx, &userpb.SearchUsersRequest{Search: "jane"})
+	if a.NoError(e) && a.Len(sr.Users, 1) {
+		a.Equal(u.Uid, sr.Users[0].Uid)
+	}
+}
+
+func (s *GrpcServerSuite) TestSessions() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	_, e := s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "sessions",
+		Email:    "sessions@mailbox.com",
+		Password: "test123",
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	lr, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "sessions", Password: "test123"})
+	if !a.NoError(e) {
+		return
+	}
+	a.NotEmpty(lr.RefreshToken)
+	a.NotEmpty(lr.SessionId)
+
+	// refresh is public and rotates the refresh token
+	rr, e := s.users.RefreshToken(ctx, &userpb.RefreshTokenRequest{RefreshToken: lr.RefreshToken})
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal(lr.SessionId, rr.SessionId)
+	a.NotEqual(lr.RefreshToken, rr.RefreshToken)
+
+	_, e = s.users.RefreshToken(ctx, &userpb.RefreshTokenRequest{RefreshToken: lr.RefreshToken})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	lr, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "sessions", Password: "test123"})
+	if !a.NoError(e) {
+		return
+	}
+
+	actx := withToken(ctx, lr.AccessToken)
+
+	ls, e := s.users.ListSessions(actx, &userpb.ListSessionsRequest{})
+	if a.NoError(e) && a.Len(ls.Sessions, 1) {
+		a.Equal(lr.SessionId, ls.Sessions[0].Uid)
+		a.True(ls.Sessions[0].Current)
+	}
+
+	_, e = s.users.RevokeSession(actx, &userpb.RevokeSessionRequest{Uid: uuid.NewString()})
+	a.Equal(codes.NotFound, status.Code(e))
+
+	_, e = s.users.RevokeSession(actx, &userpb.RevokeSessionRequest{Uid: lr.SessionId})
+	a.NoError(e)
+
+	_, e = s.users.RefreshToken(ctx, &userpb.RefreshTokenRequest{RefreshToken: lr.RefreshToken})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	// revoked session access tokens are rejected
+	_, e = s.users.ListSessions(actx, &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	l1, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "sessions", Password: "test123"})
+	if !a.NoError(e) {
+		return
+	}
+
+	l2, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "sessions", Password: "test123"})
+	if !a.NoError(e) {
+		return
+	}
+
+	_, e = s.users.Logout(withToken(ctx, l1.AccessToken), &userpb.LogoutRequest{})
+	a.NoError(e)
+
+	_, e = s.users.ListSessions(withToken(ctx, l1.AccessToken), &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.LogoutAll(withToken(ctx, l2.AccessToken), &userpb.LogoutAllRequest{})
+	a.NoError(e)
+
+	// streams authenticate with the same check
+	stream, e := s.client.StreamEnvelopes(withToken(ctx, l2.AccessToken), &pb.Conversation{Conversation: uuid.NewString()})
+	if a.NoError(e) {
+		_, e = stream.Recv()
+		a.Equal(codes.Unauthenticated, status.Code(e))
+	}
+}
+
+func (s *GrpcServerSuite) TestPassword() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	_, e := s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "password",
+		Email:    "password@mailbox.com",
+		Password: "Passw0rd!",
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	login := func(password string) (*userpb.LoginUserResponse, error) {
+		return s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "password", Password: password})
+	}
+
+	l1, e := login("Passw0rd!")
+	if !a.NoError(e) {
+		return
+	}
+
+	l2, e := login("Passw0rd!")
+	if !a.NoError(e) {
+		return
+	}
+
+	actx := withToken(ctx, l1.AccessToken)
+
+	_, e = s.users.ChangePassword(ctx, &userpb.ChangePasswordRequest{CurrentPassword: "Passw0rd!", NewPassword: "Changed0rd!"})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.ChangePassword(actx, &userpb.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "Changed0rd!"})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.ChangePassword(actx, &userpb.ChangePasswordRequest{CurrentPassword: "Passw0rd!", NewPassword: "short"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.ChangePassword(actx, &userpb.ChangePasswordRequest{CurrentPassword: "Passw0rd!", NewPassword: "Changed0rd!"})
+	a.NoError(e)
+
+	_, e = s.users.ListSessions(actx, &userpb.ListSessionsRequest{})
+	a.NoError(e)
+
+	_, e = s.users.ListSessions(withToken(ctx, l2.AccessToken), &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	// reset is public
+	_, e = s.users.RequestPasswordReset(ctx, &userpb.RequestPasswordResetRequest{Email: "nobody@mailbox.com"})
+	a.NoError(e)
+	a.Equal(0, s.mail.count(resetSubject))
+
+	_, e = s.users.RequestPasswordReset(ctx, &userpb.RequestPasswordResetRequest{Email: "password@mailbox.com"})
+	a.NoError(e)
+	a.True(s.mail.await(resetSubject, 1))
+
+	t := mailToken(s.mail.last(resetSubject))
+	a.NotEmpty(t)
+
+	_, e = s.users.ResetPassword(ctx, &userpb.ResetPasswordRequest{Token: "unknown", NewPassword: "Reset0rd!"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.ResetPassword(ctx, &userpb.ResetPasswordRequest{Token: t, NewPassword: "Reset0rd!"})
+	a.NoError(e)
+
+	_, e = s.users.ResetPassword(ctx, &userpb.ResetPasswordRequest{Token: t, NewPassword: "Reset0rd!"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.ListSessions(actx, &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = login("Changed0rd!")
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = login("Reset0rd!")
+	a.NoError(e)
+}
+
+func (s *GrpcServerSuite) TestEmailVerification() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	u, e := s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "verify",
+		Email:    "verify@mailbox.com",
+		Password: "Passw0rd!",
+	})
+	if !a.NoError(e) {
+		return
+	}
+	a.False(u.EmailVerified)
+
+	lr, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "verify", Password: "Passw0rd!"})
+	if !a.NoError(e) {
+		return
+	}
+
+	actx := withToken(ctx, lr.AccessToken)
+
+	s.bundle.VerificationService.Restrict(domain.ActionSendMessage)
+
+	cID := s.createConversation(uuid.MustParse(u.Uid), s.createUser("verify2", "verify2@mailbox.com"))
+
+	_, e = s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           uuid.MustParse(u.Uid),
+		ConversationUUID: cID,
+		Message:          "hello",
+	})
+	a.Equal(domain.ErrEmailNotVerified, e)
+
+	_, e = s.users.ResendVerification(actx, &userpb.ResendVerificationRequest{})
+	a.Equal(codes.ResourceExhausted, status.Code(e))
+
+	// verify is public
+	_, e = s.users.VerifyEmail(ctx, &userpb.VerifyEmailRequest{Token: "unknown"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.VerifyEmail(ctx, &userpb.VerifyEmailRequest{Token: mailToken(s.mail.messages(verifySubject)[0])})
+	a.NoError(e)
+
+	ru, e := s.users.ReadUser(actx, &userpb.ReadUserRequest{Uid: u.Uid})
+	if a.NoError(e) {
+		a.True(ru.EmailVerified)
+	}
+
+	_, e = s.users.ResendVerification(actx, &userpb.ResendVerificationRequest{})
+	a.Equal(codes.FailedPrecondition, status.Code(e))
+
+	_, e = s.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           uuid.MustParse(u.Uid),
+		ConversationUUID: cID,
+		Message:          "hello",
+	})
+	a.NoError(e)
+}
+
+func (s *GrpcServerSuite) TestTwoFactor() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	_, e := s.users.CreateUser(ctx, &userpb.CreateUserRequest{
+		Username: "twofactor",
+		Email:    "twofactor@mailbox.com",
+		Password: "Passw0rd!",
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	lr, e := s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "twofactor", Password: "Passw0rd!"})
+	if !a.NoError(e) {
+		return
+	}
+
+	actx := withToken(ctx, lr.AccessToken)
+
+	_, e = s.users.DisableTwoFactor(actx, &userpb.DisableTwoFactorRequest{Code: "123456"})
+	a.Equal(codes.FailedPrecondition, status.Code(e))
+
+	en, e := s.users.EnrollTwoFactor(actx, &userpb.EnrollTwoFactorRequest{})
+	if !a.NoError(e) {
+		return
+	}
+
+	_, e = s.users.ConfirmTwoFactor(actx, &userpb.ConfirmTwoFactorRequest{Code: "abcdef"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	now := time.Now()
+
+	cr, e := s.users.ConfirmTwoFactor(actx, &userpb.ConfirmTwoFactorRequest{Code: totpAt(en.Secret, now)})
+	if !a.NoError(e) || !a.Len(cr.RecoveryCodes, 10) {
+		return
+	}
+
+	lr, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "twofactor", Password: "Passw0rd!"})
+	if !a.NoError(e) {
+		return
+	}
+	a.True(lr.TwoFactorRequired)
+	a.Empty(lr.AccessToken)
+
+	// answer is public
+	_, e = s.users.AnswerLoginChallenge(ctx, &userpb.AnswerLoginChallengeRequest{
+		ChallengeToken: lr.ChallengeToken,
+		Code:           totpAt(en.Secret, now),
+	})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	ar, e := s.users.AnswerLoginChallenge(ctx, &userpb.AnswerLoginChallengeRequest{
+		ChallengeToken: lr.ChallengeToken,
+		Code:           totpAt(en.Secret, now.Add(time.Second*30)),
+	})
+	if a.NoError(e) {
+		a.NotEmpty(ar.AccessToken)
+		a.NotEmpty(ar.RefreshToken)
+	}
+
+	_, e = s.users.AnswerLoginChallenge(ctx, &userpb.AnswerLoginChallengeRequest{
+		ChallengeToken: lr.ChallengeToken,
+		Code:           cr.RecoveryCodes[0],
+	})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.DisableTwoFactor(actx, &userpb.DisableTwoFactorRequest{Code: "wrong"})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.DisableTwoFactor(actx, &userpb.DisableTwoFactorRequest{Code: cr.RecoveryCodes[0]})
+	a.NoError(e)
+
+	lr, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "twofactor", Password: "Passw0rd!"})
+	if a.NoError(e) {
+		a.False(lr.TwoFactorRequired)
+		a.NotEmpty(lr.AccessToken)
+	}
+}
+
+func (s *GrpcServerSuite) TestAPIKeys() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	uid := s.createUser("automation", "automation@mailbox.com")
+	rID := s.createUser("recipient", "recipient@mailbox.com")
+
+	actx := withToken(ctx, s.token(uid))
+
+	_, e := s.users.CreateAPIKey(actx, &userpb.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"admin"}})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.CreateAPIKey(actx, &userpb.CreateAPIKeyRequest{
+		Name:      "ci",
+		Scopes:    []string{"read"},
+		ExpiresAt: timestamppb.New(time.Now().Add(-time.Hour)),
+	})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	rk, e := s.users.CreateAPIKey(actx, &userpb.CreateAPIKeyRequest{
+		Name:      "ci",
+		Scopes:    []string{"read"},
+		ExpiresAt: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	if !a.NoError(e) {
+		return
+	}
+	a.True(strings.HasPrefix(rk.Key, domain.APIKeyPrefix))
+	a.Equal(uid.String(), rk.User)
+	a.NotNil(rk.ExpiresAt)
+
+	kctx := withToken(ctx, rk.Key)
+
+	// read scope serves read methods only
+	u, e := s.users.ReadUser(kctx, &userpb.ReadUserRequest{Uid: uid.String()})
+	if a.NoError(e) {
+		a.Equal("human", u.Type)
+		a.Empty(u.Owner)
+	}
+
+	_, e = s.conversations.ListConversations(kctx, &conversationpb.ListConversationsRequest{})
+	a.NoError(e)
+
+	_, e = s.conversations.CreateConversation(kctx, &conversationpb.CreateConversationRequest{
+		Recipients: []string{uid.String(), rID.String()},
+	})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	// account, sessions and keys are never managed with a key
+	_, e = s.users.ListSessions(kctx, &userpb.ListSessionsRequest{})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.CreateAPIKey(kctx, &userpb.CreateAPIKeyRequest{Name: "escalate", Scopes: []string{"write"}})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	kl, e := s.users.ListAPIKeys(actx, &userpb.ListAPIKeysRequest{})
+	if a.NoError(e) && a.Len(kl.ApiKeys, 1) {
+		a.Empty(kl.ApiKeys[0].Key)
+		a.NotNil(kl.ApiKeys[0].LastUsedAt)
+	}
+
+	_, e = s.users.RevokeAPIKey(actx, &userpb.RevokeAPIKeyRequest{Uid: rk.Uid})
+	a.NoError(e)
+
+	_, e = s.users.ReadUser(kctx, &userpb.ReadUserRequest{Uid: uid.String()})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.RevokeAPIKey(actx, &userpb.RevokeAPIKeyRequest{Uid: rk.Uid})
+	a.Equal(codes.NotFound, status.Code(e))
+
+	// bots stream and send with keys created by their owner
+	b, e := s.users.CreateBot(actx, &userpb.CreateBotRequest{Username: "automation-bot"})
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal("bot", b.Type)
+	a.Equal(uid.String(), b.Owner)
+
+	bl, e := s.users.ListBots(actx, &userpb.ListBotsRequest{})
+	if a.NoError(e) && a.Len(bl.Bots, 1) {
+		a.Equal(b.Uid, bl.Bots[0].Uid)
+	}
+
+	_, e = s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: "automation-bot", Password: "test123"})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	other := withToken(ctx, s.token(rID))
+
+	_, e = s.users.CreateAPIKey(other, &userpb.CreateAPIKeyRequest{Name: "steal", Scopes: []string{"write"}, BotId: b.Uid})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	bk, e := s.users.CreateAPIKey(actx, &userpb.CreateAPIKeyRequest{Name: "deploy", Scopes: []string{"read", "write"}, BotId: b.Uid})
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal(b.Uid, bk.User)
+
+	bctx := withToken(ctx, bk.Key)
+
+	c, e := s.conversations.CreateConversation(bctx, &conversationpb.CreateConversationRequest{
+		Recipients: []string{b.Uid, rID.String()},
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	// streams authenticate with the same check and stay open until cancelled
+	sctx, cancel := context.WithTimeout(bctx, time.Millisecond*200)
+	defer cancel()
+
+	stream, e := s.client.StreamEnvelopes(sctx, &pb.Conversation{Conversation: c.Uid})
+	if a.NoError(e) {
+		_, e = stream.Recv()
+		a.Equal(codes.DeadlineExceeded, status.Code(e))
+	}
+
+	_, e = s.users.RevokeAPIKey(actx, &userpb.RevokeAPIKeyRequest{Uid: bk.Uid, BotId: b.Uid})
+	a.NoError(e)
+
+	stream, e = s.client.StreamEnvelopes(bctx, &pb.Conversation{Conversation: c.Uid})
+	if a.NoError(e) {
+		_, e = stream.Recv()
+		a.Equal(codes.Unauthenticated, status.Code(e))
+	}
+}
+
+func (s *GrpcServerSuite) TestAdmin() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	adminID := s.createUser("root", "root@mailbox.com")
+	modID := s.createUser("moderator", "moderator@mailbox.com")
+	userID := s.createUser("member", "member@mailbox.com")
+
+	login := func(username string) (*userpb.LoginUserResponse, error) {
+		return s.users.LoginUser(ctx, &userpb.LoginUserRequest{Username: username, Password: "test123"})
+	}
+
+	actx := withToken(ctx, s.token(adminID))
+	mctx := withToken(ctx, s.token(modID))
+
+	// every user starts with the user role and no administrative permissions
+	_, e := s.users.ListUsers(actx, &userpb.ListUsersRequest{})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.LogoutUser(withToken(ctx, s.token(userID)), &userpb.LogoutUserRequest{Uid: modID.String()})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	a.NoError(s.bundle.AdminService.Promote(ctx, "root"))
+
+	lu, e := s.users.ListUsers(actx, &userpb.ListUsersRequest{Limit: 2})
+	if a.NoError(e) && a.Len(lu.Users, 2) {
+		a.Equal("member", lu.Users[0].Username)
+		a.NotEmpty(lu.NextCursor)
+
+		lu, e = s.users.ListUsers(actx, &userpb.ListUsersRequest{Limit: 2, Cursor: lu.NextCursor})
+		if a.NoError(e) && a.Len(lu.Users, 1) {
+			a.Equal("admin", lu.Users[0].Role)
+			a.Empty(lu.NextCursor)
+		}
+	}
+
+	_, e = s.users.ListUsers(actx, &userpb.ListUsersRequest{Role: "owner"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	_, e = s.users.SetUserRole(actx, &userpb.SetUserRoleRequest{Uid: adminID.String(), Role: "user"})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.SetUserRole(actx, &userpb.SetUserRoleRequest{Uid: "invalid", Role: "user"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+
+	u, e := s.users.SetUserRole(actx, &userpb.SetUserRoleRequest{Uid: modID.String(), Role: "moderator"})
+	if a.NoError(e) {
+		a.Equal("moderator", u.Role)
+	}
+
+	lu, e = s.users.ListUsers(mctx, &userpb.ListUsersRequest{Role: "moderator"})
+	if a.NoError(e) && a.Len(lu.Users, 1) {
+		a.Equal(modID.String(), lu.Users[0].Uid)
+	}
+
+	// moderators end sessions but do not disable accounts or act on administrators
+	_, e = s.users.DisableUser(mctx, &userpb.DisableUserRequest{Uid: userID.String()})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.LogoutUser(mctx, &userpb.LogoutUserRequest{Uid: adminID.String()})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	l1, e := login("member")
+	if !a.NoError(e) {
+		return
+	}
+
+	l2, e := login("member")
+	if !a.NoError(e) {
+		return
+	}
+
+	_, e = s.users.RevokeUserSession(mctx, &userpb.RevokeUserSessionRequest{Uid: userID.String(), SessionId: l1.SessionId})
+	a.NoError(e)
+
+	_, e = s.users.ListSessions(withToken(ctx, l1.AccessToken), &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	ls, e := s.users.ListUserSessions(mctx, &userpb.ListUserSessionsRequest{Uid: userID.String()})
+	if a.NoError(e) && a.Len(ls.Sessions, 1) {
+		a.Equal(l2.SessionId, ls.Sessions[0].Uid)
+	}
+
+	_, e = s.users.LogoutUser(mctx, &userpb.LogoutUserRequest{Uid: userID.String()})
+	a.NoError(e)
+
+	_, e = s.users.ListSessions(withToken(ctx, l2.AccessToken), &userpb.ListSessionsRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	// disabled users can not sign in or use their api keys
+	rk, e := s.users.CreateAPIKey(withToken(ctx, s.token(userID)), &userpb.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read"}})
+	if !a.NoError(e) {
+		return
+	}
+
+	u, e = s.users.DisableUser(actx, &userpb.DisableUserRequest{Uid: userID.String()})
+	if a.NoError(e) {
+		a.True(u.Disabled)
+	}
+
+	_, e = login("member")
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.users.ReadUser(withToken(ctx, rk.Key), &userpb.ReadUserRequest{Uid: userID.String()})
+	a.Equal(codes.Unauthenticated, status.Code(e))
+
+	_, e = s.users.EnableUser(actx, &userpb.EnableUserRequest{Uid: userID.String()})
+	a.NoError(e)
+
+	_, e = login("member")
+	a.NoError(e)
+
+	// api keys never carry administrative permissions
+	ak, e := s.users.CreateAPIKey(actx, &userpb.CreateAPIKeyRequest{Name: "ops", Scopes: []string{"read", "write"}})
+	if a.NoError(e) {
+		_, e = s.users.ListUsers(withToken(ctx, ak.Key), &userpb.ListUsersRequest{})
+		a.Equal(codes.PermissionDenied, status.Code(e))
+	}
+}
+
+func (s *GrpcServerSuite) TestContactService() {
+
+	a := assert.New(s.T())
+
+	oID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+
+	ctx := withToken(context.TODO(), s.token(oID))
+
+	c, e := s.contacts.AddContact(ctx, &contactpb.AddContactRequest{Recipient: rID.String()})
+	if !a.NoError(e) {
+		return
+	}
+	a.Equal(oID.String(), c.Owner)
+
+	rc, e := s.contacts.ReadContact(ctx, &contactpb.ReadContactRequest{Uid: c.Uid})
+	if a.NoError(e) {
+		a.Equal(rID.String(), rc.Recipient)
+	}
+
+	lr, e := s.contacts.ListContacts(ctx, &contactpb.ListContactsRequest{})
+	if a.NoError(e) {
+		a.Len(lr.Contacts, 1)
+	}
+
+	_, e = s.contacts.DeleteContact(ctx, &contactpb.DeleteContactRequest{Uid: c.Uid})
+	a.NoError(e)
+
+	_, e = s.contacts.ReadContact(ctx, &contactpb.ReadContactRequest{Uid: c.Uid})
+	a.Equal(codes.NotFound, status.Code(e))
+
+	_, e = s.contacts.ReadContact(ctx, &contactpb.ReadContactRequest{Uid: "contact"})
+	a.Equal(codes.InvalidArgument, status.Code(e))
+}
+
+func (s *GrpcServerSuite) TestConversationService() {
+
+	a := assert.New(s.T())
+
+	oID := s.createUser("jane.doe", "jane.doe@mailbox.com")
+	rID := s.createUser("john.doe", "john.doe@mailbox.com")
+	gID := s.createUser("jim.doe", "jim.doe@mailbox.com")
+
+	octx := withToken(context.TODO(), s.token(oID))
+	gctx := withToken(context.TODO(), s.token(gID))
+
+	c, e := s.conversations.CreateConversation(octx, &conversationpb.CreateConversationRequest{
+		Recipients: []string{oID.String(), rID.String()},
+	})
+	if !a.NoError(e) {
+		return
+	}
+
+	m, e := s.conversations.SendMessage(octx, &conversationpb.SendMessageRequest{Conversation: c.Uid, Message: "hello"})
+	if a.NoError(e) {
+		a.Equal(oID.String(), m.Sender)
+	}
+
+	_, e = s.conversations.SendMessage(gctx, &conversationpb.SendMessageRequest{Conversation: c.Uid, Message: "hello"})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	_, e = s.conversations.ListMessages(gctx, &conversationpb.ListMessagesRequest{Conversation: c.Uid})
+	a.Equal(codes.PermissionDenied, status.Code(e))
+
+	lr, e := s.conversations.ListConversations(octx, &conversationpb.ListConversationsRequest{})
+	if a.NoError(e) && a.Len(lr.Conversations, 1) && a.NotNil(lr.Conversations[0].LastMessage) {
+		a.Equal("hello", lr.Conversations[0].LastMessage.Message)
+	}
+
+	p, e := s.conversations.UpdatePreferences(octx, &conversationpb.UpdatePreferencesRequest{
+		Conversation: c.Uid,
+		Preferences:  &conversationpb.Preferences{Archived: true},
+	})
+	if a.NoError(e) {
+		a.True(p.Archived)
+	}
+
+	lr, e = s.conversations.ListConversations(octx, &conversationpb.ListConversationsRequest{
+		Filter: conversationpb.CONVERSATION_FILTER_ARCHIVED,
+	})
+	if a.NoError(e) {
+		a.Len(lr.Conversations, 1)
+	}
+
+	i, e := s.conversations.CreateInvite(octx, &conversationpb.CreateInviteRequest{Conversation: c.Uid, MaxUses: 1})
+	if !a.NoError(e) {
+		return
+	}
+
+	// preview is public
+	ip, e := s.conversations.PreviewInvite(context.TODO(), &conversationpb.PreviewInviteRequest{Token: i.Token})
+	if a.NoError(e) {
+		a.Equal(c.Uid, ip.Conversation)
+		a.Equal(int64(2), ip.MemberCount)
+	}
+
+	_, e = s.conversations.RedeemInvite(gctx, &conversationpb.RedeemInviteRequest{Token: i.Token})
+	a.NoError(e)
+
+	ml, e := s.conversations.ListMessages(gctx, &conversationpb.ListMessagesRequest{Conversation: c.Uid})
+	if a.NoError(e) {
+		a.Len(ml.Messages, 1)
+	}
+
+	_, e = s.conversations.PreviewInvite(context.TODO(), &conversationpb.PreviewInviteRequest{Token: i.Token})
+	a.Equal(codes.FailedPrecondition, status.Code(e))
+}
+
+// createUser create new user and return user id
+func (s *GrpcServerSuite) createUser(username, email string) uuid.UUID {
+
+	u, e := s.bundle.UserService.Create(context.TODO(), &domain.NewUser{
+		Username: username,
+		Email:    email,
+		Password: "test123",
+	})
+	assert.New(s.T()).NoError(e)
+
+	return u.UID
+}
+
+// createConversation create new conversation between users and return conversation id
+func (s *GrpcServerSuite) createConversation(creator uuid.UUID, recipients ...uuid.UUID) uuid.UUID {
+
+	c, e := s.bundle.MessengerService.CreateConversation(context.TODO(), &domain.NewConversation{
+		Creator:    creator,
+		Recipients: append([]uuid.UUID{creator}, recipients...),
+	})
+	assert.New(s.T()).NoError(e)
+
+	return c.UID
+}
+
+// token sign access token for user with server key
+func (s *GrpcServerSuite) token(user uuid.UUID) string {
+
+	st, e := s.keys.Sign(middleware.NewAccessClaims(user.String(), uuid.NewString()))
+	assert.New(s.T()).NoError(e)
+
+	return st
+}
+
+// foreignToken sign access token for user with an unknown key claiming the server key id
+func (s *GrpcServerSuite) foreignToken(user uuid.UUID) string {
+
+	a := assert.New(s.T())
+
+	pk, e := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	a.NoError(e)
+
+	tk := jwt.NewWithClaims(jwt.SigningMethodES384, middleware.NewAccessClaims(user.String(), uuid.NewString()))
+	tk.Header["kid"] = s.keys.JWKS().Keys[0].Kid
+
+	st, e := tk.SignedString(pk)
+	a.NoError(e)
+
+	return st
+}
+
+// withToken add access token to outgoing authorization metadata
+func withToken(ctx context.Context, token string) context.Context {
+
+	if token == "" {
+		return ctx
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestGrpcServerSuite(t *testing.T) {
+	suite.Run(t, new(GrpcServerSuite))
+}
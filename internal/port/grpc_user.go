@@ -0,0 +1,541 @@
+package port
+
+import (
+	"context"
+	"net"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	mw "github.com/trevatk/go-chat/internal/port/middleware"
+	userpb "github.com/trevatk/go-chat/proto/user/v1"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// CreateUser register new user
+func (g *GrpcServer) CreateUser(ctx context.Context, in *userpb.CreateUserRequest) (*userpb.User, error) {
+
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "no name parameter provided")
+	} else if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "no email parameter provided")
+	} else if in.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "no password parameter provided")
+	}
+
+	u, e := g.bundle.UserService.Create(ctx, &domain.NewUser{
+		Username: in.Username,
+		Email:    in.Email,
+		Password: in.Password,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create new user")
+	}
+
+	return transformUser(u), nil
+}
+
+// LoginUser verify user credentials and issue access token
+func (g *GrpcServer) LoginUser(ctx context.Context, in *userpb.LoginUserRequest) (*userpb.LoginUserResponse, error) {
+
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid username provided")
+	} else if in.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid password provided")
+	}
+
+	uid, e := g.bundle.UserService.Login(ctx, in.Username, in.Password, grpcClientIP(ctx))
+	if e != nil {
+
+		if e == domain.ErrInvalidCredentials {
+			return nil, status.Error(codes.Unauthenticated, e.Error())
+		} else if e == domain.ErrLoginLocked {
+			return nil, status.Error(codes.ResourceExhausted, e.Error())
+		} else if e == domain.ErrAccountDisabled {
+			return nil, status.Error(codes.PermissionDenied, e.Error())
+		}
+
+		logging.FromContext(ctx).Errorf("failed to check user login %v", e)
+		return nil, status.Error(codes.Internal, "unable to verify user login")
+	}
+
+	ch, e := g.bundle.TwoFactorService.Challenge(ctx, uuid.MustParse(uid))
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create login challenge")
+	}
+
+	if ch != nil {
+		return &userpb.LoginUserResponse{
+			UserId:            uid,
+			TwoFactorRequired: true,
+			ChallengeToken:    ch.Token,
+			ExpiresAt:         timestamppb.New(ch.ExpiresAt),
+		}, nil
+	}
+
+	ss, e := g.bundle.SessionService.Create(ctx, &domain.NewSession{
+		User:      uuid.MustParse(uid),
+		UserAgent: grpcUserAgent(ctx),
+		IPAddress: grpcClientIP(ctx),
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create session")
+	}
+
+	return g.issueTokens(ctx, ss)
+}
+
+// AnswerLoginChallenge complete two factor login and issue tokens
+func (g *GrpcServer) AnswerLoginChallenge(ctx context.Context, in *userpb.AnswerLoginChallengeRequest) (*userpb.LoginUserResponse, error) {
+
+	if in.ChallengeToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid challenge token provided")
+	} else if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid code provided")
+	}
+
+	uid, e := g.bundle.TwoFactorService.Answer(ctx, in.ChallengeToken, in.Code)
+	if e != nil {
+
+		if e == domain.ErrInvalidTwoFactorCode {
+			return nil, status.Error(codes.Unauthenticated, e.Error())
+		}
+
+		return nil, statusFromError(ctx, e, "unable to answer login challenge")
+	}
+
+	ss, e := g.bundle.SessionService.Create(ctx, &domain.NewSession{
+		User:      uid,
+		UserAgent: grpcUserAgent(ctx),
+		IPAddress: grpcClientIP(ctx),
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to create session")
+	}
+
+	return g.issueTokens(ctx, ss)
+}
+
+// RefreshToken exchange refresh token for a new access and refresh token pair
+func (g *GrpcServer) RefreshToken(ctx context.Context, in *userpb.RefreshTokenRequest) (*userpb.LoginUserResponse, error) {
+
+	if in.RefreshToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid refresh token provided")
+	}
+
+	ss, e := g.bundle.SessionService.Refresh(ctx, in.RefreshToken)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to refresh session")
+	}
+
+	return g.issueTokens(ctx, ss)
+}
+
+// ListSessions retrieve active sessions of the authenticated user
+func (g *GrpcServer) ListSessions(ctx context.Context, _ *userpb.ListSessionsRequest) (*userpb.ListSessionsResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	sl, e := g.bundle.SessionService.List(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to list sessions")
+	}
+
+	sid, _ := sessionFromContext(ctx)
+
+	rsp := &userpb.ListSessionsResponse{Sessions: make([]*userpb.Session, 0, len(sl))}
+
+	for _, ss := range sl {
+		rsp.Sessions = append(rsp.Sessions, &userpb.Session{
+			Uid:        ss.UID.String(),
+			UserAgent:  ss.UserAgent,
+			IpAddress:  ss.IPAddress,
+			Current:    ss.UID == sid,
+			ExpiresAt:  timestamppb.New(ss.ExpiresAt),
+			LastUsedAt: timestamppb.New(ss.LastUsedAt),
+			CreatedAt:  timestamppb.New(ss.CreatedAt),
+		})
+	}
+
+	return rsp, nil
+}
+
+// RevokeSession end session of the authenticated user
+func (g *GrpcServer) RevokeSession(ctx context.Context, in *userpb.RevokeSessionRequest) (*userpb.RevokeSessionResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	sid, e := parseUUID("session", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.SessionService.Revoke(ctx, uid, sid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to revoke session")
+	}
+
+	return &userpb.RevokeSessionResponse{}, nil
+}
+
+// Logout end the session of the access token
+func (g *GrpcServer) Logout(ctx context.Context, _ *userpb.LogoutRequest) (*userpb.LogoutResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	sid, e := sessionFromContext(ctx)
+	if e != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token claims")
+	}
+
+	tid, _ := ctx.Value(mw.TokenID).(string)
+
+	e = g.bundle.SessionService.Logout(ctx, uid, sid, tid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to logout")
+	}
+
+	return &userpb.LogoutResponse{}, nil
+}
+
+// LogoutAll end every session of the authenticated user
+func (g *GrpcServer) LogoutAll(ctx context.Context, _ *userpb.LogoutAllRequest) (*userpb.LogoutAllResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.SessionService.LogoutAll(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to logout everywhere")
+	}
+
+	return &userpb.LogoutAllResponse{}, nil
+}
+
+// ChangePassword replace password of the authenticated user
+func (g *GrpcServer) ChangePassword(ctx context.Context, in *userpb.ChangePasswordRequest) (*userpb.ChangePasswordResponse, error) {
+
+	if in.CurrentPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid current password provided")
+	} else if in.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid new password provided")
+	}
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	sid, e := sessionFromContext(ctx)
+	if e != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token claims")
+	}
+
+	e = g.bundle.PasswordService.Change(ctx, uid, sid, in.CurrentPassword, in.NewPassword)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to change password")
+	}
+
+	return &userpb.ChangePasswordResponse{}, nil
+}
+
+// RequestPasswordReset email a password reset token
+func (g *GrpcServer) RequestPasswordReset(ctx context.Context, in *userpb.RequestPasswordResetRequest) (*userpb.RequestPasswordResetResponse, error) {
+
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid email provided")
+	}
+
+	e := g.bundle.PasswordService.RequestReset(ctx, in.Email, grpcClientIP(ctx))
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to request password reset")
+	}
+
+	return &userpb.RequestPasswordResetResponse{}, nil
+}
+
+// ResetPassword replace password with an emailed reset token
+func (g *GrpcServer) ResetPassword(ctx context.Context, in *userpb.ResetPasswordRequest) (*userpb.ResetPasswordResponse, error) {
+
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid reset token provided")
+	} else if in.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid new password provided")
+	}
+
+	e := g.bundle.PasswordService.Reset(ctx, in.Token, in.NewPassword)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to reset password")
+	}
+
+	return &userpb.ResetPasswordResponse{}, nil
+}
+
+// VerifyEmail confirm email with an emailed verification token
+func (g *GrpcServer) VerifyEmail(ctx context.Context, in *userpb.VerifyEmailRequest) (*userpb.VerifyEmailResponse, error) {
+
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid verification token provided")
+	}
+
+	e := g.bundle.VerificationService.Confirm(ctx, in.Token)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to verify email")
+	}
+
+	return &userpb.VerifyEmailResponse{}, nil
+}
+
+// ResendVerification email a new verification token to the authenticated user
+func (g *GrpcServer) ResendVerification(ctx context.Context, _ *userpb.ResendVerificationRequest) (*userpb.ResendVerificationResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.VerificationService.Resend(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to resend verification email")
+	}
+
+	return &userpb.ResendVerificationResponse{}, nil
+}
+
+// EnrollTwoFactor generate totp secret for the authenticated user
+func (g *GrpcServer) EnrollTwoFactor(ctx context.Context, _ *userpb.EnrollTwoFactorRequest) (*userpb.EnrollTwoFactorResponse, error) {
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	en, e := g.bundle.TwoFactorService.Enroll(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to enroll two factor")
+	}
+
+	return &userpb.EnrollTwoFactorResponse{Secret: en.Secret, OtpauthUri: en.URI}, nil
+}
+
+// ConfirmTwoFactor enable two factor login with a code from the enrolled secret
+func (g *GrpcServer) ConfirmTwoFactor(ctx context.Context, in *userpb.ConfirmTwoFactorRequest) (*userpb.ConfirmTwoFactorResponse, error) {
+
+	if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid code provided")
+	}
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	cl, e := g.bundle.TwoFactorService.Confirm(ctx, uid, in.Code)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "unable to confirm two factor")
+	}
+
+	return &userpb.ConfirmTwoFactorResponse{RecoveryCodes: cl}, nil
+}
+
+// DisableTwoFactor turn off two factor login with a totp or recovery code
+func (g *GrpcServer) DisableTwoFactor(ctx context.Context, in *userpb.DisableTwoFactorRequest) (*userpb.DisableTwoFactorResponse, error) {
+
+	if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid code provided")
+	}
+
+	uid, e := authenticatedUser(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	e = g.bundle.TwoFactorService.Disable(ctx, uid, in.Code)
+	if e != nil {
+
+		if e == domain.ErrInvalidTwoFactorCode {
+			return nil, status.Error(codes.PermissionDenied, e.Error())
+		}
+
+		return nil, statusFromError(ctx, e, "unable to disable two factor")
+	}
+
+	return &userpb.DisableTwoFactorResponse{}, nil
+}
+
+// issueTokens sign access token for session and build login response
+func (g *GrpcServer) issueTokens(ctx context.Context, ss *domain.Session) (*userpb.LoginUserResponse, error) {
+
+	t, exp, e := signAccessToken(g.keys, ss.User.String(), ss.UID.String())
+	if e != nil {
+		logging.FromContext(ctx).Errorf("failed to sign jwt token %v", e)
+		return nil, status.Error(codes.Internal, "failed to generate jwt token")
+	}
+
+	return &userpb.LoginUserResponse{
+		UserId:       ss.User.String(),
+		AccessToken:  t,
+		RefreshToken: ss.RefreshToken,
+		SessionId:    ss.UID.String(),
+		ExpiresAt:    timestamppb.New(exp),
+	}, nil
+}
+
+// ReadUser retrieve authenticated user
+func (g *GrpcServer) ReadUser(ctx context.Context, in *userpb.ReadUserRequest) (*userpb.User, error) {
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	e = requireSelf(ctx, uid.String())
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.UserService.Read(ctx, uid)
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to read user")
+	}
+
+	return transformUser(u), nil
+}
+
+// UpdateUser update authenticated user details
+func (g *GrpcServer) UpdateUser(ctx context.Context, in *userpb.UpdateUserRequest) (*userpb.User, error) {
+
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "no name parameter provided")
+	} else if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "no email parameter provided")
+	}
+
+	uid, e := parseUUID("user", in.Uid)
+	if e != nil {
+		return nil, e
+	}
+
+	e = requireSelf(ctx, uid.String())
+	if e != nil {
+		return nil, e
+	}
+
+	u, e := g.bundle.UserService.Update(ctx, &domain.UpdateUser{
+		UID:      uid,
+		Username: in.Username,
+		Email:    in.Email,
+	})
+	if e != nil {
+		return nil, statusFromError(ctx, e, "failed to update user")
+	}
+
+	return transformUser(u), nil
+}
+
+// SearchUsers search users by username
+func (g *GrpcServer) SearchUsers(ctx context.Context, in *userpb.SearchUsersRequest) (*userpb.SearchUsersResponse, error) {
+
+	if in.Search == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty search parameter provided")
+	}
+
+	uds, e := g.bundle.UserService.Search(ctx, in.Search)
+	if e != nil && e != domain.ErrEmptyResult {
+		return nil, statusFromError(ctx, e, "failed to search users")
+	}
+
+	us := make([]*userpb.UserDetails, 0, len(uds))
+
+	for _, d := range uds {
+		us = append(us, &userpb.UserDetails{
+			Uid:      d.UUID.String(),
+			Username: d.Username,
+		})
+	}
+
+	return &userpb.SearchUsersResponse{Users: us}, nil
+}
+
+// requireSelf verify token claims match user scope
+func requireSelf(ctx context.Context, uid string) error {
+
+	sid, e := authenticatedUser(ctx)
+	if e != nil {
+		return e
+	}
+
+	if sid.String() != uid {
+		return status.Error(codes.PermissionDenied, "token claims do not match user scope")
+	}
+
+	return nil
+}
+
+// grpcUserAgent user agent from incoming metadata
+func grpcUserAgent(ctx context.Context) string {
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if vs := md.Get("user-agent"); len(vs) > 0 {
+		return vs[0]
+	}
+
+	return ""
+}
+
+// grpcClientIP remote address of peer without port
+func grpcClientIP(ctx context.Context) string {
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	h, _, e := net.SplitHostPort(p.Addr.String())
+	if e != nil {
+		return p.Addr.String()
+	}
+
+	return h
+}
+
+func transformUser(user *domain.User) *userpb.User {
+
+	up := &userpb.User{
+		Uid:           user.UID.String(),
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Type:          string(user.Type),
+		Role:          string(user.Role),
+		Disabled:      user.Disabled,
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+	}
+
+	if user.Owner != uuid.Nil {
+		up.Owner = user.Owner.String()
+	}
+
+	return up
+}
@@ -0,0 +1,324 @@
+package port
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// ListUsersParams http list users request parameters
+type ListUsersParams struct {
+	Role   domain.Role
+	Cursor string
+	Limit  int64
+}
+
+// Bind parse request into model
+//
+// optional role query parameter only lists users with that role
+// optional cursor and limit query parameters page through results
+func (lup *ListUsersParams) Bind(r *http.Request) error {
+
+	q := r.URL.Query()
+
+	lup.Role = domain.Role(q.Get("role"))
+	lup.Cursor = q.Get("cursor")
+
+	if l := q.Get("limit"); l != "" {
+
+		var e error
+		lup.Limit, e = strconv.ParseInt(l, 10, 64)
+		if e != nil || lup.Limit < 1 {
+			return errors.New("invalid limit parameter")
+		}
+	}
+
+	return nil
+}
+
+// ListUsersResponse http list users response model
+type ListUsersResponse struct {
+	Users      []*UserPayload `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// SetUserRoleRequest http set user role request model
+type SetUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Bind parse set user role request from http
+func (sur *SetUserRoleRequest) Bind(_ *http.Request) error {
+
+	if sur.Role == "" {
+		return errors.New("no role parameter provided")
+	}
+
+	return nil
+}
+
+// AdminUserResponse http administered user response model
+type AdminUserResponse struct {
+	User *UserPayload `json:"user"`
+}
+
+// LogoutUserResponse http logout user response model
+type LogoutUserResponse struct {
+	Message string `json:"message"`
+}
+
+// listUsers retrieve page of every user ordered by username
+func (h *HTTPServer) listUsers(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &ListUsersParams{}
+	e := p.Bind(r)
+	if e != nil {
+		c := http.StatusBadRequest
+		logging.FromContext(ctx).Errorf("unable to parse request parameters %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	page, e := h.bundle.AdminService.ListUsers(ctx, &domain.UserQuery{
+		Role:   p.Role,
+		Cursor: p.Cursor,
+		Limit:  p.Limit,
+	})
+	if e != nil {
+		writeAdminError(w, r, e, "unable to list users")
+		return
+	}
+
+	rsp := &ListUsersResponse{
+		Users:      make([]*UserPayload, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+
+	for _, u := range page.Users {
+		rsp.Users = append(rsp.Users, newUserPayload(u))
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// setUserRole replace global role of the user in the path
+func (h *HTTPServer) setUserRole(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	p := &SetUserRoleRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	u, e := h.bundle.AdminService.SetRole(ctx, actor, target, domain.Role(p.Role))
+	if e != nil {
+		writeAdminError(w, r, e, "unable to set user role")
+		return
+	}
+
+	h.writeAdminUser(w, r, u)
+}
+
+// disableUser disable the user in the path and end every session of the user
+func (h *HTTPServer) disableUser(w http.ResponseWriter, r *http.Request) {
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	u, e := h.bundle.AdminService.Disable(r.Context(), actor, target)
+	if e != nil {
+		writeAdminError(w, r, e, "unable to disable user")
+		return
+	}
+
+	h.writeAdminUser(w, r, u)
+}
+
+// enableUser enable the disabled user in the path
+func (h *HTTPServer) enableUser(w http.ResponseWriter, r *http.Request) {
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	u, e := h.bundle.AdminService.Enable(r.Context(), actor, target)
+	if e != nil {
+		writeAdminError(w, r, e, "unable to enable user")
+		return
+	}
+
+	h.writeAdminUser(w, r, u)
+}
+
+// listUserSessions retrieve active sessions of the user in the path
+func (h *HTTPServer) listUserSessions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	sl, e := h.bundle.AdminService.ListSessions(ctx, actor, target)
+	if e != nil {
+		writeAdminError(w, r, e, "unable to list user sessions")
+		return
+	}
+
+	rsp := &ListSessionsResponse{Sessions: make([]*SessionPayload, 0, len(sl))}
+
+	for _, ss := range sl {
+		rsp.Sessions = append(rsp.Sessions, &SessionPayload{
+			UID:        ss.UID.String(),
+			UserAgent:  ss.UserAgent,
+			IPAddress:  ss.IPAddress,
+			ExpiresAt:  ss.ExpiresAt,
+			LastUsedAt: ss.LastUsedAt,
+			CreatedAt:  ss.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// revokeUserSession end session of the user in the path
+func (h *HTTPServer) revokeUserSession(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	sid, e := uuid.Parse(chi.URLParam(r, "session_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	e = h.bundle.AdminService.RevokeSession(ctx, actor, target, sid)
+	if e != nil {
+		writeAdminError(w, r, e, "unable to revoke user session")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&RevokeSessionResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// logoutUser end every session of the user in the path
+func (h *HTTPServer) logoutUser(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	actor, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	e := h.bundle.AdminService.Logout(ctx, actor, target)
+	if e != nil {
+		writeAdminError(w, r, e, "unable to logout user")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&LogoutUserResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+func (h *HTTPServer) writeAdminUser(w http.ResponseWriter, r *http.Request, u *domain.User) {
+
+	w.WriteHeader(http.StatusAccepted)
+	e := json.NewEncoder(w).Encode(&AdminUserResponse{User: newUserPayload(u)})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// adminTarget parse authenticated actor and the user in the path, writes the error response on failure
+func adminTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+
+	target, e := uuid.Parse(chi.URLParam(r, "user_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	actor, e := userFromContext(r.Context())
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return actor, target, true
+}
+
+// writeAdminError map admin service error to http status
+func writeAdminError(w http.ResponseWriter, r *http.Request, e error, msg string) {
+
+	switch e {
+	case domain.ErrInvalidRole:
+		http.Error(w, e.Error(), http.StatusBadRequest)
+	case domain.ErrInvalidCursor:
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+	case domain.ErrForbidden:
+		http.Error(w, e.Error(), http.StatusForbidden)
+	case domain.ErrResourceNotFound:
+		c := http.StatusNotFound
+		http.Error(w, http.StatusText(c), c)
+	default:
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("%s %v", msg, e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
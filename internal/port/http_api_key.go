@@ -0,0 +1,359 @@
+package port
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// NewAPIKeyRequest http create api key request model
+//
+// expires at is optional, keys without an expiry are valid until revoked
+type NewAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Bind parse create api key request from http
+func (nkr *NewAPIKeyRequest) Bind(_ *http.Request) error {
+
+	if nkr.Name == "" {
+		return errors.New("no name parameter provided")
+	} else if len(nkr.Scopes) < 1 {
+		return errors.New("no scopes parameter provided")
+	}
+
+	return nil
+}
+
+// APIKeyPayload http api key model
+//
+// key is only returned when the api key is created
+type APIKeyPayload struct {
+	UID        string     `json:"uid"`
+	User       string     `json:"user"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKeyResponse http create api key response model
+type NewAPIKeyResponse struct {
+	APIKey *APIKeyPayload `json:"api_key"`
+}
+
+// ListAPIKeysResponse http list api keys response model
+type ListAPIKeysResponse struct {
+	APIKeys []*APIKeyPayload `json:"api_keys"`
+}
+
+// RevokeAPIKeyResponse http revoke api key response model
+type RevokeAPIKeyResponse struct {
+	Message string `json:"message"`
+}
+
+// NewBotRequest http create bot request model
+type NewBotRequest struct {
+	Username string `json:"username"`
+}
+
+// Bind parse create bot request from http
+func (nbr *NewBotRequest) Bind(_ *http.Request) error {
+
+	if nbr.Username == "" {
+		return errors.New("no username parameter provided")
+	}
+
+	return nil
+}
+
+// BotResponse http create bot response model
+type BotResponse struct {
+	Bot *UserPayload `json:"bot"`
+}
+
+// ListBotsResponse http list bots response model
+type ListBotsResponse struct {
+	Bots []*UserPayload `json:"bots"`
+}
+
+// createAPIKey issue api key for the authenticated user or one of their bots
+func (h *HTTPServer) createAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	owner, e := keyOwner(r, uid)
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	p := &NewAPIKeyRequest{}
+	e = render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	nk := &domain.NewAPIKey{Name: p.Name}
+
+	for _, s := range p.Scopes {
+		nk.Scopes = append(nk.Scopes, domain.Scope(s))
+	}
+
+	if p.ExpiresAt != nil {
+		nk.ExpiresAt = *p.ExpiresAt
+	}
+
+	k, e := h.bundle.APIKeyService.Create(ctx, uid, owner, nk)
+	if e != nil {
+		writeAPIKeyError(w, r, e, "unable to create api key")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	e = json.NewEncoder(w).Encode(&NewAPIKeyResponse{APIKey: newAPIKeyPayload(k)})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// listAPIKeys retrieve unrevoked api keys of the authenticated user or one of their bots
+func (h *HTTPServer) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	owner, e := keyOwner(r, uid)
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	kl, e := h.bundle.APIKeyService.List(ctx, uid, owner)
+	if e != nil {
+		writeAPIKeyError(w, r, e, "unable to list api keys")
+		return
+	}
+
+	rsp := &ListAPIKeysResponse{APIKeys: make([]*APIKeyPayload, 0, len(kl))}
+
+	for _, k := range kl {
+		rsp.APIKeys = append(rsp.APIKeys, newAPIKeyPayload(k))
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// revokeAPIKey revoke api key of the authenticated user or one of their bots
+func (h *HTTPServer) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	kid, e := uuid.Parse(chi.URLParam(r, "key_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	owner, e := keyOwner(r, uid)
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	e = h.bundle.APIKeyService.Revoke(ctx, uid, owner, kid)
+	if e != nil {
+		writeAPIKeyError(w, r, e, "unable to revoke api key")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&RevokeAPIKeyResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// createBot create bot owned by the authenticated user
+func (h *HTTPServer) createBot(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	p := &NewBotRequest{}
+	e = render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	u, e := h.bundle.UserService.CreateBot(ctx, uid, p.Username)
+	if e != nil {
+
+		switch e {
+		case domain.ErrUniqueExists:
+			c := http.StatusConflict
+			http.Error(w, http.StatusText(c), c)
+		case domain.ErrForbidden:
+			http.Error(w, e.Error(), http.StatusForbidden)
+		case domain.ErrResourceNotFound:
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+		default:
+			c := http.StatusInternalServerError
+			logging.FromContext(ctx).Errorf("unable to create bot %v", e)
+			http.Error(w, http.StatusText(c), c)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	e = json.NewEncoder(w).Encode(&BotResponse{Bot: newUserPayload(u)})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// listBots retrieve bots owned by the authenticated user
+func (h *HTTPServer) listBots(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	ul, e := h.bundle.UserService.ListBots(ctx, uid)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to list bots %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	rsp := &ListBotsResponse{Bots: make([]*UserPayload, 0, len(ul))}
+
+	for _, u := range ul {
+		rsp.Bots = append(rsp.Bots, newUserPayload(u))
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// keyOwner user whose api keys are managed, the bot in the path or the authenticated user
+func keyOwner(r *http.Request, uid uuid.UUID) (uuid.UUID, error) {
+
+	if b := chi.URLParam(r, "bot_id"); b != "" {
+		return uuid.Parse(b)
+	}
+
+	return uid, nil
+}
+
+// writeAPIKeyError map api key service error to http status
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, e error, msg string) {
+
+	switch e {
+	case domain.ErrInvalidAPIKeyRequest:
+		http.Error(w, e.Error(), http.StatusBadRequest)
+	case domain.ErrTooManyAPIKeys:
+		http.Error(w, e.Error(), http.StatusConflict)
+	case domain.ErrForbidden:
+		http.Error(w, e.Error(), http.StatusForbidden)
+	case domain.ErrResourceNotFound:
+		c := http.StatusNotFound
+		http.Error(w, http.StatusText(c), c)
+	default:
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("%s %v", msg, e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+func newAPIKeyPayload(k *domain.APIKey) *APIKeyPayload {
+
+	kp := &APIKeyPayload{
+		UID:       k.UID.String(),
+		User:      k.User.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Key:       k.Key,
+		Scopes:    make([]string, 0, len(k.Scopes)),
+		CreatedAt: k.CreatedAt,
+	}
+
+	for _, s := range k.Scopes {
+		kp.Scopes = append(kp.Scopes, string(s))
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		kp.ExpiresAt = &k.ExpiresAt
+	}
+
+	if !k.LastUsedAt.IsZero() {
+		kp.LastUsedAt = &k.LastUsedAt
+	}
+
+	return kp
+}
@@ -0,0 +1,144 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trevatk/go-pkg/logging"
+)
+
+const (
+	// readinessTimeout maximum duration of all readiness checks
+	readinessTimeout = time.Second * 3
+
+	checkStatusOK     = "ok"
+	checkStatusFailed = "failed"
+)
+
+// CheckPayload http dependency check model
+type CheckPayload struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadinessResponse http readiness response model
+type ReadinessResponse struct {
+	Status string                   `json:"status"`
+	Checks map[string]*CheckPayload `json:"checks"`
+}
+
+// Drain fail readiness checks so load balancers stop routing new requests
+//
+// called when graceful shutdown begins, liveness is unaffected
+func (h *HTTPServer) Drain() {
+	h.draining.Store(true)
+}
+
+// health legacy liveness check answering with plain text OK, kept for existing probes
+func (h *HTTPServer) health(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	w.WriteHeader(http.StatusOK)
+	_, e := w.Write([]byte("OK"))
+	if e != nil {
+		logging.FromContext(ctx).Errorf("error encoding health check response %v", e)
+	}
+}
+
+func (h *HTTPServer) livez(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	e := json.NewEncoder(w).Encode(&CheckPayload{Status: checkStatusOK})
+	if e != nil {
+		logging.FromContext(ctx).Errorf("error encoding liveness response %v", e)
+	}
+}
+
+func (h *HTTPServer) readyz(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := []struct {
+		name  string
+		check func(context.Context) error
+	}{
+		{name: "shutdown", check: h.checkDraining},
+		{name: "database", check: h.bundle.HealthService.PingDatabase},
+		{name: "migrations", check: h.bundle.HealthService.CheckSchemaVersion},
+		{name: "broker", check: h.bundle.HealthService.PingBroker},
+		{name: "jwt_key", check: h.checkSigningKey},
+	}
+
+	rsp := &ReadinessResponse{
+		Status: checkStatusOK,
+		Checks: make(map[string]*CheckPayload, len(checks)),
+	}
+
+	c := http.StatusOK
+
+	for _, ck := range checks {
+
+		e := ck.check(ctx)
+		if e != nil {
+			rsp.Status = checkStatusFailed
+			rsp.Checks[ck.name] = &CheckPayload{Status: checkStatusFailed, Error: e.Error()}
+			c = http.StatusServiceUnavailable
+			continue
+		}
+
+		rsp.Checks[ck.name] = &CheckPayload{Status: checkStatusOK}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c)
+
+	e := json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		logging.FromContext(ctx).Errorf("error encoding readiness response %v", e)
+	}
+}
+
+func (h *HTTPServer) checkDraining(_ context.Context) error {
+
+	if h.draining.Load() {
+		return errors.New("server is shutting down")
+	}
+
+	return nil
+}
+
+func (h *HTTPServer) checkSigningKey(_ context.Context) error {
+
+	if h.keys == nil {
+		return errors.New("signing key not loaded")
+	} else if k := h.keys.Current(); k == nil || k.Curve.Params().Name != "P-384" {
+		return errors.New("signing key is not a P-384 key")
+	}
+
+	return nil
+}
+
+// jwksMaxAge time verifiers may cache the key set, shorter than the key grace period
+const jwksMaxAge = "max-age=300"
+
+// jwks publish public signing keys so other services can verify access tokens
+func (h *HTTPServer) jwks(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, "+jwksMaxAge)
+	w.WriteHeader(http.StatusOK)
+
+	e := json.NewEncoder(w).Encode(h.keys.JWKS())
+	if e != nil {
+		logging.FromContext(r.Context()).Errorf("error encoding jwks response %v", e)
+	}
+}
@@ -0,0 +1,24 @@
+package port
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewMetricsRouter create router serving prometheus metrics
+//
+// served on its own listener so route labels, database pool and
+// subscriber metrics are not exposed through the public api
+func NewMetricsRouter() http.Handler {
+
+	r := chi.NewRouter()
+
+	r.Use(middleware.Recoverer)
+
+	r.Handle("/metrics", promhttp.Handler())
+
+	return r
+}
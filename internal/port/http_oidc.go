@@ -0,0 +1,130 @@
+package port
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// AuthorizationResponse http link identity response model
+//
+// the user agent is sent to the authorization url to sign in at the identity provider
+type AuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// IdentityResponse http linked identity callback response model
+type IdentityResponse struct {
+	Message string `json:"message"`
+}
+
+// oidcLogin redirect to the identity provider
+func (h *HTTPServer) oidcLogin(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	u, e := h.bundle.IdentityService.Login(ctx)
+	if e != nil {
+
+		if e == domain.ErrOIDCDisabled {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusBadGateway
+		logging.FromContext(ctx).Errorf("unable to start oidc login %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// linkIdentity start linking an external identity to the authenticated user
+func (h *HTTPServer) linkIdentity(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	u, e := h.bundle.IdentityService.Link(ctx, uid)
+	if e != nil {
+
+		if e == domain.ErrOIDCDisabled {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusBadGateway
+		logging.FromContext(ctx).Errorf("unable to start oidc link %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&AuthorizationResponse{AuthorizationURL: u})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// oidcCallback redeem authorization code and sign in or link the identity
+func (h *HTTPServer) oidcCallback(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	q := r.URL.Query()
+
+	if pe := q.Get("error"); pe != "" {
+		http.Error(w, "identity provider returned "+pe, http.StatusUnauthorized)
+		return
+	} else if q.Get("state") == "" || q.Get("code") == "" {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	il, e := h.bundle.IdentityService.Callback(ctx, q.Get("state"), q.Get("code"))
+	if e != nil {
+
+		switch e {
+		case domain.ErrOIDCDisabled:
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+		case domain.ErrInvalidOIDCState, domain.ErrIdentityRejected:
+			http.Error(w, e.Error(), http.StatusUnauthorized)
+		case domain.ErrIdentityNotLinked:
+			http.Error(w, e.Error(), http.StatusForbidden)
+		case domain.ErrIdentityLinked:
+			http.Error(w, e.Error(), http.StatusConflict)
+		default:
+			c := http.StatusInternalServerError
+			logging.FromContext(ctx).Errorf("unable to complete oidc login %v", e)
+			http.Error(w, http.StatusText(c), c)
+		}
+
+		return
+	}
+
+	if !il.Linked {
+		h.startSession(w, r, il.User)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&IdentityResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
@@ -0,0 +1,188 @@
+package port
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// ChangePasswordRequest http change password request model
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Bind parse change password request from http
+func (cpr *ChangePasswordRequest) Bind(_ *http.Request) error {
+
+	if len(cpr.CurrentPassword) < 1 {
+		return errors.New("invalid current password provided")
+	} else if len(cpr.NewPassword) < 1 {
+		return errors.New("invalid new password provided")
+	}
+
+	return nil
+}
+
+// ForgotPasswordRequest http forgot password request model
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// Bind parse forgot password request from http
+func (fpr *ForgotPasswordRequest) Bind(_ *http.Request) error {
+
+	if len(fpr.Email) < 1 {
+		return errors.New("invalid email provided")
+	}
+
+	return nil
+}
+
+// ResetPasswordRequest http reset password request model
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Bind parse reset password request from http
+func (rpr *ResetPasswordRequest) Bind(_ *http.Request) error {
+
+	if len(rpr.Token) < 1 {
+		return errors.New("invalid reset token provided")
+	} else if len(rpr.NewPassword) < 1 {
+		return errors.New("invalid new password provided")
+	}
+
+	return nil
+}
+
+// PasswordResponse http password change and reset response model
+type PasswordResponse struct {
+	Message string `json:"message"`
+}
+
+// changePassword replace password of the authenticated user
+func (h *HTTPServer) changePassword(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &ChangePasswordRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	sid, e := sessionFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.PasswordService.Change(ctx, uid, sid, p.CurrentPassword, p.NewPassword)
+	if e != nil {
+
+		if e == domain.ErrInvalidPassword {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		} else if e == domain.ErrInvalidCredentials {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		} else if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to change password %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writePassword(w, r)
+}
+
+// forgotPassword email a password reset token
+//
+// accepted whether or not the email belongs to a user, too many requests
+// for the email or from the client ip are rejected the same way for either
+func (h *HTTPServer) forgotPassword(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &ForgotPasswordRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	e = h.bundle.PasswordService.RequestReset(ctx, p.Email, clientIP(r))
+	if e != nil {
+
+		if e == domain.ErrTooManyRequests {
+			http.Error(w, e.Error(), http.StatusTooManyRequests)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to request password reset %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writePassword(w, r)
+}
+
+// resetPassword replace password with an emailed reset token
+func (h *HTTPServer) resetPassword(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &ResetPasswordRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	e = h.bundle.PasswordService.Reset(ctx, p.Token, p.NewPassword)
+	if e != nil {
+
+		if e == domain.ErrInvalidPassword || e == domain.ErrInvalidResetToken {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to reset password %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writePassword(w, r)
+}
+
+func (h *HTTPServer) writePassword(w http.ResponseWriter, r *http.Request) {
+
+	w.WriteHeader(http.StatusAccepted)
+	e := json.NewEncoder(w).Encode(&PasswordResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
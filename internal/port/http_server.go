@@ -2,20 +2,18 @@
 package port
 
 import (
-	"crypto/ecdsa"
-	"crypto/x509"
+	"context"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -28,46 +26,40 @@ import (
 
 // HTTPServer exposed endpoints
 type HTTPServer struct {
-	bundle     *domain.Bundle
-	privateKey *ecdsa.PrivateKey
+	bundle *domain.Bundle
+	keys   *mw.KeyManager
+
+	// draining set once graceful shutdown begins
+	draining atomic.Bool
 }
 
 // NewHTTPServer create new http server instance
-func NewHTTPServer(bundle *domain.Bundle) (*HTTPServer, error) {
-
-	p := os.Getenv("JWT_PRIVATE_KEY")
-	if p == "" {
-		return nil, errors.New("$JWT_PRIVATE_KEY is not set")
-	}
-
-	bb, e := os.ReadFile(filepath.Clean(p))
-	if e != nil {
-		return nil, fmt.Errorf("failed to open ecdsa private key %v", e)
-	}
-
-	b, _ := pem.Decode(bb)
-
-	pk, e := x509.ParseECPrivateKey(b.Bytes)
-	if e != nil {
-		return nil, fmt.Errorf("failed to to parse key from bytes %v", e)
-	}
-
-	return &HTTPServer{bundle: bundle, privateKey: pk}, nil
+func NewHTTPServer(bundle *domain.Bundle, keys *mw.KeyManager) *HTTPServer {
+	return &HTTPServer{bundle: bundle, keys: keys}
 }
 
 // NewRouter create new chi implementation of http.ServeMux
-func NewRouter(srv *HTTPServer, auth *mw.Authenticator) *chi.Mux {
+//
+// forwarded client addresses are only honoured from $TRUSTED_PROXIES
+func NewRouter(srv *HTTPServer, auth *mw.Authenticator, tr *mw.Tracing) (*chi.Mux, error) {
 
 	ao := os.Getenv("ALLOWED_ORIGINS")
 	if ao == "" {
 		ao = "http://localhost"
 	}
 
+	tp, e := mw.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if e != nil {
+		return nil, fmt.Errorf("unable to parse $TRUSTED_PROXIES %v", e)
+	}
+
 	r := chi.NewRouter()
 
-	r.Use(middleware.RealIP)
+	r.Use(tr.Handler)
+	r.Use(mw.RealIP(tp))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(mw.Metrics)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{ao},
@@ -83,7 +75,6 @@ func NewRouter(srv *HTTPServer, auth *mw.Authenticator) *chi.Mux {
 		r.Use(auth.ValidateJWT)
 
 		r.Get("/user/{user_id}", srv.fetchUser)
-		r.Put("/user", srv.updateUser)
 		r.Get("/user/search/{search_str}", srv.searchUsers)
 
 		r.Post("/contact", srv.addContact)
@@ -94,13 +85,73 @@ func NewRouter(srv *HTTPServer, auth *mw.Authenticator) *chi.Mux {
 
 		r.Post("/conversation", srv.createConversation)
 		r.Get("/conversation/", srv.listConversations)
+		r.Put("/conversation/{conversation_id}/preferences", srv.updatePreferences)
+		r.Put("/conversation/{conversation_id}/read", srv.markRead)
+		r.Post("/conversation/{conversation_id}/message", srv.sendMessage)
+		r.Get("/conversation/{conversation_id}/message/", srv.listMessages)
+
+		r.Post("/conversation/{conversation_id}/invite", srv.createInvite)
+		r.Get("/conversation/{conversation_id}/invite/", srv.listInvites)
+		r.Delete("/conversation/{conversation_id}/invite/{invite_id}", srv.revokeInvite)
+		r.Get("/conversation/{conversation_id}/invite/{invite_id}/redemptions", srv.listInviteRedemptions)
+
+		r.Post("/invite/{invite_token}/redeem", srv.redeemInvite)
+
+		r.Post("/user/verify/resend", srv.resendVerification)
+
+		// api keys may not manage the account, its sessions, other api keys or other users
+		r.Group(func(r chi.Router) {
+
+			r.Use(mw.RequireSession)
+
+			r.Put("/user", srv.updateUser)
+			r.Post("/user/logout", srv.logout)
+			r.Post("/user/logout/all", srv.logoutAll)
+			r.Put("/user/password", srv.changePassword)
+			r.Post("/user/2fa", srv.enrollTwoFactor)
+			r.Post("/user/2fa/confirm", srv.confirmTwoFactor)
+			r.Post("/user/2fa/disable", srv.disableTwoFactor)
+			r.Post("/user/oidc/link", srv.linkIdentity)
+
+			r.Get("/session/", srv.listSessions)
+			r.Delete("/session/{session_id}", srv.revokeSession)
+
+			r.Post("/user/key", srv.createAPIKey)
+			r.Get("/user/key/", srv.listAPIKeys)
+			r.Delete("/user/key/{key_id}", srv.revokeAPIKey)
+
+			r.Post("/bot", srv.createBot)
+			r.Get("/bot/", srv.listBots)
+			r.Post("/bot/{bot_id}/key", srv.createAPIKey)
+			r.Get("/bot/{bot_id}/key/", srv.listAPIKeys)
+			r.Delete("/bot/{bot_id}/key/{key_id}", srv.revokeAPIKey)
+
+			r.With(auth.RequirePermission(domain.PermissionListUsers)).Get("/admin/user/", srv.listUsers)
+			r.With(auth.RequirePermission(domain.PermissionManageRoles)).Put("/admin/user/{user_id}/role", srv.setUserRole)
+			r.With(auth.RequirePermission(domain.PermissionDisableUsers)).Post("/admin/user/{user_id}/disable", srv.disableUser)
+			r.With(auth.RequirePermission(domain.PermissionDisableUsers)).Post("/admin/user/{user_id}/enable", srv.enableUser)
+			r.With(auth.RequirePermission(domain.PermissionManageSessions)).Get("/admin/user/{user_id}/session/", srv.listUserSessions)
+			r.With(auth.RequirePermission(domain.PermissionManageSessions)).Delete("/admin/user/{user_id}/session/{session_id}", srv.revokeUserSession)
+			r.With(auth.RequirePermission(domain.PermissionManageSessions)).Post("/admin/user/{user_id}/logout", srv.logoutUser)
+		})
 	})
 
 	r.Post("/api/v1/user", srv.createUser)
 	r.Post("/api/v1/user/login", srv.userLogin)
+	r.Post("/api/v1/user/login/2fa", srv.answerLoginChallenge)
+	r.Post("/api/v1/user/refresh", srv.refreshToken)
+	r.Post("/api/v1/user/password/forgot", srv.forgotPassword)
+	r.Post("/api/v1/user/password/reset", srv.resetPassword)
+	r.Post("/api/v1/user/verify", srv.verifyEmail)
+	r.Get("/api/v1/oidc/login", srv.oidcLogin)
+	r.Get("/api/v1/oidc/callback", srv.oidcCallback)
+	r.Get("/api/v1/invite/{invite_token}", srv.previewInvite)
 	r.Get("/health", srv.health)
+	r.Get("/livez", srv.livez)
+	r.Get("/readyz", srv.readyz)
+	r.Get("/.well-known/jwks.json", srv.jwks)
 
-	return r
+	return r, nil
 }
 
 // NewUserPayload http new user payload model
@@ -139,12 +190,40 @@ type NewUserResponse struct {
 }
 
 // UserPayload http user model
+//
+// owner is only set on bots
 type UserPayload struct {
-	UID       string    `json:"uid"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	UID           string    `json:"uid"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Type          string    `json:"type"`
+	Owner         string    `json:"owner,omitempty"`
+	Role          string    `json:"role"`
+	Disabled      bool      `json:"disabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func newUserPayload(user *domain.User) *UserPayload {
+
+	up := &UserPayload{
+		UID:           user.UID.String(),
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Type:          string(user.Type),
+		Role:          string(user.Role),
+		Disabled:      user.Disabled,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+
+	if user.Owner != uuid.Nil {
+		up.Owner = user.Owner.String()
+	}
+
+	return up
 }
 
 // UserDetailsPayload http user details model
@@ -155,13 +234,7 @@ type UserDetailsPayload struct {
 
 func newUserResponse(user *domain.User) *NewUserResponse {
 	return &NewUserResponse{
-		UserPayload: &UserPayload{
-			UID:       user.UID.String(),
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		UserPayload: newUserPayload(user),
 	}
 }
 
@@ -214,13 +287,7 @@ type FetchUserResponse struct {
 
 func newFetchUserResponse(user *domain.User) *FetchUserResponse {
 	return &FetchUserResponse{
-		User: &UserPayload{
-			UID:       user.UID.String(),
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		User: newUserPayload(user),
 	}
 }
 
@@ -286,9 +353,20 @@ func (ulp *UserLoginRequest) Bind(_ *http.Request) error {
 }
 
 // UserLoginResponse http user login response model
+//
+// the access token expires at expires_at, the refresh token
+// is exchanged for a new token pair before then
+//
+// users with two factor login instead receive a challenge token
+// expiring at expires_at, answered with a code for the token pair
 type UserLoginResponse struct {
-	UserID string `json:"user_id"`
-	Token  string `json:"access_token"`
+	UserID            string    `json:"user_id"`
+	SessionID         string    `json:"session_id,omitempty"`
+	Token             string    `json:"access_token,omitempty"`
+	RefreshToken      string    `json:"refresh_token,omitempty"`
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token,omitempty"`
+	ExpiresAt         time.Time `json:"expires_at"`
 }
 
 func (h *HTTPServer) userLogin(w http.ResponseWriter, r *http.Request) {
@@ -302,46 +380,65 @@ func (h *HTTPServer) userLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uid, e := h.bundle.UserService.Login(ctx, p.Username, p.Password)
+	uid, e := h.bundle.UserService.Login(ctx, p.Username, p.Password, clientIP(r))
 	if e != nil {
+
+		if e == domain.ErrInvalidCredentials {
+			http.Error(w, e.Error(), http.StatusUnauthorized)
+			return
+		} else if e == domain.ErrLoginLocked {
+			http.Error(w, e.Error(), http.StatusTooManyRequests)
+			return
+		} else if e == domain.ErrAccountDisabled {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
 		logging.FromContext(ctx).Errorf("failed to check user login %v", e)
 		http.Error(w, "unable to verify user login", http.StatusInternalServerError)
 		return
 	}
 
-	c := &mw.CustomClaims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "test",
-			Subject:   "somebody",
-			ID:        "1",
-			Audience:  []string{"somebody_else"},
-		},
-		UserID: uid,
-	}
+	h.startSession(w, r, uuid.MustParse(uid))
+}
+
+// startSession issue tokens for an authenticated user or ask for a two factor code
+func (h *HTTPServer) startSession(w http.ResponseWriter, r *http.Request, uid uuid.UUID) {
 
-	t := jwt.NewWithClaims(jwt.SigningMethodES384, c)
+	ctx := r.Context()
 
-	s, e := t.SignedString(h.privateKey)
+	ch, e := h.bundle.TwoFactorService.Challenge(ctx, uid)
 	if e != nil {
-		logging.FromContext(ctx).Errorf("failed to sign jwt token %v", e)
-		http.Error(w, "failed to generate jwt token", http.StatusInternalServerError)
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to create login challenge %v", e)
+		http.Error(w, http.StatusText(c), c)
 		return
 	}
 
-	rsp := &UserLoginResponse{
-		UserID: uid,
-		Token:  s,
+	if ch != nil {
+		h.writeChallenge(w, r, uid.String(), ch)
+		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	if e := json.NewEncoder(w).Encode(rsp); e != nil {
-		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
-		http.Error(w, "unable to encode response", http.StatusInternalServerError)
+	ss, e := h.bundle.SessionService.Create(ctx, &domain.NewSession{
+		User:      uid,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	})
+	if e != nil {
+
+		if e == domain.ErrAccountDisabled {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to create session %v", e)
+		http.Error(w, http.StatusText(c), c)
 		return
 	}
+
+	h.writeTokens(w, r, ss)
 }
 
 // AddContactPayload http add contact request model
@@ -401,6 +498,12 @@ func (h *HTTPServer) addContact(w http.ResponseWriter, r *http.Request) {
 
 	c, e := h.bundle.ContactService.Create(ctx, p.NewContact)
 	if e != nil {
+
+		if e == domain.ErrEmailNotVerified {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
 		logging.FromContext(ctx).Errorf("failed to add new contact %v", e)
 		http.Error(w, "failed to add new contact", http.StatusInternalServerError)
 		return
@@ -452,13 +555,7 @@ type UpdateUserResponse struct {
 
 func newUpdateUserResponse(user *domain.User) *UpdateUserResponse {
 	return &UpdateUserResponse{
-		User: &UserPayload{
-			UID:       user.UID.String(),
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		User: newUserPayload(user),
 	}
 }
 
@@ -870,8 +967,22 @@ func (h *HTTPServer) createConversation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	p.NewConversation.Creator = uid
+
 	c, e := h.bundle.MessengerService.CreateConversation(ctx, p.NewConversation)
 	if e != nil {
+
+		if e == domain.ErrEmailNotVerified {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
 		logging.FromContext(ctx).Errorf("unable to create new conversation %v", e)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -886,49 +997,124 @@ func (h *HTTPServer) createConversation(w http.ResponseWriter, r *http.Request)
 
 // ListConversationsParams http list conversations params model
 type ListConversationsParams struct {
-	UIDString string `json:"uid"`
-	UID       uuid.UUID
+	UID    uuid.UUID
+	Filter domain.ConversationFilter
+	Cursor string
+	Limit  int64
 }
 
 // Bind parse request into model
-func (lcp *ListConversationsParams) Bind(_ *http.Request) error {
+//
+// conversations are listed for the authenticated user
+// optional filter query parameter accepts unarchived, archived or all
+// optional cursor and limit query parameters page through results
+func (lcp *ListConversationsParams) Bind(r *http.Request) error {
 
-	uid, e := uuid.Parse(lcp.UIDString)
+	uid, e := userFromContext(r.Context())
 	if e != nil {
-		return fmt.Errorf("failed to pase uid from request body %v", e)
+		return fmt.Errorf("failed to parse uid from token claims %v", e)
 	}
 
 	lcp.UID = uid
+	lcp.Cursor = r.URL.Query().Get("cursor")
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+
+		lcp.Limit, e = strconv.ParseInt(l, 10, 64)
+		if e != nil || lcp.Limit < 1 {
+			return errors.New("invalid limit parameter")
+		}
+	}
+
+	switch r.URL.Query().Get("filter") {
+	case "", "unarchived":
+		lcp.Filter = domain.ConversationFilterUnarchived
+	case "archived":
+		lcp.Filter = domain.ConversationFilterArchived
+	case "all":
+		lcp.Filter = domain.ConversationFilterAll
+	default:
+		return errors.New("invalid filter parameter")
+	}
 
 	return nil
 }
 
+// EnvelopePayload http envelope model
+type EnvelopePayload struct {
+	UID             string    `json:"uid"`
+	Conversation    string    `json:"conversation"`
+	Sender          string    `json:"sender"`
+	Message         string    `json:"message"`
+	Seq             int64     `json:"seq"`
+	ClientMessageID string    `json:"client_message_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
 // ConversationPayload http conversation model
 type ConversationPayload struct {
-	UID        string    `json:"uid"`
-	Recipients []string  `json:"recipients"`
-	CreatedAt  time.Time `json:"created_at"`
+	UID         string           `json:"uid"`
+	Recipients  []string         `json:"recipients"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	MutedUntil  *time.Time       `json:"muted_until"`
+	Archived    bool             `json:"archived"`
+	Pinned      bool             `json:"pinned"`
+	LastMessage *EnvelopePayload `json:"last_message"`
+	UnreadCount int64            `json:"unread_count"`
+	MemberCount int64            `json:"member_count"`
+	MemberNames []string         `json:"member_names"`
 }
 
 // ListConversationsResponse http list conversations response model
 type ListConversationsResponse struct {
 	Conversations []*ConversationPayload `json:"conversations"`
+	NextCursor    string                 `json:"next_cursor"`
+}
+
+func newEnvelopePayload(envelope *domain.Envelope) *EnvelopePayload {
+	return &EnvelopePayload{
+		UID:             envelope.UID.String(),
+		Conversation:    envelope.ConversationUUID.String(),
+		Sender:          envelope.Sender.String(),
+		Message:         envelope.Message,
+		Seq:             envelope.Seq,
+		ClientMessageID: envelope.ClientMessageID,
+		CreatedAt:       envelope.CreatedAt,
+	}
 }
 
-func newListConversationsResponse(conversations []*domain.Conversation) *ListConversationsResponse {
+func newListConversationsResponse(page *domain.ConversationPage) *ListConversationsResponse {
 
-	cs := make([]*ConversationPayload, 0, len(conversations))
+	cs := make([]*ConversationPayload, 0, len(page.Conversations))
 
-	for _, c := range conversations {
+	for _, c := range page.Conversations {
 
-		cs = append(cs, &ConversationPayload{
-			UID:       c.UID.String(),
-			CreatedAt: c.CreatedAt,
-		})
+		cp := &ConversationPayload{
+			UID:         c.UID.String(),
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			Archived:    c.Archived,
+			Pinned:      !c.PinnedAt.IsZero(),
+			UnreadCount: c.UnreadCount,
+			MemberCount: c.MemberCount,
+			MemberNames: c.MemberNames,
+		}
+
+		if !c.MutedUntil.IsZero() {
+			cp.MutedUntil = &c.MutedUntil
+		}
+
+		if c.LastMessage != nil {
+			cp.LastMessage = newEnvelopePayload(c.LastMessage)
+		}
+
+		cs = append(cs, cp)
 	}
 
 	return &ListConversationsResponse{
 		Conversations: cs,
+		NextCursor:    page.NextCursor,
 	}
 }
 
@@ -937,7 +1123,7 @@ func (h *HTTPServer) listConversations(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	p := &ListConversationsParams{}
-	e := render.Bind(r, p)
+	e := p.Bind(r)
 	if e != nil {
 		c := http.StatusBadRequest
 		logging.FromContext(ctx).Errorf("unable to parse request parameters %v", e)
@@ -945,8 +1131,20 @@ func (h *HTTPServer) listConversations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cs, e := h.bundle.MessengerService.ListConversations(ctx, p.UID)
+	cs, e := h.bundle.MessengerService.ListConversations(ctx, &domain.ConversationQuery{
+		User:   p.UID,
+		Filter: p.Filter,
+		Cursor: p.Cursor,
+		Limit:  p.Limit,
+	})
 	if e != nil {
+
+		if e == domain.ErrInvalidCursor {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
 		logging.FromContext(ctx).Errorf("failed to list conversations %v", e)
 		http.Error(w, "failed to list conversations", http.StatusInternalServerError)
 		return
@@ -960,16 +1158,825 @@ func (h *HTTPServer) listConversations(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *HTTPServer) health(w http.ResponseWriter, r *http.Request) {
+// MarkReadResponse http mark conversation read response model
+type MarkReadResponse struct {
+	Message string `json:"message"`
+}
+
+func (h *HTTPServer) markRead(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("OK"))
-	if err != nil {
-		logging.FromContext(ctx).Errorf("error encoding health check response %v", err)
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.MessengerService.MarkRead(ctx, cID, uid)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to mark conversation read %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&MarkReadResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// ConversationPreferencesPayload http conversation preferences model
+type ConversationPreferencesPayload struct {
+	// MutedUntil null or omitted unmutes the conversation
+	MutedUntil *time.Time `json:"muted_until"`
+	Archived   bool       `json:"archived"`
+	Pinned     bool       `json:"pinned"`
+}
+
+// UpdatePreferencesParams http update conversation preferences params model
+type UpdatePreferencesParams struct {
+	ConversationUID                 uuid.UUID `json:"-"`
+	*ConversationPreferencesPayload `json:"preferences"`
+}
+
+// Bind parse http request into update preferences params model
+func (upp *UpdatePreferencesParams) Bind(r *http.Request) error {
+
+	if upp.ConversationPreferencesPayload == nil {
+		return errors.New("missing preferences parameters")
+	}
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		return fmt.Errorf("unable to parse conversation id parameter %v", e)
+	}
+
+	upp.ConversationUID = cID
+
+	return nil
+}
+
+// UpdatePreferencesResponse http update conversation preferences response model
+type UpdatePreferencesResponse struct {
+	*ConversationPreferencesPayload `json:"preferences"`
+}
+
+func newUpdatePreferencesResponse(preferences *domain.ConversationPreferences) *UpdatePreferencesResponse {
+
+	cpp := &ConversationPreferencesPayload{
+		Archived: preferences.Archived,
+		Pinned:   preferences.Pinned,
+	}
+
+	if !preferences.MutedUntil.IsZero() {
+		cpp.MutedUntil = &preferences.MutedUntil
+	}
+
+	return &UpdatePreferencesResponse{
+		ConversationPreferencesPayload: cpp,
+	}
+}
+
+func (h *HTTPServer) updatePreferences(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &UpdatePreferencesParams{}
+	e := render.Bind(r, p)
+	if e != nil {
+		c := http.StatusBadRequest
+		logging.FromContext(ctx).Errorf("unable to parse request update preferences body %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	cp := &domain.ConversationPreferences{
+		ConversationUUID: p.ConversationUID,
+		User:             uid,
+		Archived:         p.Archived,
+		Pinned:           p.Pinned,
+	}
+
+	if p.MutedUntil != nil {
+		cp.MutedUntil = *p.MutedUntil
+	}
+
+	cp, e = h.bundle.MessengerService.UpdatePreferences(ctx, cp)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to update conversation preferences %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(newUpdatePreferencesResponse(cp))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// NewEnvelopePayload http new envelope model
+//
+// the sender is always the authenticated user, retrying a send
+// with the same client_message_id returns the original envelope
+type NewEnvelopePayload struct {
+	Message         string `json:"message"`
+	ClientMessageID string `json:"client_message_id,omitempty"`
+}
+
+// NewEnvelopeParams http new envelope params model
+type NewEnvelopeParams struct {
+	ConversationUID     uuid.UUID `json:"-"`
+	*NewEnvelopePayload `json:"new_envelope"`
+}
+
+// Bind parse http request into new envelope params model
+func (nep *NewEnvelopeParams) Bind(r *http.Request) error {
+
+	if nep.NewEnvelopePayload == nil {
+		return errors.New("missing new envelope parameters")
+	}
+
+	if nep.Message == "" {
+		return errors.New("no message parameter provided")
 	}
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		return fmt.Errorf("unable to parse conversation id parameter %v", e)
+	}
+
+	nep.ConversationUID = cID
+
+	return nil
+}
+
+// NewEnvelopeResponse http new envelope response model
+type NewEnvelopeResponse struct {
+	*EnvelopePayload `json:"envelope"`
+}
+
+// ListMessagesParams http list messages params model
+type ListMessagesParams struct {
+	ConversationUID uuid.UUID
+	AfterSeq        int64
+	Limit           int64
+}
+
+// Bind parse request into model
+//
+// optional after_seq and limit query parameters page through
+// messages in sequence order, the last seq of a page is the next after_seq
+func (lmp *ListMessagesParams) Bind(r *http.Request) error {
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		return fmt.Errorf("failed to parse conversation uid %v", e)
+	}
+
+	lmp.ConversationUID = cID
+
+	if as := r.URL.Query().Get("after_seq"); as != "" {
+
+		lmp.AfterSeq, e = strconv.ParseInt(as, 10, 64)
+		if e != nil || lmp.AfterSeq < 0 {
+			return errors.New("invalid after_seq parameter")
+		}
+	}
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+
+		lmp.Limit, e = strconv.ParseInt(l, 10, 64)
+		if e != nil || lmp.Limit < 1 {
+			return errors.New("invalid limit parameter")
+		}
+	}
+
+	return nil
+}
+
+// ListMessagesResponse http list messages response model
+type ListMessagesResponse struct {
+	Envelopes []*EnvelopePayload `json:"envelopes"`
+}
+
+func newListMessagesResponse(envelopes []*domain.Envelope) *ListMessagesResponse {
+
+	eps := make([]*EnvelopePayload, 0, len(envelopes))
+
+	for _, ev := range envelopes {
+		eps = append(eps, newEnvelopePayload(ev))
+	}
+
+	return &ListMessagesResponse{
+		Envelopes: eps,
+	}
+}
+
+func (h *HTTPServer) sendMessage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &NewEnvelopeParams{}
+	e := render.Bind(r, p)
+	if e != nil {
+		c := http.StatusBadRequest
+		logging.FromContext(ctx).Errorf("unable to parse request new envelope body %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	ev, e := h.bundle.MessengerService.CreateMessage(ctx, &domain.NewEnvelope{
+		Sender:           uid,
+		ConversationUUID: p.ConversationUID,
+		Message:          p.Message,
+		ClientMessageID:  p.ClientMessageID,
+	})
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		} else if e == domain.ErrEmailNotVerified {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		} else if e == domain.ErrInvalidClientMessageID {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to send message %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	c := http.StatusCreated
+	if ev.Duplicate {
+		c = http.StatusOK
+	}
+
+	w.WriteHeader(c)
+	e = json.NewEncoder(w).Encode(&NewEnvelopeResponse{EnvelopePayload: newEnvelopePayload(ev)})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+func (h *HTTPServer) listMessages(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &ListMessagesParams{}
+	e := p.Bind(r)
+	if e != nil {
+		c := http.StatusBadRequest
+		logging.FromContext(ctx).Errorf("unable to parse request parameters %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	evs, e := h.bundle.MessengerService.ListMessages(ctx, &domain.MessageQuery{
+		ConversationUUID: p.ConversationUID,
+		Requester:        uid,
+		AfterSeq:         p.AfterSeq,
+		Limit:            p.Limit,
+	})
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to list messages %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(newListMessagesResponse(evs))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// NewInvitePayload http new invite model
+type NewInvitePayload struct {
+	MaxUses int64 `json:"max_uses"`
+	// ExpiresIn invite lifetime in seconds
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// NewInviteParams http new invite params model
+type NewInviteParams struct {
+	ConversationUID   uuid.UUID `json:"-"`
+	*NewInvitePayload `json:"new_invite"`
+}
+
+// Bind parse http request into new invite params model
+func (nip *NewInviteParams) Bind(r *http.Request) error {
+
+	if nip.NewInvitePayload == nil {
+		return errors.New("missing new invite parameters")
+	}
+
+	if nip.MaxUses < 0 {
+		return errors.New("invalid max uses parameter")
+	} else if nip.ExpiresIn < 0 {
+		return errors.New("invalid expires in parameter")
+	}
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		return fmt.Errorf("unable to parse conversation id parameter %v", e)
+	}
+
+	nip.ConversationUID = cID
+
+	return nil
+}
+
+// InvitePayload http invite model
+type InvitePayload struct {
+	UID          string     `json:"uid"`
+	Conversation string     `json:"conversation"`
+	Creator      string     `json:"creator"`
+	Token        string     `json:"token,omitempty"`
+	MaxUses      int64      `json:"max_uses"`
+	Uses         int64      `json:"uses"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewInviteResponse http new invite response model
+type NewInviteResponse struct {
+	*InvitePayload `json:"invite"`
+}
+
+// ListInvitesResponse http list invites response model
+type ListInvitesResponse struct {
+	Invites []*InvitePayload `json:"invites"`
+}
+
+func newInvitePayload(invite *domain.Invite) *InvitePayload {
+
+	ip := &InvitePayload{
+		UID:          invite.UID.String(),
+		Conversation: invite.ConversationUUID.String(),
+		Creator:      invite.Creator.String(),
+		Token:        invite.Token,
+		MaxUses:      invite.MaxUses,
+		Uses:         invite.Uses,
+		CreatedAt:    invite.CreatedAt,
+	}
+
+	if !invite.ExpiresAt.IsZero() {
+		ip.ExpiresAt = &invite.ExpiresAt
+	}
+
+	if !invite.RevokedAt.IsZero() {
+		ip.RevokedAt = &invite.RevokedAt
+	}
+
+	return ip
+}
+
+func newListInvitesResponse(invites []*domain.Invite) *ListInvitesResponse {
+
+	ips := make([]*InvitePayload, 0, len(invites))
+
+	for _, i := range invites {
+		ips = append(ips, newInvitePayload(i))
+	}
+
+	return &ListInvitesResponse{
+		Invites: ips,
+	}
+}
+
+func (h *HTTPServer) createInvite(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &NewInviteParams{}
+	e := render.Bind(r, p)
+	if e != nil {
+		c := http.StatusBadRequest
+		logging.FromContext(ctx).Errorf("unable to parse request create invite body %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	i, e := h.bundle.InviteService.Create(ctx, &domain.NewInvite{
+		ConversationUUID: p.ConversationUID,
+		Creator:          uid,
+		MaxUses:          p.MaxUses,
+		ExpiresIn:        time.Duration(p.ExpiresIn) * time.Second,
+	})
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		} else if e == domain.ErrEmailNotVerified {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to create invite %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	e = json.NewEncoder(w).Encode(&NewInviteResponse{InvitePayload: newInvitePayload(i)})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+func (h *HTTPServer) listInvites(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	is, e := h.bundle.InviteService.List(ctx, cID, uid)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to list invites %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(newListInvitesResponse(is))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// InviteParams http invite path params model
+type InviteParams struct {
+	ConversationUID uuid.UUID
+	InviteUID       uuid.UUID
+}
+
+// Bind parse http request into invite params model
+func (ip *InviteParams) Bind(r *http.Request) error {
+
+	cID, e := uuid.Parse(chi.URLParam(r, "conversation_id"))
+	if e != nil {
+		return fmt.Errorf("unable to parse conversation id parameter %v", e)
+	}
+
+	iID, e := uuid.Parse(chi.URLParam(r, "invite_id"))
+	if e != nil {
+		return fmt.Errorf("unable to parse invite id parameter %v", e)
+	}
+
+	ip.ConversationUID = cID
+	ip.InviteUID = iID
+
+	return nil
+}
+
+// RevokeInviteResponse http revoke invite response model
+type RevokeInviteResponse struct {
+	Message string `json:"message"`
+}
+
+func (h *HTTPServer) revokeInvite(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &InviteParams{}
+	e := p.Bind(r)
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.InviteService.Revoke(ctx, p.ConversationUID, p.InviteUID, uid)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		} else if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to revoke invite %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&RevokeInviteResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// InviteRedemptionPayload http invite redemption model
+type InviteRedemptionPayload struct {
+	UID       string    `json:"uid"`
+	Invite    string    `json:"invite"`
+	User      string    `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListInviteRedemptionsResponse http list invite redemptions response model
+type ListInviteRedemptionsResponse struct {
+	Redemptions []*InviteRedemptionPayload `json:"redemptions"`
+}
+
+func newListInviteRedemptionsResponse(redemptions []*domain.InviteRedemption) *ListInviteRedemptionsResponse {
+
+	rps := make([]*InviteRedemptionPayload, 0, len(redemptions))
+
+	for _, rd := range redemptions {
+		rps = append(rps, &InviteRedemptionPayload{
+			UID:       rd.UID.String(),
+			Invite:    rd.InviteUID.String(),
+			User:      rd.User.String(),
+			CreatedAt: rd.CreatedAt,
+		})
+	}
+
+	return &ListInviteRedemptionsResponse{
+		Redemptions: rps,
+	}
+}
+
+func (h *HTTPServer) listInviteRedemptions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &InviteParams{}
+	e := p.Bind(r)
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	rds, e := h.bundle.InviteService.ListRedemptions(ctx, p.ConversationUID, p.InviteUID, uid)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			c := http.StatusForbidden
+			http.Error(w, http.StatusText(c), c)
+			return
+		} else if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to list invite redemptions %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(newListInviteRedemptionsResponse(rds))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// InvitePreviewResponse http invite preview response model
+type InvitePreviewResponse struct {
+	Conversation string     `json:"conversation"`
+	Members      int64      `json:"members"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func newInvitePreviewResponse(preview *domain.InvitePreview) *InvitePreviewResponse {
+
+	ipr := &InvitePreviewResponse{
+		Conversation: preview.ConversationUUID.String(),
+		Members:      preview.Members,
+		CreatedAt:    preview.CreatedAt,
+	}
+
+	if !preview.ExpiresAt.IsZero() {
+		ipr.ExpiresAt = &preview.ExpiresAt
+	}
+
+	return ipr
+}
+
+func (h *HTTPServer) previewInvite(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	t := chi.URLParam(r, "invite_token")
+	if t == "" {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	ip, e := h.bundle.InviteService.Preview(ctx, t)
+	if e != nil {
+
+		if e == domain.ErrInviteInvalid {
+			c := http.StatusGone
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to preview invite %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(newInvitePreviewResponse(ip))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+func (h *HTTPServer) redeemInvite(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	t := chi.URLParam(r, "invite_token")
+	if t == "" {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	cv, e := h.bundle.InviteService.Redeem(ctx, t, uid)
+	if e != nil {
+
+		if e == domain.ErrInviteInvalid {
+			c := http.StatusGone
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to redeem invite %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(createConversationResponse(cv))
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// signAccessToken issue access token for user within session signed by the current key
+func signAccessToken(keys *mw.KeyManager, uid, sid string) (string, time.Time, error) {
+
+	c := mw.NewAccessClaims(uid, sid)
+
+	st, e := keys.Sign(c)
+	if e != nil {
+		return "", time.Time{}, e
+	}
+
+	return st, c.ExpiresAt.Time, nil
+}
+
+// userFromContext parse authenticated user id set by jwt middleware
+func userFromContext(ctx context.Context) (uuid.UUID, error) {
+
+	sid, ok := ctx.Value(mw.User).(string)
+	if !ok {
+		return uuid.Nil, errors.New("no user found in context")
+	}
+
+	return uuid.Parse(sid)
 }
 
 func newContactResponse(contact *domain.Contact) *AddContactResponse {
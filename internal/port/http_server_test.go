@@ -2,35 +2,80 @@ package port_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/x509"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-chat/internal/mail"
+	"github.com/trevatk/go-chat/internal/oidc"
+	"github.com/trevatk/go-chat/internal/oidc/oidctest"
 	"github.com/trevatk/go-chat/internal/port"
 	"github.com/trevatk/go-chat/internal/port/middleware"
 	"github.com/trevatk/go-pkg/db"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 func init() {
-	_ = os.Setenv("SQLITE_DSN", "testfiles/db/chat.db")
+	_ = os.Setenv("SQLITE_DSN", "testfiles/db/chat.db?_pragma=busy_timeout(5000)&_txlock=immediate")
 	_ = os.Setenv("SQLITE_MIGRATIONS_DIR", "../../migrations")
 	_ = os.Setenv("JWT_PRIVATE_KEY", "testfiles/certs/privateKey.der")
 }
 
 type HTTPServerSuite struct {
 	suite.Suite
-	mux *chi.Mux
+	db     *sql.DB
+	srv    *port.HTTPServer
+	auth   *middleware.Authenticator
+	keys   *middleware.KeyManager
+	mux    *chi.Mux
+	bundle *domain.Bundle
+	mail   *mailbox
+	issuer *oidctest.Issuer
+	idp    *httptest.Server
+}
+
+func (s *HTTPServerSuite) SetupSuite() {
+
+	iss, e := oidctest.NewIssuer()
+	s.Require().NoError(e)
+
+	s.issuer = iss
+	s.idp = httptest.NewServer(iss)
+}
+
+func (s *HTTPServerSuite) TearDownSuite() {
+	s.idp.Close()
 }
 
 func (s *HTTPServerSuite) SetupTest() {
@@ -65,15 +110,175 @@ func (s *HTTPServerSuite) SetupTest() {
 	e = db.MigrateSQLite(sdb)
 	a.NoError(e)
 
-	b := domain.NewBundle(sdb)
+	s.db = sdb
+
+	s.mail = &mailbox{}
+
+	b := domain.NewBundle(sdb, s.mail, oidc.NewClient(&oidc.Config{
+		Issuer:      s.idp.URL,
+		ClientID:    "go-chat",
+		RedirectURL: "http://go-chat.test/api/v1/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}))
+	s.bundle = b
+
+	k, e := middleware.NewPrivateKey()
+	a.NoError(e)
+
+	s.keys, e = middleware.NewKeyManager(k)
+	a.NoError(e)
+
+	s.srv = port.NewHTTPServer(b, s.keys)
+	s.auth = middleware.NewAuthenticator(s.keys)
+	s.auth.CheckRevocations(b.RevocationList)
+	s.auth.AcceptAPIKeys(b.APIKeyService)
+	s.auth.AuthorizeWith(b.AccessControl)
+	a.NoError(b.RevocationList.Start(context.TODO()))
+
+	s.mux, e = port.NewRouter(s.srv, s.auth, middleware.NewTracing(zap.NewNop()))
+	a.NoError(e)
+}
+
+func (s *HTTPServerSuite) TearDownTest() {
+	s.bundle.RevocationList.Shutdown()
+}
+
+func (s *HTTPServerSuite) TestHealthChecks() {
+
+	a := assert.New(s.T())
+
+	rr := s.do(http.MethodGet, "/livez", "", nil)
+	a.Equal(http.StatusOK, rr.Code)
+
+	// legacy health check keeps its original plain text response
+	rr = s.do(http.MethodGet, "/health", "", nil)
+	a.Equal(http.StatusOK, rr.Code)
+	a.Equal("OK", rr.Body.String())
+
+	ready := func(expected int) *port.ReadinessResponse {
+
+		rr := s.do(http.MethodGet, "/readyz", "", nil)
+		a.Equal(expected, rr.Code)
+
+		rsp := &port.ReadinessResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(rsp))
+
+		return rsp
+	}
+
+	rsp := ready(http.StatusOK)
+	a.Equal("ok", rsp.Status)
+
+	for _, name := range []string{"shutdown", "database", "migrations", "broker", "jwt_key"} {
+		if a.Contains(rsp.Checks, name) {
+			a.Equal("ok", rsp.Checks[name].Status)
+		}
+	}
+
+	// binary expects a newer schema than applied
+	_, e := s.db.Exec("UPDATE schema_migrations SET version = version - 1")
+	a.NoError(e)
+
+	rsp = ready(http.StatusServiceUnavailable)
+	if a.Contains(rsp.Checks, "migrations") {
+		a.Equal("failed", rsp.Checks["migrations"].Status)
+		a.NotEmpty(rsp.Checks["migrations"].Error)
+	}
+
+	_, e = s.db.Exec("UPDATE schema_migrations SET version = version + 1")
+	a.NoError(e)
+
+	// readiness fails once graceful shutdown begins, liveness does not
+	s.srv.Drain()
+
+	rsp = ready(http.StatusServiceUnavailable)
+	if a.Contains(rsp.Checks, "shutdown") {
+		a.Equal("failed", rsp.Checks["shutdown"].Status)
+	}
+
+	rr = s.do(http.MethodGet, "/livez", "", nil)
+	a.Equal(http.StatusOK, rr.Code)
+}
+
+func (s *HTTPServerSuite) TestMetrics() {
+
+	a := assert.New(s.T())
+
+	rr := s.do(http.MethodGet, "/livez", "", nil)
+	a.Equal(http.StatusOK, rr.Code)
+
+	// metrics are not served by the public router
+	rr = s.do(http.MethodGet, "/metrics", "", nil)
+	a.Equal(http.StatusNotFound, rr.Code)
+
+	rq, e := http.NewRequest(http.MethodGet, "/metrics", nil)
+	a.NoError(e)
+
+	rr = httptest.NewRecorder()
+	port.NewMetricsRouter().ServeHTTP(rr, rq)
+	a.Equal(http.StatusOK, rr.Code)
+
+	b := rr.Body.String()
+
+	// requests are labeled by route pattern
+	a.Contains(b, `chat_http_requests_total{code="200",method="GET",route="/livez"}`)
+	a.Contains(b, "chat_messenger_messages_sent_total")
+	a.Contains(b, "chat_user_login_bcrypt_duration_seconds")
+}
+
+func (s *HTTPServerSuite) TestTracing() {
+
+	a := assert.New(s.T())
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
 
-	srv, e := port.NewHTTPServer(b)
+	// router picks up the recording provider when created
+	var e error
+	s.mux, e = port.NewRouter(s.srv, s.auth, middleware.NewTracing(zap.NewNop()))
 	a.NoError(e)
 
-	mw, e := middleware.NewAuthenticator()
+	uid, token := s.createUserAndLogin("tracing", "tracing@example.com", "Passw0rd!")
+
+	// trace context propagated by the caller
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00f067aa0ba902b7"
+	)
+
+	rq, e := http.NewRequest(http.MethodGet, "/api/v1/user/"+uid, nil)
 	a.NoError(e)
+	rq.Header.Add("Authorization", "Bearer: "+token)
+	rq.Header.Add("traceparent", "00-"+traceID+"-"+parent+"-01")
+
+	rr := httptest.NewRecorder()
+	s.mux.ServeHTTP(rr, rq)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	var (
+		root  sdktrace.ReadOnlySpan
+		query sdktrace.ReadOnlySpan
+	)
+
+	for _, span := range sr.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
 
-	s.mux = port.NewRouter(srv, mw)
+		switch span.Name() {
+		case "GET /api/v1/user/{user_id}":
+			root = span
+		case "repository.ReadUser":
+			query = span
+		}
+	}
+
+	if a.NotNil(root) && a.NotNil(query) {
+		a.Equal(parent, root.Parent().SpanID().String())
+		a.Equal(root.SpanContext().SpanID(), query.Parent().SpanID())
+	}
 }
 
 func (s *HTTPServerSuite) TestUserLogin() {
@@ -138,43 +343,1942 @@ func (s *HTTPServerSuite) TestUserLogin() {
 	}
 }
 
-func (s *HTTPServerSuite) TestCreateUser() {
+func (s *HTTPServerSuite) TestLoginLockout() {
 
 	a := assert.New(s.T())
 
-	cases := []struct {
-		expected int
-		payload  *port.NewUserParams
-	}{
-		{
-			// success
-			expected: http.StatusCreated,
-			payload: &port.NewUserParams{
-				NewUserPayload: &port.NewUserPayload{
-					Username: "john.doe",
-					Email:    "john.doe@mailbox.com",
-					Password: "test123",
-				},
-			},
-		},
+	login := func(username, password, addr string, forwarded ...string) *httptest.ResponseRecorder {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: username, Password: password})
+		a.NoError(e)
+
+		rq, e := http.NewRequest(http.MethodPost, "/api/v1/user/login", bytes.NewReader(bb))
+		a.NoError(e)
+
+		rq.Header.Add("Content-Type", "application/json")
+		rq.RemoteAddr = addr
+
+		for _, f := range forwarded {
+			rq.Header.Add("X-Forwarded-For", f)
+		}
+
+		rr := httptest.NewRecorder()
+
+		s.mux.ServeHTTP(rr, rq)
+
+		return rr
 	}
 
-	for _, c := range cases {
+	audits := func(subject string) int {
 
-		bb, e := json.Marshal(c.payload)
+		var c int
+		a.NoError(s.db.QueryRow("SELECT COUNT(*) FROM audit_events WHERE event = 'login_locked' AND subject = ?", subject).Scan(&c))
+
+		return c
+	}
+
+	uid, _ := s.createUserAndLogin("lockout", "lockout@example.com", "Passw0rd!")
+
+	// unknown users are indistinguishable from wrong passwords
+	known := login("lockout", "wrong", "198.51.100.1:4000")
+	unknown := login("nobody", "wrong", "198.51.100.1:4000")
+	a.Equal(http.StatusUnauthorized, known.Code)
+	a.Equal(http.StatusUnauthorized, unknown.Code)
+	a.Equal(known.Body.String(), unknown.Body.String())
+
+	// username and email share failures
+	for i := 0; i < 3; i++ {
+		a.Equal(http.StatusUnauthorized, login("lockout@example.com", "wrong", "198.51.100.1:4000").Code)
+	}
+	a.Equal(0, audits("lockout"))
+
+	a.Equal(http.StatusUnauthorized, login("lockout", "wrong", "198.51.100.1:4000").Code)
+	a.Equal(1, audits("lockout"))
+
+	var au sql.NullString
+	a.NoError(s.db.QueryRow("SELECT user_uuid FROM audit_events WHERE subject = 'lockout'").Scan(&au))
+	a.Equal(uid, au.String)
+
+	// locked accounts reject the correct password from any ip
+	a.Equal(http.StatusTooManyRequests, login("lockout", "Passw0rd!", "198.51.100.1:4000").Code)
+	a.Equal(http.StatusTooManyRequests, login("lockout@example.com", "Passw0rd!", "198.51.100.2:4000").Code)
+
+	var lu time.Time
+	a.NoError(s.db.QueryRow("SELECT locked_until FROM login_throttles WHERE throttle_key = ?", "account:"+uid).Scan(&lu))
+	a.WithinDuration(time.Now().Add(time.Minute), lu, time.Second*10)
+
+	_, e := s.db.Exec("UPDATE login_throttles SET locked_until = ?", time.Now().UTC().Add(-time.Second))
+	a.NoError(e)
+
+	// further failures double the lockout
+	a.Equal(http.StatusUnauthorized, login("lockout", "wrong", "198.51.100.1:4000").Code)
+	a.NoError(s.db.QueryRow("SELECT locked_until FROM login_throttles WHERE throttle_key = ?", "account:"+uid).Scan(&lu))
+	a.WithinDuration(time.Now().Add(time.Minute*2), lu, time.Second*10)
+
+	_, e = s.db.Exec("UPDATE login_throttles SET locked_until = ?", time.Now().UTC().Add(-time.Second))
+	a.NoError(e)
+
+	// a successful login forgets account failures
+	a.Equal(http.StatusAccepted, login("lockout", "Passw0rd!", "198.51.100.1:4000").Code)
+
+	var c int
+	a.NoError(s.db.QueryRow("SELECT COUNT(*) FROM login_throttles WHERE throttle_key = ?", "account:"+uid).Scan(&c))
+	a.Equal(0, c)
+
+	// client ips are locked across accounts
+	_, e = s.db.Exec(
+		"INSERT INTO login_throttles (throttle_key, failures, updated_at) VALUES (?, ?, ?)",
+		"ip:203.0.113.9", 19, time.Now().UTC(),
+	)
+	a.NoError(e)
+
+	a.Equal(http.StatusUnauthorized, login("somebody", "wrong", "203.0.113.9:4000").Code)
+	a.Equal(1, audits("203.0.113.9"))
+
+	a.Equal(http.StatusTooManyRequests, login("lockout", "Passw0rd!", "203.0.113.9:4000").Code)
+	a.Equal(http.StatusAccepted, login("lockout", "Passw0rd!", "198.51.100.1:4000").Code)
+
+	// forwarded headers of untrusted peers can neither escape nor impose an ip lockout
+	a.Equal(http.StatusTooManyRequests, login("lockout", "Passw0rd!", "203.0.113.9:4000", "192.0.2.77").Code)
+	a.Equal(http.StatusAccepted, login("lockout", "Passw0rd!", "198.51.100.1:4000", "203.0.113.9").Code)
+
+	// trusted proxies forward the client address, spoofed hops before the proxy are ignored
+	s.T().Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 127.0.0.1")
+
+	s.mux, e = port.NewRouter(s.srv, s.auth, middleware.NewTracing(zap.NewNop()))
+	a.NoError(e)
+
+	a.Equal(http.StatusTooManyRequests, login("lockout", "Passw0rd!", "127.0.0.1:4000", "192.0.2.77, 203.0.113.9, 10.0.0.2").Code)
+	a.Equal(http.StatusAccepted, login("lockout", "Passw0rd!", "127.0.0.1:4000", "203.0.113.9, 198.51.100.1").Code)
+
+	s.T().Setenv("TRUSTED_PROXIES", "proxy")
+
+	_, e = port.NewRouter(s.srv, s.auth, middleware.NewTracing(zap.NewNop()))
+	a.Error(e)
+}
+
+func (s *HTTPServerSuite) TestSessions() {
+
+	a := assert.New(s.T())
+
+	login := func() *port.UserLoginResponse {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: "sessions", Password: "Passw0rd!"})
 		a.NoError(e)
 
-		rq, e := http.NewRequest(http.MethodPost, "/api/v1/user", bytes.NewReader(bb))
+		rr := s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		return lr
+	}
+
+	refresh := func(token string, expected int) *port.UserLoginResponse {
+
+		bb, e := json.Marshal(&port.RefreshTokenRequest{RefreshToken: token})
+		a.NoError(e)
+
+		rr := s.do(http.MethodPost, "/api/v1/user/refresh", "", bb)
+		a.Equal(expected, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		if rr.Code == http.StatusAccepted {
+			a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+		}
+
+		return lr
+	}
+
+	uid, _ := s.createUserAndLogin("sessions", "sessions@example.com", "Passw0rd!")
+
+	l1 := login()
+	a.NotEmpty(l1.RefreshToken)
+	a.WithinDuration(time.Now().Add(domain.AccessTokenTTL), l1.ExpiresAt, time.Minute)
+
+	// access tokens carry per token claims
+	c, e := s.auth.ParseToken(l1.Token)
+	if a.NoError(e) {
+		a.Equal(uid, c.Subject)
+		a.Equal(l1.SessionID, c.SessionID)
+		a.NotEmpty(c.ID)
+		a.Equal("go-chat", c.Issuer)
+	}
+
+	// refresh tokens rotate within the session
+	r1 := refresh(l1.RefreshToken, http.StatusAccepted)
+	a.Equal(l1.SessionID, r1.SessionID)
+	a.NotEqual(l1.RefreshToken, r1.RefreshToken)
+
+	c2, e := s.auth.ParseToken(r1.Token)
+	if a.NoError(e) {
+		a.NotEqual(c.ID, c2.ID)
+	}
+
+	refresh("unknown", http.StatusUnauthorized)
+
+	// replaying a used token revokes the session
+	refresh(l1.RefreshToken, http.StatusUnauthorized)
+	refresh(r1.RefreshToken, http.StatusUnauthorized)
+
+	l2 := login()
+
+	rr := s.do(http.MethodGet, "/api/v1/session/", l2.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	ls := &port.ListSessionsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(ls))
+
+	// replayed session is gone, the first login and the current session remain
+	if a.Len(ls.Sessions, 2) {
+		for _, ss := range ls.Sessions {
+			a.NotEqual(l1.SessionID, ss.UID)
+			a.Equal(ss.UID == l2.SessionID, ss.Current)
+		}
+	}
+
+	// replayed session was already revoked
+	rr = s.do(http.MethodDelete, "/api/v1/session/"+l1.SessionID, l2.Token, nil)
+	a.Equal(http.StatusNotFound, rr.Code)
+
+	rr = s.do(http.MethodDelete, "/api/v1/session/"+l2.SessionID, l2.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	// revoked session can no longer authenticate
+	rr = s.do(http.MethodDelete, "/api/v1/session/"+l2.SessionID, l2.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	refresh(l2.RefreshToken, http.StatusUnauthorized)
+
+	// tokens from another issuer are rejected
+	ac := middleware.NewAccessClaims(uid, uuid.NewString())
+	ac.Issuer = "someone-else"
+
+	st, e := s.keys.Sign(ac)
+	a.NoError(e)
+
+	rr = s.do(http.MethodGet, "/api/v1/session/", st, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+}
+
+func (s *HTTPServerSuite) TestLogout() {
+
+	a := assert.New(s.T())
+
+	login := func() *port.UserLoginResponse {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: "logout", Password: "Passw0rd!"})
+		a.NoError(e)
+
+		rr := s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		return lr
+	}
+
+	uid, _ := s.createUserAndLogin("logout", "logout@example.com", "Passw0rd!")
+
+	l1 := login()
+	l2 := login()
+
+	rr := s.do(http.MethodPost, "/api/v1/user/logout", l1.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	// access token is rejected before it expires
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l1.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	bb, e := json.Marshal(&port.RefreshTokenRequest{RefreshToken: l1.RefreshToken})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/refresh", "", bb)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	// other sessions are unaffected
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l2.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	// revoking a session from another device rejects its access tokens
+	l3 := login()
+
+	rr = s.do(http.MethodDelete, "/api/v1/session/"+l3.SessionID, l2.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l3.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/logout/all", l2.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l2.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	// revocations are persisted and loaded on startup
+	rl := domain.NewBundle(s.db, s.mail, nil).RevocationList
+	a.NoError(rl.Start(context.TODO()))
+	defer rl.Shutdown()
+
+	c, e := s.auth.ParseToken(l2.Token)
+	if a.NoError(e) {
+		a.True(rl.IsRevoked(c.ID, c.SessionID))
+	}
+
+	a.NoError(rl.Prune(context.TODO()))
+	a.True(rl.IsRevoked(c.ID, c.SessionID))
+}
+
+func (s *HTTPServerSuite) TestJWKS() {
+
+	a := assert.New(s.T())
+
+	jwks := func() map[string]*middleware.JWK {
+
+		rr := s.do(http.MethodGet, "/.well-known/jwks.json", "", nil)
+		a.Equal(http.StatusOK, rr.Code)
+		a.Equal("public, max-age=300", rr.Header().Get("Cache-Control"))
+
+		ks := &middleware.JWKS{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(ks))
+
+		km := make(map[string]*middleware.JWK, len(ks.Keys))
+		for _, k := range ks.Keys {
+			a.Equal("EC", k.Kty)
+			a.Equal("P-384", k.Crv)
+			a.Equal("ES384", k.Alg)
+			km[k.Kid] = k
+		}
+
+		return km
+	}
+
+	kid := func(token string) string {
+
+		tk, _, e := jwt.NewParser().ParseUnverified(token, &middleware.CustomClaims{})
+		a.NoError(e)
+
+		k, _ := tk.Header["kid"].(string)
+		return k
+	}
+
+	uid, t1 := s.createUserAndLogin("jwks", "jwks@example.com", "Passw0rd!")
+
+	// current and next key are published ahead of rotation
+	km := jwks()
+	a.Len(km, 2)
+	a.Contains(km, kid(t1))
+
+	a.NoError(s.keys.Rotate())
+
+	_, t2 := s.createUserAndLogin("jwks2", "jwks2@example.com", "Passw0rd!")
+	a.NotEqual(kid(t1), kid(t2))
+
+	// retired key remains published for the grace period
+	km = jwks()
+	a.Len(km, 3)
+	a.Contains(km, kid(t1))
+	a.Contains(km, kid(t2))
+
+	// tokens signed before rotation are still accepted
+	rr := s.do(http.MethodGet, "/api/v1/user/"+uid, t1, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	// tokens signed with an unpublished key are rejected
+	k, e := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	a.NoError(e)
+
+	other, e := middleware.NewKeyManager(k)
+	a.NoError(e)
+
+	st, e := other.Sign(middleware.NewAccessClaims(uid, uuid.NewString()))
+	a.NoError(e)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, st, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	// instances sharing the configured key without rotation publish the same key set
+	s.T().Setenv("JWT_KEY_ROTATION_INTERVAL", "0")
+
+	r1, e := middleware.NewKeyManager(k)
+	a.NoError(e)
+
+	r2, e := middleware.NewKeyManager(k)
+	a.NoError(e)
+
+	a.Len(r1.JWKS().Keys, 1)
+	a.Equal(r1.JWKS(), r2.JWKS())
+
+	st, e = r1.Sign(middleware.NewAccessClaims(uid, uuid.NewString()))
+	a.NoError(e)
+
+	_, ok := r2.PublicKey(kid(st))
+	a.True(ok)
+}
+
+func (s *HTTPServerSuite) TestPassword() {
+
+	a := assert.New(s.T())
+
+	login := func(password string, code int) *port.UserLoginResponse {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: "password", Password: password})
+		a.NoError(e)
+
+		rr := s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+		a.Equal(code, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		if code == http.StatusAccepted {
+			a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+		}
+
+		return lr
+	}
+
+	change := func(token, current, password string) int {
+
+		bb, e := json.Marshal(&port.ChangePasswordRequest{CurrentPassword: current, NewPassword: password})
+		a.NoError(e)
+
+		return s.do(http.MethodPut, "/api/v1/user/password", token, bb).Code
+	}
+
+	uid, _ := s.createUserAndLogin("password", "Password@example.com", "Passw0rd!")
+
+	l1 := login("Passw0rd!", http.StatusAccepted)
+	l2 := login("Passw0rd!", http.StatusAccepted)
+
+	a.Equal(http.StatusUnauthorized, change("", "Passw0rd!", "Changed0rd!"))
+	a.Equal(http.StatusForbidden, change(l1.Token, "wrong", "Changed0rd!"))
+	a.Equal(http.StatusBadRequest, change(l1.Token, "Passw0rd!", "short"))
+	a.Equal(http.StatusAccepted, change(l1.Token, "Passw0rd!", "Changed0rd!"))
+
+	login("Passw0rd!", http.StatusUnauthorized)
+	login("Changed0rd!", http.StatusAccepted)
+
+	// other sessions are ended, the current one stays signed in
+	rr := s.do(http.MethodGet, "/api/v1/user/"+uid, l1.Token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l2.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	forgotCode := func(email string) int {
+
+		bb, e := json.Marshal(&port.ForgotPasswordRequest{Email: email})
+		a.NoError(e)
+
+		rq, e := http.NewRequest(http.MethodPost, "/api/v1/user/password/forgot", bytes.NewReader(bb))
 		a.NoError(e)
 
 		rq.Header.Add("Content-Type", "application/json")
+		rq.RemoteAddr = "198.51.100.1:4000"
 
 		rr := httptest.NewRecorder()
 
 		s.mux.ServeHTTP(rr, rq)
 
+		return rr.Code
+	}
+
+	forgot := func(email string) {
+		a.Equal(http.StatusAccepted, forgotCode(email))
+	}
+
+	reset := func(token, password string) int {
+
+		bb, e := json.Marshal(&port.ResetPasswordRequest{Token: token, NewPassword: password})
+		a.NoError(e)
+
+		return s.do(http.MethodPost, "/api/v1/user/password/reset", "", bb).Code
+	}
+
+	// unknown emails are accepted without sending mail
+	forgot("nobody@example.com")
+	a.Equal(0, s.mail.count(resetSubject))
+
+	forgot("password@example.com")
+	forgot("password@example.com")
+	a.True(s.mail.await(resetSubject, 2))
+	a.Equal(2, s.mail.count(resetSubject))
+
+	m := s.mail.last(resetSubject)
+	a.Equal("Password@example.com", m.To)
+
+	t := mailToken(m)
+	a.NotEmpty(t)
+
+	a.Equal(http.StatusBadRequest, reset("unknown", "Reset0rd!"))
+	a.Equal(http.StatusBadRequest, reset(t, "short"))
+
+	// a reset ends an account lockout
+	_, e := s.db.Exec(
+		"INSERT INTO login_throttles (throttle_key, failures, locked_until, updated_at) VALUES (?, ?, ?, ?)",
+		"account:"+l1.UserID, 5, time.Now().UTC().Add(time.Hour), time.Now().UTC(),
+	)
+	a.NoError(e)
+
+	login("Changed0rd!", http.StatusTooManyRequests)
+	a.Equal(http.StatusAccepted, reset(t, "Reset0rd!"))
+
+	// tokens are single use and earlier tokens are used up
+	a.Equal(http.StatusBadRequest, reset(t, "Reset0rd!"))
+	a.Equal(http.StatusBadRequest, reset(mailToken(s.mail.messages(resetSubject)[0]), "Reset0rd!"))
+
+	login("Changed0rd!", http.StatusUnauthorized)
+	login("Reset0rd!", http.StatusAccepted)
+
+	// every session is ended on reset
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, l1.Token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	// expired tokens are rejected
+	forgot("password@example.com")
+	a.True(s.mail.await(resetSubject, 3))
+
+	_, e = s.db.Exec("UPDATE password_resets SET expires_at = ? WHERE used_at IS NULL", time.Now().Add(-time.Minute))
+	a.NoError(e)
+
+	a.Equal(http.StatusBadRequest, reset(mailToken(s.mail.last(resetSubject)), "Expired0rd!"))
+
+	// known and unknown emails are throttled alike
+	a.Equal(http.StatusTooManyRequests, forgotCode("Password@example.com"))
+
+	forgot("nobody@example.com")
+	forgot("nobody@example.com")
+	a.Equal(http.StatusTooManyRequests, forgotCode("nobody@example.com"))
+
+	// requests from a client ip are throttled across emails
+	for i := 0; i < 14; i++ {
+		forgot(fmt.Sprintf("nobody%d@example.com", i))
+	}
+	a.Equal(http.StatusTooManyRequests, forgotCode("somebody@example.com"))
+
+	_, e = s.db.Exec("DELETE FROM password_reset_requests")
+	a.NoError(e)
+
+	// mail failures are not reported
+	s.mail.setErr(errors.New("mail server unavailable"))
+	defer s.mail.setErr(nil)
+
+	forgot("password@example.com")
+	forgot("nobody@example.com")
+	a.Equal(3, s.mail.count(resetSubject))
+}
+
+func (s *HTTPServerSuite) TestEmailVerification() {
+
+	a := assert.New(s.T())
+
+	verified := func(uid, token string) bool {
+
+		rr := s.do(http.MethodGet, "/api/v1/user/"+uid, token, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		fr := &port.FetchUserResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(fr))
+
+		return fr.User != nil && fr.User.EmailVerified
+	}
+
+	verify := func(token string) int {
+
+		bb, e := json.Marshal(&port.VerifyEmailRequest{Token: token})
+		a.NoError(e)
+
+		return s.do(http.MethodPost, "/api/v1/user/verify", "", bb).Code
+	}
+
+	s.bundle.VerificationService.Restrict(domain.ActionCreateConversation)
+
+	oID, oToken := s.createUserAndLogin("verify", "verify@example.com", "Passw0rd!")
+	rID, _ := s.createUserAndLogin("verify2", "verify2@example.com", "Passw0rd!")
+
+	// new accounts are unverified and emailed a token
+	a.False(verified(oID, oToken))
+	a.Equal(2, s.mail.count(verifySubject))
+
+	m := s.mail.messages(verifySubject)[0]
+	a.Equal("verify@example.com", m.To)
+
+	cb, e := json.Marshal(map[string]interface{}{
+		"new_conversation": map[string]interface{}{
+			"Recipients": []string{oID, rID},
+		},
+	})
+	a.NoError(e)
+
+	rr := s.do(http.MethodPost, "/api/v1/conversation", oToken, cb)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	// resend is throttled
+	rr = s.do(http.MethodPost, "/api/v1/user/verify/resend", oToken, nil)
+	a.Equal(http.StatusTooManyRequests, rr.Code)
+
+	_, e = s.db.Exec("UPDATE email_verifications SET created_at = ?", time.Now().UTC().Add(-time.Hour*2))
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/verify/resend", oToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+	a.Equal(3, s.mail.count(verifySubject))
+
+	a.Equal(http.StatusBadRequest, verify("unknown"))
+	a.Equal(http.StatusAccepted, verify(mailToken(s.mail.last(verifySubject))))
+
+	// tokens are single use and earlier tokens are used up
+	a.Equal(http.StatusBadRequest, verify(mailToken(s.mail.last(verifySubject))))
+	a.Equal(http.StatusBadRequest, verify(mailToken(m)))
+
+	a.True(verified(oID, oToken))
+
+	rr = s.do(http.MethodPost, "/api/v1/user/verify/resend", oToken, nil)
+	a.Equal(http.StatusConflict, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", oToken, cb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	// a changed email must be verified again
+	bb, e := json.Marshal(&port.UpdateUserParams{
+		UpdateUserPayload: &port.UpdateUserPayload{
+			UID:      oID,
+			Username: "verify",
+			Email:    "changed@example.com",
+		},
+	})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPut, "/api/v1/user", oToken, bb)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	a.False(verified(oID, oToken))
+
+	m = s.mail.last(verifySubject)
+	a.Equal("changed@example.com", m.To)
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", oToken, cb)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	// unrestricted actions are permitted
+	s.bundle.VerificationService.Restrict()
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", oToken, cb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	a.Equal(http.StatusAccepted, verify(mailToken(m)))
+	a.True(verified(oID, oToken))
+}
+
+func (s *HTTPServerSuite) TestTwoFactor() {
+
+	a := assert.New(s.T())
+
+	login := func() *port.UserLoginResponse {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: "twofactor", Password: "Passw0rd!"})
+		a.NoError(e)
+
+		rr := s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		return lr
+	}
+
+	answer := func(challenge, code string) int {
+
+		bb, e := json.Marshal(&port.LoginChallengeRequest{ChallengeToken: challenge, Code: code})
+		a.NoError(e)
+
+		return s.do(http.MethodPost, "/api/v1/user/login/2fa", "", bb).Code
+	}
+
+	withCode := func(path, token, code string) *httptest.ResponseRecorder {
+
+		bb, e := json.Marshal(&port.TwoFactorCodeRequest{Code: code})
+		a.NoError(e)
+
+		return s.do(http.MethodPost, path, token, bb)
+	}
+
+	_, token := s.createUserAndLogin("twofactor", "twofactor@example.com", "Passw0rd!")
+
+	rr := withCode("/api/v1/user/2fa/confirm", token, "123456")
+	a.Equal(http.StatusConflict, rr.Code)
+
+	// enrolling again before confirming replaces the secret
+	rr = s.do(http.MethodPost, "/api/v1/user/2fa", token, nil)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/2fa", token, nil)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	en := &port.TwoFactorEnrollmentResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(en))
+	a.Contains(en.OtpauthURI, "secret="+en.Secret)
+
+	// not enabled until confirmed
+	a.False(login().TwoFactorRequired)
+
+	rr = withCode("/api/v1/user/2fa/confirm", token, "abcdef")
+	a.Equal(http.StatusBadRequest, rr.Code)
+
+	now := time.Now()
+
+	rr = withCode("/api/v1/user/2fa/confirm", token, totpAt(en.Secret, now))
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rc := &port.RecoveryCodesResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(rc))
+	if !a.Len(rc.RecoveryCodes, 10) {
+		return
+	}
+
+	rr = s.do(http.MethodPost, "/api/v1/user/2fa", token, nil)
+	a.Equal(http.StatusConflict, rr.Code)
+
+	// password alone no longer issues tokens
+	lr := login()
+	a.True(lr.TwoFactorRequired)
+	a.Empty(lr.Token)
+	a.Empty(lr.RefreshToken)
+	a.NotEmpty(lr.ChallengeToken)
+
+	a.Equal(http.StatusUnauthorized, answer("unknown", totpAt(en.Secret, now)))
+
+	// codes are single use
+	a.Equal(http.StatusUnauthorized, answer(lr.ChallengeToken, totpAt(en.Secret, now)))
+	a.Equal(http.StatusAccepted, answer(lr.ChallengeToken, totpAt(en.Secret, now.Add(time.Second*30))))
+	a.Equal(http.StatusUnauthorized, answer(lr.ChallengeToken, rc.RecoveryCodes[0]))
+
+	// recovery codes ignore case and separators
+	lr = login()
+	a.Equal(http.StatusAccepted, answer(lr.ChallengeToken, strings.ToUpper(rc.RecoveryCodes[0])))
+
+	lr = login()
+	a.Equal(http.StatusUnauthorized, answer(lr.ChallengeToken, rc.RecoveryCodes[0]))
+
+	// challenge is used up after too many attempts
+	for i := 0; i < 4; i++ {
+		a.Equal(http.StatusUnauthorized, answer(lr.ChallengeToken, "wrong"))
+	}
+	a.Equal(http.StatusUnauthorized, answer(lr.ChallengeToken, rc.RecoveryCodes[1]))
+
+	lr = login()
+	a.Equal(http.StatusAccepted, answer(lr.ChallengeToken, rc.RecoveryCodes[1]))
+
+	rr = withCode("/api/v1/user/2fa/disable", token, "wrong")
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = withCode("/api/v1/user/2fa/disable", token, rc.RecoveryCodes[2])
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = withCode("/api/v1/user/2fa/disable", token, rc.RecoveryCodes[3])
+	a.Equal(http.StatusConflict, rr.Code)
+
+	lr = login()
+	a.False(lr.TwoFactorRequired)
+	a.NotEmpty(lr.Token)
+}
+
+func (s *HTTPServerSuite) TestOIDC() {
+
+	a := assert.New(s.T())
+
+	nc := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// authorize follow authorization url at the issuer and return the callback path
+	authorize := func(u string) string {
+
+		rsp, e := nc.Get(u)
+		if !a.NoError(e) {
+			return ""
+		}
+		_ = rsp.Body.Close()
+		a.Equal(http.StatusFound, rsp.StatusCode)
+
+		cb, e := url.Parse(rsp.Header.Get("Location"))
+		a.NoError(e)
+
+		return cb.Path + "?" + cb.RawQuery
+	}
+
+	login := func() string {
+
+		rr := s.do(http.MethodGet, "/api/v1/oidc/login", "", nil)
+		a.Equal(http.StatusFound, rr.Code)
+
+		return authorize(rr.Header().Get("Location"))
+	}
+
+	link := func(token string) string {
+
+		rr := s.do(http.MethodPost, "/api/v1/user/oidc/link", token, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		ar := &port.AuthorizationResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(ar))
+
+		return authorize(ar.AuthorizationURL)
+	}
+
+	signedIn := func(rr *httptest.ResponseRecorder) string {
+
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.UserLoginResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+		a.NotEmpty(lr.Token)
+
+		return lr.UserID
+	}
+
+	rr := s.do(http.MethodGet, "/api/v1/oidc/login", "", nil)
+	a.Equal(http.StatusFound, rr.Code)
+
+	au, e := url.Parse(rr.Header().Get("Location"))
+	if a.NoError(e) {
+		a.Equal(s.idp.URL+"/authorize", au.Scheme+"://"+au.Host+au.Path)
+		a.Equal("S256", au.Query().Get("code_challenge_method"))
+		a.Equal("go-chat", au.Query().Get("client_id"))
+	}
+
+	s.issuer.SetUser(oidctest.User{
+		Subject:           "alice-sub",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "Alice",
+	})
+
+	// unknown identities are rejected until provisioning is allowed
+	cb := login()
+	a.Equal(http.StatusForbidden, s.do(http.MethodGet, cb, "", nil).Code)
+
+	// callbacks are single use
+	a.Equal(http.StatusUnauthorized, s.do(http.MethodGet, cb, "", nil).Code)
+
+	s.bundle.IdentityService.AllowProvisioning(true)
+
+	cb = login()
+	aID := signedIn(s.do(http.MethodGet, cb, "", nil))
+	a.Equal(aID, signedIn(s.do(http.MethodGet, login(), "", nil)))
+
+	u, e := s.bundle.UserService.Read(context.TODO(), uuid.MustParse(aID))
+	if a.NoError(e) {
+		a.Equal("alice", u.Username)
+		a.Equal("alice@example.com", u.Email)
+		a.True(u.EmailVerified)
+	}
+
+	// provisioned users have no password
+	bb, e := json.Marshal(&port.UserLoginRequest{Username: "alice", Password: "Passw0rd!"})
+	a.NoError(e)
+	a.Equal(http.StatusUnauthorized, s.do(http.MethodPost, "/api/v1/user/login", "", bb).Code)
+
+	// codes are bound to the pkce verifier and state of their login
+	q, e := url.ParseQuery(login()[len("/api/v1/oidc/callback?"):])
+	a.NoError(e)
+	q2, e := url.ParseQuery(login()[len("/api/v1/oidc/callback?"):])
+	a.NoError(e)
+
+	q.Set("code", q2.Get("code"))
+	a.Equal(http.StatusUnauthorized, s.do(http.MethodGet, "/api/v1/oidc/callback?"+q.Encode(), "", nil).Code)
+
+	a.Equal(http.StatusUnauthorized, s.do(http.MethodGet, "/api/v1/oidc/callback?error=access_denied&state=x", "", nil).Code)
+
+	// emails of existing users are never linked automatically
+	bID, bToken := s.createUserAndLogin("bob", "bob@example.com", "Passw0rd!")
+
+	s.issuer.SetUser(oidctest.User{
+		Subject:           "bob-sub",
+		Email:             "bob@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "bob",
+	})
+
+	a.Equal(http.StatusForbidden, s.do(http.MethodGet, login(), "", nil).Code)
+
+	rr = s.do(http.MethodGet, link(bToken), "", nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	a.Equal(bID, signedIn(s.do(http.MethodGet, login(), "", nil)))
+
+	// identities are linked to a single user
+	_, cToken := s.createUserAndLogin("carol", "carol@example.com", "Passw0rd!")
+	a.Equal(http.StatusConflict, s.do(http.MethodGet, link(cToken), "", nil).Code)
+
+	// taken usernames are suffixed
+	s.issuer.SetUser(oidctest.User{
+		Subject:           "other-bob-sub",
+		Email:             "other.bob@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "bob",
+	})
+
+	oID := signedIn(s.do(http.MethodGet, login(), "", nil))
+	a.NotEqual(bID, oID)
+
+	u, e = s.bundle.UserService.Read(context.TODO(), uuid.MustParse(oID))
+	if a.NoError(e) {
+		a.Regexp(`^bob-[0-9a-f]{4}$`, u.Username)
+	}
+
+	// unverified emails are not provisioned
+	s.issuer.SetUser(oidctest.User{
+		Subject: "unverified-sub",
+		Email:   "unverified@example.com",
+	})
+
+	a.Equal(http.StatusForbidden, s.do(http.MethodGet, login(), "", nil).Code)
+}
+
+func (s *HTTPServerSuite) TestAPIKeys() {
+
+	a := assert.New(s.T())
+
+	uid, token := s.createUserAndLogin("automation", "automation@example.com", "Passw0rd!")
+
+	create := func(path, token string, nk *port.NewAPIKeyRequest) (*httptest.ResponseRecorder, *port.APIKeyPayload) {
+
+		bb, e := json.Marshal(nk)
+		a.NoError(e)
+
+		rr := s.do(http.MethodPost, path, token, bb)
+		if rr.Code != http.StatusCreated {
+			return rr, nil
+		}
+
+		kr := &port.NewAPIKeyResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(kr))
+
+		return rr, kr.APIKey
+	}
+
+	list := func(path string) []*port.APIKeyPayload {
+
+		rr := s.do(http.MethodGet, path, token, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.ListAPIKeysResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		return lr.APIKeys
+	}
+
+	// name, known scopes and a future expiry are required
+	rr, _ := create("/api/v1/user/key", token, &port.NewAPIKeyRequest{Name: "ci", Scopes: []string{"admin"}})
+	a.Equal(http.StatusBadRequest, rr.Code)
+
+	past := time.Now().Add(-time.Hour)
+	rr, _ = create("/api/v1/user/key", token, &port.NewAPIKeyRequest{Name: "ci", Scopes: []string{"read"}, ExpiresAt: &past})
+	a.Equal(http.StatusBadRequest, rr.Code)
+
+	rr, _ = create("/api/v1/user/key", token, &port.NewAPIKeyRequest{Scopes: []string{"read"}})
+	a.Equal(http.StatusBadRequest, rr.Code)
+
+	rr, rk := create("/api/v1/user/key", token, &port.NewAPIKeyRequest{Name: "ci", Scopes: []string{"read"}})
+	a.Equal(http.StatusCreated, rr.Code)
+	if !a.NotNil(rk) {
+		return
+	}
+
+	a.True(strings.HasPrefix(rk.Key, domain.APIKeyPrefix))
+	a.Equal(rk.Key[:len(rk.Prefix)], rk.Prefix)
+	a.Equal(uid, rk.User)
+	a.Equal([]string{"read"}, rk.Scopes)
+	a.Nil(rk.ExpiresAt)
+	a.Nil(rk.LastUsedAt)
+
+	// read scope serves safe methods only
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, rk.Key, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/conversation/", rk.Key, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	cb := func(recipients ...string) []byte {
+
+		bb, e := json.Marshal(map[string]interface{}{
+			"new_conversation": map[string]interface{}{
+				"Recipients": recipients,
+			},
+		})
+		a.NoError(e)
+
+		return bb
+	}
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", rk.Key, cb(uid, uuid.NewString()))
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	// account, sessions and keys are never managed with a key
+	rr = s.do(http.MethodGet, "/api/v1/session/", rk.Key, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/key/", rk.Key, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/logout", rk.Key, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	// listings never contain the key and record its last use
+	kl := list("/api/v1/user/key/")
+	if a.Len(kl, 1) {
+		a.Equal(rk.UID, kl[0].UID)
+		a.Empty(kl[0].Key)
+		a.NotNil(kl[0].LastUsedAt)
+	}
+
+	// unknown, expired and revoked keys are rejected
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, domain.APIKeyPrefix+"unknown", nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	_, ek := create("/api/v1/user/key", token, &port.NewAPIKeyRequest{Name: "expiring", Scopes: []string{"read"}})
+	if a.NotNil(ek) {
+
+		_, e := s.db.Exec("UPDATE api_keys SET expires_at = ? WHERE uuid = ?", past.UTC(), ek.UID)
+		a.NoError(e)
+
+		rr = s.do(http.MethodGet, "/api/v1/user/"+uid, ek.Key, nil)
+		a.Equal(http.StatusUnauthorized, rr.Code)
+	}
+
+	rr = s.do(http.MethodDelete, "/api/v1/user/key/"+rk.UID, token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+uid, rk.Key, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	rr = s.do(http.MethodDelete, "/api/v1/user/key/"+rk.UID, token, nil)
+	a.Equal(http.StatusNotFound, rr.Code)
+
+	a.Len(list("/api/v1/user/key/"), 1)
+
+	// bots are owned by a user and authenticate with keys created by the owner
+	bb, e := json.Marshal(&port.NewBotRequest{Username: "automation-bot"})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/bot", token, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	br := &port.BotResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(br))
+	a.Equal("bot", br.Bot.Type)
+	a.Equal(uid, br.Bot.Owner)
+
+	rr = s.do(http.MethodPost, "/api/v1/bot", token, bb)
+	a.Equal(http.StatusConflict, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/bot/", token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr := &port.ListBotsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+	if a.Len(lr.Bots, 1) {
+		a.Equal(br.Bot.UID, lr.Bots[0].UID)
+	}
+
+	rr, bk := create("/api/v1/bot/"+br.Bot.UID+"/key", token, &port.NewAPIKeyRequest{Name: "deploy", Scopes: []string{"read", "write"}})
+	a.Equal(http.StatusCreated, rr.Code)
+	if !a.NotNil(bk) {
+		return
+	}
+
+	a.Equal(br.Bot.UID, bk.User)
+	a.Equal([]string{"read", "write"}, bk.Scopes)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+br.Bot.UID, bk.Key, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	fr := &port.FetchUserResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(fr))
+	a.Equal("bot", fr.User.Type)
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", bk.Key, cb(br.Bot.UID, uid))
+	a.Equal(http.StatusCreated, rr.Code)
+
+	a.Len(list("/api/v1/bot/"+br.Bot.UID+"/key/"), 1)
+
+	// bots never log in with a password and other users may not manage their keys
+	bb, e = json.Marshal(&port.UserLoginRequest{Username: "automation-bot", Password: "Passw0rd!"})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	_, other := s.createUserAndLogin("bystander", "bystander@example.com", "Passw0rd!")
+
+	rr, _ = create("/api/v1/bot/"+br.Bot.UID+"/key", other, &port.NewAPIKeyRequest{Name: "steal", Scopes: []string{"write"}})
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodDelete, "/api/v1/bot/"+br.Bot.UID+"/key/"+bk.UID, other, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodDelete, "/api/v1/bot/"+br.Bot.UID+"/key/"+bk.UID, token, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+br.Bot.UID, bk.Key, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+}
+
+func (s *HTTPServerSuite) TestAdmin() {
+
+	a := assert.New(s.T())
+
+	ctx := context.TODO()
+
+	adminID, admin := s.createUserAndLogin("root", "root@example.com", "Passw0rd!")
+	modID, moderator := s.createUserAndLogin("moderator", "moderator@example.com", "Passw0rd!")
+	userID, token := s.createUserAndLogin("member", "member@example.com", "Passw0rd!")
+
+	login := func(username string) *httptest.ResponseRecorder {
+
+		bb, e := json.Marshal(&port.UserLoginRequest{Username: username, Password: "Passw0rd!"})
+		a.NoError(e)
+
+		return s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+	}
+
+	setRole := func(token, user, role string) int {
+
+		bb, e := json.Marshal(&port.SetUserRoleRequest{Role: role})
+		a.NoError(e)
+
+		return s.do(http.MethodPut, "/api/v1/admin/user/"+user+"/role", token, bb).Code
+	}
+
+	listUsers := func(token, query string) (int, *port.ListUsersResponse) {
+
+		rr := s.do(http.MethodGet, "/api/v1/admin/user/"+query, token, nil)
+		if rr.Code != http.StatusAccepted {
+			return rr.Code, nil
+		}
+
+		lr := &port.ListUsersResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		return rr.Code, lr
+	}
+
+	// every user starts with the user role and no administrative permissions
+	code, _ := listUsers(admin, "")
+	a.Equal(http.StatusForbidden, code)
+
+	rr := s.do(http.MethodPost, "/api/v1/admin/user/"+userID+"/logout", token, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	a.Equal(domain.ErrResourceNotFound, s.bundle.AdminService.Promote(ctx, "unknown"))
+	a.NoError(s.bundle.AdminService.Promote(ctx, "root"))
+
+	// only the first administrator is bootstrapped
+	a.Equal(domain.ErrAdminExists, s.bundle.AdminService.Promote(ctx, "member"))
+
+	// admins list every user ordered by username and filtered by role
+	code, lr := listUsers(admin, "?limit=2")
+	a.Equal(http.StatusAccepted, code)
+	if a.NotNil(lr) && a.Len(lr.Users, 2) {
+		a.Equal("member", lr.Users[0].Username)
+		a.Equal("moderator", lr.Users[1].Username)
+		a.Equal("user", lr.Users[0].Role)
+		a.NotEmpty(lr.NextCursor)
+
+		code, lr = listUsers(admin, "?limit=2&cursor="+lr.NextCursor)
+		if a.Equal(http.StatusAccepted, code) && a.Len(lr.Users, 1) {
+			a.Equal(adminID, lr.Users[0].UID)
+			a.Equal("admin", lr.Users[0].Role)
+			a.Empty(lr.NextCursor)
+		}
+	}
+
+	code, _ = listUsers(admin, "?role=owner")
+	a.Equal(http.StatusBadRequest, code)
+
+	code, _ = listUsers(admin, "?cursor=!")
+	a.Equal(http.StatusBadRequest, code)
+
+	a.Equal(http.StatusBadRequest, setRole(admin, modID, "owner"))
+	a.Equal(http.StatusNotFound, setRole(admin, uuid.NewString(), "moderator"))
+	a.Equal(http.StatusForbidden, setRole(admin, adminID, "user"))
+	a.Equal(http.StatusAccepted, setRole(admin, modID, "moderator"))
+
+	code, lr = listUsers(admin, "?role=moderator")
+	if a.Equal(http.StatusAccepted, code) && a.Len(lr.Users, 1) {
+		a.Equal(modID, lr.Users[0].UID)
+	}
+
+	// moderators list users and end sessions but do not change roles or disable accounts
+	code, _ = listUsers(moderator, "")
+	a.Equal(http.StatusAccepted, code)
+
+	a.Equal(http.StatusForbidden, setRole(moderator, userID, "moderator"))
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+userID+"/disable", moderator, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+adminID+"/logout", moderator, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/admin/user/"+userID+"/session/", moderator, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	ls := &port.ListSessionsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(ls))
+	if a.Len(ls.Sessions, 1) {
+
+		rr = s.do(http.MethodDelete, "/api/v1/admin/user/"+userID+"/session/"+uuid.NewString(), moderator, nil)
+		a.Equal(http.StatusNotFound, rr.Code)
+
+		rr = s.do(http.MethodDelete, "/api/v1/admin/user/"+userID+"/session/"+ls.Sessions[0].UID, moderator, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		rr = s.do(http.MethodGet, "/api/v1/user/"+userID, token, nil)
+		a.Equal(http.StatusUnauthorized, rr.Code)
+	}
+
+	// force logout ends every session of the user
+	a.Equal(http.StatusAccepted, login("member").Code)
+	a.Equal(http.StatusAccepted, login("member").Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+userID+"/logout", moderator, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/admin/user/"+userID+"/session/", moderator, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	ls = &port.ListSessionsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(ls))
+	a.Empty(ls.Sessions)
+
+	// disabled users are logged out and can not sign in or use their api keys
+	rr = login("member")
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	ur := &port.UserLoginResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(ur))
+	token = ur.Token
+
+	bb, e := json.Marshal(&port.NewAPIKeyRequest{Name: "ci", Scopes: []string{"read"}})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/key", token, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	kr := &port.NewAPIKeyResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(kr))
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+userID+"/disable", admin, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	dr := &port.AdminUserResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(dr))
+	a.True(dr.User.Disabled)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+userID, token, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+userID, kr.APIKey.Key, nil)
+	a.Equal(http.StatusUnauthorized, rr.Code)
+
+	a.Equal(http.StatusForbidden, login("member").Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+userID+"/enable", admin, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	a.Equal(http.StatusAccepted, login("member").Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/user/"+userID, kr.APIKey.Key, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	// users with the same role can not act on each other
+	peerID, _ := s.createUserAndLogin("peer", "peer@example.com", "Passw0rd!")
+
+	_, e = s.db.Exec("UPDATE users SET role = 'admin' WHERE uuid = ?", peerID)
+	a.NoError(e)
+
+	a.Equal(http.StatusForbidden, setRole(admin, peerID, "user"))
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+peerID+"/disable", admin, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+peerID+"/logout", admin, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	_, e = s.db.Exec("UPDATE users SET role = 'moderator' WHERE uuid = ?", peerID)
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+peerID+"/logout", moderator, nil)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	// disabled administrators lose their permissions
+	rr = s.do(http.MethodPost, "/api/v1/admin/user/"+modID+"/disable", admin, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	code, _ = listUsers(moderator, "")
+	a.Equal(http.StatusUnauthorized, code)
+
+	// api keys never carry administrative permissions
+	rr = s.do(http.MethodPost, "/api/v1/user/key", admin, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	kr = &port.NewAPIKeyResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(kr))
+
+	code, _ = listUsers(kr.APIKey.Key, "")
+	a.Equal(http.StatusForbidden, code)
+}
+
+func (s *HTTPServerSuite) TestCreateUser() {
+
+	a := assert.New(s.T())
+
+	cases := []struct {
+		expected int
+		payload  *port.NewUserParams
+	}{
+		{
+			// success
+			expected: http.StatusCreated,
+			payload: &port.NewUserParams{
+				NewUserPayload: &port.NewUserPayload{
+					Username: "john.doe",
+					Email:    "john.doe@mailbox.com",
+					Password: "test123",
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+
+		bb, e := json.Marshal(c.payload)
+		a.NoError(e)
+
+		rq, e := http.NewRequest(http.MethodPost, "/api/v1/user", bytes.NewReader(bb))
+		a.NoError(e)
+
+		rq.Header.Add("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+
+		s.mux.ServeHTTP(rr, rq)
+
+		a.Equal(c.expected, rr.Code)
+	}
+}
+
+func (s *HTTPServerSuite) TestConversationInvite() {
+
+	a := assert.New(s.T())
+
+	oID, oToken := s.createUserAndLogin("jane.doe", "jane.doe@mailbox.com", "test123")
+	rID, _ := s.createUserAndLogin("john.doe", "john.doe@mailbox.com", "test123")
+	_, gToken := s.createUserAndLogin("jim.doe", "jim.doe@mailbox.com", "test123")
+
+	bb, e := json.Marshal(map[string]interface{}{
+		"new_conversation": map[string]interface{}{
+			"Recipients": []string{oID, rID},
+		},
+	})
+	a.NoError(e)
+
+	rr := s.do(http.MethodPost, "/api/v1/conversation", oToken, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	var ccr struct {
+		Conversation struct {
+			UID string `json:"UID"`
+		} `json:"conversation"`
+	}
+	a.NoError(json.NewDecoder(rr.Body).Decode(&ccr))
+
+	bb, e = json.Marshal(&port.NewInviteParams{
+		NewInvitePayload: &port.NewInvitePayload{MaxUses: 1, ExpiresIn: 3600},
+	})
+	a.NoError(e)
+
+	// non member is not permitted to create invites
+	rr = s.do(http.MethodPost, "/api/v1/conversation/"+ccr.Conversation.UID+"/invite", gToken, bb)
+	a.Equal(http.StatusForbidden, rr.Code)
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation/"+ccr.Conversation.UID+"/invite", oToken, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	ir := &port.NewInviteResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(ir))
+	a.NotEmpty(ir.Token)
+
+	rr = s.do(http.MethodGet, "/api/v1/invite/"+ir.Token, "", nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	pr := &port.InvitePreviewResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(pr))
+	a.Equal(int64(2), pr.Members)
+
+	cases := []struct {
+		expected int
+		token    string
+	}{
+		{
+			// success
+			expected: http.StatusAccepted,
+			token:    gToken,
+		},
+		{
+			// already a member does not consume use
+			expected: http.StatusAccepted,
+			token:    gToken,
+		},
+	}
+
+	for _, c := range cases {
+		rr = s.do(http.MethodPost, "/api/v1/invite/"+ir.Token+"/redeem", c.token, nil)
+		a.Equal(c.expected, rr.Code)
+	}
+
+	// max uses exhausted
+	rr = s.do(http.MethodGet, "/api/v1/invite/"+ir.Token, "", nil)
+	a.Equal(http.StatusGone, rr.Code)
+
+	rr = s.do(http.MethodGet, "/api/v1/conversation/"+ccr.Conversation.UID+"/invite/"+ir.UID+"/redemptions", oToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr := &port.ListInviteRedemptionsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+	a.Len(lr.Redemptions, 1)
+
+	// admins of another conversation can not audit invites of this conversation
+	xID, xToken := s.createUserAndLogin("joe.doe", "joe.doe@mailbox.com", "test123")
+
+	bb, e = json.Marshal(map[string]interface{}{
+		"new_conversation": map[string]interface{}{
+			"Recipients": []string{xID, rID},
+		},
+	})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/conversation", xToken, bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	var xcr struct {
+		Conversation struct {
+			UID string `json:"UID"`
+		} `json:"conversation"`
+	}
+	a.NoError(json.NewDecoder(rr.Body).Decode(&xcr))
+
+	rr = s.do(http.MethodGet, "/api/v1/conversation/"+xcr.Conversation.UID+"/invite/"+ir.UID+"/redemptions", xToken, nil)
+	a.Equal(http.StatusNotFound, rr.Code)
+
+	rr = s.do(http.MethodDelete, "/api/v1/conversation/"+ccr.Conversation.UID+"/invite/"+ir.UID, oToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+}
+
+func (s *HTTPServerSuite) TestConversationPreferences() {
+
+	a := assert.New(s.T())
+
+	oID, oToken := s.createUserAndLogin("jane.doe", "jane.doe@mailbox.com", "test123")
+	rID, _ := s.createUserAndLogin("john.doe", "john.doe@mailbox.com", "test123")
+
+	bb, e := json.Marshal(map[string]interface{}{
+		"new_conversation": map[string]interface{}{
+			"Recipients": []string{oID, rID},
+		},
+	})
+	a.NoError(e)
+
+	cIDs := make([]string, 0, 3)
+
+	for i := 0; i < 3; i++ {
+
+		rr := s.do(http.MethodPost, "/api/v1/conversation", oToken, bb)
+		a.Equal(http.StatusCreated, rr.Code)
+
+		var ccr struct {
+			Conversation struct {
+				UID string `json:"UID"`
+			} `json:"conversation"`
+		}
+		a.NoError(json.NewDecoder(rr.Body).Decode(&ccr))
+
+		cIDs = append(cIDs, ccr.Conversation.UID)
+	}
+
+	mu := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		expected     int
+		conversation string
+		payload      *port.ConversationPreferencesPayload
+	}{
+		{
+			// pin oldest conversation
+			expected:     http.StatusAccepted,
+			conversation: cIDs[0],
+			payload:      &port.ConversationPreferencesPayload{Pinned: true, MutedUntil: &mu},
+		},
+		{
+			// archive
+			expected:     http.StatusAccepted,
+			conversation: cIDs[1],
+			payload:      &port.ConversationPreferencesPayload{Archived: true},
+		},
+	}
+
+	for _, c := range cases {
+
+		bb, e := json.Marshal(&port.UpdatePreferencesParams{ConversationPreferencesPayload: c.payload})
+		a.NoError(e)
+
+		rr := s.do(http.MethodPut, "/api/v1/conversation/"+c.conversation+"/preferences", oToken, bb)
 		a.Equal(c.expected, rr.Code)
 	}
+
+	filters := []struct {
+		filter   string
+		expected []string
+	}{
+		{
+			// pinned first
+			filter:   "",
+			expected: []string{cIDs[0], cIDs[2]},
+		},
+		{
+			filter:   "archived",
+			expected: []string{cIDs[1]},
+		},
+		{
+			filter:   "all",
+			expected: []string{cIDs[0], cIDs[2], cIDs[1]},
+		},
+	}
+
+	for _, f := range filters {
+
+		rr := s.do(http.MethodGet, "/api/v1/conversation/?filter="+f.filter, oToken, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.ListConversationsResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		ids := make([]string, 0, len(lr.Conversations))
+		for _, c := range lr.Conversations {
+			ids = append(ids, c.UID)
+		}
+
+		a.ElementsMatch(f.expected, ids)
+		if a.NotEmpty(lr.Conversations) {
+			a.Equal(f.expected[0], lr.Conversations[0].UID)
+		}
+	}
+}
+
+func (s *HTTPServerSuite) TestConversationInbox() {
+
+	a := assert.New(s.T())
+
+	oID, oToken := s.createUserAndLogin("jane.doe", "jane.doe@mailbox.com", "test123")
+	rID, _ := s.createUserAndLogin("john.doe", "john.doe@mailbox.com", "test123")
+
+	cIDs := make([]uuid.UUID, 0, 3)
+
+	for i := 0; i < 3; i++ {
+		c, e := s.bundle.MessengerService.CreateConversation(context.TODO(), &domain.NewConversation{
+			Creator:    uuid.MustParse(oID),
+			Recipients: []uuid.UUID{uuid.MustParse(oID), uuid.MustParse(rID)},
+		})
+		a.NoError(e)
+		cIDs = append(cIDs, c.UID)
+	}
+
+	// oldest conversation receives the latest message
+	evs := make([]*domain.Envelope, 0, 2)
+	for _, body := range []string{"hello", "world"} {
+		ev, e := s.bundle.MessengerService.CreateMessage(context.TODO(), &domain.NewEnvelope{
+			Sender:           uuid.MustParse(rID),
+			ConversationUUID: cIDs[0],
+			Message:          body,
+		})
+		a.NoError(e)
+		evs = append(evs, ev)
+	}
+
+	expected := []string{cIDs[0].String(), cIDs[2].String(), cIDs[1].String()}
+	ids := make([]string, 0, len(expected))
+
+	cursor := ""
+	for i := 0; i < len(expected); i++ {
+
+		rr := s.do(http.MethodGet, "/api/v1/conversation/?limit=1&cursor="+cursor, oToken, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.ListConversationsResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		if !a.Len(lr.Conversations, 1) {
+			return
+		}
+
+		ids = append(ids, lr.Conversations[0].UID)
+		cursor = lr.NextCursor
+	}
+
+	a.Equal(expected, ids)
+	a.Empty(cursor)
+
+	rr := s.do(http.MethodGet, "/api/v1/conversation/", oToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr := &port.ListConversationsResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+	if a.NotEmpty(lr.Conversations) && a.NotNil(lr.Conversations[0].LastMessage) {
+		a.Equal("world", lr.Conversations[0].LastMessage.Message)
+		a.Equal(rID, lr.Conversations[0].LastMessage.Sender)
+		a.Equal(int64(2), lr.Conversations[0].UnreadCount)
+		a.Equal(int64(2), lr.Conversations[0].MemberCount)
+		a.ElementsMatch([]string{"jane.doe", "john.doe"}, lr.Conversations[0].MemberNames)
+	}
+
+	unread := func() int64 {
+
+		rr := s.do(http.MethodGet, "/api/v1/conversation/", oToken, nil)
+		a.Equal(http.StatusAccepted, rr.Code)
+
+		lr := &port.ListConversationsResponse{}
+		a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+		if !a.NotEmpty(lr.Conversations) {
+			return -1
+		}
+
+		return lr.Conversations[0].UnreadCount
+	}
+
+	// acknowledging an envelope only reads messages up to it
+	ctx := context.TODO()
+	a.NoError(s.bundle.MessengerService.Acknowledge(ctx, cIDs[0], uuid.MustParse(oID), evs[0].UID))
+	a.Equal(int64(1), unread())
+
+	a.Equal(domain.ErrInvalidCursor, s.bundle.MessengerService.Acknowledge(ctx, cIDs[0], uuid.MustParse(oID), uuid.New()))
+
+	rr = s.do(http.MethodPut, "/api/v1/conversation/"+cIDs[0].String()+"/read", oToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+	a.Equal(int64(0), unread())
+
+	// late acknowledgements of older envelopes never unread later messages
+	a.NoError(s.bundle.MessengerService.Acknowledge(ctx, cIDs[0], uuid.MustParse(oID), evs[0].UID))
+	a.Equal(int64(0), unread())
+}
+
+func (s *HTTPServerSuite) TestConversationMessages() {
+
+	a := assert.New(s.T())
+
+	oID, oToken := s.createUserAndLogin("jane.doe", "jane.doe@mailbox.com", "test123")
+	rID, rToken := s.createUserAndLogin("john.doe", "john.doe@mailbox.com", "test123")
+	_, gToken := s.createUserAndLogin("jim.doe", "jim.doe@mailbox.com", "test123")
+
+	c, e := s.bundle.MessengerService.CreateConversation(context.TODO(), &domain.NewConversation{
+		Creator:    uuid.MustParse(oID),
+		Recipients: []uuid.UUID{uuid.MustParse(oID), uuid.MustParse(rID)},
+	})
+	a.NoError(e)
+
+	path := "/api/v1/conversation/" + c.UID.String() + "/message"
+
+	// sender is taken from token and spoofed sender field is ignored
+	bb, e := json.Marshal(map[string]interface{}{
+		"new_envelope": map[string]interface{}{
+			"message": "hello",
+			"sender":  rID,
+		},
+	})
+	a.NoError(e)
+
+	cases := []struct {
+		expected int
+		method   string
+		path     string
+		token    string
+		body     []byte
+	}{
+		{
+			// missing token
+			expected: http.StatusUnauthorized,
+			method:   http.MethodPost,
+			path:     path,
+			body:     bb,
+		},
+		{
+			// non member send
+			expected: http.StatusForbidden,
+			method:   http.MethodPost,
+			path:     path,
+			token:    gToken,
+			body:     bb,
+		},
+		{
+			// non member list
+			expected: http.StatusForbidden,
+			method:   http.MethodGet,
+			path:     path + "/",
+			token:    gToken,
+		},
+		{
+			// invalid after_seq
+			expected: http.StatusBadRequest,
+			method:   http.MethodGet,
+			path:     path + "/?after_seq=-1",
+			token:    rToken,
+		},
+		{
+			// non member mark read
+			expected: http.StatusForbidden,
+			method:   http.MethodPut,
+			path:     "/api/v1/conversation/" + c.UID.String() + "/read",
+			token:    gToken,
+		},
+		{
+			// unknown conversation
+			expected: http.StatusForbidden,
+			method:   http.MethodPost,
+			path:     "/api/v1/conversation/" + uuid.NewString() + "/message",
+			token:    oToken,
+			body:     bb,
+		},
+		{
+			// success
+			expected: http.StatusCreated,
+			method:   http.MethodPost,
+			path:     path,
+			token:    oToken,
+			body:     bb,
+		},
+	}
+
+	for _, cs := range cases {
+		rr := s.do(cs.method, cs.path, cs.token, cs.body)
+		a.Equal(cs.expected, rr.Code)
+	}
+
+	rr := s.do(http.MethodGet, path+"/", rToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr := &port.ListMessagesResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+	if a.Len(lr.Envelopes, 1) {
+		a.Equal(oID, lr.Envelopes[0].Sender)
+		a.Equal("hello", lr.Envelopes[0].Message)
+		a.Equal(int64(1), lr.Envelopes[0].Seq)
+	}
+
+	rr = s.do(http.MethodGet, path+"/?after_seq=1", rToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr = &port.ListMessagesResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+	a.Empty(lr.Envelopes)
+
+	bb, e = json.Marshal(&port.NewEnvelopeParams{
+		NewEnvelopePayload: &port.NewEnvelopePayload{Message: "retry", ClientMessageID: "k1"},
+	})
+	a.NoError(e)
+
+	// retry with the same client message id returns the original envelope
+	uids := make([]string, 0, 2)
+	for _, expected := range []int{http.StatusCreated, http.StatusOK} {
+
+		rr = s.do(http.MethodPost, path, oToken, bb)
+		a.Equal(expected, rr.Code)
+
+		nr := &port.NewEnvelopeResponse{}
+		if a.NoError(json.NewDecoder(rr.Body).Decode(nr)) && a.NotNil(nr.EnvelopePayload) {
+			a.Equal("k1", nr.ClientMessageID)
+			uids = append(uids, nr.UID)
+		}
+	}
+
+	if a.Len(uids, 2) {
+		a.Equal(uids[0], uids[1])
+	}
+
+	rr = s.do(http.MethodGet, path+"/?after_seq=1", rToken, nil)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr = &port.ListMessagesResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+	a.Len(lr.Envelopes, 1)
+}
+
+// createUserAndLogin create new user and return user id and access token
+func (s *HTTPServerSuite) createUserAndLogin(username, email, password string) (string, string) {
+
+	a := assert.New(s.T())
+
+	bb, e := json.Marshal(&port.NewUserParams{
+		NewUserPayload: &port.NewUserPayload{
+			Username: username,
+			Email:    email,
+			Password: password,
+		},
+	})
+	a.NoError(e)
+
+	rr := s.do(http.MethodPost, "/api/v1/user", "", bb)
+	a.Equal(http.StatusCreated, rr.Code)
+
+	bb, e = json.Marshal(&port.UserLoginRequest{
+		Username: username,
+		Password: password,
+	})
+	a.NoError(e)
+
+	rr = s.do(http.MethodPost, "/api/v1/user/login", "", bb)
+	a.Equal(http.StatusAccepted, rr.Code)
+
+	lr := &port.UserLoginResponse{}
+	a.NoError(json.NewDecoder(rr.Body).Decode(lr))
+
+	return lr.UserID, lr.Token
+}
+
+// do execute request against router with optional access token
+func (s *HTTPServerSuite) do(method, path, token string, body []byte) *httptest.ResponseRecorder {
+
+	a := assert.New(s.T())
+
+	rq, e := http.NewRequest(method, path, bytes.NewReader(body))
+	a.NoError(e)
+
+	rq.Header.Add("Content-Type", "application/json")
+
+	if token != "" {
+		rq.Header.Add("Authorization", "Bearer: "+token)
+	}
+
+	rr := httptest.NewRecorder()
+
+	s.mux.ServeHTTP(rr, rq)
+
+	return rr
+}
+
+// mailbox mail sender recording messages instead of delivering them
+type mailbox struct {
+	mu   sync.Mutex
+	msgs []*mail.Message
+	err  error
+}
+
+func (m *mailbox) Send(_ context.Context, msg *mail.Message) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.msgs = append(m.msgs, msg)
+
+	return nil
+}
+
+// setErr fail every send with e until reset with nil
+func (m *mailbox) setErr(e error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.err = e
+}
+
+// await wait for at least n messages with subject, emails sent in the background arrive after the response
+func (m *mailbox) await(subject string, n int) bool {
+
+	for i := 0; i < 100; i++ {
+
+		if m.count(subject) >= n {
+			return true
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return false
+}
+
+// messages recorded messages with subject
+func (m *mailbox) messages(subject string) []*mail.Message {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ml := make([]*mail.Message, 0, len(m.msgs))
+	for _, msg := range m.msgs {
+		if msg.Subject == subject {
+			ml = append(ml, msg)
+		}
+	}
+
+	return ml
+}
+
+func (m *mailbox) count(subject string) int {
+	return len(m.messages(subject))
+}
+
+func (m *mailbox) last(subject string) *mail.Message {
+
+	ml := m.messages(subject)
+	if len(ml) < 1 {
+		return &mail.Message{}
+	}
+
+	return ml[len(ml)-1]
+}
+
+const (
+	resetSubject  = "Reset your go-chat password"
+	verifySubject = "Verify your go-chat email"
+)
+
+var mailTokenPattern = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}\r?$`)
+
+// mailToken password reset or verification token on its own line of an emailed message
+func mailToken(msg *mail.Message) string {
+	return strings.TrimSpace(mailTokenPattern.FindString(msg.Body))
+}
+
+// totpAt RFC 6238 code of base32 secret at t
+func totpAt(secret string, t time.Time) string {
+
+	k, e := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if e != nil {
+		return ""
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(t.Unix()/30))
+
+	m := hmac.New(sha1.New, k)
+	m.Write(msg[:])
+	h := m.Sum(nil)
+
+	o := h[len(h)-1] & 0x0f
+	v := binary.BigEndian.Uint32(h[o:o+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", v%1000000)
 }
 
 func TestHTTPServerSuite(t *testing.T) {
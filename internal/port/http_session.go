@@ -0,0 +1,296 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	mw "github.com/trevatk/go-chat/internal/port/middleware"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// RefreshTokenRequest http refresh token request model
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Bind parse refresh token request from http
+func (rtr *RefreshTokenRequest) Bind(_ *http.Request) error {
+
+	if len(rtr.RefreshToken) < 1 {
+		return errors.New("invalid refresh token provided")
+	}
+
+	return nil
+}
+
+// refreshToken exchange refresh token for a new access and refresh token pair
+func (h *HTTPServer) refreshToken(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &RefreshTokenRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	ss, e := h.bundle.SessionService.Refresh(ctx, p.RefreshToken)
+	if e != nil {
+
+		if e == domain.ErrInvalidRefreshToken || e == domain.ErrRefreshTokenReused {
+			http.Error(w, e.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to refresh session %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeTokens(w, r, ss)
+}
+
+// writeTokens sign access token for session and write login response
+func (h *HTTPServer) writeTokens(w http.ResponseWriter, r *http.Request, ss *domain.Session) {
+
+	ctx := r.Context()
+
+	t, exp, e := signAccessToken(h.keys, ss.User.String(), ss.UID.String())
+	if e != nil {
+		logging.FromContext(ctx).Errorf("failed to sign jwt token %v", e)
+		http.Error(w, "failed to generate jwt token", http.StatusInternalServerError)
+		return
+	}
+
+	rsp := &UserLoginResponse{
+		UserID:       ss.User.String(),
+		SessionID:    ss.UID.String(),
+		Token:        t,
+		RefreshToken: ss.RefreshToken,
+		ExpiresAt:    exp,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if e := json.NewEncoder(w).Encode(rsp); e != nil {
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, "unable to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SessionPayload http session model
+//
+// current is set on the session the request was authenticated with
+type SessionPayload struct {
+	UID        string    `json:"uid"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListSessionsResponse http list sessions response model
+type ListSessionsResponse struct {
+	Sessions []*SessionPayload `json:"sessions"`
+}
+
+func (h *HTTPServer) listSessions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	sl, e := h.bundle.SessionService.List(ctx, uid)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to list sessions %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	sid, _ := sessionFromContext(ctx)
+
+	rsp := &ListSessionsResponse{Sessions: make([]*SessionPayload, 0, len(sl))}
+
+	for _, ss := range sl {
+		rsp.Sessions = append(rsp.Sessions, &SessionPayload{
+			UID:        ss.UID.String(),
+			UserAgent:  ss.UserAgent,
+			IPAddress:  ss.IPAddress,
+			Current:    ss.UID == sid,
+			ExpiresAt:  ss.ExpiresAt,
+			LastUsedAt: ss.LastUsedAt,
+			CreatedAt:  ss.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(rsp)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// RevokeSessionResponse http revoke session response model
+type RevokeSessionResponse struct {
+	Message string `json:"message"`
+}
+
+func (h *HTTPServer) revokeSession(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	sid, e := uuid.Parse(chi.URLParam(r, "session_id"))
+	if e != nil {
+		c := http.StatusBadRequest
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.SessionService.Revoke(ctx, uid, sid)
+	if e != nil {
+
+		if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to revoke session %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&RevokeSessionResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// LogoutResponse http logout response model
+type LogoutResponse struct {
+	Message string `json:"message"`
+}
+
+// logout end the session the request was authenticated with
+func (h *HTTPServer) logout(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	sid, e := sessionFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	tid, _ := ctx.Value(mw.TokenID).(string)
+
+	e = h.bundle.SessionService.Logout(ctx, uid, sid, tid)
+	if e != nil {
+
+		if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to logout %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeLogout(w, r)
+}
+
+// logoutAll end every session of the authenticated user
+func (h *HTTPServer) logoutAll(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.SessionService.LogoutAll(ctx, uid)
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to logout everywhere %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeLogout(w, r)
+}
+
+func (h *HTTPServer) writeLogout(w http.ResponseWriter, r *http.Request) {
+
+	w.WriteHeader(http.StatusAccepted)
+	e := json.NewEncoder(w).Encode(&LogoutResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// sessionFromContext parse authenticated session id set by jwt middleware
+func sessionFromContext(ctx context.Context) (uuid.UUID, error) {
+
+	sid, ok := ctx.Value(mw.Session).(string)
+	if !ok {
+		return uuid.Nil, errors.New("no session found in context")
+	}
+
+	return uuid.Parse(sid)
+}
+
+// clientIP remote address of request without port
+//
+// the real ip middleware has already replaced it with a forwarded address
+func clientIP(r *http.Request) string {
+
+	h, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		return r.RemoteAddr
+	}
+
+	return h
+}
@@ -0,0 +1,257 @@
+package port
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// LoginChallengeRequest http answer login challenge request model
+type LoginChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	// Code totp or recovery code
+	Code string `json:"code"`
+}
+
+// Bind parse answer login challenge request from http
+func (lcr *LoginChallengeRequest) Bind(_ *http.Request) error {
+
+	if len(lcr.ChallengeToken) < 1 {
+		return errors.New("invalid challenge token provided")
+	} else if len(lcr.Code) < 1 {
+		return errors.New("invalid code provided")
+	}
+
+	return nil
+}
+
+// TwoFactorCodeRequest http confirm and disable two factor request model
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// Bind parse two factor code request from http
+func (tfr *TwoFactorCodeRequest) Bind(_ *http.Request) error {
+
+	if len(tfr.Code) < 1 {
+		return errors.New("invalid code provided")
+	}
+
+	return nil
+}
+
+// TwoFactorEnrollmentResponse http enroll two factor response model
+//
+// the secret is entered manually or the otpauth uri is scanned as a qr code
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse http confirm two factor response model
+//
+// recovery codes are only returned once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorResponse http disable two factor response model
+type TwoFactorResponse struct {
+	Message string `json:"message"`
+}
+
+// writeChallenge write login response asking for a two factor code
+func (h *HTTPServer) writeChallenge(w http.ResponseWriter, r *http.Request, uid string, ch *domain.LoginChallenge) {
+
+	rsp := &UserLoginResponse{
+		UserID:            uid,
+		TwoFactorRequired: true,
+		ChallengeToken:    ch.Token,
+		ExpiresAt:         ch.ExpiresAt,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if e := json.NewEncoder(w).Encode(rsp); e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// answerLoginChallenge complete two factor login and issue tokens
+func (h *HTTPServer) answerLoginChallenge(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &LoginChallengeRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	uid, e := h.bundle.TwoFactorService.Answer(ctx, p.ChallengeToken, p.Code)
+	if e != nil {
+
+		if e == domain.ErrInvalidLoginChallenge || e == domain.ErrInvalidTwoFactorCode {
+			http.Error(w, e.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to answer login challenge %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	ss, e := h.bundle.SessionService.Create(ctx, &domain.NewSession{
+		User:      uid,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	})
+	if e != nil {
+
+		if e == domain.ErrAccountDisabled {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to create session %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeTokens(w, r, ss)
+}
+
+// enrollTwoFactor generate totp secret for the authenticated user
+func (h *HTTPServer) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	en, e := h.bundle.TwoFactorService.Enroll(ctx, uid)
+	if e != nil {
+
+		if e == domain.ErrTwoFactorEnabled {
+			http.Error(w, e.Error(), http.StatusConflict)
+			return
+		} else if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to enroll two factor %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	e = json.NewEncoder(w).Encode(&TwoFactorEnrollmentResponse{Secret: en.Secret, OtpauthURI: en.URI})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// confirmTwoFactor enable two factor login with a code from the enrolled secret
+func (h *HTTPServer) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &TwoFactorCodeRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	cl, e := h.bundle.TwoFactorService.Confirm(ctx, uid, p.Code)
+	if e != nil {
+
+		if e == domain.ErrInvalidTwoFactorCode {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		} else if e == domain.ErrTwoFactorEnabled || e == domain.ErrTwoFactorNotEnabled {
+			http.Error(w, e.Error(), http.StatusConflict)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to confirm two factor %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&RecoveryCodesResponse{RecoveryCodes: cl})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
+
+// disableTwoFactor turn off two factor login with a totp or recovery code
+func (h *HTTPServer) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &TwoFactorCodeRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.TwoFactorService.Disable(ctx, uid, p.Code)
+	if e != nil {
+
+		if e == domain.ErrInvalidTwoFactorCode {
+			http.Error(w, e.Error(), http.StatusForbidden)
+			return
+		} else if e == domain.ErrTwoFactorNotEnabled {
+			http.Error(w, e.Error(), http.StatusConflict)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to disable two factor %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	e = json.NewEncoder(w).Encode(&TwoFactorResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
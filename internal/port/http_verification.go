@@ -0,0 +1,107 @@
+package port
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// VerifyEmailRequest http verify email request model
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// Bind parse verify email request from http
+func (ver *VerifyEmailRequest) Bind(_ *http.Request) error {
+
+	if len(ver.Token) < 1 {
+		return errors.New("invalid verification token provided")
+	}
+
+	return nil
+}
+
+// VerificationResponse http verify email and resend verification response model
+type VerificationResponse struct {
+	Message string `json:"message"`
+}
+
+// verifyEmail confirm email with an emailed verification token
+func (h *HTTPServer) verifyEmail(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p := &VerifyEmailRequest{}
+	e := render.Bind(r, p)
+	if e != nil {
+		http.Error(w, "invalid request object", http.StatusBadRequest)
+		return
+	}
+
+	e = h.bundle.VerificationService.Confirm(ctx, p.Token)
+	if e != nil {
+
+		if e == domain.ErrInvalidVerificationToken {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to verify email %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeVerification(w, r)
+}
+
+// resendVerification email a new verification token to the authenticated user
+func (h *HTTPServer) resendVerification(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	uid, e := userFromContext(ctx)
+	if e != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+
+	e = h.bundle.VerificationService.Resend(ctx, uid)
+	if e != nil {
+
+		if e == domain.ErrEmailAlreadyVerified {
+			http.Error(w, e.Error(), http.StatusConflict)
+			return
+		} else if e == domain.ErrTooManyRequests {
+			http.Error(w, e.Error(), http.StatusTooManyRequests)
+			return
+		} else if e == domain.ErrResourceNotFound {
+			c := http.StatusNotFound
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
+
+		c := http.StatusInternalServerError
+		logging.FromContext(ctx).Errorf("unable to resend verification email %v", e)
+		http.Error(w, http.StatusText(c), c)
+		return
+	}
+
+	h.writeVerification(w, r)
+}
+
+func (h *HTTPServer) writeVerification(w http.ResponseWriter, r *http.Request) {
+
+	w.WriteHeader(http.StatusAccepted)
+	e := json.NewEncoder(w).Encode(&VerificationResponse{Message: "success"})
+	if e != nil {
+		c := http.StatusInternalServerError
+		logging.FromContext(r.Context()).Errorf("unable to encode response %v", e)
+		http.Error(w, http.StatusText(c), c)
+	}
+}
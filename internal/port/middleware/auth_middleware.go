@@ -1,19 +1,20 @@
-// Package middleware http server middlewares
+// Package middleware http and gRPC server middlewares
 package middleware
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
 )
 
 type contextKey string
@@ -21,71 +22,366 @@ type contextKey string
 const (
 	// User middleware key-value for user id
 	User contextKey = "user"
+	// Session middleware key-value for session id
+	Session contextKey = "session"
+	// TokenID middleware key-value for access token id
+	TokenID contextKey = "token"
+	// APIKey middleware key-value for api key id, unset when authenticated with a JWT
+	APIKey contextKey = "api_key"
+
+	defaultTokenIssuer   = "go-chat"
+	defaultTokenAudience = "go-chat"
 )
 
 // CustomClaims custom JWT claims
+//
+// subject is the user id and id is unique per token
 type CustomClaims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// NewAccessClaims claims of a new access token for user within session
+//
+// $JWT_ISSUER and $JWT_AUDIENCE override the iss and aud claims
+func NewAccessClaims(uid, sid string) *CustomClaims {
+
+	now := time.Now()
+
+	return &CustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(domain.AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer(),
+			Subject:   uid,
+			ID:        uuid.NewString(),
+			Audience:  []string{tokenAudience()},
+		},
+		UserID:    uid,
+		SessionID: sid,
+	}
+}
+
+// RevocationChecker report whether an access token or its session has been revoked
+type RevocationChecker interface {
+	IsRevoked(tokenID, sessionID string) bool
+}
+
+// APIKeyVerifier resolve an api key to its user and scopes
+type APIKeyVerifier interface {
+	Verify(ctx context.Context, key string) (*domain.APIKey, error)
+}
+
+// PermissionChecker authorize user for an administrative permission
+//
+// returns domain.ErrForbidden when the permission is not granted
+type PermissionChecker interface {
+	Authorize(ctx context.Context, user uuid.UUID, permission domain.Permission) error
+}
+
 // Authenticator JWT authenication middleware
 type Authenticator struct {
-	publicKey *ecdsa.PublicKey
+	keys     *KeyManager
+	issuer   string
+	audience string
+	// publicMethods full gRPC method names served without a token
+	publicMethods map[string]struct{}
+	// revocations rejects tokens revoked before they expire
+	revocations RevocationChecker
+	// apiKeys accepts api keys in place of tokens when set
+	apiKeys APIKeyVerifier
+	// readMethods full gRPC method names api keys call with the read scope
+	readMethods map[string]struct{}
+	// sessionMethods full gRPC method names api keys may not call
+	sessionMethods map[string]struct{}
+	// permissions authorizes administrative requests, unset denies them
+	permissions PermissionChecker
+	// methodPermissions permission required by full gRPC method names
+	methodPermissions map[string]domain.Permission
 }
 
 // NewAuthenticator create new authenticator instance
-func NewAuthenticator() (*Authenticator, error) {
+func NewAuthenticator(keys *KeyManager) *Authenticator {
+	return &Authenticator{
+		keys:              keys,
+		issuer:            tokenIssuer(),
+		audience:          tokenAudience(),
+		publicMethods:     make(map[string]struct{}),
+		readMethods:       make(map[string]struct{}),
+		sessionMethods:    make(map[string]struct{}),
+		methodPermissions: make(map[string]domain.Permission),
+	}
+}
 
-	p := os.Getenv("JWT_PRIVATE_KEY")
-	if p == "" {
-		return nil, errors.New("$JWT_PRIVATE_KEY is not set")
+// AllowUnauthenticated serve full gRPC method names without a token
+//
+// must be called before the gRPC server starts serving
+func (a *Authenticator) AllowUnauthenticated(methods ...string) {
+	for _, m := range methods {
+		a.publicMethods[m] = struct{}{}
 	}
+}
 
-	bb, e := os.ReadFile(filepath.Clean(p))
-	if e != nil {
-		return nil, fmt.Errorf("unable to open private key %v", e)
+// CheckRevocations reject tokens reported revoked by rc
+//
+// must be called before the servers start serving
+func (a *Authenticator) CheckRevocations(rc RevocationChecker) {
+	a.revocations = rc
+}
+
+// AcceptAPIKeys accept api keys verified by v in place of tokens
+//
+// must be called before the servers start serving
+func (a *Authenticator) AcceptAPIKeys(v APIKeyVerifier) {
+	a.apiKeys = v
+}
+
+// AllowReadScope serve full gRPC method names to api keys with the read scope
+//
+// every other method requires the write scope, must be called before the gRPC server starts serving
+func (a *Authenticator) AllowReadScope(methods ...string) {
+	for _, m := range methods {
+		a.readMethods[m] = struct{}{}
 	}
+}
 
-	b, _ := pem.Decode(bb)
+// RequireSessionFor reject api keys calling full gRPC method names
+//
+// must be called before the gRPC server starts serving
+func (a *Authenticator) RequireSessionFor(methods ...string) {
+	for _, m := range methods {
+		a.sessionMethods[m] = struct{}{}
+	}
+}
 
-	pk, e := x509.ParseECPrivateKey(b.Bytes)
-	if e != nil {
-		return nil, fmt.Errorf("failed to parse ecdsa private key %v", e)
+// AuthorizeWith authorize administrative requests with pc
+//
+// must be called before the servers start serving
+func (a *Authenticator) AuthorizeWith(pc PermissionChecker) {
+	a.permissions = pc
+}
+
+// RequirePermissions require the permission of each full gRPC method name
+//
+// must be called before the gRPC server starts serving
+func (a *Authenticator) RequirePermissions(methods map[string]domain.Permission) {
+	for m, p := range methods {
+		a.methodPermissions[m] = p
 	}
+}
 
-	return &Authenticator{
-		publicKey: &pk.PublicKey,
-	}, nil
+// RequirePermission reject authenticated requests of users not granted permission
+func (a *Authenticator) RequirePermission(permission domain.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+
+			e := a.authorize(ctx, permission)
+			if e != nil {
+
+				if e == domain.ErrForbidden {
+					c := http.StatusForbidden
+					http.Error(w, http.StatusText(c), c)
+					return
+				}
+
+				c := http.StatusInternalServerError
+				logging.FromContext(ctx).Errorf("unable to authorize request %v", e)
+				http.Error(w, http.StatusText(c), c)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-// ValidateJWT parse and validate JWT token
+// ValidateJWT parse and validate JWT token or api key
+//
+// api keys need the read scope for safe methods and the write scope otherwise
 func (a *Authenticator) ValidateJWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		// get authorization header value
-		h := r.Header.Get("Authorization")
+		t, ok := tokenFromHeader(r.Header.Get("Authorization"))
+		if !ok {
+			http.Error(w, "missing auth token", http.StatusUnauthorized)
+			return
+		}
+
+		if a.apiKeys != nil && strings.HasPrefix(t, domain.APIKeyPrefix) {
+
+			ctx := r.Context()
 
-		t := strings.Split(h, "Bearer: ")[1]
+			k, e := a.apiKeys.Verify(ctx, t)
+			if e != nil {
 
-		tk, e := jwt.ParseWithClaims(t, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+				if e == domain.ErrInvalidAPIKey {
+					http.Error(w, "invalid api key", http.StatusUnauthorized)
+					return
+				}
 
-			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+				c := http.StatusInternalServerError
+				logging.FromContext(ctx).Errorf("unable to verify api key %v", e)
+				http.Error(w, http.StatusText(c), c)
+				return
 			}
 
-			return a.publicKey, nil
-		})
+			if !k.HasScope(requestScope(r.Method)) {
+				http.Error(w, "insufficient api key scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withAPIKey(ctx, k)))
+			return
+		}
+
+		c, e := a.ParseToken(t)
 		if e != nil {
 			http.Error(w, "invalid auth token", http.StatusUnauthorized)
 			return
+		} else if a.isRevoked(c) {
+			http.Error(w, "revoked auth token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), c)))
+	})
+}
+
+// ParseToken parse and validate signed JWT token returning its claims
+//
+// the kid header must name a key of the key manager,
+// issuer and audience must match, expiry, subject, id and session are required
+func (a *Authenticator) ParseToken(token string) (*CustomClaims, error) {
+
+	tk, e := jwt.ParseWithClaims(token, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
 		}
 
-		if c, ok := tk.Claims.(*CustomClaims); ok && tk.Valid {
-			ctx := context.WithValue(r.Context(), User, c.UserID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			http.Error(w, "invalid claims", http.StatusUnauthorized)
+		kid, _ := token.Header["kid"].(string)
+
+		k, ok := a.keys.PublicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %s", kid)
 		}
+
+		return k, nil
+	}, jwt.WithIssuer(a.issuer), jwt.WithAudience(a.audience))
+	if e != nil {
+		return nil, fmt.Errorf("unable to parse token %v", e)
+	}
+
+	c, ok := tk.Claims.(*CustomClaims)
+	if !ok || !tk.Valid {
+		return nil, errors.New("invalid claims")
+	}
+
+	if c.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	} else if c.ID == "" {
+		return nil, errors.New("token has no id")
+	} else if c.SessionID == "" {
+		return nil, errors.New("token has no session")
+	} else if c.Subject == "" || c.Subject != c.UserID {
+		return nil, errors.New("token subject does not match user")
+	}
+
+	return c, nil
+}
+
+// RequireSession reject requests authenticated with an api key
+//
+// guards credentials, sessions and api key management
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if _, ok := r.Context().Value(APIKey).(string); ok {
+			http.Error(w, "api keys may not be used for this request", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
+
+// authorize verify the authenticated user of ctx was granted permission
+func (a *Authenticator) authorize(ctx context.Context, permission domain.Permission) error {
+
+	if a.permissions == nil {
+		return domain.ErrForbidden
+	}
+
+	sid, _ := ctx.Value(User).(string)
+
+	uid, e := uuid.Parse(sid)
+	if e != nil {
+		return domain.ErrForbidden
+	}
+
+	return a.permissions.Authorize(ctx, uid, permission)
+}
+
+// requestScope api key scope required by http method
+func requestScope(method string) domain.Scope {
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return domain.ScopeRead
+	default:
+		return domain.ScopeWrite
+	}
+}
+
+// isRevoked report whether validated claims belong to a revoked token or session
+func (a *Authenticator) isRevoked(c *CustomClaims) bool {
+	return a.revocations != nil && a.revocations.IsRevoked(c.ID, c.SessionID)
+}
+
+// withClaims add user, session and token id of validated claims to context
+func withClaims(ctx context.Context, c *CustomClaims) context.Context {
+	ctx = context.WithValue(ctx, User, c.UserID)
+	ctx = context.WithValue(ctx, Session, c.SessionID)
+	return context.WithValue(ctx, TokenID, c.ID)
+}
+
+// withAPIKey add user and key id of verified api key to context
+func withAPIKey(ctx context.Context, k *domain.APIKey) context.Context {
+	ctx = context.WithValue(ctx, User, k.User.String())
+	return context.WithValue(ctx, APIKey, k.UID.String())
+}
+
+// tokenFromHeader extract token from authorization header value
+//
+// both "Bearer: <token>" and "Bearer <token>" are accepted
+func tokenFromHeader(h string) (string, bool) {
+
+	t, ok := strings.CutPrefix(h, "Bearer: ")
+	if !ok {
+		t, ok = strings.CutPrefix(h, "Bearer ")
+	}
+
+	return t, ok && t != ""
+}
+
+func tokenIssuer() string {
+
+	if iss := os.Getenv("JWT_ISSUER"); iss != "" {
+		return iss
+	}
+
+	return defaultTokenIssuer
+}
+
+func tokenAudience() string {
+
+	if aud := os.Getenv("JWT_AUDIENCE"); aud != "" {
+		return aud
+	}
+
+	return defaultTokenAudience
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// UnaryInterceptor validate JWT token from authorization metadata
+//
+// methods allowed unauthenticated are passed through
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	if _, ok := a.publicMethods[info.FullMethod]; ok {
+		return handler(ctx, req)
+	}
+
+	ctx, e := a.authenticateContext(ctx, info.FullMethod)
+	if e != nil {
+		return nil, e
+	}
+
+	e = a.authorizeMethod(ctx, info.FullMethod)
+	if e != nil {
+		return nil, e
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamInterceptor validate JWT token from authorization metadata
+//
+// methods allowed unauthenticated are passed through
+func (a *Authenticator) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	if _, ok := a.publicMethods[info.FullMethod]; ok {
+		return handler(srv, ss)
+	}
+
+	ctx, e := a.authenticateContext(ss.Context(), info.FullMethod)
+	if e != nil {
+		return e
+	}
+
+	e = a.authorizeMethod(ctx, info.FullMethod)
+	if e != nil {
+		return e
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticateContext parse token or api key from incoming metadata and add user id to context
+func (a *Authenticator) authenticateContext(ctx context.Context, method string) (context.Context, error) {
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	vs := md.Get("authorization")
+	if len(vs) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing auth token")
+	}
+
+	t, ok := tokenFromHeader(vs[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing auth token")
+	}
+
+	if a.apiKeys != nil && strings.HasPrefix(t, domain.APIKeyPrefix) {
+		return a.authenticateAPIKey(ctx, t, method)
+	}
+
+	c, e := a.ParseToken(t)
+	if e != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid auth token")
+	} else if a.isRevoked(c) {
+		return nil, status.Error(codes.Unauthenticated, "revoked auth token")
+	}
+
+	return withClaims(ctx, c), nil
+}
+
+// authenticateAPIKey verify api key may call method and add user and key id to context
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, key, method string) (context.Context, error) {
+
+	if _, ok := a.sessionMethods[method]; ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys may not be used for this request")
+	}
+
+	k, e := a.apiKeys.Verify(ctx, key)
+	if e != nil {
+
+		if e == domain.ErrInvalidAPIKey {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+
+		logging.FromContext(ctx).Errorf("unable to verify api key %v", e)
+		return nil, status.Error(codes.Internal, "unable to verify api key")
+	}
+
+	sc := domain.ScopeWrite
+	if _, ok := a.readMethods[method]; ok {
+		sc = domain.ScopeRead
+	}
+
+	if !k.HasScope(sc) {
+		return nil, status.Error(codes.PermissionDenied, "insufficient api key scope")
+	}
+
+	return withAPIKey(ctx, k), nil
+}
+
+// authorizeMethod verify the authenticated user was granted the permission required by method
+func (a *Authenticator) authorizeMethod(ctx context.Context, method string) error {
+
+	p, ok := a.methodPermissions[method]
+	if !ok {
+		return nil
+	}
+
+	e := a.authorize(ctx, p)
+	if e != nil {
+
+		if e == domain.ErrForbidden {
+			return status.Error(codes.PermissionDenied, e.Error())
+		}
+
+		logging.FromContext(ctx).Errorf("unable to authorize request %v", e)
+		return status.Error(codes.Internal, "unable to authorize request")
+	}
+
+	return nil
+}
+
+// contextStream server stream carrying a derived context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context return derived context
+func (cs *contextStream) Context() context.Context {
+	return cs.ctx
+}
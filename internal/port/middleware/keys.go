@@ -0,0 +1,346 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/trevatk/go-chat/internal/domain"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// NewPrivateKey load ecdsa private key used as the first JWT signing key
+//
+// the key is read once and handed to the key manager
+func NewPrivateKey() (*ecdsa.PrivateKey, error) {
+
+	p := os.Getenv("JWT_PRIVATE_KEY")
+	if p == "" {
+		return nil, errors.New("$JWT_PRIVATE_KEY is not set")
+	}
+
+	bb, e := os.ReadFile(filepath.Clean(p))
+	if e != nil {
+		return nil, fmt.Errorf("unable to open private key %v", e)
+	}
+
+	b, _ := pem.Decode(bb)
+	if b == nil {
+		return nil, errors.New("no PEM data found in private key")
+	}
+
+	pk, e := x509.ParseECPrivateKey(b.Bytes)
+	if e != nil {
+		return nil, fmt.Errorf("failed to parse ecdsa private key %v", e)
+	}
+
+	return pk, nil
+}
+
+const (
+	// defaultKeyRotationInterval time between signing key rotations when unset
+	defaultKeyRotationInterval = time.Hour * 24
+	// defaultKeyGracePeriod time a retired key still verifies tokens when unset
+	defaultKeyGracePeriod = time.Hour
+
+	// keyCurve curve of every signing key
+	keyCurve = "P-384"
+	// keyCoordinateSize byte length of P-384 curve coordinates
+	keyCoordinateSize = 48
+)
+
+// JWK public ecdsa JSON web key
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKS JSON web key set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// signingKey private key identified by the thumbprint of its public key
+type signingKey struct {
+	kid string
+	key *ecdsa.PrivateKey
+	// retiredAt zero value while the key is current or next
+	retiredAt time.Time
+}
+
+// KeyManager JWT signing keys identified by kid
+//
+// tokens are signed with the current key, the next key is published ahead
+// of use so verifiers caching the key set know it before it signs tokens,
+// retired keys keep verifying tokens until the grace period ends
+//
+// the configured private key is the first current key, rotated keys
+// only live in memory so a restart falls back to the configured key
+// and clients holding tokens signed by a rotated key refresh them
+//
+// rotation is only supported for a single instance, replicas would sign
+// with and publish different keys, deployments running more than one
+// instance disable rotation so every instance signs with and publishes
+// only the configured key
+type KeyManager struct {
+	mu      sync.RWMutex
+	current *signingKey
+	next    *signingKey
+	retired []*signingKey
+
+	rotation time.Duration
+	grace    time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewKeyManager create new key manager starting with privateKey
+//
+// $JWT_KEY_ROTATION_INTERVAL sets the rotation schedule, 0 disables rotation
+// and no next key is published, $JWT_KEY_GRACE_PERIOD must outlast the access token lifetime
+func NewKeyManager(privateKey *ecdsa.PrivateKey) (*KeyManager, error) {
+
+	if privateKey.Curve.Params().Name != keyCurve {
+		return nil, fmt.Errorf("signing key must use curve %s", keyCurve)
+	}
+
+	rotation := defaultKeyRotationInterval
+	if v := os.Getenv("JWT_KEY_ROTATION_INTERVAL"); v != "" {
+
+		d, e := time.ParseDuration(v)
+		if e != nil || d < 0 {
+			return nil, fmt.Errorf("invalid $JWT_KEY_ROTATION_INTERVAL %s", v)
+		}
+
+		rotation = d
+	}
+
+	grace := defaultKeyGracePeriod
+	if v := os.Getenv("JWT_KEY_GRACE_PERIOD"); v != "" {
+
+		d, e := time.ParseDuration(v)
+		if e != nil || d < domain.AccessTokenTTL {
+			return nil, fmt.Errorf("invalid $JWT_KEY_GRACE_PERIOD %s must be at least %s", v, domain.AccessTokenTTL)
+		}
+
+		grace = d
+	}
+
+	// instances sharing the configured key publish the same key set without rotation
+	var next *signingKey
+	if rotation > 0 {
+
+		var e error
+		next, e = generateSigningKey()
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return &KeyManager{
+		current:  newSigningKey(privateKey),
+		next:     next,
+		rotation: rotation,
+		grace:    grace,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Start rotate signing keys on schedule until shutdown
+func (km *KeyManager) Start(ctx context.Context) {
+
+	if km.rotation == 0 {
+		return
+	}
+
+	go func() {
+
+		t := time.NewTicker(km.rotation)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-km.stop:
+				return
+			case <-t.C:
+				if e := km.Rotate(); e != nil {
+					logging.FromContext(ctx).Errorf("failed to rotate signing key %v", e)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stop rotating signing keys
+func (km *KeyManager) Shutdown() {
+	km.once.Do(func() { close(km.stop) })
+}
+
+// Rotate promote the next key to current and retire the current key
+//
+// without a published next key, rotation disabled, a new key signs immediately
+func (km *KeyManager) Rotate() error {
+
+	next, e := generateSigningKey()
+	if e != nil {
+		return e
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	now := time.Now()
+
+	km.current.retiredAt = now
+	km.retired = append(km.pruneRetired(now), km.current)
+
+	if km.next == nil {
+		km.current = next
+		return nil
+	}
+
+	km.current = km.next
+	km.next = next
+
+	return nil
+}
+
+// Sign sign claims with the current key, the key id is set in the kid header
+func (km *KeyManager) Sign(claims jwt.Claims) (string, error) {
+
+	km.mu.RLock()
+	k := km.current
+	km.mu.RUnlock()
+
+	tk := jwt.NewWithClaims(jwt.SigningMethodES384, claims)
+	tk.Header["kid"] = k.kid
+
+	return tk.SignedString(k.key)
+}
+
+// Current public key of the key currently signing tokens
+func (km *KeyManager) Current() *ecdsa.PublicKey {
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	return &km.current.key.PublicKey
+}
+
+// PublicKey public key verifying tokens signed with kid
+//
+// the next key is included as it may become current at any time
+func (km *KeyManager) PublicKey(kid string) (*ecdsa.PublicKey, bool) {
+
+	for _, k := range km.verificationKeys() {
+		if k.kid == kid {
+			return &k.key.PublicKey, true
+		}
+	}
+
+	return nil, false
+}
+
+// JWKS public keys of the current, next when rotating and retired keys within the grace period
+func (km *KeyManager) JWKS() *JWKS {
+
+	ks := km.verificationKeys()
+
+	set := &JWKS{Keys: make([]*JWK, 0, len(ks))}
+
+	for _, k := range ks {
+		set.Keys = append(set.Keys, &JWK{
+			Kty: "EC",
+			Crv: keyCurve,
+			X:   encodeCoordinate(k.key.X),
+			Y:   encodeCoordinate(k.key.Y),
+			Kid: k.kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodES384.Alg(),
+		})
+	}
+
+	return set
+}
+
+func (km *KeyManager) verificationKeys() []*signingKey {
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	ks := []*signingKey{km.current}
+	if km.next != nil {
+		ks = append(ks, km.next)
+	}
+
+	now := time.Now()
+	for _, k := range km.retired {
+		if now.Sub(k.retiredAt) < km.grace {
+			ks = append(ks, k)
+		}
+	}
+
+	return ks
+}
+
+// pruneRetired retired keys still within the grace period, caller must hold the lock
+func (km *KeyManager) pruneRetired(now time.Time) []*signingKey {
+
+	ks := km.retired[:0]
+	for _, k := range km.retired {
+		if now.Sub(k.retiredAt) < km.grace {
+			ks = append(ks, k)
+		}
+	}
+
+	return ks
+}
+
+func generateSigningKey() (*signingKey, error) {
+
+	pk, e := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if e != nil {
+		return nil, fmt.Errorf("failed to generate signing key %v", e)
+	}
+
+	return newSigningKey(pk), nil
+}
+
+// newSigningKey identify key by its RFC 7638 JWK thumbprint
+func newSigningKey(pk *ecdsa.PrivateKey) *signingKey {
+
+	// members in lexicographic order without whitespace
+	tp := fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`,
+		keyCurve, encodeCoordinate(pk.X), encodeCoordinate(pk.Y))
+
+	h := sha256.Sum256([]byte(tp))
+
+	return &signingKey{
+		kid: base64.RawURLEncoding.EncodeToString(h[:]),
+		key: pk,
+	}
+}
+
+func encodeCoordinate(c *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(c.FillBytes(make([]byte, keyCoordinateSize)))
+}
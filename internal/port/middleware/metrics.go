@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/trevatk/go-chat/internal/metrics"
+)
+
+// unmatchedRoute route label for requests no chi route matched
+const unmatchedRoute = "unmatched"
+
+// Metrics record http request count and latency per chi route pattern
+//
+// route patterns are used instead of paths to keep label cardinality bounded
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		s := time.Now()
+		ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		rt := unmatchedRoute
+		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+			rt = rc.RoutePattern()
+		}
+
+		c := ww.Status()
+		if c == 0 {
+			c = http.StatusOK
+		}
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, rt, strconv.Itoa(c)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, rt).Observe(time.Since(s).Seconds())
+	})
+}
+
+// MetricsUnaryInterceptor record gRPC call count and latency per method
+func MetricsUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	s := time.Now()
+
+	rsp, e := handler(ctx, req)
+
+	observeGrpc(info.FullMethod, s, e)
+
+	return rsp, e
+}
+
+// MetricsStreamInterceptor record gRPC stream count, duration and open streams per method
+func MetricsStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	s := time.Now()
+
+	g := metrics.GrpcActiveStreams.WithLabelValues(info.FullMethod)
+	g.Inc()
+	defer g.Dec()
+
+	e := handler(srv, ss)
+
+	observeGrpc(info.FullMethod, s, e)
+
+	return e
+}
+
+func observeGrpc(method string, start time.Time, e error) {
+	metrics.GrpcRequests.WithLabelValues(method, status.Code(e).String()).Inc()
+	metrics.GrpcRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies proxies whose forwarded client address headers are honoured
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parse comma separated ip addresses and cidr ranges
+//
+// an empty value trusts no proxy
+func ParseTrustedProxies(v string) (TrustedProxies, error) {
+
+	var tp TrustedProxies
+
+	for _, p := range strings.Split(v, ",") {
+
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+
+		_, n, e := net.ParseCIDR(p)
+		if e != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s %v", p, e)
+		}
+
+		tp = append(tp, n)
+	}
+
+	return tp, nil
+}
+
+// contains report whether ip belongs to a trusted proxy
+func (tp TrustedProxies) contains(ip net.IP) bool {
+
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RealIP replace the remote address of requests forwarded by a trusted proxy with the client address
+//
+// headers of untrusted peers are ignored so clients can not choose the address
+// login throttling and sessions record, X-Forwarded-For is walked from the
+// nearest hop and the first untrusted address is the client
+func RealIP(tp TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if ip := tp.clientIP(r); ip != "" {
+				r.RemoteAddr = ip
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP forwarded client address or empty when the peer is not a trusted proxy
+func (tp TrustedProxies) clientIP(r *http.Request) string {
+
+	h, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		h = r.RemoteAddr
+	}
+
+	peer := net.ParseIP(h)
+	if peer == nil || !tp.contains(peer) {
+		return ""
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+
+		hops := strings.Split(strings.Join(xff, ","), ",")
+
+		for i := len(hops) - 1; i >= 0; i-- {
+
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			} else if !tp.contains(ip) {
+				return ip.String()
+			}
+		}
+
+		return ""
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/trevatk/go-chat/internal/tracing"
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// Tracing start spans for http requests and gRPC calls
+//
+// trace context is extracted from incoming headers and metadata,
+// the request logger is annotated with the trace and span id
+type Tracing struct {
+	l *zap.SugaredLogger
+
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+}
+
+// NewTracing create new tracing middleware
+func NewTracing(log *zap.Logger) *Tracing {
+	return &Tracing{
+		l:      log.Sugar(),
+		unary:  otelgrpc.UnaryServerInterceptor(),
+		stream: otelgrpc.StreamServerInterceptor(),
+	}
+}
+
+// Handler start a span per http request named after the chi route pattern
+func (t *Tracing) Handler(next http.Handler) http.Handler {
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		next.ServeHTTP(w, r.WithContext(t.withLogger(r.Context())))
+
+		// route pattern is only known once chi has matched the request
+		rt := unmatchedRoute
+		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+			rt = rc.RoutePattern()
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + rt)
+		span.SetAttributes(semconv.HTTPRoute(rt))
+	})
+
+	return otelhttp.NewHandler(h, "http.request")
+}
+
+// UnaryInterceptor start a span per gRPC call
+func (t *Tracing) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return t.unary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return handler(t.withLogger(ctx), req)
+	})
+}
+
+// StreamInterceptor start a span per gRPC stream
+func (t *Tracing) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return t.stream(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+		return handler(srv, &contextStream{ServerStream: ss, ctx: t.withLogger(ss.Context())})
+	})
+}
+
+// withLogger add the service logger annotated with the active trace to ctx
+func (t *Tracing) withLogger(ctx context.Context) context.Context {
+	return tracing.WithTraceLogger(logging.WithLogger(ctx, t.l))
+}
@@ -0,0 +1,170 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: api_keys.sql
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+const countUserAPIKeys = `-- name: CountUserAPIKeys :one
+SELECT COUNT(*)
+FROM api_keys
+WHERE user_uuid = ?
+    AND revoked_at IS NULL
+`
+
+func (q *Queries) CountUserAPIKeys(ctx context.Context, userUuid string) (int64, error) {
+	row := q.queryRow(ctx, q.countUserAPIKeysStmt, countUserAPIKeys, userUuid)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const insertAPIKey = `-- name: InsertAPIKey :one
+INSERT INTO api_keys (
+    uuid, user_uuid, name, prefix, key_hash, scopes, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+) RETURNING uuid, user_uuid, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+`
+
+type InsertAPIKeyParams struct {
+	Uuid      string
+	UserUuid  string
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) InsertAPIKey(ctx context.Context, arg *InsertAPIKeyParams) (*ApiKey, error) {
+	row := q.queryRow(ctx, q.insertAPIKeyStmt, insertAPIKey,
+		arg.Uuid,
+		arg.UserUuid,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.Uuid,
+		&i.UserUuid,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const listUserAPIKeys = `-- name: ListUserAPIKeys :many
+SELECT uuid, user_uuid, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+FROM api_keys
+WHERE user_uuid = ?
+    AND revoked_at IS NULL
+ORDER BY created_at ASC
+`
+
+// unrevoked api keys of user including expired keys
+func (q *Queries) ListUserAPIKeys(ctx context.Context, userUuid string) ([]*ApiKey, error) {
+	rows, err := q.query(ctx, q.listUserAPIKeysStmt, listUserAPIKeys, userUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ApiKey{}
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.UserUuid,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const readAPIKeyByHash = `-- name: ReadAPIKeyByHash :one
+SELECT uuid, user_uuid, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+FROM api_keys
+WHERE key_hash = ?
+`
+
+func (q *Queries) ReadAPIKeyByHash(ctx context.Context, keyHash string) (*ApiKey, error) {
+	row := q.queryRow(ctx, q.readAPIKeyByHashStmt, readAPIKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.Uuid,
+		&i.UserUuid,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :execresult
+UPDATE api_keys
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND user_uuid = ?
+    AND revoked_at IS NULL
+`
+
+type RevokeAPIKeyParams struct {
+	Uuid     string
+	UserUuid string
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg *RevokeAPIKeyParams) (sql.Result, error) {
+	return q.exec(ctx, q.revokeAPIKeyStmt, revokeAPIKey, arg.Uuid, arg.UserUuid)
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = ?
+WHERE uuid = ?
+`
+
+type TouchAPIKeyParams struct {
+	LastUsedAt sql.NullTime
+	Uuid       string
+}
+
+// record use of api key
+func (q *Queries) TouchAPIKey(ctx context.Context, arg *TouchAPIKeyParams) error {
+	_, err := q.exec(ctx, q.touchAPIKeyStmt, touchAPIKey, arg.LastUsedAt, arg.Uuid)
+	return err
+}
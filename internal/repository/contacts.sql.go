@@ -101,7 +101,7 @@ func (q *Queries) ReadContact(ctx context.Context, uuid string) (*Contact, error
 }
 
 const searchContacts = `-- name: SearchContacts :many
-SELECT contacts.uuid, origin_uuid, recipient_uuid, contacts.created_at, users.uuid, usernm, email, users.created_at, updated_at, pssword
+SELECT contacts.uuid, origin_uuid, recipient_uuid, contacts.created_at, users.uuid, usernm, email, users.created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
 FROM contacts 
 JOIN users
     ON contacts.recipient_uuid = users.uuid
@@ -116,16 +116,21 @@ type SearchContactsParams struct {
 }
 
 type SearchContactsRow struct {
-	Uuid          string
-	OriginUuid    string
-	RecipientUuid string
-	CreatedAt     time.Time
-	Uuid_2        string
-	Usernm        string
-	Email         string
-	CreatedAt_2   time.Time
-	UpdatedAt     sql.NullTime
-	Pssword       string
+	Uuid            string
+	OriginUuid      string
+	RecipientUuid   string
+	CreatedAt       time.Time
+	Uuid_2          string
+	Usernm          string
+	Email           string
+	CreatedAt_2     time.Time
+	UpdatedAt       sql.NullTime
+	Pssword         string
+	EmailVerifiedAt sql.NullTime
+	UserType        string
+	OwnerUuid       sql.NullString
+	Role            string
+	DisabledAt      sql.NullTime
 }
 
 // retrieve all contacts by name and email
@@ -149,6 +154,11 @@ func (q *Queries) SearchContacts(ctx context.Context, arg *SearchContactsParams)
 			&i.CreatedAt_2,
 			&i.UpdatedAt,
 			&i.Pssword,
+			&i.EmailVerifiedAt,
+			&i.UserType,
+			&i.OwnerUuid,
+			&i.Role,
+			&i.DisabledAt,
 		); err != nil {
 			return nil, err
 		}
@@ -24,64 +24,409 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.advanceLastReadStmt, err = db.PrepareContext(ctx, advanceLastRead); err != nil {
+		return nil, fmt.Errorf("error preparing query AdvanceLastRead: %w", err)
+	}
+	if q.attemptLoginChallengeStmt, err = db.PrepareContext(ctx, attemptLoginChallenge); err != nil {
+		return nil, fmt.Errorf("error preparing query AttemptLoginChallenge: %w", err)
+	}
+	if q.confirmUserTOTPStmt, err = db.PrepareContext(ctx, confirmUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query ConfirmUserTOTP: %w", err)
+	}
+	if q.countAdminsStmt, err = db.PrepareContext(ctx, countAdmins); err != nil {
+		return nil, fmt.Errorf("error preparing query CountAdmins: %w", err)
+	}
+	if q.countConversationMembersStmt, err = db.PrepareContext(ctx, countConversationMembers); err != nil {
+		return nil, fmt.Errorf("error preparing query CountConversationMembers: %w", err)
+	}
+	if q.countUserAPIKeysStmt, err = db.PrepareContext(ctx, countUserAPIKeys); err != nil {
+		return nil, fmt.Errorf("error preparing query CountUserAPIKeys: %w", err)
+	}
 	if q.deleteContactStmt, err = db.PrepareContext(ctx, deleteContact); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteContact: %w", err)
 	}
+	if q.deleteExpiredRevocationsStmt, err = db.PrepareContext(ctx, deleteExpiredRevocations); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteExpiredRevocations: %w", err)
+	}
+	if q.deleteLoginThrottleStmt, err = db.PrepareContext(ctx, deleteLoginThrottle); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteLoginThrottle: %w", err)
+	}
+	if q.deletePasswordResetRequestsBeforeStmt, err = db.PrepareContext(ctx, deletePasswordResetRequestsBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query DeletePasswordResetRequestsBefore: %w", err)
+	}
+	if q.deleteUserRecoveryCodesStmt, err = db.PrepareContext(ctx, deleteUserRecoveryCodes); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteUserRecoveryCodes: %w", err)
+	}
+	if q.deleteUserTOTPStmt, err = db.PrepareContext(ctx, deleteUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteUserTOTP: %w", err)
+	}
+	if q.disableUserStmt, err = db.PrepareContext(ctx, disableUser); err != nil {
+		return nil, fmt.Errorf("error preparing query DisableUser: %w", err)
+	}
+	if q.enableUserStmt, err = db.PrepareContext(ctx, enableUser); err != nil {
+		return nil, fmt.Errorf("error preparing query EnableUser: %w", err)
+	}
+	if q.incrementConversationSeqStmt, err = db.PrepareContext(ctx, incrementConversationSeq); err != nil {
+		return nil, fmt.Errorf("error preparing query IncrementConversationSeq: %w", err)
+	}
+	if q.incrementInviteUsesStmt, err = db.PrepareContext(ctx, incrementInviteUses); err != nil {
+		return nil, fmt.Errorf("error preparing query IncrementInviteUses: %w", err)
+	}
+	if q.insertAPIKeyStmt, err = db.PrepareContext(ctx, insertAPIKey); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertAPIKey: %w", err)
+	}
+	if q.insertAuditEventStmt, err = db.PrepareContext(ctx, insertAuditEvent); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertAuditEvent: %w", err)
+	}
+	if q.insertBotUserStmt, err = db.PrepareContext(ctx, insertBotUser); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertBotUser: %w", err)
+	}
 	if q.insertContactStmt, err = db.PrepareContext(ctx, insertContact); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertContact: %w", err)
 	}
 	if q.insertConversationStmt, err = db.PrepareContext(ctx, insertConversation); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertConversation: %w", err)
 	}
+	if q.insertEmailVerificationStmt, err = db.PrepareContext(ctx, insertEmailVerification); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertEmailVerification: %w", err)
+	}
+	if q.insertInviteStmt, err = db.PrepareContext(ctx, insertInvite); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertInvite: %w", err)
+	}
+	if q.insertInviteRedemptionStmt, err = db.PrepareContext(ctx, insertInviteRedemption); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertInviteRedemption: %w", err)
+	}
+	if q.insertLoginChallengeStmt, err = db.PrepareContext(ctx, insertLoginChallenge); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertLoginChallenge: %w", err)
+	}
 	if q.insertMMConversationUserStmt, err = db.PrepareContext(ctx, insertMMConversationUser); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertMMConversationUser: %w", err)
 	}
 	if q.insertMessageStmt, err = db.PrepareContext(ctx, insertMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertMessage: %w", err)
 	}
+	if q.insertOIDCLoginStmt, err = db.PrepareContext(ctx, insertOIDCLogin); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertOIDCLogin: %w", err)
+	}
+	if q.insertPasswordResetStmt, err = db.PrepareContext(ctx, insertPasswordReset); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertPasswordReset: %w", err)
+	}
+	if q.insertPasswordResetRequestStmt, err = db.PrepareContext(ctx, insertPasswordResetRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertPasswordResetRequest: %w", err)
+	}
+	if q.insertRecoveryCodeStmt, err = db.PrepareContext(ctx, insertRecoveryCode); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertRecoveryCode: %w", err)
+	}
+	if q.insertRefreshTokenStmt, err = db.PrepareContext(ctx, insertRefreshToken); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertRefreshToken: %w", err)
+	}
+	if q.insertRevocationStmt, err = db.PrepareContext(ctx, insertRevocation); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertRevocation: %w", err)
+	}
+	if q.insertSessionStmt, err = db.PrepareContext(ctx, insertSession); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertSession: %w", err)
+	}
 	if q.insertUserStmt, err = db.PrepareContext(ctx, insertUser); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertUser: %w", err)
 	}
+	if q.insertUserIdentityStmt, err = db.PrepareContext(ctx, insertUserIdentity); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertUserIdentity: %w", err)
+	}
+	if q.listOwnedBotsStmt, err = db.PrepareContext(ctx, listOwnedBots); err != nil {
+		return nil, fmt.Errorf("error preparing query ListOwnedBots: %w", err)
+	}
+	if q.listUserAPIKeysStmt, err = db.PrepareContext(ctx, listUserAPIKeys); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUserAPIKeys: %w", err)
+	}
+	if q.listUsersStmt, err = db.PrepareContext(ctx, listUsers); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsers: %w", err)
+	}
+	if q.listUsersByRoleStmt, err = db.PrepareContext(ctx, listUsersByRole); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsersByRole: %w", err)
+	}
+	if q.promoteUserByUsernameStmt, err = db.PrepareContext(ctx, promoteUserByUsername); err != nil {
+		return nil, fmt.Errorf("error preparing query PromoteUserByUsername: %w", err)
+	}
+	if q.readAPIKeyByHashStmt, err = db.PrepareContext(ctx, readAPIKeyByHash); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAPIKeyByHash: %w", err)
+	}
 	if q.readAllContactsStmt, err = db.PrepareContext(ctx, readAllContacts); err != nil {
 		return nil, fmt.Errorf("error preparing query ReadAllContacts: %w", err)
 	}
-	if q.readAllConversationsStmt, err = db.PrepareContext(ctx, readAllConversations); err != nil {
-		return nil, fmt.Errorf("error preparing query ReadAllConversations: %w", err)
+	if q.readAllConversationMembersStmt, err = db.PrepareContext(ctx, readAllConversationMembers); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAllConversationMembers: %w", err)
 	}
-	if q.readAllMessagesStmt, err = db.PrepareContext(ctx, readAllMessages); err != nil {
-		return nil, fmt.Errorf("error preparing query ReadAllMessages: %w", err)
+	if q.readAllInviteRedemptionsStmt, err = db.PrepareContext(ctx, readAllInviteRedemptions); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAllInviteRedemptions: %w", err)
+	}
+	if q.readAllInvitesStmt, err = db.PrepareContext(ctx, readAllInvites); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAllInvites: %w", err)
+	}
+	if q.readAllRevocationsStmt, err = db.PrepareContext(ctx, readAllRevocations); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAllRevocations: %w", err)
+	}
+	if q.readAllUserSessionsStmt, err = db.PrepareContext(ctx, readAllUserSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadAllUserSessions: %w", err)
 	}
 	if q.readContactStmt, err = db.PrepareContext(ctx, readContact); err != nil {
 		return nil, fmt.Errorf("error preparing query ReadContact: %w", err)
 	}
+	if q.readConversationStmt, err = db.PrepareContext(ctx, readConversation); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadConversation: %w", err)
+	}
+	if q.readConversationInviteStmt, err = db.PrepareContext(ctx, readConversationInvite); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadConversationInvite: %w", err)
+	}
+	if q.readConversationMemberStmt, err = db.PrepareContext(ctx, readConversationMember); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadConversationMember: %w", err)
+	}
+	if q.readEmailVerificationStmt, err = db.PrepareContext(ctx, readEmailVerification); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadEmailVerification: %w", err)
+	}
+	if q.readInboxStmt, err = db.PrepareContext(ctx, readInbox); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadInbox: %w", err)
+	}
+	if q.readInviteByTokenHashStmt, err = db.PrepareContext(ctx, readInviteByTokenHash); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadInviteByTokenHash: %w", err)
+	}
+	if q.readLoginChallengeStmt, err = db.PrepareContext(ctx, readLoginChallenge); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadLoginChallenge: %w", err)
+	}
+	if q.readLoginThrottleStmt, err = db.PrepareContext(ctx, readLoginThrottle); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadLoginThrottle: %w", err)
+	}
+	if q.readMessageStmt, err = db.PrepareContext(ctx, readMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadMessage: %w", err)
+	}
+	if q.readMessageByClientIDStmt, err = db.PrepareContext(ctx, readMessageByClientID); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadMessageByClientID: %w", err)
+	}
+	if q.readMessagesAfterSeqStmt, err = db.PrepareContext(ctx, readMessagesAfterSeq); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadMessagesAfterSeq: %w", err)
+	}
+	if q.readOIDCLoginStmt, err = db.PrepareContext(ctx, readOIDCLogin); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadOIDCLogin: %w", err)
+	}
+	if q.readPasswordResetStmt, err = db.PrepareContext(ctx, readPasswordReset); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadPasswordReset: %w", err)
+	}
+	if q.readPinnedInboxStmt, err = db.PrepareContext(ctx, readPinnedInbox); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadPinnedInbox: %w", err)
+	}
+	if q.readRecentEmailVerificationsStmt, err = db.PrepareContext(ctx, readRecentEmailVerifications); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadRecentEmailVerifications: %w", err)
+	}
+	if q.readRecentPasswordResetRequestsStmt, err = db.PrepareContext(ctx, readRecentPasswordResetRequests); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadRecentPasswordResetRequests: %w", err)
+	}
+	if q.readRefreshTokenStmt, err = db.PrepareContext(ctx, readRefreshToken); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadRefreshToken: %w", err)
+	}
+	if q.readSessionStmt, err = db.PrepareContext(ctx, readSession); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadSession: %w", err)
+	}
 	if q.readUserStmt, err = db.PrepareContext(ctx, readUser); err != nil {
 		return nil, fmt.Errorf("error preparing query ReadUser: %w", err)
 	}
+	if q.readUserByEmailStmt, err = db.PrepareContext(ctx, readUserByEmail); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadUserByEmail: %w", err)
+	}
 	if q.readUserDetailsStmt, err = db.PrepareContext(ctx, readUserDetails); err != nil {
 		return nil, fmt.Errorf("error preparing query ReadUserDetails: %w", err)
 	}
+	if q.readUserIdentityStmt, err = db.PrepareContext(ctx, readUserIdentity); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadUserIdentity: %w", err)
+	}
 	if q.readUserLoginDetailsStmt, err = db.PrepareContext(ctx, readUserLoginDetails); err != nil {
 		return nil, fmt.Errorf("error preparing query ReadUserLoginDetails: %w", err)
 	}
+	if q.readUserTOTPStmt, err = db.PrepareContext(ctx, readUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query ReadUserTOTP: %w", err)
+	}
+	if q.resetEmailVerificationStmt, err = db.PrepareContext(ctx, resetEmailVerification); err != nil {
+		return nil, fmt.Errorf("error preparing query ResetEmailVerification: %w", err)
+	}
+	if q.revokeAPIKeyStmt, err = db.PrepareContext(ctx, revokeAPIKey); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeAPIKey: %w", err)
+	}
+	if q.revokeAllUserSessionsStmt, err = db.PrepareContext(ctx, revokeAllUserSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeAllUserSessions: %w", err)
+	}
+	if q.revokeInviteStmt, err = db.PrepareContext(ctx, revokeInvite); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeInvite: %w", err)
+	}
+	if q.revokeOtherUserSessionsStmt, err = db.PrepareContext(ctx, revokeOtherUserSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeOtherUserSessions: %w", err)
+	}
+	if q.revokeSessionStmt, err = db.PrepareContext(ctx, revokeSession); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeSession: %w", err)
+	}
 	if q.searchContactsStmt, err = db.PrepareContext(ctx, searchContacts); err != nil {
 		return nil, fmt.Errorf("error preparing query SearchContacts: %w", err)
 	}
 	if q.searchUserDetailsStmt, err = db.PrepareContext(ctx, searchUserDetails); err != nil {
 		return nil, fmt.Errorf("error preparing query SearchUserDetails: %w", err)
 	}
+	if q.touchAPIKeyStmt, err = db.PrepareContext(ctx, touchAPIKey); err != nil {
+		return nil, fmt.Errorf("error preparing query TouchAPIKey: %w", err)
+	}
+	if q.touchSessionStmt, err = db.PrepareContext(ctx, touchSession); err != nil {
+		return nil, fmt.Errorf("error preparing query TouchSession: %w", err)
+	}
+	if q.updateConversationActivityStmt, err = db.PrepareContext(ctx, updateConversationActivity); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateConversationActivity: %w", err)
+	}
+	if q.updateConversationPreferencesStmt, err = db.PrepareContext(ctx, updateConversationPreferences); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateConversationPreferences: %w", err)
+	}
+	if q.updateLastReadStmt, err = db.PrepareContext(ctx, updateLastRead); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateLastRead: %w", err)
+	}
 	if q.updateUserStmt, err = db.PrepareContext(ctx, updateUser); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateUser: %w", err)
 	}
+	if q.updateUserPasswordStmt, err = db.PrepareContext(ctx, updateUserPassword); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateUserPassword: %w", err)
+	}
+	if q.updateUserRoleStmt, err = db.PrepareContext(ctx, updateUserRole); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateUserRole: %w", err)
+	}
+	if q.upsertLoginThrottleStmt, err = db.PrepareContext(ctx, upsertLoginThrottle); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertLoginThrottle: %w", err)
+	}
+	if q.upsertUserTOTPStmt, err = db.PrepareContext(ctx, upsertUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertUserTOTP: %w", err)
+	}
+	if q.useAllUserEmailVerificationsStmt, err = db.PrepareContext(ctx, useAllUserEmailVerifications); err != nil {
+		return nil, fmt.Errorf("error preparing query UseAllUserEmailVerifications: %w", err)
+	}
+	if q.useAllUserPasswordResetsStmt, err = db.PrepareContext(ctx, useAllUserPasswordResets); err != nil {
+		return nil, fmt.Errorf("error preparing query UseAllUserPasswordResets: %w", err)
+	}
+	if q.useEmailVerificationStmt, err = db.PrepareContext(ctx, useEmailVerification); err != nil {
+		return nil, fmt.Errorf("error preparing query UseEmailVerification: %w", err)
+	}
+	if q.useLoginChallengeStmt, err = db.PrepareContext(ctx, useLoginChallenge); err != nil {
+		return nil, fmt.Errorf("error preparing query UseLoginChallenge: %w", err)
+	}
+	if q.useOIDCLoginStmt, err = db.PrepareContext(ctx, useOIDCLogin); err != nil {
+		return nil, fmt.Errorf("error preparing query UseOIDCLogin: %w", err)
+	}
+	if q.usePasswordResetStmt, err = db.PrepareContext(ctx, usePasswordReset); err != nil {
+		return nil, fmt.Errorf("error preparing query UsePasswordReset: %w", err)
+	}
+	if q.useRecoveryCodeStmt, err = db.PrepareContext(ctx, useRecoveryCode); err != nil {
+		return nil, fmt.Errorf("error preparing query UseRecoveryCode: %w", err)
+	}
+	if q.useRefreshTokenStmt, err = db.PrepareContext(ctx, useRefreshToken); err != nil {
+		return nil, fmt.Errorf("error preparing query UseRefreshToken: %w", err)
+	}
+	if q.useTOTPStepStmt, err = db.PrepareContext(ctx, useTOTPStep); err != nil {
+		return nil, fmt.Errorf("error preparing query UseTOTPStep: %w", err)
+	}
+	if q.verifyUserEmailStmt, err = db.PrepareContext(ctx, verifyUserEmail); err != nil {
+		return nil, fmt.Errorf("error preparing query VerifyUserEmail: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.advanceLastReadStmt != nil {
+		if cerr := q.advanceLastReadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing advanceLastReadStmt: %w", cerr)
+		}
+	}
+	if q.attemptLoginChallengeStmt != nil {
+		if cerr := q.attemptLoginChallengeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing attemptLoginChallengeStmt: %w", cerr)
+		}
+	}
+	if q.confirmUserTOTPStmt != nil {
+		if cerr := q.confirmUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing confirmUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.countAdminsStmt != nil {
+		if cerr := q.countAdminsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countAdminsStmt: %w", cerr)
+		}
+	}
+	if q.countConversationMembersStmt != nil {
+		if cerr := q.countConversationMembersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countConversationMembersStmt: %w", cerr)
+		}
+	}
+	if q.countUserAPIKeysStmt != nil {
+		if cerr := q.countUserAPIKeysStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countUserAPIKeysStmt: %w", cerr)
+		}
+	}
 	if q.deleteContactStmt != nil {
 		if cerr := q.deleteContactStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteContactStmt: %w", cerr)
 		}
 	}
+	if q.deleteExpiredRevocationsStmt != nil {
+		if cerr := q.deleteExpiredRevocationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteExpiredRevocationsStmt: %w", cerr)
+		}
+	}
+	if q.deleteLoginThrottleStmt != nil {
+		if cerr := q.deleteLoginThrottleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteLoginThrottleStmt: %w", cerr)
+		}
+	}
+	if q.deletePasswordResetRequestsBeforeStmt != nil {
+		if cerr := q.deletePasswordResetRequestsBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deletePasswordResetRequestsBeforeStmt: %w", cerr)
+		}
+	}
+	if q.deleteUserRecoveryCodesStmt != nil {
+		if cerr := q.deleteUserRecoveryCodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteUserRecoveryCodesStmt: %w", cerr)
+		}
+	}
+	if q.deleteUserTOTPStmt != nil {
+		if cerr := q.deleteUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.disableUserStmt != nil {
+		if cerr := q.disableUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing disableUserStmt: %w", cerr)
+		}
+	}
+	if q.enableUserStmt != nil {
+		if cerr := q.enableUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing enableUserStmt: %w", cerr)
+		}
+	}
+	if q.incrementConversationSeqStmt != nil {
+		if cerr := q.incrementConversationSeqStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing incrementConversationSeqStmt: %w", cerr)
+		}
+	}
+	if q.incrementInviteUsesStmt != nil {
+		if cerr := q.incrementInviteUsesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing incrementInviteUsesStmt: %w", cerr)
+		}
+	}
+	if q.insertAPIKeyStmt != nil {
+		if cerr := q.insertAPIKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertAPIKeyStmt: %w", cerr)
+		}
+	}
+	if q.insertAuditEventStmt != nil {
+		if cerr := q.insertAuditEventStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertAuditEventStmt: %w", cerr)
+		}
+	}
+	if q.insertBotUserStmt != nil {
+		if cerr := q.insertBotUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertBotUserStmt: %w", cerr)
+		}
+	}
 	if q.insertContactStmt != nil {
 		if cerr := q.insertContactStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertContactStmt: %w", cerr)
@@ -92,6 +437,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing insertConversationStmt: %w", cerr)
 		}
 	}
+	if q.insertEmailVerificationStmt != nil {
+		if cerr := q.insertEmailVerificationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertEmailVerificationStmt: %w", cerr)
+		}
+	}
+	if q.insertInviteStmt != nil {
+		if cerr := q.insertInviteStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertInviteStmt: %w", cerr)
+		}
+	}
+	if q.insertInviteRedemptionStmt != nil {
+		if cerr := q.insertInviteRedemptionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertInviteRedemptionStmt: %w", cerr)
+		}
+	}
+	if q.insertLoginChallengeStmt != nil {
+		if cerr := q.insertLoginChallengeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertLoginChallengeStmt: %w", cerr)
+		}
+	}
 	if q.insertMMConversationUserStmt != nil {
 		if cerr := q.insertMMConversationUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertMMConversationUserStmt: %w", cerr)
@@ -102,24 +467,109 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing insertMessageStmt: %w", cerr)
 		}
 	}
+	if q.insertOIDCLoginStmt != nil {
+		if cerr := q.insertOIDCLoginStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertOIDCLoginStmt: %w", cerr)
+		}
+	}
+	if q.insertPasswordResetStmt != nil {
+		if cerr := q.insertPasswordResetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertPasswordResetStmt: %w", cerr)
+		}
+	}
+	if q.insertPasswordResetRequestStmt != nil {
+		if cerr := q.insertPasswordResetRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertPasswordResetRequestStmt: %w", cerr)
+		}
+	}
+	if q.insertRecoveryCodeStmt != nil {
+		if cerr := q.insertRecoveryCodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertRecoveryCodeStmt: %w", cerr)
+		}
+	}
+	if q.insertRefreshTokenStmt != nil {
+		if cerr := q.insertRefreshTokenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertRefreshTokenStmt: %w", cerr)
+		}
+	}
+	if q.insertRevocationStmt != nil {
+		if cerr := q.insertRevocationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertRevocationStmt: %w", cerr)
+		}
+	}
+	if q.insertSessionStmt != nil {
+		if cerr := q.insertSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertSessionStmt: %w", cerr)
+		}
+	}
 	if q.insertUserStmt != nil {
 		if cerr := q.insertUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertUserStmt: %w", cerr)
 		}
 	}
+	if q.insertUserIdentityStmt != nil {
+		if cerr := q.insertUserIdentityStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertUserIdentityStmt: %w", cerr)
+		}
+	}
+	if q.listOwnedBotsStmt != nil {
+		if cerr := q.listOwnedBotsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listOwnedBotsStmt: %w", cerr)
+		}
+	}
+	if q.listUserAPIKeysStmt != nil {
+		if cerr := q.listUserAPIKeysStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUserAPIKeysStmt: %w", cerr)
+		}
+	}
+	if q.listUsersStmt != nil {
+		if cerr := q.listUsersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsersStmt: %w", cerr)
+		}
+	}
+	if q.listUsersByRoleStmt != nil {
+		if cerr := q.listUsersByRoleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsersByRoleStmt: %w", cerr)
+		}
+	}
+	if q.promoteUserByUsernameStmt != nil {
+		if cerr := q.promoteUserByUsernameStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing promoteUserByUsernameStmt: %w", cerr)
+		}
+	}
+	if q.readAPIKeyByHashStmt != nil {
+		if cerr := q.readAPIKeyByHashStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAPIKeyByHashStmt: %w", cerr)
+		}
+	}
 	if q.readAllContactsStmt != nil {
 		if cerr := q.readAllContactsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing readAllContactsStmt: %w", cerr)
 		}
 	}
-	if q.readAllConversationsStmt != nil {
-		if cerr := q.readAllConversationsStmt.Close(); cerr != nil {
-			err = fmt.Errorf("error closing readAllConversationsStmt: %w", cerr)
+	if q.readAllConversationMembersStmt != nil {
+		if cerr := q.readAllConversationMembersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAllConversationMembersStmt: %w", cerr)
+		}
+	}
+	if q.readAllInviteRedemptionsStmt != nil {
+		if cerr := q.readAllInviteRedemptionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAllInviteRedemptionsStmt: %w", cerr)
+		}
+	}
+	if q.readAllInvitesStmt != nil {
+		if cerr := q.readAllInvitesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAllInvitesStmt: %w", cerr)
+		}
+	}
+	if q.readAllRevocationsStmt != nil {
+		if cerr := q.readAllRevocationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAllRevocationsStmt: %w", cerr)
 		}
 	}
-	if q.readAllMessagesStmt != nil {
-		if cerr := q.readAllMessagesStmt.Close(); cerr != nil {
-			err = fmt.Errorf("error closing readAllMessagesStmt: %w", cerr)
+	if q.readAllUserSessionsStmt != nil {
+		if cerr := q.readAllUserSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readAllUserSessionsStmt: %w", cerr)
 		}
 	}
 	if q.readContactStmt != nil {
@@ -127,21 +577,156 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing readContactStmt: %w", cerr)
 		}
 	}
+	if q.readConversationStmt != nil {
+		if cerr := q.readConversationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readConversationStmt: %w", cerr)
+		}
+	}
+	if q.readConversationInviteStmt != nil {
+		if cerr := q.readConversationInviteStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readConversationInviteStmt: %w", cerr)
+		}
+	}
+	if q.readConversationMemberStmt != nil {
+		if cerr := q.readConversationMemberStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readConversationMemberStmt: %w", cerr)
+		}
+	}
+	if q.readEmailVerificationStmt != nil {
+		if cerr := q.readEmailVerificationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readEmailVerificationStmt: %w", cerr)
+		}
+	}
+	if q.readInboxStmt != nil {
+		if cerr := q.readInboxStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readInboxStmt: %w", cerr)
+		}
+	}
+	if q.readInviteByTokenHashStmt != nil {
+		if cerr := q.readInviteByTokenHashStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readInviteByTokenHashStmt: %w", cerr)
+		}
+	}
+	if q.readLoginChallengeStmt != nil {
+		if cerr := q.readLoginChallengeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readLoginChallengeStmt: %w", cerr)
+		}
+	}
+	if q.readLoginThrottleStmt != nil {
+		if cerr := q.readLoginThrottleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readLoginThrottleStmt: %w", cerr)
+		}
+	}
+	if q.readMessageStmt != nil {
+		if cerr := q.readMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readMessageStmt: %w", cerr)
+		}
+	}
+	if q.readMessageByClientIDStmt != nil {
+		if cerr := q.readMessageByClientIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readMessageByClientIDStmt: %w", cerr)
+		}
+	}
+	if q.readMessagesAfterSeqStmt != nil {
+		if cerr := q.readMessagesAfterSeqStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readMessagesAfterSeqStmt: %w", cerr)
+		}
+	}
+	if q.readOIDCLoginStmt != nil {
+		if cerr := q.readOIDCLoginStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readOIDCLoginStmt: %w", cerr)
+		}
+	}
+	if q.readPasswordResetStmt != nil {
+		if cerr := q.readPasswordResetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readPasswordResetStmt: %w", cerr)
+		}
+	}
+	if q.readPinnedInboxStmt != nil {
+		if cerr := q.readPinnedInboxStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readPinnedInboxStmt: %w", cerr)
+		}
+	}
+	if q.readRecentEmailVerificationsStmt != nil {
+		if cerr := q.readRecentEmailVerificationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readRecentEmailVerificationsStmt: %w", cerr)
+		}
+	}
+	if q.readRecentPasswordResetRequestsStmt != nil {
+		if cerr := q.readRecentPasswordResetRequestsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readRecentPasswordResetRequestsStmt: %w", cerr)
+		}
+	}
+	if q.readRefreshTokenStmt != nil {
+		if cerr := q.readRefreshTokenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readRefreshTokenStmt: %w", cerr)
+		}
+	}
+	if q.readSessionStmt != nil {
+		if cerr := q.readSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readSessionStmt: %w", cerr)
+		}
+	}
 	if q.readUserStmt != nil {
 		if cerr := q.readUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing readUserStmt: %w", cerr)
 		}
 	}
+	if q.readUserByEmailStmt != nil {
+		if cerr := q.readUserByEmailStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readUserByEmailStmt: %w", cerr)
+		}
+	}
 	if q.readUserDetailsStmt != nil {
 		if cerr := q.readUserDetailsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing readUserDetailsStmt: %w", cerr)
 		}
 	}
+	if q.readUserIdentityStmt != nil {
+		if cerr := q.readUserIdentityStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readUserIdentityStmt: %w", cerr)
+		}
+	}
 	if q.readUserLoginDetailsStmt != nil {
 		if cerr := q.readUserLoginDetailsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing readUserLoginDetailsStmt: %w", cerr)
 		}
 	}
+	if q.readUserTOTPStmt != nil {
+		if cerr := q.readUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing readUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.resetEmailVerificationStmt != nil {
+		if cerr := q.resetEmailVerificationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing resetEmailVerificationStmt: %w", cerr)
+		}
+	}
+	if q.revokeAPIKeyStmt != nil {
+		if cerr := q.revokeAPIKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeAPIKeyStmt: %w", cerr)
+		}
+	}
+	if q.revokeAllUserSessionsStmt != nil {
+		if cerr := q.revokeAllUserSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeAllUserSessionsStmt: %w", cerr)
+		}
+	}
+	if q.revokeInviteStmt != nil {
+		if cerr := q.revokeInviteStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeInviteStmt: %w", cerr)
+		}
+	}
+	if q.revokeOtherUserSessionsStmt != nil {
+		if cerr := q.revokeOtherUserSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeOtherUserSessionsStmt: %w", cerr)
+		}
+	}
+	if q.revokeSessionStmt != nil {
+		if cerr := q.revokeSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeSessionStmt: %w", cerr)
+		}
+	}
 	if q.searchContactsStmt != nil {
 		if cerr := q.searchContactsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing searchContactsStmt: %w", cerr)
@@ -152,11 +737,106 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing searchUserDetailsStmt: %w", cerr)
 		}
 	}
+	if q.touchAPIKeyStmt != nil {
+		if cerr := q.touchAPIKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing touchAPIKeyStmt: %w", cerr)
+		}
+	}
+	if q.touchSessionStmt != nil {
+		if cerr := q.touchSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing touchSessionStmt: %w", cerr)
+		}
+	}
+	if q.updateConversationActivityStmt != nil {
+		if cerr := q.updateConversationActivityStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateConversationActivityStmt: %w", cerr)
+		}
+	}
+	if q.updateConversationPreferencesStmt != nil {
+		if cerr := q.updateConversationPreferencesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateConversationPreferencesStmt: %w", cerr)
+		}
+	}
+	if q.updateLastReadStmt != nil {
+		if cerr := q.updateLastReadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateLastReadStmt: %w", cerr)
+		}
+	}
 	if q.updateUserStmt != nil {
 		if cerr := q.updateUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateUserStmt: %w", cerr)
 		}
 	}
+	if q.updateUserPasswordStmt != nil {
+		if cerr := q.updateUserPasswordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateUserPasswordStmt: %w", cerr)
+		}
+	}
+	if q.updateUserRoleStmt != nil {
+		if cerr := q.updateUserRoleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateUserRoleStmt: %w", cerr)
+		}
+	}
+	if q.upsertLoginThrottleStmt != nil {
+		if cerr := q.upsertLoginThrottleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertLoginThrottleStmt: %w", cerr)
+		}
+	}
+	if q.upsertUserTOTPStmt != nil {
+		if cerr := q.upsertUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.useAllUserEmailVerificationsStmt != nil {
+		if cerr := q.useAllUserEmailVerificationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useAllUserEmailVerificationsStmt: %w", cerr)
+		}
+	}
+	if q.useAllUserPasswordResetsStmt != nil {
+		if cerr := q.useAllUserPasswordResetsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useAllUserPasswordResetsStmt: %w", cerr)
+		}
+	}
+	if q.useEmailVerificationStmt != nil {
+		if cerr := q.useEmailVerificationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useEmailVerificationStmt: %w", cerr)
+		}
+	}
+	if q.useLoginChallengeStmt != nil {
+		if cerr := q.useLoginChallengeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useLoginChallengeStmt: %w", cerr)
+		}
+	}
+	if q.useOIDCLoginStmt != nil {
+		if cerr := q.useOIDCLoginStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useOIDCLoginStmt: %w", cerr)
+		}
+	}
+	if q.usePasswordResetStmt != nil {
+		if cerr := q.usePasswordResetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing usePasswordResetStmt: %w", cerr)
+		}
+	}
+	if q.useRecoveryCodeStmt != nil {
+		if cerr := q.useRecoveryCodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useRecoveryCodeStmt: %w", cerr)
+		}
+	}
+	if q.useRefreshTokenStmt != nil {
+		if cerr := q.useRefreshTokenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useRefreshTokenStmt: %w", cerr)
+		}
+	}
+	if q.useTOTPStepStmt != nil {
+		if cerr := q.useTOTPStepStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useTOTPStepStmt: %w", cerr)
+		}
+	}
+	if q.verifyUserEmailStmt != nil {
+		if cerr := q.verifyUserEmailStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing verifyUserEmailStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -194,45 +874,215 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                           DBTX
-	tx                           *sql.Tx
-	deleteContactStmt            *sql.Stmt
-	insertContactStmt            *sql.Stmt
-	insertConversationStmt       *sql.Stmt
-	insertMMConversationUserStmt *sql.Stmt
-	insertMessageStmt            *sql.Stmt
-	insertUserStmt               *sql.Stmt
-	readAllContactsStmt          *sql.Stmt
-	readAllConversationsStmt     *sql.Stmt
-	readAllMessagesStmt          *sql.Stmt
-	readContactStmt              *sql.Stmt
-	readUserStmt                 *sql.Stmt
-	readUserDetailsStmt          *sql.Stmt
-	readUserLoginDetailsStmt     *sql.Stmt
-	searchContactsStmt           *sql.Stmt
-	searchUserDetailsStmt        *sql.Stmt
-	updateUserStmt               *sql.Stmt
+	db                                    DBTX
+	tx                                    *sql.Tx
+	advanceLastReadStmt                   *sql.Stmt
+	attemptLoginChallengeStmt             *sql.Stmt
+	confirmUserTOTPStmt                   *sql.Stmt
+	countAdminsStmt                       *sql.Stmt
+	countConversationMembersStmt          *sql.Stmt
+	countUserAPIKeysStmt                  *sql.Stmt
+	deleteContactStmt                     *sql.Stmt
+	deleteExpiredRevocationsStmt          *sql.Stmt
+	deleteLoginThrottleStmt               *sql.Stmt
+	deletePasswordResetRequestsBeforeStmt *sql.Stmt
+	deleteUserRecoveryCodesStmt           *sql.Stmt
+	deleteUserTOTPStmt                    *sql.Stmt
+	disableUserStmt                       *sql.Stmt
+	enableUserStmt                        *sql.Stmt
+	incrementConversationSeqStmt          *sql.Stmt
+	incrementInviteUsesStmt               *sql.Stmt
+	insertAPIKeyStmt                      *sql.Stmt
+	insertAuditEventStmt                  *sql.Stmt
+	insertBotUserStmt                     *sql.Stmt
+	insertContactStmt                     *sql.Stmt
+	insertConversationStmt                *sql.Stmt
+	insertEmailVerificationStmt           *sql.Stmt
+	insertInviteStmt                      *sql.Stmt
+	insertInviteRedemptionStmt            *sql.Stmt
+	insertLoginChallengeStmt              *sql.Stmt
+	insertMMConversationUserStmt          *sql.Stmt
+	insertMessageStmt                     *sql.Stmt
+	insertOIDCLoginStmt                   *sql.Stmt
+	insertPasswordResetStmt               *sql.Stmt
+	insertPasswordResetRequestStmt        *sql.Stmt
+	insertRecoveryCodeStmt                *sql.Stmt
+	insertRefreshTokenStmt                *sql.Stmt
+	insertRevocationStmt                  *sql.Stmt
+	insertSessionStmt                     *sql.Stmt
+	insertUserStmt                        *sql.Stmt
+	insertUserIdentityStmt                *sql.Stmt
+	listOwnedBotsStmt                     *sql.Stmt
+	listUserAPIKeysStmt                   *sql.Stmt
+	listUsersStmt                         *sql.Stmt
+	listUsersByRoleStmt                   *sql.Stmt
+	promoteUserByUsernameStmt             *sql.Stmt
+	readAPIKeyByHashStmt                  *sql.Stmt
+	readAllContactsStmt                   *sql.Stmt
+	readAllConversationMembersStmt        *sql.Stmt
+	readAllInviteRedemptionsStmt          *sql.Stmt
+	readAllInvitesStmt                    *sql.Stmt
+	readAllRevocationsStmt                *sql.Stmt
+	readAllUserSessionsStmt               *sql.Stmt
+	readContactStmt                       *sql.Stmt
+	readConversationStmt                  *sql.Stmt
+	readConversationInviteStmt            *sql.Stmt
+	readConversationMemberStmt            *sql.Stmt
+	readEmailVerificationStmt             *sql.Stmt
+	readInboxStmt                         *sql.Stmt
+	readInviteByTokenHashStmt             *sql.Stmt
+	readLoginChallengeStmt                *sql.Stmt
+	readLoginThrottleStmt                 *sql.Stmt
+	readMessageStmt                       *sql.Stmt
+	readMessageByClientIDStmt             *sql.Stmt
+	readMessagesAfterSeqStmt              *sql.Stmt
+	readOIDCLoginStmt                     *sql.Stmt
+	readPasswordResetStmt                 *sql.Stmt
+	readPinnedInboxStmt                   *sql.Stmt
+	readRecentEmailVerificationsStmt      *sql.Stmt
+	readRecentPasswordResetRequestsStmt   *sql.Stmt
+	readRefreshTokenStmt                  *sql.Stmt
+	readSessionStmt                       *sql.Stmt
+	readUserStmt                          *sql.Stmt
+	readUserByEmailStmt                   *sql.Stmt
+	readUserDetailsStmt                   *sql.Stmt
+	readUserIdentityStmt                  *sql.Stmt
+	readUserLoginDetailsStmt              *sql.Stmt
+	readUserTOTPStmt                      *sql.Stmt
+	resetEmailVerificationStmt            *sql.Stmt
+	revokeAPIKeyStmt                      *sql.Stmt
+	revokeAllUserSessionsStmt             *sql.Stmt
+	revokeInviteStmt                      *sql.Stmt
+	revokeOtherUserSessionsStmt           *sql.Stmt
+	revokeSessionStmt                     *sql.Stmt
+	searchContactsStmt                    *sql.Stmt
+	searchUserDetailsStmt                 *sql.Stmt
+	touchAPIKeyStmt                       *sql.Stmt
+	touchSessionStmt                      *sql.Stmt
+	updateConversationActivityStmt        *sql.Stmt
+	updateConversationPreferencesStmt     *sql.Stmt
+	updateLastReadStmt                    *sql.Stmt
+	updateUserStmt                        *sql.Stmt
+	updateUserPasswordStmt                *sql.Stmt
+	updateUserRoleStmt                    *sql.Stmt
+	upsertLoginThrottleStmt               *sql.Stmt
+	upsertUserTOTPStmt                    *sql.Stmt
+	useAllUserEmailVerificationsStmt      *sql.Stmt
+	useAllUserPasswordResetsStmt          *sql.Stmt
+	useEmailVerificationStmt              *sql.Stmt
+	useLoginChallengeStmt                 *sql.Stmt
+	useOIDCLoginStmt                      *sql.Stmt
+	usePasswordResetStmt                  *sql.Stmt
+	useRecoveryCodeStmt                   *sql.Stmt
+	useRefreshTokenStmt                   *sql.Stmt
+	useTOTPStepStmt                       *sql.Stmt
+	verifyUserEmailStmt                   *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                           tx,
-		tx:                           tx,
-		deleteContactStmt:            q.deleteContactStmt,
-		insertContactStmt:            q.insertContactStmt,
-		insertConversationStmt:       q.insertConversationStmt,
-		insertMMConversationUserStmt: q.insertMMConversationUserStmt,
-		insertMessageStmt:            q.insertMessageStmt,
-		insertUserStmt:               q.insertUserStmt,
-		readAllContactsStmt:          q.readAllContactsStmt,
-		readAllConversationsStmt:     q.readAllConversationsStmt,
-		readAllMessagesStmt:          q.readAllMessagesStmt,
-		readContactStmt:              q.readContactStmt,
-		readUserStmt:                 q.readUserStmt,
-		readUserDetailsStmt:          q.readUserDetailsStmt,
-		readUserLoginDetailsStmt:     q.readUserLoginDetailsStmt,
-		searchContactsStmt:           q.searchContactsStmt,
-		searchUserDetailsStmt:        q.searchUserDetailsStmt,
-		updateUserStmt:               q.updateUserStmt,
+		db:                                    tx,
+		tx:                                    tx,
+		advanceLastReadStmt:                   q.advanceLastReadStmt,
+		attemptLoginChallengeStmt:             q.attemptLoginChallengeStmt,
+		confirmUserTOTPStmt:                   q.confirmUserTOTPStmt,
+		countAdminsStmt:                       q.countAdminsStmt,
+		countConversationMembersStmt:          q.countConversationMembersStmt,
+		countUserAPIKeysStmt:                  q.countUserAPIKeysStmt,
+		deleteContactStmt:                     q.deleteContactStmt,
+		deleteExpiredRevocationsStmt:          q.deleteExpiredRevocationsStmt,
+		deleteLoginThrottleStmt:               q.deleteLoginThrottleStmt,
+		deletePasswordResetRequestsBeforeStmt: q.deletePasswordResetRequestsBeforeStmt,
+		deleteUserRecoveryCodesStmt:           q.deleteUserRecoveryCodesStmt,
+		deleteUserTOTPStmt:                    q.deleteUserTOTPStmt,
+		disableUserStmt:                       q.disableUserStmt,
+		enableUserStmt:                        q.enableUserStmt,
+		incrementConversationSeqStmt:          q.incrementConversationSeqStmt,
+		incrementInviteUsesStmt:               q.incrementInviteUsesStmt,
+		insertAPIKeyStmt:                      q.insertAPIKeyStmt,
+		insertAuditEventStmt:                  q.insertAuditEventStmt,
+		insertBotUserStmt:                     q.insertBotUserStmt,
+		insertContactStmt:                     q.insertContactStmt,
+		insertConversationStmt:                q.insertConversationStmt,
+		insertEmailVerificationStmt:           q.insertEmailVerificationStmt,
+		insertInviteStmt:                      q.insertInviteStmt,
+		insertInviteRedemptionStmt:            q.insertInviteRedemptionStmt,
+		insertLoginChallengeStmt:              q.insertLoginChallengeStmt,
+		insertMMConversationUserStmt:          q.insertMMConversationUserStmt,
+		insertMessageStmt:                     q.insertMessageStmt,
+		insertOIDCLoginStmt:                   q.insertOIDCLoginStmt,
+		insertPasswordResetStmt:               q.insertPasswordResetStmt,
+		insertPasswordResetRequestStmt:        q.insertPasswordResetRequestStmt,
+		insertRecoveryCodeStmt:                q.insertRecoveryCodeStmt,
+		insertRefreshTokenStmt:                q.insertRefreshTokenStmt,
+		insertRevocationStmt:                  q.insertRevocationStmt,
+		insertSessionStmt:                     q.insertSessionStmt,
+		insertUserStmt:                        q.insertUserStmt,
+		insertUserIdentityStmt:                q.insertUserIdentityStmt,
+		listOwnedBotsStmt:                     q.listOwnedBotsStmt,
+		listUserAPIKeysStmt:                   q.listUserAPIKeysStmt,
+		listUsersStmt:                         q.listUsersStmt,
+		listUsersByRoleStmt:                   q.listUsersByRoleStmt,
+		promoteUserByUsernameStmt:             q.promoteUserByUsernameStmt,
+		readAPIKeyByHashStmt:                  q.readAPIKeyByHashStmt,
+		readAllContactsStmt:                   q.readAllContactsStmt,
+		readAllConversationMembersStmt:        q.readAllConversationMembersStmt,
+		readAllInviteRedemptionsStmt:          q.readAllInviteRedemptionsStmt,
+		readAllInvitesStmt:                    q.readAllInvitesStmt,
+		readAllRevocationsStmt:                q.readAllRevocationsStmt,
+		readAllUserSessionsStmt:               q.readAllUserSessionsStmt,
+		readContactStmt:                       q.readContactStmt,
+		readConversationStmt:                  q.readConversationStmt,
+		readConversationInviteStmt:            q.readConversationInviteStmt,
+		readConversationMemberStmt:            q.readConversationMemberStmt,
+		readEmailVerificationStmt:             q.readEmailVerificationStmt,
+		readInboxStmt:                         q.readInboxStmt,
+		readInviteByTokenHashStmt:             q.readInviteByTokenHashStmt,
+		readLoginChallengeStmt:                q.readLoginChallengeStmt,
+		readLoginThrottleStmt:                 q.readLoginThrottleStmt,
+		readMessageStmt:                       q.readMessageStmt,
+		readMessageByClientIDStmt:             q.readMessageByClientIDStmt,
+		readMessagesAfterSeqStmt:              q.readMessagesAfterSeqStmt,
+		readOIDCLoginStmt:                     q.readOIDCLoginStmt,
+		readPasswordResetStmt:                 q.readPasswordResetStmt,
+		readPinnedInboxStmt:                   q.readPinnedInboxStmt,
+		readRecentEmailVerificationsStmt:      q.readRecentEmailVerificationsStmt,
+		readRecentPasswordResetRequestsStmt:   q.readRecentPasswordResetRequestsStmt,
+		readRefreshTokenStmt:                  q.readRefreshTokenStmt,
+		readSessionStmt:                       q.readSessionStmt,
+		readUserStmt:                          q.readUserStmt,
+		readUserByEmailStmt:                   q.readUserByEmailStmt,
+		readUserDetailsStmt:                   q.readUserDetailsStmt,
+		readUserIdentityStmt:                  q.readUserIdentityStmt,
+		readUserLoginDetailsStmt:              q.readUserLoginDetailsStmt,
+		readUserTOTPStmt:                      q.readUserTOTPStmt,
+		resetEmailVerificationStmt:            q.resetEmailVerificationStmt,
+		revokeAPIKeyStmt:                      q.revokeAPIKeyStmt,
+		revokeAllUserSessionsStmt:             q.revokeAllUserSessionsStmt,
+		revokeInviteStmt:                      q.revokeInviteStmt,
+		revokeOtherUserSessionsStmt:           q.revokeOtherUserSessionsStmt,
+		revokeSessionStmt:                     q.revokeSessionStmt,
+		searchContactsStmt:                    q.searchContactsStmt,
+		searchUserDetailsStmt:                 q.searchUserDetailsStmt,
+		touchAPIKeyStmt:                       q.touchAPIKeyStmt,
+		touchSessionStmt:                      q.touchSessionStmt,
+		updateConversationActivityStmt:        q.updateConversationActivityStmt,
+		updateConversationPreferencesStmt:     q.updateConversationPreferencesStmt,
+		updateLastReadStmt:                    q.updateLastReadStmt,
+		updateUserStmt:                        q.updateUserStmt,
+		updateUserPasswordStmt:                q.updateUserPasswordStmt,
+		updateUserRoleStmt:                    q.updateUserRoleStmt,
+		upsertLoginThrottleStmt:               q.upsertLoginThrottleStmt,
+		upsertUserTOTPStmt:                    q.upsertUserTOTPStmt,
+		useAllUserEmailVerificationsStmt:      q.useAllUserEmailVerificationsStmt,
+		useAllUserPasswordResetsStmt:          q.useAllUserPasswordResetsStmt,
+		useEmailVerificationStmt:              q.useEmailVerificationStmt,
+		useLoginChallengeStmt:                 q.useLoginChallengeStmt,
+		useOIDCLoginStmt:                      q.useOIDCLoginStmt,
+		usePasswordResetStmt:                  q.usePasswordResetStmt,
+		useRecoveryCodeStmt:                   q.useRecoveryCodeStmt,
+		useRefreshTokenStmt:                   q.useRefreshTokenStmt,
+		useTOTPStepStmt:                       q.useTOTPStepStmt,
+		verifyUserEmailStmt:                   q.verifyUserEmailStmt,
 	}
 }
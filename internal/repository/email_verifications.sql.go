@@ -0,0 +1,121 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: email_verifications.sql
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const insertEmailVerification = `-- name: InsertEmailVerification :exec
+INSERT INTO email_verifications (
+    token_hash, user_uuid, email, expires_at
+) VALUES (
+    ?, ?, ?, ?
+)
+`
+
+type InsertEmailVerificationParams struct {
+	TokenHash string
+	UserUuid  string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// add hashed email verification token to database
+func (q *Queries) InsertEmailVerification(ctx context.Context, arg *InsertEmailVerificationParams) error {
+	_, err := q.exec(ctx, q.insertEmailVerificationStmt, insertEmailVerification,
+		arg.TokenHash,
+		arg.UserUuid,
+		arg.Email,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const readEmailVerification = `-- name: ReadEmailVerification :one
+SELECT token_hash, user_uuid, email, expires_at, used_at, created_at
+FROM email_verifications
+WHERE token_hash = ?
+`
+
+// retrieve email verification by hashed token
+func (q *Queries) ReadEmailVerification(ctx context.Context, tokenHash string) (*EmailVerification, error) {
+	row := q.queryRow(ctx, q.readEmailVerificationStmt, readEmailVerification, tokenHash)
+	var i EmailVerification
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserUuid,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const readRecentEmailVerifications = `-- name: ReadRecentEmailVerifications :many
+SELECT created_at
+FROM email_verifications
+WHERE user_uuid = ?
+ORDER BY created_at DESC
+LIMIT ?
+`
+
+type ReadRecentEmailVerificationsParams struct {
+	UserUuid string
+	Limit    int64
+}
+
+// retrieve send times of the latest email verifications of user
+func (q *Queries) ReadRecentEmailVerifications(ctx context.Context, arg *ReadRecentEmailVerificationsParams) ([]time.Time, error) {
+	rows, err := q.query(ctx, q.readRecentEmailVerificationsStmt, readRecentEmailVerifications, arg.UserUuid, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []time.Time{}
+	for rows.Next() {
+		var created_at time.Time
+		if err := rows.Scan(&created_at); err != nil {
+			return nil, err
+		}
+		items = append(items, created_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const useAllUserEmailVerifications = `-- name: UseAllUserEmailVerifications :exec
+UPDATE email_verifications
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND used_at IS NULL
+`
+
+// mark every outstanding email verification token of user used
+func (q *Queries) UseAllUserEmailVerifications(ctx context.Context, userUuid string) error {
+	_, err := q.exec(ctx, q.useAllUserEmailVerificationsStmt, useAllUserEmailVerifications, userUuid)
+	return err
+}
+
+const useEmailVerification = `-- name: UseEmailVerification :execresult
+UPDATE email_verifications
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+    AND used_at IS NULL
+`
+
+// mark email verification token used, no rows are affected if it was already used
+func (q *Queries) UseEmailVerification(ctx context.Context, tokenHash string) (sql.Result, error) {
+	return q.exec(ctx, q.useEmailVerificationStmt, useEmailVerification, tokenHash)
+}
@@ -8,28 +8,100 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+const advanceLastRead = `-- name: AdvanceLastRead :execresult
+UPDATE mm_conversations_users
+SET
+    last_read_at = ?,
+    last_read_seq = MAX(last_read_seq, COALESCE((
+        SELECT messages.seq
+        FROM messages
+        WHERE messages.uuid = ?
+            AND messages.conversation_uuid = mm_conversations_users.conversation_uuid
+    ), 0))
+WHERE mm_conversations_users.conversation_uuid = ?
+    AND mm_conversations_users.user_uuid = ?
+`
+
+type AdvanceLastReadParams struct {
+	LastReadAt       sql.NullTime
+	Uuid             string
+	ConversationUuid string
+	UserUuid         string
+}
+
+// mark conversation messages read for member up to envelope, never moves backwards
+func (q *Queries) AdvanceLastRead(ctx context.Context, arg *AdvanceLastReadParams) (sql.Result, error) {
+	return q.exec(ctx, q.advanceLastReadStmt, advanceLastRead,
+		arg.LastReadAt,
+		arg.Uuid,
+		arg.ConversationUuid,
+		arg.UserUuid,
+	)
+}
+
+const countConversationMembers = `-- name: CountConversationMembers :one
+SELECT COUNT(*)
+FROM mm_conversations_users
+WHERE conversation_uuid = ?
+`
+
+func (q *Queries) CountConversationMembers(ctx context.Context, conversationUuid string) (int64, error) {
+	row := q.queryRow(ctx, q.countConversationMembersStmt, countConversationMembers, conversationUuid)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const incrementConversationSeq = `-- name: IncrementConversationSeq :one
+UPDATE conversations
+SET last_seq = last_seq + 1
+WHERE uuid = ?
+RETURNING last_seq
+`
+
+// reserve next message sequence number for conversation
+func (q *Queries) IncrementConversationSeq(ctx context.Context, uuid string) (int64, error) {
+	row := q.queryRow(ctx, q.incrementConversationSeqStmt, incrementConversationSeq, uuid)
+	var last_seq int64
+	err := row.Scan(&last_seq)
+	return last_seq, err
+}
+
 const insertConversation = `-- name: InsertConversation :one
-INSERT INTO conversations (uuid)
+INSERT INTO conversations (uuid, activity_key)
 VALUES (
-    ?
-) RETURNING uuid, created_at, updated_at
+    ?, ?
+) RETURNING uuid, created_at, updated_at, last_message_uuid, activity_key, last_seq
 `
 
+type InsertConversationParams struct {
+	Uuid        string
+	ActivityKey string
+}
+
 // add conversation to database
-func (q *Queries) InsertConversation(ctx context.Context, uuid string) (*Conversation, error) {
-	row := q.queryRow(ctx, q.insertConversationStmt, insertConversation, uuid)
+func (q *Queries) InsertConversation(ctx context.Context, arg *InsertConversationParams) (*Conversation, error) {
+	row := q.queryRow(ctx, q.insertConversationStmt, insertConversation, arg.Uuid, arg.ActivityKey)
 	var i Conversation
-	err := row.Scan(&i.Uuid, &i.CreatedAt, &i.UpdatedAt)
+	err := row.Scan(
+		&i.Uuid,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastMessageUuid,
+		&i.ActivityKey,
+		&i.LastSeq,
+	)
 	return &i, err
 }
 
 const insertMMConversationUser = `-- name: InsertMMConversationUser :execresult
 INSERT INTO mm_conversations_users (
-    uuid, conversation_uuid, user_uuid
+    uuid, conversation_uuid, user_uuid, user_role
 ) VALUES (
-    ?, ?, ?
+    ?, ?, ?, ?
 )
 `
 
@@ -37,17 +109,23 @@ type InsertMMConversationUserParams struct {
 	Uuid             string
 	ConversationUuid string
 	UserUuid         string
+	UserRole         string
 }
 
 func (q *Queries) InsertMMConversationUser(ctx context.Context, arg *InsertMMConversationUserParams) (sql.Result, error) {
-	return q.exec(ctx, q.insertMMConversationUserStmt, insertMMConversationUser, arg.Uuid, arg.ConversationUuid, arg.UserUuid)
+	return q.exec(ctx, q.insertMMConversationUserStmt, insertMMConversationUser,
+		arg.Uuid,
+		arg.ConversationUuid,
+		arg.UserUuid,
+		arg.UserRole,
+	)
 }
 
 const insertMessage = `-- name: InsertMessage :one
-INSERT INTO messages (uuid, conversation_uuid, sender, body)
+INSERT INTO messages (uuid, conversation_uuid, sender, body, created_at, seq, client_message_id)
 VALUES (
-    ?, ?, ?, ?
-) RETURNING uuid, conversation_uuid, sender, body, created_at
+    ?, ?, ?, ?, ?, ?, ?
+) RETURNING uuid, conversation_uuid, sender, body, created_at, seq, client_message_id
 `
 
 type InsertMessageParams struct {
@@ -55,6 +133,9 @@ type InsertMessageParams struct {
 	ConversationUuid string
 	Sender           string
 	Body             string
+	CreatedAt        time.Time
+	Seq              int64
+	ClientMessageID  sql.NullString
 }
 
 // add new message to database
@@ -64,6 +145,9 @@ func (q *Queries) InsertMessage(ctx context.Context, arg *InsertMessageParams) (
 		arg.ConversationUuid,
 		arg.Sender,
 		arg.Body,
+		arg.CreatedAt,
+		arg.Seq,
+		arg.ClientMessageID,
 	)
 	var i Message
 	err := row.Scan(
@@ -72,35 +156,198 @@ func (q *Queries) InsertMessage(ctx context.Context, arg *InsertMessageParams) (
 		&i.Sender,
 		&i.Body,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.ClientMessageID,
+	)
+	return &i, err
+}
+
+const readAllConversationMembers = `-- name: ReadAllConversationMembers :many
+SELECT uuid, conversation_uuid, user_uuid, user_role, muted_until, archived, pinned_at, last_read_at, last_read_seq
+FROM mm_conversations_users
+WHERE conversation_uuid = ?
+`
+
+// retrieve all members of conversation
+func (q *Queries) ReadAllConversationMembers(ctx context.Context, conversationUuid string) ([]*MmConversationsUser, error) {
+	rows, err := q.query(ctx, q.readAllConversationMembersStmt, readAllConversationMembers, conversationUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*MmConversationsUser{}
+	for rows.Next() {
+		var i MmConversationsUser
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.ConversationUuid,
+			&i.UserUuid,
+			&i.UserRole,
+			&i.MutedUntil,
+			&i.Archived,
+			&i.PinnedAt,
+			&i.LastReadAt,
+			&i.LastReadSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const readConversation = `-- name: ReadConversation :one
+SELECT uuid, created_at, updated_at, last_message_uuid, activity_key, last_seq
+FROM conversations
+WHERE uuid = ?
+`
+
+// read conversation by uuid
+func (q *Queries) ReadConversation(ctx context.Context, uuid string) (*Conversation, error) {
+	row := q.queryRow(ctx, q.readConversationStmt, readConversation, uuid)
+	var i Conversation
+	err := row.Scan(
+		&i.Uuid,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastMessageUuid,
+		&i.ActivityKey,
+		&i.LastSeq,
+	)
+	return &i, err
+}
+
+const readConversationMember = `-- name: ReadConversationMember :one
+SELECT uuid, conversation_uuid, user_uuid, user_role, muted_until, archived, pinned_at, last_read_at, last_read_seq
+FROM mm_conversations_users
+WHERE conversation_uuid = ?
+    AND user_uuid = ?
+`
+
+type ReadConversationMemberParams struct {
+	ConversationUuid string
+	UserUuid         string
+}
+
+// read conversation membership for user
+func (q *Queries) ReadConversationMember(ctx context.Context, arg *ReadConversationMemberParams) (*MmConversationsUser, error) {
+	row := q.queryRow(ctx, q.readConversationMemberStmt, readConversationMember, arg.ConversationUuid, arg.UserUuid)
+	var i MmConversationsUser
+	err := row.Scan(
+		&i.Uuid,
+		&i.ConversationUuid,
+		&i.UserUuid,
+		&i.UserRole,
+		&i.MutedUntil,
+		&i.Archived,
+		&i.PinnedAt,
+		&i.LastReadAt,
+		&i.LastReadSeq,
 	)
 	return &i, err
 }
 
-const readAllConversations = `-- name: ReadAllConversations :many
-SELECT conversations.uuid, mm_conversations_users.user_uuid, conversations.updated_at
+const readInbox = `-- name: ReadInbox :many
+SELECT conversations.uuid, conversations.created_at, conversations.updated_at, conversations.activity_key,
+    mm_conversations_users.muted_until, mm_conversations_users.archived, mm_conversations_users.pinned_at,
+    COALESCE(messages.uuid, '') AS last_message_uuid,
+    COALESCE(messages.sender, '') AS last_message_sender,
+    COALESCE(messages.body, '') AS last_message_body,
+    (
+        SELECT COUNT(*)
+        FROM messages AS unread
+        WHERE unread.conversation_uuid = conversations.uuid
+            AND unread.sender != mm_conversations_users.user_uuid
+            AND unread.seq > mm_conversations_users.last_read_seq
+    ) AS unread_count,
+    (
+        SELECT COUNT(*)
+        FROM mm_conversations_users AS members
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_count,
+    (
+        SELECT GROUP_CONCAT(users.usernm, ',')
+        FROM mm_conversations_users AS members
+        JOIN users
+            ON members.user_uuid = users.uuid
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_names
 FROM conversations
 JOIN mm_conversations_users
-    ON conversation.uuid = mm_conversations_users.conversation_uuid
+    ON conversations.uuid = mm_conversations_users.conversation_uuid
+LEFT JOIN messages
+    ON conversations.last_message_uuid = messages.uuid
 WHERE mm_conversations_users.user_uuid = ?
+    AND mm_conversations_users.archived >= ?
+    AND mm_conversations_users.archived <= ?
+    AND mm_conversations_users.pinned_at IS NULL
+    AND conversations.activity_key < ?
+ORDER BY conversations.activity_key DESC
+LIMIT ?
 `
 
-type ReadAllConversationsRow struct {
-	Uuid      string
-	UserUuid  string
-	UpdatedAt sql.NullTime
+type ReadInboxParams struct {
+	UserUuid    string
+	Archived    bool
+	Archived_2  bool
+	ActivityKey string
+	Limit       int64
+}
+
+type ReadInboxRow struct {
+	Uuid              string
+	CreatedAt         time.Time
+	UpdatedAt         sql.NullTime
+	ActivityKey       string
+	MutedUntil        sql.NullTime
+	Archived          bool
+	PinnedAt          sql.NullTime
+	LastMessageUuid   string
+	LastMessageSender string
+	LastMessageBody   string
+	UnreadCount       int64
+	MemberCount       int64
+	MemberNames       string
 }
 
-// retrieve all conversations that includes user uuid in recipients field
-func (q *Queries) ReadAllConversations(ctx context.Context, userUuid string) ([]*ReadAllConversationsRow, error) {
-	rows, err := q.query(ctx, q.readAllConversationsStmt, readAllConversations, userUuid)
+// retrieve page of unpinned conversations that includes user uuid ordered by last activity
+// archived bounds select the states to include e.g. false..true for all
+func (q *Queries) ReadInbox(ctx context.Context, arg *ReadInboxParams) ([]*ReadInboxRow, error) {
+	rows, err := q.query(ctx, q.readInboxStmt, readInbox,
+		arg.UserUuid,
+		arg.Archived,
+		arg.Archived_2,
+		arg.ActivityKey,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []*ReadAllConversationsRow{}
+	items := []*ReadInboxRow{}
 	for rows.Next() {
-		var i ReadAllConversationsRow
-		if err := rows.Scan(&i.Uuid, &i.UserUuid, &i.UpdatedAt); err != nil {
+		var i ReadInboxRow
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ActivityKey,
+			&i.MutedUntil,
+			&i.Archived,
+			&i.PinnedAt,
+			&i.LastMessageUuid,
+			&i.LastMessageSender,
+			&i.LastMessageBody,
+			&i.UnreadCount,
+			&i.MemberCount,
+			&i.MemberNames,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, &i)
@@ -114,15 +361,82 @@ func (q *Queries) ReadAllConversations(ctx context.Context, userUuid string) ([]
 	return items, nil
 }
 
-const readAllMessages = `-- name: ReadAllMessages :many
-SELECT uuid, conversation_uuid, sender, body, created_at
+const readMessage = `-- name: ReadMessage :one
+SELECT uuid, conversation_uuid, sender, body, created_at, seq, client_message_id
+FROM messages
+WHERE uuid = ?
+    AND conversation_uuid = ?
+`
+
+type ReadMessageParams struct {
+	Uuid             string
+	ConversationUuid string
+}
+
+// retrieve message by uuid within conversation
+func (q *Queries) ReadMessage(ctx context.Context, arg *ReadMessageParams) (*Message, error) {
+	row := q.queryRow(ctx, q.readMessageStmt, readMessage, arg.Uuid, arg.ConversationUuid)
+	var i Message
+	err := row.Scan(
+		&i.Uuid,
+		&i.ConversationUuid,
+		&i.Sender,
+		&i.Body,
+		&i.CreatedAt,
+		&i.Seq,
+		&i.ClientMessageID,
+	)
+	return &i, err
+}
+
+const readMessageByClientID = `-- name: ReadMessageByClientID :one
+SELECT uuid, conversation_uuid, sender, body, created_at, seq, client_message_id
+FROM messages
+WHERE conversation_uuid = ?
+    AND sender = ?
+    AND client_message_id = ?
+`
+
+type ReadMessageByClientIDParams struct {
+	ConversationUuid string
+	Sender           string
+	ClientMessageID  sql.NullString
+}
+
+// retrieve message previously sent by sender with client message id
+func (q *Queries) ReadMessageByClientID(ctx context.Context, arg *ReadMessageByClientIDParams) (*Message, error) {
+	row := q.queryRow(ctx, q.readMessageByClientIDStmt, readMessageByClientID, arg.ConversationUuid, arg.Sender, arg.ClientMessageID)
+	var i Message
+	err := row.Scan(
+		&i.Uuid,
+		&i.ConversationUuid,
+		&i.Sender,
+		&i.Body,
+		&i.CreatedAt,
+		&i.Seq,
+		&i.ClientMessageID,
+	)
+	return &i, err
+}
+
+const readMessagesAfterSeq = `-- name: ReadMessagesAfterSeq :many
+SELECT uuid, conversation_uuid, sender, body, created_at, seq, client_message_id
 FROM messages
 WHERE conversation_uuid = ?
+    AND seq > ?
+ORDER BY seq
+LIMIT ?
 `
 
-// retrieve all messages by conversation uuid
-func (q *Queries) ReadAllMessages(ctx context.Context, conversationUuid string) ([]*Message, error) {
-	rows, err := q.query(ctx, q.readAllMessagesStmt, readAllMessages, conversationUuid)
+type ReadMessagesAfterSeqParams struct {
+	ConversationUuid string
+	Seq              int64
+	Limit            int64
+}
+
+// retrieve page of conversation messages after sequence number
+func (q *Queries) ReadMessagesAfterSeq(ctx context.Context, arg *ReadMessagesAfterSeqParams) ([]*Message, error) {
+	rows, err := q.query(ctx, q.readMessagesAfterSeqStmt, readMessagesAfterSeq, arg.ConversationUuid, arg.Seq, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +450,8 @@ func (q *Queries) ReadAllMessages(ctx context.Context, conversationUuid string)
 			&i.Sender,
 			&i.Body,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.ClientMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -149,3 +465,188 @@ func (q *Queries) ReadAllMessages(ctx context.Context, conversationUuid string)
 	}
 	return items, nil
 }
+
+const readPinnedInbox = `-- name: ReadPinnedInbox :many
+SELECT conversations.uuid, conversations.created_at, conversations.updated_at, conversations.activity_key,
+    mm_conversations_users.muted_until, mm_conversations_users.archived, mm_conversations_users.pinned_at,
+    COALESCE(messages.uuid, '') AS last_message_uuid,
+    COALESCE(messages.sender, '') AS last_message_sender,
+    COALESCE(messages.body, '') AS last_message_body,
+    (
+        SELECT COUNT(*)
+        FROM messages AS unread
+        WHERE unread.conversation_uuid = conversations.uuid
+            AND unread.sender != mm_conversations_users.user_uuid
+            AND unread.seq > mm_conversations_users.last_read_seq
+    ) AS unread_count,
+    (
+        SELECT COUNT(*)
+        FROM mm_conversations_users AS members
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_count,
+    (
+        SELECT GROUP_CONCAT(users.usernm, ',')
+        FROM mm_conversations_users AS members
+        JOIN users
+            ON members.user_uuid = users.uuid
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_names
+FROM conversations
+JOIN mm_conversations_users
+    ON conversations.uuid = mm_conversations_users.conversation_uuid
+LEFT JOIN messages
+    ON conversations.last_message_uuid = messages.uuid
+WHERE mm_conversations_users.user_uuid = ?
+    AND mm_conversations_users.archived >= ?
+    AND mm_conversations_users.archived <= ?
+    AND mm_conversations_users.pinned_at IS NOT NULL
+ORDER BY mm_conversations_users.pinned_at DESC
+`
+
+type ReadPinnedInboxParams struct {
+	UserUuid   string
+	Archived   bool
+	Archived_2 bool
+}
+
+type ReadPinnedInboxRow struct {
+	Uuid              string
+	CreatedAt         time.Time
+	UpdatedAt         sql.NullTime
+	ActivityKey       string
+	MutedUntil        sql.NullTime
+	Archived          bool
+	PinnedAt          sql.NullTime
+	LastMessageUuid   string
+	LastMessageSender string
+	LastMessageBody   string
+	UnreadCount       int64
+	MemberCount       int64
+	MemberNames       string
+}
+
+// retrieve all pinned conversations that includes user uuid
+// archived bounds select the states to include e.g. false..true for all
+func (q *Queries) ReadPinnedInbox(ctx context.Context, arg *ReadPinnedInboxParams) ([]*ReadPinnedInboxRow, error) {
+	rows, err := q.query(ctx, q.readPinnedInboxStmt, readPinnedInbox, arg.UserUuid, arg.Archived, arg.Archived_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ReadPinnedInboxRow{}
+	for rows.Next() {
+		var i ReadPinnedInboxRow
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ActivityKey,
+			&i.MutedUntil,
+			&i.Archived,
+			&i.PinnedAt,
+			&i.LastMessageUuid,
+			&i.LastMessageSender,
+			&i.LastMessageBody,
+			&i.UnreadCount,
+			&i.MemberCount,
+			&i.MemberNames,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateConversationActivity = `-- name: UpdateConversationActivity :execresult
+UPDATE conversations
+SET
+    last_message_uuid = ?,
+    activity_key = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+`
+
+type UpdateConversationActivityParams struct {
+	LastMessageUuid sql.NullString
+	ActivityKey     string
+	Uuid            string
+}
+
+// record new message as latest conversation activity
+func (q *Queries) UpdateConversationActivity(ctx context.Context, arg *UpdateConversationActivityParams) (sql.Result, error) {
+	return q.exec(ctx, q.updateConversationActivityStmt, updateConversationActivity, arg.LastMessageUuid, arg.ActivityKey, arg.Uuid)
+}
+
+const updateConversationPreferences = `-- name: UpdateConversationPreferences :one
+UPDATE mm_conversations_users
+SET
+    muted_until = ?,
+    archived = ?,
+    pinned_at = ?
+WHERE conversation_uuid = ?
+    AND user_uuid = ?
+RETURNING uuid, conversation_uuid, user_uuid, user_role, muted_until, archived, pinned_at, last_read_at, last_read_seq
+`
+
+type UpdateConversationPreferencesParams struct {
+	MutedUntil       sql.NullTime
+	Archived         bool
+	PinnedAt         sql.NullTime
+	ConversationUuid string
+	UserUuid         string
+}
+
+// update per member conversation preferences
+func (q *Queries) UpdateConversationPreferences(ctx context.Context, arg *UpdateConversationPreferencesParams) (*MmConversationsUser, error) {
+	row := q.queryRow(ctx, q.updateConversationPreferencesStmt, updateConversationPreferences,
+		arg.MutedUntil,
+		arg.Archived,
+		arg.PinnedAt,
+		arg.ConversationUuid,
+		arg.UserUuid,
+	)
+	var i MmConversationsUser
+	err := row.Scan(
+		&i.Uuid,
+		&i.ConversationUuid,
+		&i.UserUuid,
+		&i.UserRole,
+		&i.MutedUntil,
+		&i.Archived,
+		&i.PinnedAt,
+		&i.LastReadAt,
+		&i.LastReadSeq,
+	)
+	return &i, err
+}
+
+const updateLastRead = `-- name: UpdateLastRead :execresult
+UPDATE mm_conversations_users
+SET
+    last_read_at = ?,
+    last_read_seq = MAX(last_read_seq, (
+        SELECT conversations.last_seq
+        FROM conversations
+        WHERE conversations.uuid = mm_conversations_users.conversation_uuid
+    ))
+WHERE conversation_uuid = ?
+    AND user_uuid = ?
+`
+
+type UpdateLastReadParams struct {
+	LastReadAt       sql.NullTime
+	ConversationUuid string
+	UserUuid         string
+}
+
+// mark all current conversation messages as read for member
+func (q *Queries) UpdateLastRead(ctx context.Context, arg *UpdateLastReadParams) (sql.Result, error) {
+	return q.exec(ctx, q.updateLastReadStmt, updateLastRead, arg.LastReadAt, arg.ConversationUuid, arg.UserUuid)
+}
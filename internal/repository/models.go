@@ -9,6 +9,28 @@ import (
 	"time"
 )
 
+type ApiKey struct {
+	Uuid       string
+	UserUuid   string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+	CreatedAt  time.Time
+}
+
+type AuditEvent struct {
+	Uuid      string
+	Event     string
+	UserUuid  sql.NullString
+	Subject   string
+	IpAddress string
+	CreatedAt time.Time
+}
+
 type Contact struct {
 	Uuid          string
 	OriginUuid    string
@@ -17,9 +39,56 @@ type Contact struct {
 }
 
 type Conversation struct {
-	Uuid      string
+	Uuid            string
+	CreatedAt       time.Time
+	UpdatedAt       sql.NullTime
+	LastMessageUuid sql.NullString
+	ActivityKey     string
+	LastSeq         int64
+}
+
+type ConversationInvite struct {
+	Uuid             string
+	ConversationUuid string
+	CreatorUuid      string
+	TokenHash        string
+	MaxUses          sql.NullInt64
+	Uses             int64
+	ExpiresAt        sql.NullTime
+	RevokedAt        sql.NullTime
+	CreatedAt        time.Time
+}
+
+type ConversationInviteRedemption struct {
+	Uuid       string
+	InviteUuid string
+	UserUuid   string
+	CreatedAt  time.Time
+}
+
+type EmailVerification struct {
+	TokenHash string
+	UserUuid  string
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 	CreatedAt time.Time
-	UpdatedAt sql.NullTime
+}
+
+type LoginChallenge struct {
+	TokenHash string
+	UserUuid  string
+	Attempts  int64
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type LoginThrottle struct {
+	ThrottleKey string
+	Failures    int64
+	LockedUntil sql.NullTime
+	UpdatedAt   time.Time
 }
 
 type Message struct {
@@ -28,19 +97,103 @@ type Message struct {
 	Sender           string
 	Body             string
 	CreatedAt        time.Time
+	Seq              int64
+	ClientMessageID  sql.NullString
 }
 
 type MmConversationsUser struct {
 	Uuid             string
 	ConversationUuid string
 	UserUuid         string
+	UserRole         string
+	MutedUntil       sql.NullTime
+	Archived         bool
+	PinnedAt         sql.NullTime
+	LastReadAt       sql.NullTime
+	LastReadSeq      int64
+}
+
+type OidcLogin struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	UserUuid     sql.NullString
+	ExpiresAt    time.Time
+	UsedAt       sql.NullTime
+	CreatedAt    time.Time
+}
+
+type PasswordReset struct {
+	TokenHash string
+	UserUuid  string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PasswordResetRequest struct {
+	ThrottleKey string
+	CreatedAt   time.Time
+}
+
+type RecoveryCode struct {
+	CodeHash  string
+	UserUuid  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	TokenHash   string
+	SessionUuid string
+	UsedAt      sql.NullTime
+	CreatedAt   time.Time
+}
+
+type RevokedToken struct {
+	RevocationKey string
+	ExpiresAt     int64
+	CreatedAt     time.Time
 }
 
 type User struct {
+	Uuid            string
+	Usernm          string
+	Email           string
+	CreatedAt       time.Time
+	UpdatedAt       sql.NullTime
+	Pssword         string
+	EmailVerifiedAt sql.NullTime
+	UserType        string
+	OwnerUuid       sql.NullString
+	Role            string
+	DisabledAt      sql.NullTime
+}
+
+type UserIdentity struct {
 	Uuid      string
-	Usernm    string
+	UserUuid  string
+	Issuer    string
+	Subject   string
 	Email     string
 	CreatedAt time.Time
-	UpdatedAt sql.NullTime
-	Pssword   string
+}
+
+type UserSession struct {
+	Uuid       string
+	UserUuid   string
+	UserAgent  string
+	IpAddress  string
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	LastUsedAt time.Time
+	CreatedAt  time.Time
+}
+
+type UserTotp struct {
+	UserUuid    string
+	Secret      string
+	ConfirmedAt sql.NullTime
+	LastStep    int64
+	CreatedAt   time.Time
 }
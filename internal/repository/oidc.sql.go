@@ -0,0 +1,126 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: oidc.sql
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const insertOIDCLogin = `-- name: InsertOIDCLogin :exec
+INSERT INTO oidc_logins (
+    state_hash, nonce, code_verifier, user_uuid, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?
+)
+`
+
+type InsertOIDCLoginParams struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	UserUuid     sql.NullString
+	ExpiresAt    time.Time
+}
+
+func (q *Queries) InsertOIDCLogin(ctx context.Context, arg *InsertOIDCLoginParams) error {
+	_, err := q.exec(ctx, q.insertOIDCLoginStmt, insertOIDCLogin,
+		arg.StateHash,
+		arg.Nonce,
+		arg.CodeVerifier,
+		arg.UserUuid,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const insertUserIdentity = `-- name: InsertUserIdentity :exec
+INSERT INTO user_identities (
+    uuid, user_uuid, issuer, subject, email
+) VALUES (
+    ?, ?, ?, ?, ?
+)
+`
+
+type InsertUserIdentityParams struct {
+	Uuid     string
+	UserUuid string
+	Issuer   string
+	Subject  string
+	Email    string
+}
+
+func (q *Queries) InsertUserIdentity(ctx context.Context, arg *InsertUserIdentityParams) error {
+	_, err := q.exec(ctx, q.insertUserIdentityStmt, insertUserIdentity,
+		arg.Uuid,
+		arg.UserUuid,
+		arg.Issuer,
+		arg.Subject,
+		arg.Email,
+	)
+	return err
+}
+
+const readOIDCLogin = `-- name: ReadOIDCLogin :one
+SELECT state_hash, nonce, code_verifier, user_uuid, expires_at, used_at, created_at
+FROM oidc_logins
+WHERE state_hash = ?
+`
+
+func (q *Queries) ReadOIDCLogin(ctx context.Context, stateHash string) (*OidcLogin, error) {
+	row := q.queryRow(ctx, q.readOIDCLoginStmt, readOIDCLogin, stateHash)
+	var i OidcLogin
+	err := row.Scan(
+		&i.StateHash,
+		&i.Nonce,
+		&i.CodeVerifier,
+		&i.UserUuid,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const readUserIdentity = `-- name: ReadUserIdentity :one
+SELECT uuid, user_uuid, issuer, subject, email, created_at
+FROM user_identities
+WHERE issuer = ?
+    AND subject = ?
+`
+
+type ReadUserIdentityParams struct {
+	Issuer  string
+	Subject string
+}
+
+// read identity by issuer and subject
+func (q *Queries) ReadUserIdentity(ctx context.Context, arg *ReadUserIdentityParams) (*UserIdentity, error) {
+	row := q.queryRow(ctx, q.readUserIdentityStmt, readUserIdentity, arg.Issuer, arg.Subject)
+	var i UserIdentity
+	err := row.Scan(
+		&i.Uuid,
+		&i.UserUuid,
+		&i.Issuer,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const useOIDCLogin = `-- name: UseOIDCLogin :execresult
+UPDATE oidc_logins
+SET used_at = CURRENT_TIMESTAMP
+WHERE state_hash = ?
+    AND used_at IS NULL
+`
+
+// mark login used, no rows are affected if already used
+func (q *Queries) UseOIDCLogin(ctx context.Context, stateHash string) (sql.Result, error) {
+	return q.exec(ctx, q.useOIDCLoginStmt, useOIDCLogin, stateHash)
+}
@@ -0,0 +1,139 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: password_resets.sql
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const deletePasswordResetRequestsBefore = `-- name: DeletePasswordResetRequestsBefore :exec
+DELETE FROM password_reset_requests
+WHERE created_at < ?
+`
+
+// forget password reset requests no longer counted by the throttle
+func (q *Queries) DeletePasswordResetRequestsBefore(ctx context.Context, createdAt time.Time) error {
+	_, err := q.exec(ctx, q.deletePasswordResetRequestsBeforeStmt, deletePasswordResetRequestsBefore, createdAt)
+	return err
+}
+
+const insertPasswordReset = `-- name: InsertPasswordReset :exec
+INSERT INTO password_resets (
+    token_hash, user_uuid, expires_at
+) VALUES (
+    ?, ?, ?
+)
+`
+
+type InsertPasswordResetParams struct {
+	TokenHash string
+	UserUuid  string
+	ExpiresAt time.Time
+}
+
+// add hashed password reset token to database
+func (q *Queries) InsertPasswordReset(ctx context.Context, arg *InsertPasswordResetParams) error {
+	_, err := q.exec(ctx, q.insertPasswordResetStmt, insertPasswordReset, arg.TokenHash, arg.UserUuid, arg.ExpiresAt)
+	return err
+}
+
+const insertPasswordResetRequest = `-- name: InsertPasswordResetRequest :exec
+INSERT INTO password_reset_requests (
+    throttle_key
+) VALUES (
+    ?
+)
+`
+
+// record password reset request of email or client ip
+func (q *Queries) InsertPasswordResetRequest(ctx context.Context, throttleKey string) error {
+	_, err := q.exec(ctx, q.insertPasswordResetRequestStmt, insertPasswordResetRequest, throttleKey)
+	return err
+}
+
+const readPasswordReset = `-- name: ReadPasswordReset :one
+SELECT token_hash, user_uuid, expires_at, used_at, created_at
+FROM password_resets
+WHERE token_hash = ?
+`
+
+// retrieve password reset by hashed token
+func (q *Queries) ReadPasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	row := q.queryRow(ctx, q.readPasswordResetStmt, readPasswordReset, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserUuid,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const readRecentPasswordResetRequests = `-- name: ReadRecentPasswordResetRequests :many
+SELECT created_at
+FROM password_reset_requests
+WHERE throttle_key = ?
+ORDER BY created_at DESC
+LIMIT ?
+`
+
+type ReadRecentPasswordResetRequestsParams struct {
+	ThrottleKey string
+	Limit       int64
+}
+
+// retrieve times of the latest password reset requests of key
+func (q *Queries) ReadRecentPasswordResetRequests(ctx context.Context, arg *ReadRecentPasswordResetRequestsParams) ([]time.Time, error) {
+	rows, err := q.query(ctx, q.readRecentPasswordResetRequestsStmt, readRecentPasswordResetRequests, arg.ThrottleKey, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []time.Time{}
+	for rows.Next() {
+		var created_at time.Time
+		if err := rows.Scan(&created_at); err != nil {
+			return nil, err
+		}
+		items = append(items, created_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const useAllUserPasswordResets = `-- name: UseAllUserPasswordResets :exec
+UPDATE password_resets
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND used_at IS NULL
+`
+
+// mark every outstanding password reset token of user used
+func (q *Queries) UseAllUserPasswordResets(ctx context.Context, userUuid string) error {
+	_, err := q.exec(ctx, q.useAllUserPasswordResetsStmt, useAllUserPasswordResets, userUuid)
+	return err
+}
+
+const usePasswordReset = `-- name: UsePasswordReset :execresult
+UPDATE password_resets
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+    AND used_at IS NULL
+`
+
+// mark password reset token used, no rows are affected if it was already used
+func (q *Queries) UsePasswordReset(ctx context.Context, tokenHash string) (sql.Result, error) {
+	return q.exec(ctx, q.usePasswordResetStmt, usePasswordReset, tokenHash)
+}
@@ -0,0 +1,208 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: two_factor.sql
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const attemptLoginChallenge = `-- name: AttemptLoginChallenge :execresult
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash = ?
+    AND used_at IS NULL
+`
+
+// count an attempt at answering a login challenge, no rows are affected once used
+func (q *Queries) AttemptLoginChallenge(ctx context.Context, tokenHash string) (sql.Result, error) {
+	return q.exec(ctx, q.attemptLoginChallengeStmt, attemptLoginChallenge, tokenHash)
+}
+
+const confirmUserTOTP = `-- name: ConfirmUserTOTP :execresult
+UPDATE user_totp
+SET
+    confirmed_at = CURRENT_TIMESTAMP,
+    last_step = ?
+WHERE user_uuid = ?
+    AND confirmed_at IS NULL
+`
+
+type ConfirmUserTOTPParams struct {
+	LastStep int64
+	UserUuid string
+}
+
+// enable two factor login of user
+func (q *Queries) ConfirmUserTOTP(ctx context.Context, arg *ConfirmUserTOTPParams) (sql.Result, error) {
+	return q.exec(ctx, q.confirmUserTOTPStmt, confirmUserTOTP, arg.LastStep, arg.UserUuid)
+}
+
+const deleteUserRecoveryCodes = `-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_uuid = ?
+`
+
+// remove every recovery code of user
+func (q *Queries) DeleteUserRecoveryCodes(ctx context.Context, userUuid string) error {
+	_, err := q.exec(ctx, q.deleteUserRecoveryCodesStmt, deleteUserRecoveryCodes, userUuid)
+	return err
+}
+
+const deleteUserTOTP = `-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_uuid = ?
+`
+
+// disable two factor login of user
+func (q *Queries) DeleteUserTOTP(ctx context.Context, userUuid string) error {
+	_, err := q.exec(ctx, q.deleteUserTOTPStmt, deleteUserTOTP, userUuid)
+	return err
+}
+
+const insertLoginChallenge = `-- name: InsertLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_uuid, expires_at)
+VALUES (?, ?, ?)
+`
+
+type InsertLoginChallengeParams struct {
+	TokenHash string
+	UserUuid  string
+	ExpiresAt time.Time
+}
+
+// add hashed login challenge token
+func (q *Queries) InsertLoginChallenge(ctx context.Context, arg *InsertLoginChallengeParams) error {
+	_, err := q.exec(ctx, q.insertLoginChallengeStmt, insertLoginChallenge, arg.TokenHash, arg.UserUuid, arg.ExpiresAt)
+	return err
+}
+
+const insertRecoveryCode = `-- name: InsertRecoveryCode :exec
+INSERT INTO recovery_codes (code_hash, user_uuid)
+VALUES (?, ?)
+`
+
+type InsertRecoveryCodeParams struct {
+	CodeHash string
+	UserUuid string
+}
+
+// add hashed recovery code of user
+func (q *Queries) InsertRecoveryCode(ctx context.Context, arg *InsertRecoveryCodeParams) error {
+	_, err := q.exec(ctx, q.insertRecoveryCodeStmt, insertRecoveryCode, arg.CodeHash, arg.UserUuid)
+	return err
+}
+
+const readLoginChallenge = `-- name: ReadLoginChallenge :one
+SELECT token_hash, user_uuid, attempts, expires_at, used_at, created_at
+FROM login_challenges
+WHERE token_hash = ?
+`
+
+// retrieve login challenge by hashed token
+func (q *Queries) ReadLoginChallenge(ctx context.Context, tokenHash string) (*LoginChallenge, error) {
+	row := q.queryRow(ctx, q.readLoginChallengeStmt, readLoginChallenge, tokenHash)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserUuid,
+		&i.Attempts,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const readUserTOTP = `-- name: ReadUserTOTP :one
+SELECT user_uuid, secret, confirmed_at, last_step, created_at
+FROM user_totp
+WHERE user_uuid = ?
+`
+
+// retrieve totp secret of user
+func (q *Queries) ReadUserTOTP(ctx context.Context, userUuid string) (*UserTotp, error) {
+	row := q.queryRow(ctx, q.readUserTOTPStmt, readUserTOTP, userUuid)
+	var i UserTotp
+	err := row.Scan(
+		&i.UserUuid,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastStep,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const upsertUserTOTP = `-- name: UpsertUserTOTP :execresult
+INSERT INTO user_totp (user_uuid, secret)
+VALUES (?, ?)
+ON CONFLICT (user_uuid) DO UPDATE
+SET
+    secret = excluded.secret,
+    last_step = 0,
+    created_at = CURRENT_TIMESTAMP
+WHERE user_totp.confirmed_at IS NULL
+`
+
+type UpsertUserTOTPParams struct {
+	UserUuid string
+	Secret   string
+}
+
+// add or replace unconfirmed totp secret of user, no rows are affected once confirmed
+func (q *Queries) UpsertUserTOTP(ctx context.Context, arg *UpsertUserTOTPParams) (sql.Result, error) {
+	return q.exec(ctx, q.upsertUserTOTPStmt, upsertUserTOTP, arg.UserUuid, arg.Secret)
+}
+
+const useLoginChallenge = `-- name: UseLoginChallenge :exec
+UPDATE login_challenges
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+`
+
+// mark login challenge answered
+func (q *Queries) UseLoginChallenge(ctx context.Context, tokenHash string) error {
+	_, err := q.exec(ctx, q.useLoginChallengeStmt, useLoginChallenge, tokenHash)
+	return err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execresult
+UPDATE recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE code_hash = ?
+    AND user_uuid = ?
+    AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	CodeHash string
+	UserUuid string
+}
+
+// mark recovery code used, no rows are affected if it is unknown or was already used
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg *UseRecoveryCodeParams) (sql.Result, error) {
+	return q.exec(ctx, q.useRecoveryCodeStmt, useRecoveryCode, arg.CodeHash, arg.UserUuid)
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execresult
+UPDATE user_totp
+SET last_step = ?
+WHERE user_uuid = ?
+    AND last_step < ?
+`
+
+type UseTOTPStepParams struct {
+	LastStep   int64
+	UserUuid   string
+	LastStep_2 int64
+}
+
+// record accepted time step, no rows are affected if it was already used
+func (q *Queries) UseTOTPStep(ctx context.Context, arg *UseTOTPStepParams) (sql.Result, error) {
+	return q.exec(ctx, q.useTOTPStepStmt, useTOTPStep, arg.LastStep, arg.UserUuid, arg.LastStep_2)
+}
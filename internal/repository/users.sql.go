@@ -7,13 +7,122 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 )
 
+const countAdmins = `-- name: CountAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE role = 'admin'
+`
+
+func (q *Queries) CountAdmins(ctx context.Context) (int64, error) {
+	row := q.queryRow(ctx, q.countAdminsStmt, countAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const disableUser = `-- name: DisableUser :one
+UPDATE users
+SET
+    disabled_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+`
+
+func (q *Queries) DisableUser(ctx context.Context, uuid string) (*User, error) {
+	row := q.queryRow(ctx, q.disableUserStmt, disableUser, uuid)
+	var i User
+	err := row.Scan(
+		&i.Uuid,
+		&i.Usernm,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return &i, err
+}
+
+const enableUser = `-- name: EnableUser :one
+UPDATE users
+SET
+    disabled_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+`
+
+func (q *Queries) EnableUser(ctx context.Context, uuid string) (*User, error) {
+	row := q.queryRow(ctx, q.enableUserStmt, enableUser, uuid)
+	var i User
+	err := row.Scan(
+		&i.Uuid,
+		&i.Usernm,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return &i, err
+}
+
+const insertBotUser = `-- name: InsertBotUser :one
+INSERT INTO users (uuid, usernm, email, pssword, user_type, owner_uuid, email_verified_at)
+VALUES (
+    ?, ?, ?, '', 'bot', ?, CURRENT_TIMESTAMP
+) RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+`
+
+type InsertBotUserParams struct {
+	Uuid      string
+	Usernm    string
+	Email     string
+	OwnerUuid sql.NullString
+}
+
+// add bot owned by a user, bots have no password and their email is verified
+func (q *Queries) InsertBotUser(ctx context.Context, arg *InsertBotUserParams) (*User, error) {
+	row := q.queryRow(ctx, q.insertBotUserStmt, insertBotUser,
+		arg.Uuid,
+		arg.Usernm,
+		arg.Email,
+		arg.OwnerUuid,
+	)
+	var i User
+	err := row.Scan(
+		&i.Uuid,
+		&i.Usernm,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return &i, err
+}
+
 const insertUser = `-- name: InsertUser :one
 INSERT INTO users (uuid, usernm, email, pssword)
 VALUES (
     ?, ?, ?, ?
-) RETURNING uuid, usernm, email, created_at, updated_at, pssword
+) RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
 `
 
 type InsertUserParams struct {
@@ -39,12 +148,174 @@ func (q *Queries) InsertUser(ctx context.Context, arg *InsertUserParams) (*User,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
 	)
 	return &i, err
 }
 
+const listOwnedBots = `-- name: ListOwnedBots :many
+SELECT uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+FROM users
+WHERE owner_uuid = ?
+    AND user_type = 'bot'
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListOwnedBots(ctx context.Context, ownerUuid sql.NullString) ([]*User, error) {
+	rows, err := q.query(ctx, q.listOwnedBotsStmt, listOwnedBots, ownerUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.Usernm,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Pssword,
+			&i.EmailVerifiedAt,
+			&i.UserType,
+			&i.OwnerUuid,
+			&i.Role,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+FROM users
+WHERE usernm > ?
+ORDER BY usernm ASC
+LIMIT ?
+`
+
+type ListUsersParams struct {
+	Usernm string
+	Limit  int64
+}
+
+// page of users ordered by username after the cursor username
+func (q *Queries) ListUsers(ctx context.Context, arg *ListUsersParams) ([]*User, error) {
+	rows, err := q.query(ctx, q.listUsersStmt, listUsers, arg.Usernm, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.Usernm,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Pssword,
+			&i.EmailVerifiedAt,
+			&i.UserType,
+			&i.OwnerUuid,
+			&i.Role,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByRole = `-- name: ListUsersByRole :many
+SELECT uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+FROM users
+WHERE role = ?
+    AND usernm > ?
+ORDER BY usernm ASC
+LIMIT ?
+`
+
+type ListUsersByRoleParams struct {
+	Role   string
+	Usernm string
+	Limit  int64
+}
+
+// page of users with role ordered by username after the cursor username
+func (q *Queries) ListUsersByRole(ctx context.Context, arg *ListUsersByRoleParams) ([]*User, error) {
+	rows, err := q.query(ctx, q.listUsersByRoleStmt, listUsersByRole, arg.Role, arg.Usernm, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Uuid,
+			&i.Usernm,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Pssword,
+			&i.EmailVerifiedAt,
+			&i.UserType,
+			&i.OwnerUuid,
+			&i.Role,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const promoteUserByUsername = `-- name: PromoteUserByUsername :execresult
+UPDATE users
+SET
+    role = 'admin',
+    updated_at = CURRENT_TIMESTAMP
+WHERE usernm = ?
+    AND user_type = 'human'
+`
+
+// grant the admin role to a human user
+func (q *Queries) PromoteUserByUsername(ctx context.Context, usernm string) (sql.Result, error) {
+	return q.exec(ctx, q.promoteUserByUsernameStmt, promoteUserByUsername, usernm)
+}
+
 const readUser = `-- name: ReadUser :one
-SELECT uuid, usernm, email, created_at, updated_at, pssword
+SELECT uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
 FROM users
 WHERE uuid = ?
 `
@@ -60,6 +331,38 @@ func (q *Queries) ReadUser(ctx context.Context, uuid string) (*User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return &i, err
+}
+
+const readUserByEmail = `-- name: ReadUserByEmail :one
+SELECT uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+FROM users
+WHERE lower(email) = lower(?)
+LIMIT 1
+`
+
+// read user by email, case insensitive
+func (q *Queries) ReadUserByEmail(ctx context.Context, lower string) (*User, error) {
+	row := q.queryRow(ctx, q.readUserByEmailStmt, readUserByEmail, lower)
+	var i User
+	err := row.Scan(
+		&i.Uuid,
+		&i.Usernm,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
 	)
 	return &i, err
 }
@@ -84,7 +387,7 @@ func (q *Queries) ReadUserDetails(ctx context.Context, uuid string) (*ReadUserDe
 }
 
 const readUserLoginDetails = `-- name: ReadUserLoginDetails :one
-SELECT uuid, pssword
+SELECT uuid, pssword, disabled_at
 FROM users
 WHERE email = ? 
     OR usernm = ?
@@ -97,17 +400,30 @@ type ReadUserLoginDetailsParams struct {
 }
 
 type ReadUserLoginDetailsRow struct {
-	Uuid    string
-	Pssword string
+	Uuid       string
+	Pssword    string
+	DisabledAt sql.NullTime
 }
 
 func (q *Queries) ReadUserLoginDetails(ctx context.Context, arg *ReadUserLoginDetailsParams) (*ReadUserLoginDetailsRow, error) {
 	row := q.queryRow(ctx, q.readUserLoginDetailsStmt, readUserLoginDetails, arg.Email, arg.Usernm)
 	var i ReadUserLoginDetailsRow
-	err := row.Scan(&i.Uuid, &i.Pssword)
+	err := row.Scan(&i.Uuid, &i.Pssword, &i.DisabledAt)
 	return &i, err
 }
 
+const resetEmailVerification = `-- name: ResetEmailVerification :exec
+UPDATE users
+SET email_verified_at = NULL
+WHERE uuid = ?
+`
+
+// mark email of user unverified
+func (q *Queries) ResetEmailVerification(ctx context.Context, uuid string) error {
+	_, err := q.exec(ctx, q.resetEmailVerificationStmt, resetEmailVerification, uuid)
+	return err
+}
+
 const searchUserDetails = `-- name: SearchUserDetails :many
 SELECT uuid, usernm
 FROM users
@@ -157,7 +473,7 @@ SET
     email = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE uuid = ?
-RETURNING uuid, usernm, email, created_at, updated_at, pssword
+RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
 `
 
 type UpdateUserParams struct {
@@ -176,6 +492,79 @@ func (q *Queries) UpdateUser(ctx context.Context, arg *UpdateUserParams) (*User,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return &i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :execresult
+UPDATE users
+SET
+    pssword = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+`
+
+type UpdateUserPasswordParams struct {
+	Pssword string
+	Uuid    string
+}
+
+// replace hashed password of user
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg *UpdateUserPasswordParams) (sql.Result, error) {
+	return q.exec(ctx, q.updateUserPasswordStmt, updateUserPassword, arg.Pssword, arg.Uuid)
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET
+    role = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING uuid, usernm, email, created_at, updated_at, pssword, email_verified_at, user_type, owner_uuid, role, disabled_at
+`
+
+type UpdateUserRoleParams struct {
+	Role string
+	Uuid string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg *UpdateUserRoleParams) (*User, error) {
+	row := q.queryRow(ctx, q.updateUserRoleStmt, updateUserRole, arg.Role, arg.Uuid)
+	var i User
+	err := row.Scan(
+		&i.Uuid,
+		&i.Usernm,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Pssword,
+		&i.EmailVerifiedAt,
+		&i.UserType,
+		&i.OwnerUuid,
+		&i.Role,
+		&i.DisabledAt,
 	)
 	return &i, err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execresult
+UPDATE users
+SET email_verified_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND email = ?
+`
+
+type VerifyUserEmailParams struct {
+	Uuid  string
+	Email string
+}
+
+// mark email of user verified, no rows are affected if the email has since changed
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg *VerifyUserEmailParams) (sql.Result, error) {
+	return q.exec(ctx, q.verifyUserEmailStmt, verifyUserEmail, arg.Uuid, arg.Email)
+}
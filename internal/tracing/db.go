@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/trevatk/go-chat/internal/repository"
+
+	// queryNamePrefix prefix of the name comment sqlc places before every query
+	queryNamePrefix = "-- name: "
+	// unnamedQuery span name of queries not generated by sqlc
+	unnamedQuery = "query"
+)
+
+// DBTX database connection or transaction used by repository queries
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// tracedDB DBTX recording a span for every query
+type tracedDB struct {
+	db     DBTX
+	tracer trace.Tracer
+}
+
+// WrapDB record a child span named after the sqlc query for every call on db
+func WrapDB(db DBTX) DBTX {
+	return &tracedDB{db: db, tracer: otel.Tracer(instrumentationName)}
+}
+
+// ExecContext execute query within a span
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	r, e := t.db.ExecContext(ctx, query, args...)
+	recordError(span, e)
+
+	return r, e
+}
+
+// PrepareContext prepare statement within a span
+func (t *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	s, e := t.db.PrepareContext(ctx, query)
+	recordError(span, e)
+
+	return s, e
+}
+
+// QueryContext execute query within a span
+//
+// the span ends once the query returns, iterating rows is not included
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	r, e := t.db.QueryContext(ctx, query, args...)
+	recordError(span, e)
+
+	return r, e
+}
+
+// QueryRowContext execute query within a span
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	r := t.db.QueryRowContext(ctx, query, args...)
+	if e := r.Err(); e != nil && e != sql.ErrNoRows {
+		recordError(span, e)
+	}
+
+	return r
+}
+
+func (t *tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+
+	n := queryName(query)
+
+	return t.tracer.Start(ctx, "repository."+n,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemSqlite,
+			attribute.String("db.operation", n),
+		),
+	)
+}
+
+// queryName parse query name from the sqlc name comment
+func queryName(query string) string {
+
+	if !strings.HasPrefix(query, queryNamePrefix) {
+		return unnamedQuery
+	}
+
+	f := strings.Fields(strings.TrimPrefix(query, queryNamePrefix))
+	if len(f) == 0 {
+		return unnamedQuery
+	}
+
+	return f[0]
+}
+
+func recordError(span trace.Span, e error) {
+
+	if e == nil {
+		return
+	}
+
+	span.RecordError(e)
+	span.SetStatus(codes.Error, e.Error())
+}
@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/trevatk/go-pkg/logging"
+)
+
+// WithTraceLogger add trace and span id of the active span to the context logger
+//
+// logging.FromContext returns the annotated logger for the rest of the request,
+// ctx is returned unchanged when no span is recording
+func WithTraceLogger(ctx context.Context) context.Context {
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+
+	l := logging.FromContext(ctx).With(
+		"trace_id", sc.TraceID().String(),
+		"span_id", sc.SpanID().String(),
+	)
+
+	return logging.WithLogger(ctx, l)
+}
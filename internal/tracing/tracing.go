@@ -0,0 +1,139 @@
+// Package tracing opentelemetry trace exporters and instrumentation
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+const (
+	// defaultServiceName service name reported when $OTEL_SERVICE_NAME is unset
+	defaultServiceName = "go-chat"
+
+	exporterOTLP   = "otlp"
+	exporterStdout = "stdout"
+	exporterFile   = "file"
+	exporterNone   = "none"
+)
+
+// Provider tracer provider flushing spans to the configured exporter
+type Provider struct {
+	tp *sdktrace.TracerProvider
+	w  io.Closer
+}
+
+// NewProvider create new tracer provider and register it globally
+//
+// $OTEL_TRACES_EXPORTER selects the exporter
+//   - otlp   export over gRPC, configured by the standard $OTEL_EXPORTER_OTLP_* variables
+//   - stdout write spans as json to stdout
+//   - file   write spans as json to $OTEL_TRACES_FILE
+//   - none   record spans without exporting, the default
+//
+// spans are still recorded without an exporter so trace ids
+// are propagated and added to request loggers
+func NewProvider(ctx context.Context) (*Provider, error) {
+
+	p := &Provider{}
+
+	r, e := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName())),
+	)
+	if e != nil {
+		return nil, fmt.Errorf("failed to create trace resource %v", e)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(r)}
+
+	ex := os.Getenv("OTEL_TRACES_EXPORTER")
+	switch ex {
+	case "", exporterNone:
+	case exporterOTLP:
+
+		exp, e := otlptracegrpc.New(ctx)
+		if e != nil {
+			return nil, fmt.Errorf("failed to create otlp trace exporter %v", e)
+		}
+
+		opts = append(opts, sdktrace.WithBatcher(exp))
+
+	case exporterStdout:
+
+		exp, e := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if e != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter %v", e)
+		}
+
+		opts = append(opts, sdktrace.WithBatcher(exp))
+
+	case exporterFile:
+
+		fp := os.Getenv("OTEL_TRACES_FILE")
+		if fp == "" {
+			return nil, errors.New("$OTEL_TRACES_FILE is unset")
+		}
+
+		f, e := os.OpenFile(fp, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if e != nil {
+			return nil, fmt.Errorf("failed to open trace file %v", e)
+		}
+
+		exp, e := stdouttrace.New(stdouttrace.WithWriter(f))
+		if e != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to create file trace exporter %v", e)
+		}
+
+		p.w = f
+		opts = append(opts, sdktrace.WithBatcher(exp))
+
+	default:
+		return nil, fmt.Errorf("invalid $OTEL_TRACES_EXPORTER %s", ex)
+	}
+
+	p.tp = sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(p.tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return p, nil
+}
+
+// Shutdown flush pending spans and stop the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+
+	e := p.tp.Shutdown(ctx)
+	if e != nil {
+		return fmt.Errorf("failed to shutdown tracer provider %v", e)
+	}
+
+	if p.w != nil {
+		return p.w.Close()
+	}
+
+	return nil
+}
+
+func serviceName() string {
+
+	if sn := os.Getenv("OTEL_SERVICE_NAME"); sn != "" {
+		return sn
+	}
+
+	return defaultServiceName
+}
@@ -0,0 +1,6 @@
+DROP TABLE conversation_invite_redemptions;
+
+DROP TABLE conversation_invites;
+
+ALTER TABLE mm_conversations_users
+DROP COLUMN user_role;
@@ -0,0 +1,25 @@
+ALTER TABLE mm_conversations_users
+ADD COLUMN user_role VARCHAR(10) NOT NULL DEFAULT 'member';
+
+CREATE TABLE IF NOT EXISTS conversation_invites (
+    uuid VARCHAR(36) PRIMARY KEY,
+    conversation_uuid VARCHAR(36) NOT NULL,
+    creator_uuid VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    max_uses INTEGER,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (conversation_uuid) REFERENCES conversations (uuid),
+    FOREIGN KEY (creator_uuid) REFERENCES users (uuid)
+);
+
+CREATE TABLE IF NOT EXISTS conversation_invite_redemptions (
+    uuid VARCHAR(36) PRIMARY KEY,
+    invite_uuid VARCHAR(36) NOT NULL,
+    user_uuid VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (invite_uuid) REFERENCES conversation_invites (uuid),
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
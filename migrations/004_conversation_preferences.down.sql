@@ -0,0 +1,8 @@
+ALTER TABLE mm_conversations_users
+DROP COLUMN pinned_at;
+
+ALTER TABLE mm_conversations_users
+DROP COLUMN archived;
+
+ALTER TABLE mm_conversations_users
+DROP COLUMN muted_until;
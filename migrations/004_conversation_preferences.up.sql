@@ -0,0 +1,8 @@
+ALTER TABLE mm_conversations_users
+ADD COLUMN muted_until TIMESTAMP;
+
+ALTER TABLE mm_conversations_users
+ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE mm_conversations_users
+ADD COLUMN pinned_at TIMESTAMP;
@@ -0,0 +1,12 @@
+DROP INDEX idx_messages_conversation_created_at;
+
+DROP INDEX idx_conversations_activity_key;
+
+ALTER TABLE mm_conversations_users
+DROP COLUMN last_read_at;
+
+ALTER TABLE conversations
+DROP COLUMN activity_key;
+
+ALTER TABLE conversations
+DROP COLUMN last_message_uuid;
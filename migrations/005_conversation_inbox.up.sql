@@ -0,0 +1,32 @@
+ALTER TABLE conversations
+ADD COLUMN last_message_uuid VARCHAR(36);
+
+-- activity_key orders conversations by last activity
+-- microsecond timestamp with conversation uuid suffix keeps keys unique for cursor pagination
+ALTER TABLE conversations
+ADD COLUMN activity_key VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE mm_conversations_users
+ADD COLUMN last_read_at TIMESTAMP;
+
+UPDATE conversations
+SET
+    last_message_uuid = (
+        SELECT messages.uuid
+        FROM messages
+        WHERE messages.conversation_uuid = conversations.uuid
+        ORDER BY messages.created_at DESC
+        LIMIT 1
+    ),
+    updated_at = (
+        SELECT MAX(messages.created_at)
+        FROM messages
+        WHERE messages.conversation_uuid = conversations.uuid
+    );
+
+UPDATE conversations
+SET activity_key = strftime('%Y-%m-%dT%H:%M:%S.000000', COALESCE(updated_at, created_at)) || uuid;
+
+CREATE INDEX IF NOT EXISTS idx_conversations_activity_key ON conversations (activity_key);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_at ON messages (conversation_uuid, created_at);
@@ -0,0 +1,7 @@
+DROP INDEX idx_messages_conversation_seq;
+
+ALTER TABLE conversations
+DROP COLUMN last_seq;
+
+ALTER TABLE messages
+DROP COLUMN seq;
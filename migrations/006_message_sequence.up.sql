@@ -0,0 +1,27 @@
+-- seq strictly increasing message number per conversation
+-- last_seq on conversations is incremented inside the create message transaction
+ALTER TABLE messages
+ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE conversations
+ADD COLUMN last_seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE messages
+SET seq = (
+    SELECT COUNT(*)
+    FROM messages AS prior
+    WHERE prior.conversation_uuid = messages.conversation_uuid
+        AND (
+            prior.created_at < messages.created_at
+            OR prior.created_at = messages.created_at AND prior.uuid <= messages.uuid
+        )
+);
+
+UPDATE conversations
+SET last_seq = (
+    SELECT COALESCE(MAX(messages.seq), 0)
+    FROM messages
+    WHERE messages.conversation_uuid = conversations.uuid
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_conversation_seq ON messages (conversation_uuid, seq);
@@ -0,0 +1,4 @@
+DROP INDEX idx_messages_client_message_id;
+
+ALTER TABLE messages
+DROP COLUMN client_message_id;
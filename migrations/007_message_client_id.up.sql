@@ -0,0 +1,6 @@
+-- client_message_id optional client supplied key making message sends idempotent
+-- null values are distinct in unique indexes so messages without a key are unaffected
+ALTER TABLE messages
+ADD COLUMN client_message_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_client_message_id ON messages (conversation_uuid, sender, client_message_id);
@@ -0,0 +1,26 @@
+-- a session is created per login and extended by rotating refresh tokens
+CREATE TABLE IF NOT EXISTS user_sessions (
+    uuid VARCHAR(36) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    user_agent VARCHAR(256) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_uuid ON user_sessions (user_uuid);
+
+-- only the hashed refresh token is persisted
+-- used tokens are kept so a replayed token can be detected
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    session_uuid VARCHAR(36) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (session_uuid) REFERENCES user_sessions (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_uuid ON refresh_tokens (session_uuid);
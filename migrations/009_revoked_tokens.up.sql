@@ -0,0 +1,10 @@
+-- revoked access tokens and sessions, keyed by token id or session id
+-- entries are pruned once every access token they cover has expired
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    revocation_key VARCHAR(64) PRIMARY KEY,
+    -- unix seconds
+    expires_at INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
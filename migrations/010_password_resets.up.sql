@@ -0,0 +1,17 @@
+-- single use password reset tokens, only the hash of the emailed token is persisted
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_uuid ON password_resets (user_uuid);
+
+-- 002 defaulted existing users to the literal 'password' which is not a bcrypt hash,
+-- clear it so no code mistakes it for a credential, those users recover through a reset
+UPDATE users
+SET pssword = ''
+WHERE pssword = 'password';
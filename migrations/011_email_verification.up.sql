@@ -0,0 +1,20 @@
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+-- accounts created before verification existed are trusted
+UPDATE users
+SET email_verified_at = CURRENT_TIMESTAMP;
+
+-- single use email verification tokens, only the hash of the emailed token is persisted
+-- the email is kept so a token cannot verify an address the user changed to afterwards
+CREATE TABLE IF NOT EXISTS email_verifications (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    email VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user_uuid ON email_verifications (user_uuid, created_at);
@@ -0,0 +1,33 @@
+-- totp secret of user, two factor login is enabled once confirmed
+-- last_step is the latest accepted time step so a code cannot be replayed
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_uuid VARCHAR(36) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+-- single use codes replacing a totp code when the authenticator is lost, only hashes are persisted
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_uuid ON recovery_codes (user_uuid);
+
+-- issued after the password is verified for users with two factor login,
+-- exchanged with a totp or recovery code for a session
+CREATE TABLE IF NOT EXISTS login_challenges (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
@@ -0,0 +1,20 @@
+-- failed login attempts by account or client ip, keys are prefixed with their scope
+-- locked_until is set once failures reach the lockout threshold
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- security relevant events, user_uuid is empty when the subject is not a known user
+CREATE TABLE IF NOT EXISTS audit_events (
+    uuid VARCHAR(36) PRIMARY KEY,
+    event VARCHAR(64) NOT NULL,
+    user_uuid VARCHAR(36),
+    subject VARCHAR(320) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
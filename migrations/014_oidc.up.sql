@@ -0,0 +1,26 @@
+-- external OpenID Connect identities signing in as a user
+CREATE TABLE IF NOT EXISTS user_identities (
+    uuid VARCHAR(36) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_uuid ON user_identities (user_uuid);
+
+-- pending authorization code logins, keyed by the hashed state parameter
+-- user_uuid is set when an authenticated user links an identity
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    user_uuid VARCHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
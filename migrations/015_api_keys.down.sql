@@ -0,0 +1,6 @@
+DROP TABLE api_keys;
+DROP INDEX idx_users_owner_uuid;
+ALTER TABLE users
+DROP COLUMN owner_uuid;
+ALTER TABLE users
+DROP COLUMN user_type;
@@ -0,0 +1,27 @@
+-- human users sign in with a password or identity provider, bots only with api keys
+ALTER TABLE users
+ADD COLUMN user_type VARCHAR(16) NOT NULL DEFAULT 'human';
+
+-- human user managing a bot
+ALTER TABLE users
+ADD COLUMN owner_uuid VARCHAR(36) REFERENCES users (uuid);
+
+CREATE INDEX IF NOT EXISTS idx_users_owner_uuid ON users (owner_uuid);
+
+-- personal api keys, only hashes are persisted and prefix identifies a key in listings
+-- scopes is a comma separated list
+CREATE TABLE IF NOT EXISTS api_keys (
+    uuid VARCHAR(36) PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_uuid) REFERENCES users (uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_uuid ON api_keys (user_uuid);
@@ -0,0 +1,7 @@
+-- global role of a user, one of admin, moderator or user
+ALTER TABLE users
+ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user';
+
+-- disabled users can not sign in and their api keys are rejected
+ALTER TABLE users
+ADD COLUMN disabled_at TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE mm_conversations_users
+DROP COLUMN last_read_seq;
@@ -0,0 +1,12 @@
+-- highest message sequence number the member has read, unread counts compare against it
+ALTER TABLE mm_conversations_users
+ADD COLUMN last_read_seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE mm_conversations_users
+SET last_read_seq = COALESCE((
+    SELECT MAX(messages.seq)
+    FROM messages
+    WHERE messages.conversation_uuid = mm_conversations_users.conversation_uuid
+        AND messages.created_at <= mm_conversations_users.last_read_at
+), 0)
+WHERE last_read_at IS NOT NULL;
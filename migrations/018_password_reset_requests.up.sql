@@ -0,0 +1,9 @@
+-- password reset requests by requested email or client ip, keys are prefixed with their scope
+-- recorded whether or not the email belongs to a user so throttling does not reveal accounts
+CREATE TABLE IF NOT EXISTS password_reset_requests (
+    throttle_key VARCHAR(320) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_requests_key ON password_reset_requests (throttle_key, created_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_requests_created_at ON password_reset_requests (created_at);
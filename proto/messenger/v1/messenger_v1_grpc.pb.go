@@ -24,6 +24,9 @@ const _ = grpc.SupportPackageIsVersion7
 type MessengerServiceClient interface {
 	StreamEnvelopes(ctx context.Context, in *Conversation, opts ...grpc.CallOption) (MessengerService_StreamEnvelopesClient, error)
 	SendEnvelope(ctx context.Context, opts ...grpc.CallOption) (MessengerService_SendEnvelopeClient, error)
+	// send messages, typing signals and acks and receive envelopes and
+	// events for every conversation the user is a member of
+	Chat(ctx context.Context, opts ...grpc.CallOption) (MessengerService_ChatClient, error)
 }
 
 type messengerServiceClient struct {
@@ -100,12 +103,46 @@ func (x *messengerServiceSendEnvelopeClient) CloseAndRecv() (*Envelope, error) {
 	return m, nil
 }
 
+func (c *messengerServiceClient) Chat(ctx context.Context, opts ...grpc.CallOption) (MessengerService_ChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MessengerService_ServiceDesc.Streams[2], "/messenger.MessengerService/Chat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &messengerServiceChatClient{stream}
+	return x, nil
+}
+
+type MessengerService_ChatClient interface {
+	Send(*ChatRequest) error
+	Recv() (*ChatResponse, error)
+	grpc.ClientStream
+}
+
+type messengerServiceChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *messengerServiceChatClient) Send(m *ChatRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *messengerServiceChatClient) Recv() (*ChatResponse, error) {
+	m := new(ChatResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // MessengerServiceServer is the server API for MessengerService service.
 // All implementations must embed UnimplementedMessengerServiceServer
 // for forward compatibility
 type MessengerServiceServer interface {
 	StreamEnvelopes(*Conversation, MessengerService_StreamEnvelopesServer) error
 	SendEnvelope(MessengerService_SendEnvelopeServer) error
+	// send messages, typing signals and acks and receive envelopes and
+	// events for every conversation the user is a member of
+	Chat(MessengerService_ChatServer) error
 	mustEmbedUnimplementedMessengerServiceServer()
 }
 
@@ -119,6 +156,9 @@ func (UnimplementedMessengerServiceServer) StreamEnvelopes(*Conversation, Messen
 func (UnimplementedMessengerServiceServer) SendEnvelope(MessengerService_SendEnvelopeServer) error {
 	return status.Errorf(codes.Unimplemented, "method SendEnvelope not implemented")
 }
+func (UnimplementedMessengerServiceServer) Chat(MessengerService_ChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
 func (UnimplementedMessengerServiceServer) mustEmbedUnimplementedMessengerServiceServer() {}
 
 // UnsafeMessengerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -179,6 +219,32 @@ func (x *messengerServiceSendEnvelopeServer) Recv() (*NewEnvelope, error) {
 	return m, nil
 }
 
+func _MessengerService_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MessengerServiceServer).Chat(&messengerServiceChatServer{stream})
+}
+
+type MessengerService_ChatServer interface {
+	Send(*ChatResponse) error
+	Recv() (*ChatRequest, error)
+	grpc.ServerStream
+}
+
+type messengerServiceChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *messengerServiceChatServer) Send(m *ChatResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *messengerServiceChatServer) Recv() (*ChatRequest, error) {
+	m := new(ChatRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // MessengerService_ServiceDesc is the grpc.ServiceDesc for MessengerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -197,6 +263,12 @@ var MessengerService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _MessengerService_SendEnvelope_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Chat",
+			Handler:       _MessengerService_Chat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/messenger/v1/messenger_v1.proto",
 }
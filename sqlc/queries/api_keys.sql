@@ -0,0 +1,38 @@
+-- name: InsertAPIKey :one
+INSERT INTO api_keys (
+    uuid, user_uuid, name, prefix, key_hash, scopes, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+) RETURNING *;
+
+-- name: ReadAPIKeyByHash :one
+SELECT *
+FROM api_keys
+WHERE key_hash = ?;
+
+-- name: ListUserAPIKeys :many
+-- unrevoked api keys of user including expired keys
+SELECT *
+FROM api_keys
+WHERE user_uuid = ?
+    AND revoked_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: CountUserAPIKeys :one
+SELECT COUNT(*)
+FROM api_keys
+WHERE user_uuid = ?
+    AND revoked_at IS NULL;
+
+-- name: RevokeAPIKey :execresult
+UPDATE api_keys
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND user_uuid = ?
+    AND revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+-- record use of api key
+UPDATE api_keys
+SET last_used_at = ?
+WHERE uuid = ?;
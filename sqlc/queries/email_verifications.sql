@@ -0,0 +1,35 @@
+-- name: InsertEmailVerification :exec
+-- add hashed email verification token to database
+INSERT INTO email_verifications (
+    token_hash, user_uuid, email, expires_at
+) VALUES (
+    ?, ?, ?, ?
+);
+
+-- name: ReadEmailVerification :one
+-- retrieve email verification by hashed token
+SELECT *
+FROM email_verifications
+WHERE token_hash = ?;
+
+-- name: ReadRecentEmailVerifications :many
+-- retrieve send times of the latest email verifications of user
+SELECT created_at
+FROM email_verifications
+WHERE user_uuid = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: UseEmailVerification :execresult
+-- mark email verification token used, no rows are affected if it was already used
+UPDATE email_verifications
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+    AND used_at IS NULL;
+
+-- name: UseAllUserEmailVerifications :exec
+-- mark every outstanding email verification token of user used
+UPDATE email_verifications
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND used_at IS NULL;
@@ -0,0 +1,60 @@
+-- name: InsertInvite :one
+-- add conversation invite to database
+INSERT INTO conversation_invites (
+    uuid, conversation_uuid, creator_uuid, token_hash, max_uses, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+) RETURNING *;
+
+-- name: ReadInviteByTokenHash :one
+-- retrieve invite by hashed token
+SELECT *
+FROM conversation_invites
+WHERE token_hash = ?;
+
+-- name: ReadAllInvites :many
+-- retrieve all invites created for a conversation
+SELECT *
+FROM conversation_invites
+WHERE conversation_uuid = ?
+ORDER BY created_at DESC;
+
+-- name: RevokeInvite :execresult
+-- soft delete invite by setting revoked timestamp
+UPDATE conversation_invites
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND conversation_uuid = ?
+    AND revoked_at IS NULL;
+
+-- name: IncrementInviteUses :execresult
+-- consume a single invite use if the invite is still redeemable
+UPDATE conversation_invites
+SET uses = uses + 1
+WHERE uuid = ?
+    AND revoked_at IS NULL
+    AND (max_uses IS NULL OR uses < max_uses);
+
+-- name: InsertInviteRedemption :execresult
+-- audit invite usage
+INSERT INTO conversation_invite_redemptions (
+    uuid, invite_uuid, user_uuid
+) VALUES (
+    ?, ?, ?
+);
+
+-- name: ReadConversationInvite :one
+-- retrieve invite created for a conversation
+SELECT *
+FROM conversation_invites
+WHERE uuid = ?
+    AND conversation_uuid = ?;
+
+-- name: ReadAllInviteRedemptions :many
+-- retrieve usage audit records of an invite created for a conversation
+SELECT r.*
+FROM conversation_invite_redemptions r
+JOIN conversation_invites ci ON ci.uuid = r.invite_uuid
+WHERE ci.uuid = ?
+    AND ci.conversation_uuid = ?
+ORDER BY r.created_at ASC;
@@ -0,0 +1,27 @@
+-- name: ReadLoginThrottle :one
+SELECT *
+FROM login_throttles
+WHERE throttle_key = ?;
+
+-- name: UpsertLoginThrottle :exec
+-- record failed login attempts and lockout of key
+INSERT INTO login_throttles (
+    throttle_key, failures, locked_until, updated_at
+) VALUES (
+    ?, ?, ?, ?
+) ON CONFLICT (throttle_key) DO UPDATE
+SET failures = excluded.failures,
+    locked_until = excluded.locked_until,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteLoginThrottle :exec
+-- forget failed login attempts of key after a successful login
+DELETE FROM login_throttles
+WHERE throttle_key = ?;
+
+-- name: InsertAuditEvent :exec
+INSERT INTO audit_events (
+    uuid, event, user_uuid, subject, ip_address
+) VALUES (
+    ?, ?, ?, ?, ?
+);
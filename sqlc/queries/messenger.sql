@@ -1,35 +1,201 @@
 
 -- name: InsertConversation :one
 -- add conversation to database
-INSERT INTO conversations (uuid)
+INSERT INTO conversations (uuid, activity_key)
 VALUES (
-    ?
+    ?, ?
 ) RETURNING *;
 
+-- name: UpdateConversationActivity :execresult
+-- record new message as latest conversation activity
+UPDATE conversations
+SET
+    last_message_uuid = ?,
+    activity_key = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?;
+
 -- name: InsertMMConversationUser :execresult
 INSERT INTO mm_conversations_users (
-    uuid, conversation_uuid, user_uuid
+    uuid, conversation_uuid, user_uuid, user_role
 ) VALUES (
-    ?, ?, ?
+    ?, ?, ?, ?
 );
 
--- name: ReadAllConversations :many
--- retrieve all conversations that includes user uuid in recipients field
-SELECT conversations.uuid, mm_conversations_users.user_uuid, conversations.updated_at
+-- name: ReadConversation :one
+-- read conversation by uuid
+SELECT *
+FROM conversations
+WHERE uuid = ?;
+
+-- name: ReadConversationMember :one
+-- read conversation membership for user
+SELECT *
+FROM mm_conversations_users
+WHERE conversation_uuid = ?
+    AND user_uuid = ?;
+
+-- name: CountConversationMembers :one
+SELECT COUNT(*)
+FROM mm_conversations_users
+WHERE conversation_uuid = ?;
+
+-- name: ReadInbox :many
+-- retrieve page of unpinned conversations that includes user uuid ordered by last activity
+-- archived bounds select the states to include e.g. false..true for all
+SELECT conversations.uuid, conversations.created_at, conversations.updated_at, conversations.activity_key,
+    mm_conversations_users.muted_until, mm_conversations_users.archived, mm_conversations_users.pinned_at,
+    COALESCE(messages.uuid, '') AS last_message_uuid,
+    COALESCE(messages.sender, '') AS last_message_sender,
+    COALESCE(messages.body, '') AS last_message_body,
+    (
+        SELECT COUNT(*)
+        FROM messages AS unread
+        WHERE unread.conversation_uuid = conversations.uuid
+            AND unread.sender != mm_conversations_users.user_uuid
+            AND unread.seq > mm_conversations_users.last_read_seq
+    ) AS unread_count,
+    (
+        SELECT COUNT(*)
+        FROM mm_conversations_users AS members
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_count,
+    (
+        SELECT GROUP_CONCAT(users.usernm, ',')
+        FROM mm_conversations_users AS members
+        JOIN users
+            ON members.user_uuid = users.uuid
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_names
 FROM conversations
 JOIN mm_conversations_users
-    ON conversation.uuid = mm_conversations_users.conversation_uuid
-WHERE mm_conversations_users.user_uuid = ?;
+    ON conversations.uuid = mm_conversations_users.conversation_uuid
+LEFT JOIN messages
+    ON conversations.last_message_uuid = messages.uuid
+WHERE mm_conversations_users.user_uuid = ?
+    AND mm_conversations_users.archived >= ?
+    AND mm_conversations_users.archived <= ?
+    AND mm_conversations_users.pinned_at IS NULL
+    AND conversations.activity_key < ?
+ORDER BY conversations.activity_key DESC
+LIMIT ?;
+
+-- name: ReadPinnedInbox :many
+-- retrieve all pinned conversations that includes user uuid
+-- archived bounds select the states to include e.g. false..true for all
+SELECT conversations.uuid, conversations.created_at, conversations.updated_at, conversations.activity_key,
+    mm_conversations_users.muted_until, mm_conversations_users.archived, mm_conversations_users.pinned_at,
+    COALESCE(messages.uuid, '') AS last_message_uuid,
+    COALESCE(messages.sender, '') AS last_message_sender,
+    COALESCE(messages.body, '') AS last_message_body,
+    (
+        SELECT COUNT(*)
+        FROM messages AS unread
+        WHERE unread.conversation_uuid = conversations.uuid
+            AND unread.sender != mm_conversations_users.user_uuid
+            AND unread.seq > mm_conversations_users.last_read_seq
+    ) AS unread_count,
+    (
+        SELECT COUNT(*)
+        FROM mm_conversations_users AS members
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_count,
+    (
+        SELECT GROUP_CONCAT(users.usernm, ',')
+        FROM mm_conversations_users AS members
+        JOIN users
+            ON members.user_uuid = users.uuid
+        WHERE members.conversation_uuid = conversations.uuid
+    ) AS member_names
+FROM conversations
+JOIN mm_conversations_users
+    ON conversations.uuid = mm_conversations_users.conversation_uuid
+LEFT JOIN messages
+    ON conversations.last_message_uuid = messages.uuid
+WHERE mm_conversations_users.user_uuid = ?
+    AND mm_conversations_users.archived >= ?
+    AND mm_conversations_users.archived <= ?
+    AND mm_conversations_users.pinned_at IS NOT NULL
+ORDER BY mm_conversations_users.pinned_at DESC;
+
+-- name: ReadAllConversationMembers :many
+-- retrieve all members of conversation
+SELECT *
+FROM mm_conversations_users
+WHERE conversation_uuid = ?;
+
+-- name: UpdateConversationPreferences :one
+-- update per member conversation preferences
+UPDATE mm_conversations_users
+SET
+    muted_until = ?,
+    archived = ?,
+    pinned_at = ?
+WHERE conversation_uuid = ?
+    AND user_uuid = ?
+RETURNING *;
+
+-- name: UpdateLastRead :execresult
+-- mark all current conversation messages as read for member
+UPDATE mm_conversations_users
+SET
+    last_read_at = ?,
+    last_read_seq = MAX(last_read_seq, (
+        SELECT conversations.last_seq
+        FROM conversations
+        WHERE conversations.uuid = mm_conversations_users.conversation_uuid
+    ))
+WHERE conversation_uuid = ?
+    AND user_uuid = ?;
+
+-- name: AdvanceLastRead :execresult
+-- mark conversation messages read for member up to envelope, never moves backwards
+UPDATE mm_conversations_users
+SET
+    last_read_at = ?,
+    last_read_seq = MAX(last_read_seq, COALESCE((
+        SELECT messages.seq
+        FROM messages
+        WHERE messages.uuid = ?
+            AND messages.conversation_uuid = mm_conversations_users.conversation_uuid
+    ), 0))
+WHERE mm_conversations_users.conversation_uuid = ?
+    AND mm_conversations_users.user_uuid = ?;
+
+-- name: IncrementConversationSeq :one
+-- reserve next message sequence number for conversation
+UPDATE conversations
+SET last_seq = last_seq + 1
+WHERE uuid = ?
+RETURNING last_seq;
 
 -- name: InsertMessage :one
 -- add new message to database
-INSERT INTO messages (uuid, conversation_uuid, sender, body)
+INSERT INTO messages (uuid, conversation_uuid, sender, body, created_at, seq, client_message_id)
 VALUES (
-    ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?
 ) RETURNING *;
 
--- name: ReadAllMessages :many
--- retrieve all messages by conversation uuid
+-- name: ReadMessage :one
+-- retrieve message by uuid within conversation
 SELECT *
 FROM messages
-WHERE conversation_uuid = ?;
+WHERE uuid = ?
+    AND conversation_uuid = ?;
+
+-- name: ReadMessageByClientID :one
+-- retrieve message previously sent by sender with client message id
+SELECT *
+FROM messages
+WHERE conversation_uuid = ?
+    AND sender = ?
+    AND client_message_id = ?;
+
+-- name: ReadMessagesAfterSeq :many
+-- retrieve page of conversation messages after sequence number
+SELECT *
+FROM messages
+WHERE conversation_uuid = ?
+    AND seq > ?
+ORDER BY seq
+LIMIT ?;
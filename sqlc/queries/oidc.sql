@@ -0,0 +1,32 @@
+-- name: InsertOIDCLogin :exec
+INSERT INTO oidc_logins (
+    state_hash, nonce, code_verifier, user_uuid, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: UseOIDCLogin :execresult
+-- mark login used, no rows are affected if already used
+UPDATE oidc_logins
+SET used_at = CURRENT_TIMESTAMP
+WHERE state_hash = ?
+    AND used_at IS NULL;
+
+-- name: ReadOIDCLogin :one
+SELECT *
+FROM oidc_logins
+WHERE state_hash = ?;
+
+-- name: InsertUserIdentity :exec
+INSERT INTO user_identities (
+    uuid, user_uuid, issuer, subject, email
+) VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: ReadUserIdentity :one
+-- read identity by issuer and subject
+SELECT *
+FROM user_identities
+WHERE issuer = ?
+    AND subject = ?;
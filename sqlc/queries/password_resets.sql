@@ -0,0 +1,48 @@
+-- name: InsertPasswordReset :exec
+-- add hashed password reset token to database
+INSERT INTO password_resets (
+    token_hash, user_uuid, expires_at
+) VALUES (
+    ?, ?, ?
+);
+
+-- name: ReadPasswordReset :one
+-- retrieve password reset by hashed token
+SELECT *
+FROM password_resets
+WHERE token_hash = ?;
+
+-- name: UsePasswordReset :execresult
+-- mark password reset token used, no rows are affected if it was already used
+UPDATE password_resets
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+    AND used_at IS NULL;
+
+-- name: UseAllUserPasswordResets :exec
+-- mark every outstanding password reset token of user used
+UPDATE password_resets
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND used_at IS NULL;
+
+-- name: InsertPasswordResetRequest :exec
+-- record password reset request of email or client ip
+INSERT INTO password_reset_requests (
+    throttle_key
+) VALUES (
+    ?
+);
+
+-- name: ReadRecentPasswordResetRequests :many
+-- retrieve times of the latest password reset requests of key
+SELECT created_at
+FROM password_reset_requests
+WHERE throttle_key = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: DeletePasswordResetRequestsBefore :exec
+-- forget password reset requests no longer counted by the throttle
+DELETE FROM password_reset_requests
+WHERE created_at < ?;
@@ -0,0 +1,90 @@
+-- name: InsertSession :one
+-- add user session to database
+INSERT INTO user_sessions (
+    uuid, user_uuid, user_agent, ip_address, expires_at
+) VALUES (
+    ?, ?, ?, ?, ?
+) RETURNING *;
+
+-- name: ReadSession :one
+-- retrieve session by uuid
+SELECT *
+FROM user_sessions
+WHERE uuid = ?;
+
+-- name: ReadAllUserSessions :many
+-- retrieve all sessions of a user which have not been revoked
+SELECT *
+FROM user_sessions
+WHERE user_uuid = ?
+    AND revoked_at IS NULL
+ORDER BY last_used_at DESC;
+
+-- name: TouchSession :exec
+-- record session activity
+UPDATE user_sessions
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE uuid = ?;
+
+-- name: RevokeSession :execresult
+-- soft delete session by setting revoked timestamp
+UPDATE user_sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND user_uuid = ?
+    AND revoked_at IS NULL;
+
+-- name: InsertRefreshToken :exec
+-- add hashed refresh token issued for session
+INSERT INTO refresh_tokens (
+    token_hash, session_uuid
+) VALUES (
+    ?, ?
+);
+
+-- name: ReadRefreshToken :one
+-- retrieve refresh token by hash
+SELECT *
+FROM refresh_tokens
+WHERE token_hash = ?;
+
+-- name: UseRefreshToken :execresult
+-- consume refresh token if it has not been used before
+UPDATE refresh_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?
+    AND used_at IS NULL;
+
+-- name: RevokeAllUserSessions :exec
+-- soft delete every active session of a user
+UPDATE user_sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :exec
+-- soft delete every active session of a user except one
+UPDATE user_sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_uuid = ?
+    AND uuid != ?
+    AND revoked_at IS NULL;
+
+-- name: InsertRevocation :exec
+-- add token or session to revocation list
+INSERT INTO revoked_tokens (
+    revocation_key, expires_at
+) VALUES (
+    ?, ?
+) ON CONFLICT (revocation_key) DO UPDATE SET expires_at = excluded.expires_at;
+
+-- name: ReadAllRevocations :many
+-- retrieve revocation list entries which have not expired
+SELECT *
+FROM revoked_tokens
+WHERE expires_at > ?;
+
+-- name: DeleteExpiredRevocations :execresult
+-- prune revocation list entries covering only expired tokens
+DELETE FROM revoked_tokens
+WHERE expires_at <= ?;
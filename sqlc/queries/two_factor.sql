@@ -0,0 +1,79 @@
+-- name: UpsertUserTOTP :execresult
+-- add or replace unconfirmed totp secret of user, no rows are affected once confirmed
+INSERT INTO user_totp (user_uuid, secret)
+VALUES (?, ?)
+ON CONFLICT (user_uuid) DO UPDATE
+SET
+    secret = excluded.secret,
+    last_step = 0,
+    created_at = CURRENT_TIMESTAMP
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: ReadUserTOTP :one
+-- retrieve totp secret of user
+SELECT *
+FROM user_totp
+WHERE user_uuid = ?;
+
+-- name: ConfirmUserTOTP :execresult
+-- enable two factor login of user
+UPDATE user_totp
+SET
+    confirmed_at = CURRENT_TIMESTAMP,
+    last_step = ?
+WHERE user_uuid = ?
+    AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execresult
+-- record accepted time step, no rows are affected if it was already used
+UPDATE user_totp
+SET last_step = ?
+WHERE user_uuid = ?
+    AND last_step < ?;
+
+-- name: DeleteUserTOTP :exec
+-- disable two factor login of user
+DELETE FROM user_totp
+WHERE user_uuid = ?;
+
+-- name: InsertRecoveryCode :exec
+-- add hashed recovery code of user
+INSERT INTO recovery_codes (code_hash, user_uuid)
+VALUES (?, ?);
+
+-- name: UseRecoveryCode :execresult
+-- mark recovery code used, no rows are affected if it is unknown or was already used
+UPDATE recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE code_hash = ?
+    AND user_uuid = ?
+    AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+-- remove every recovery code of user
+DELETE FROM recovery_codes
+WHERE user_uuid = ?;
+
+-- name: InsertLoginChallenge :exec
+-- add hashed login challenge token
+INSERT INTO login_challenges (token_hash, user_uuid, expires_at)
+VALUES (?, ?, ?);
+
+-- name: AttemptLoginChallenge :execresult
+-- count an attempt at answering a login challenge, no rows are affected once used
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash = ?
+    AND used_at IS NULL;
+
+-- name: ReadLoginChallenge :one
+-- retrieve login challenge by hashed token
+SELECT *
+FROM login_challenges
+WHERE token_hash = ?;
+
+-- name: UseLoginChallenge :exec
+-- mark login challenge answered
+UPDATE login_challenges
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = ?;
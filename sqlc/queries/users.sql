@@ -13,7 +13,7 @@ FROM users
 WHERE uuid = ?;
 
 -- name: ReadUserLoginDetails :one
-SELECT uuid, pssword
+SELECT uuid, pssword, disabled_at
 FROM users
 WHERE email = ? 
     OR usernm = ?
@@ -40,4 +40,100 @@ SET
     email = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE uuid = ?
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+-- name: ReadUserByEmail :one
+-- read user by email, case insensitive
+SELECT *
+FROM users
+WHERE lower(email) = lower(?)
+LIMIT 1;
+
+-- name: UpdateUserPassword :execresult
+-- replace hashed password of user
+UPDATE users
+SET
+    pssword = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?;
+
+-- name: VerifyUserEmail :execresult
+-- mark email of user verified, no rows are affected if the email has since changed
+UPDATE users
+SET email_verified_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+    AND email = ?;
+
+-- name: ResetEmailVerification :exec
+-- mark email of user unverified
+UPDATE users
+SET email_verified_at = NULL
+WHERE uuid = ?;
+
+-- name: InsertBotUser :one
+-- add bot owned by a user, bots have no password and their email is verified
+INSERT INTO users (uuid, usernm, email, pssword, user_type, owner_uuid, email_verified_at)
+VALUES (
+    ?, ?, ?, '', 'bot', ?, CURRENT_TIMESTAMP
+) RETURNING *;
+
+-- name: ListOwnedBots :many
+SELECT *
+FROM users
+WHERE owner_uuid = ?
+    AND user_type = 'bot'
+ORDER BY created_at ASC;
+
+-- name: ListUsers :many
+-- page of users ordered by username after the cursor username
+SELECT *
+FROM users
+WHERE usernm > ?
+ORDER BY usernm ASC
+LIMIT ?;
+
+-- name: ListUsersByRole :many
+-- page of users with role ordered by username after the cursor username
+SELECT *
+FROM users
+WHERE role = ?
+    AND usernm > ?
+ORDER BY usernm ASC
+LIMIT ?;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET
+    role = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING *;
+
+-- name: CountAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE role = 'admin';
+
+-- name: PromoteUserByUsername :execresult
+-- grant the admin role to a human user
+UPDATE users
+SET
+    role = 'admin',
+    updated_at = CURRENT_TIMESTAMP
+WHERE usernm = ?
+    AND user_type = 'human';
+
+-- name: DisableUser :one
+UPDATE users
+SET
+    disabled_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING *;
+
+-- name: EnableUser :one
+UPDATE users
+SET
+    disabled_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE uuid = ?
+RETURNING *;